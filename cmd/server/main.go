@@ -3,21 +3,30 @@ package main
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"log/slog"
+	"minimart/internal/analytics"
+	"minimart/internal/ledger"
 	"minimart/internal/menu"
 	"minimart/internal/merchant"
 	"minimart/internal/notifications"
 	"minimart/internal/order"
+	"minimart/internal/shared/blobstore"
 	"minimart/internal/shared/eventbus"
+	"minimart/internal/shared/jwtkeys"
+	"minimart/internal/shared/mailer"
 	middlerware "minimart/internal/shared/middleware"
+	"minimart/internal/shared/validation"
 	"minimart/internal/user"
+	"minimart/internal/webhook"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/jackc/pgx/v5/stdlib" // Goose requires a database driver
 	"github.com/joho/godotenv"
@@ -29,7 +38,92 @@ type AppConfig struct {
 	Port        string `mapstructure:"PORT"`
 	DatabaseURL string `mapstructure:"DATABASE_URL"`
 	RedisURL    string `mapstructure:"REDIS_URL"`
-	JwtSecret   string `mapstructure:"JWT_SECRET"`
+
+	// Access tokens are signed with the PEM private key in JWTSigningKeyFile
+	// and verified with it and the keys in JWTVerificationKeyFiles, which
+	// carry keys across a rotation; see jwtkeys.KeyManager
+	JWTSigningKeyFile       string `mapstructure:"JWT_SIGNING_KEY_FILE"`
+	JWTVerificationKeyFiles string `mapstructure:"JWT_VERIFICATION_KEY_FILES"`
+
+	// Access tokens are short-lived; clients renew them with rotating refresh tokens
+	AccessTokenTTL  time.Duration `mapstructure:"ACCESS_TOKEN_TTL"`
+	RefreshTokenTTL time.Duration `mapstructure:"REFRESH_TOKEN_TTL"`
+
+	// Failed logins within LoginFailureWindow slow down, then lock out, the
+	// account or IP address they come from; see user.LoginGuardConfig
+	LoginFailureWindow      time.Duration `mapstructure:"LOGIN_FAILURE_WINDOW"`
+	LoginMaxAccountFailures int           `mapstructure:"LOGIN_MAX_ACCOUNT_FAILURES"`
+	LoginMaxIPFailures      int           `mapstructure:"LOGIN_MAX_IP_FAILURES"`
+	LoginLockoutDuration    time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`
+	LoginFreeFailures       int           `mapstructure:"LOGIN_FREE_FAILURES"`
+	LoginBaseDelay          time.Duration `mapstructure:"LOGIN_BASE_DELAY"`
+	LoginMaxDelay           time.Duration `mapstructure:"LOGIN_MAX_DELAY"`
+
+	// New passwords must have PasswordMinLength characters and the kinds of
+	// characters required; see validation.PasswordPolicy
+	PasswordMinLength     int  `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordRequireUpper  bool `mapstructure:"PASSWORD_REQUIRE_UPPER"`
+	PasswordRequireLower  bool `mapstructure:"PASSWORD_REQUIRE_LOWER"`
+	PasswordRequireDigit  bool `mapstructure:"PASSWORD_REQUIRE_DIGIT"`
+	PasswordRequireSymbol bool `mapstructure:"PASSWORD_REQUIRE_SYMBOL"`
+
+	// TrustedProxies is a comma-separated list of proxy addresses whose
+	// X-Forwarded-For header gives the client's IP address.
+	TrustedProxies string `mapstructure:"TRUSTED_PROXIES"`
+
+	// Password reset links point at PasswordResetURL and last PasswordResetTTL
+	PasswordResetURL string        `mapstructure:"PASSWORD_RESET_URL"`
+	PasswordResetTTL time.Duration `mapstructure:"PASSWORD_RESET_TTL"`
+
+	// OIDCProviders is a comma-separated list of OpenID Connect providers
+	// users can sign in with, e.g. "google,apple". Each is configured with
+	// OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL and
+	// optionally _SCOPES and _RESPONSE_MODE.
+	OIDCProviders string `mapstructure:"OIDC_PROVIDERS"`
+
+	// Email verification links point at EmailVerificationURL and last
+	// EmailVerificationTTL. With RequireVerifiedEmailToOrder, customers must
+	// verify their email before placing orders.
+	EmailVerificationURL        string        `mapstructure:"EMAIL_VERIFICATION_URL"`
+	EmailVerificationTTL        time.Duration `mapstructure:"EMAIL_VERIFICATION_TTL"`
+	RequireVerifiedEmailToOrder bool          `mapstructure:"REQUIRE_VERIFIED_EMAIL_TO_ORDER"`
+
+	// Email: "file" (default) writes messages to MailDir for development,
+	// "smtp" sends them through the SMTP server
+	MailBackend  string `mapstructure:"MAIL_BACKEND"`
+	MailDir      string `mapstructure:"MAIL_DIR"`
+	MailFrom     string `mapstructure:"MAIL_FROM"`
+	SMTPHost     string `mapstructure:"SMTP_HOST"`
+	SMTPPort     int    `mapstructure:"SMTP_PORT"`
+	SMTPUsername string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword string `mapstructure:"SMTP_PASSWORD"`
+
+	// Blob storage for uploaded images: "local" (default) or "s3"
+	BlobBackend       string `mapstructure:"BLOB_BACKEND"`
+	MediaDir          string `mapstructure:"MEDIA_DIR"`
+	MediaBaseURL      string `mapstructure:"MEDIA_BASE_URL"`
+	S3Endpoint        string `mapstructure:"S3_ENDPOINT"`
+	S3Region          string `mapstructure:"S3_REGION"`
+	S3Bucket          string `mapstructure:"S3_BUCKET"`
+	S3AccessKeyID     string `mapstructure:"S3_ACCESS_KEY_ID"`
+	S3SecretAccessKey string `mapstructure:"S3_SECRET_ACCESS_KEY"`
+	S3PublicURL       string `mapstructure:"S3_PUBLIC_URL"`
+
+	MenuCacheTTL time.Duration `mapstructure:"MENU_CACHE_TTL"`
+
+	// AdminUserIDs is a comma-separated list of users given the admin role at startup.
+	AdminUserIDs string `mapstructure:"ADMIN_USER_IDS"`
+
+	// Sales reports: "live" (default) aggregates orders per request, "rollup"
+	// reads daily rollups refreshed every AnalyticsRollupInterval
+	AnalyticsSource         string        `mapstructure:"ANALYTICS_SOURCE"`
+	AnalyticsRollupInterval time.Duration `mapstructure:"ANALYTICS_ROLLUP_INTERVAL"`
+
+	// Outbound merchant webhooks. Private addresses are refused unless
+	// WebhookAllowPrivateTargets is set, e.g. for local development.
+	WebhookDispatchInterval    time.Duration `mapstructure:"WEBHOOK_DISPATCH_INTERVAL"`
+	WebhookTimeout             time.Duration `mapstructure:"WEBHOOK_TIMEOUT"`
+	WebhookAllowPrivateTargets bool          `mapstructure:"WEBHOOK_ALLOW_PRIVATE_TARGETS"`
 }
 
 func main() {
@@ -48,7 +142,85 @@ func main() {
 	viper.BindEnv("PORT")
 	viper.BindEnv("DATABASE_URL")
 	viper.BindEnv("REDIS_URL")
-	viper.BindEnv("JWT_SECRET")
+	viper.BindEnv("JWT_SIGNING_KEY_FILE")
+	viper.BindEnv("JWT_VERIFICATION_KEY_FILES")
+	viper.BindEnv("ACCESS_TOKEN_TTL")
+	viper.BindEnv("REFRESH_TOKEN_TTL")
+	viper.BindEnv("LOGIN_FAILURE_WINDOW")
+	viper.BindEnv("LOGIN_MAX_ACCOUNT_FAILURES")
+	viper.BindEnv("LOGIN_MAX_IP_FAILURES")
+	viper.BindEnv("LOGIN_LOCKOUT_DURATION")
+	viper.BindEnv("LOGIN_FREE_FAILURES")
+	viper.BindEnv("LOGIN_BASE_DELAY")
+	viper.BindEnv("LOGIN_MAX_DELAY")
+	viper.BindEnv("PASSWORD_MIN_LENGTH")
+	viper.BindEnv("PASSWORD_REQUIRE_UPPER")
+	viper.BindEnv("PASSWORD_REQUIRE_LOWER")
+	viper.BindEnv("PASSWORD_REQUIRE_DIGIT")
+	viper.BindEnv("PASSWORD_REQUIRE_SYMBOL")
+	viper.BindEnv("TRUSTED_PROXIES")
+	viper.BindEnv("PASSWORD_RESET_URL")
+	viper.BindEnv("PASSWORD_RESET_TTL")
+	viper.BindEnv("OIDC_PROVIDERS")
+	viper.BindEnv("EMAIL_VERIFICATION_URL")
+	viper.BindEnv("EMAIL_VERIFICATION_TTL")
+	viper.BindEnv("REQUIRE_VERIFIED_EMAIL_TO_ORDER")
+	viper.BindEnv("MAIL_BACKEND")
+	viper.BindEnv("MAIL_DIR")
+	viper.BindEnv("MAIL_FROM")
+	viper.BindEnv("SMTP_HOST")
+	viper.BindEnv("SMTP_PORT")
+	viper.BindEnv("SMTP_USERNAME")
+	viper.BindEnv("SMTP_PASSWORD")
+	viper.BindEnv("BLOB_BACKEND")
+	viper.BindEnv("MEDIA_DIR")
+	viper.BindEnv("MEDIA_BASE_URL")
+	viper.BindEnv("S3_ENDPOINT")
+	viper.BindEnv("S3_REGION")
+	viper.BindEnv("S3_BUCKET")
+	viper.BindEnv("S3_ACCESS_KEY_ID")
+	viper.BindEnv("S3_SECRET_ACCESS_KEY")
+	viper.BindEnv("S3_PUBLIC_URL")
+	viper.BindEnv("MENU_CACHE_TTL")
+	viper.BindEnv("ADMIN_USER_IDS")
+	viper.BindEnv("ANALYTICS_SOURCE")
+	viper.BindEnv("ANALYTICS_ROLLUP_INTERVAL")
+	viper.BindEnv("WEBHOOK_DISPATCH_INTERVAL")
+	viper.BindEnv("WEBHOOK_TIMEOUT")
+	viper.BindEnv("WEBHOOK_ALLOW_PRIVATE_TARGETS")
+
+	viper.SetDefault("ACCESS_TOKEN_TTL", "15m")
+	viper.SetDefault("REFRESH_TOKEN_TTL", "720h")
+	viper.SetDefault("LOGIN_FAILURE_WINDOW", "15m")
+	viper.SetDefault("LOGIN_MAX_ACCOUNT_FAILURES", 10)
+	viper.SetDefault("LOGIN_MAX_IP_FAILURES", 50)
+	viper.SetDefault("LOGIN_LOCKOUT_DURATION", "15m")
+	viper.SetDefault("LOGIN_FREE_FAILURES", 3)
+	viper.SetDefault("LOGIN_BASE_DELAY", "1s")
+	viper.SetDefault("LOGIN_MAX_DELAY", "30s")
+	viper.SetDefault("PASSWORD_MIN_LENGTH", validation.DefaultPasswordPolicy.MinLength)
+	viper.SetDefault("PASSWORD_REQUIRE_UPPER", false)
+	viper.SetDefault("PASSWORD_REQUIRE_LOWER", false)
+	viper.SetDefault("PASSWORD_REQUIRE_DIGIT", false)
+	viper.SetDefault("PASSWORD_REQUIRE_SYMBOL", false)
+	viper.SetDefault("PASSWORD_RESET_URL", "http://localhost:3000/reset-password")
+	viper.SetDefault("PASSWORD_RESET_TTL", "1h")
+	viper.SetDefault("EMAIL_VERIFICATION_URL", "http://localhost:3000/users/verify")
+	viper.SetDefault("EMAIL_VERIFICATION_TTL", "48h")
+	viper.SetDefault("REQUIRE_VERIFIED_EMAIL_TO_ORDER", false)
+	viper.SetDefault("MAIL_BACKEND", "file")
+	viper.SetDefault("MAIL_DIR", "./mail")
+	viper.SetDefault("MAIL_FROM", "MiniMart <no-reply@minimart.local>")
+	viper.SetDefault("SMTP_PORT", 587)
+	viper.SetDefault("BLOB_BACKEND", "local")
+	viper.SetDefault("MEDIA_DIR", "./media")
+	viper.SetDefault("MEDIA_BASE_URL", "/media")
+	viper.SetDefault("MENU_CACHE_TTL", "5m")
+	viper.SetDefault("ANALYTICS_SOURCE", "live")
+	viper.SetDefault("ANALYTICS_ROLLUP_INTERVAL", "15m")
+	viper.SetDefault("WEBHOOK_DISPATCH_INTERVAL", "5s")
+	viper.SetDefault("WEBHOOK_TIMEOUT", "10s")
+	viper.SetDefault("WEBHOOK_ALLOW_PRIVATE_TARGETS", false)
 
 	viper.AddConfigPath(".")
 	viper.SetConfigName("config")
@@ -73,7 +245,8 @@ func main() {
 		"Port", config.Port,
 		"DatabaseURL", config.DatabaseURL,
 		"RedisURL", config.RedisURL,
-		"JwtSecret", "...", // Don't log the secret itself
+		"JWTSigningKeyFile", config.JWTSigningKeyFile,
+		"BlobBackend", config.BlobBackend,
 	)
 
 	// --- Run Database Migrations ---
@@ -106,10 +279,22 @@ func main() {
 	}
 	defer dbpool.Close()
 
+	// Behind trusted proxies, client IPs (used to throttle logins) come from X-Forwarded-For
+	trustedProxies := splitList(config.TrustedProxies)
+	proxyHeader := ""
+	if len(trustedProxies) > 0 {
+		proxyHeader = fiber.HeaderXForwardedFor
+	}
 	app := fiber.New(fiber.Config{
 		Network:      "tcp",
 		ServerHeader: "Fiber",
 		AppName:      "Minimart App v0.0.1",
+		// Leave headroom above menu.MaxImageSize for the multipart envelope
+		BodyLimit:               8 * 1024 * 1024,
+		EnableTrustedProxyCheck: len(trustedProxies) > 0,
+		TrustedProxies:          trustedProxies,
+		ProxyHeader:             proxyHeader,
+		EnableIPValidation:      true,
 	})
 
 	// --- Initialize Redis Client ---
@@ -148,66 +333,203 @@ func main() {
 	// Event bus
 	eventBus := eventbus.NewRedisEventBus(redisClient)
 
-	userSubscriber := notifications.NewUserSubscriber(logger)
+	// Email
+	var mail mailer.Mailer
+	switch config.MailBackend {
+	case "smtp":
+		mail = mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     config.SMTPHost,
+			Port:     config.SMTPPort,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+			From:     config.MailFrom,
+		})
+		logger.Info("Sending email through SMTP", "host", config.SMTPHost, "port", config.SMTPPort)
+	default:
+		mail = mailer.NewFileMailer(config.MailDir, config.MailFrom)
+		logger.Info("Writing email to files", "dir", config.MailDir)
+	}
 
-	go func() {
-		pubsub := redisClient.Subscribe(context.Background(), user.UserCreatedTopic)
-		defer pubsub.Close()
+	userSubscriber := notifications.NewUserSubscriber(logger, mail, notifications.UserLinks{
+		PasswordReset:     config.PasswordResetURL,
+		EmailVerification: config.EmailVerificationURL,
+	})
 
-		ch := pubsub.Channel()
-		logger.Info("Subscribed to Redis topic", "topic", user.UserCreatedTopic)
+	eventbus.SubscribeRedis[user.UserCreatedEvent](ctx, redisClient, user.UserCreatedTopic, userSubscriber.HandleUserCreatedEvent, logger)
+	eventbus.SubscribeRedis[user.EmailVerificationRequestedEvent](ctx, redisClient, user.EmailVerificationRequestedTopic, userSubscriber.HandleEmailVerificationRequestedEvent, logger)
+	eventbus.SubscribeRedis[user.PasswordResetRequestedEvent](ctx, redisClient, user.PasswordResetRequestedTopic, userSubscriber.HandlePasswordResetRequestedEvent, logger)
+	eventbus.SubscribeRedis[user.AccountLockedEvent](ctx, redisClient, user.AccountLockedTopic, userSubscriber.HandleAccountLockedEvent, logger)
+
+	merchantSubscriber := notifications.NewMerchantSubscriber(logger)
+
+	eventbus.SubscribeRedis[merchant.MerchantStatusChangedEvent](ctx, redisClient, merchant.MerchantStatusChangedTopic, merchantSubscriber.HandleMerchantStatusChangedEvent, logger)
+	eventbus.SubscribeRedis[merchant.MerchantInviteCreatedEvent](ctx, redisClient, merchant.MerchantInviteCreatedTopic, merchantSubscriber.HandleMerchantInviteCreatedEvent, logger)
+
+	// Blob storage
+	var blobs blobstore.BlobStore
+	switch config.BlobBackend {
+	case "s3":
+		blobs = blobstore.NewS3BlobStore(blobstore.S3Config{
+			Endpoint:        config.S3Endpoint,
+			Region:          config.S3Region,
+			Bucket:          config.S3Bucket,
+			AccessKeyID:     config.S3AccessKeyID,
+			SecretAccessKey: config.S3SecretAccessKey,
+			PublicURL:       config.S3PublicURL,
+		}, nil)
+		logger.Info("Using S3 blob storage", "endpoint", config.S3Endpoint, "bucket", config.S3Bucket)
+	default:
+		blobs = blobstore.NewLocalBlobStore(config.MediaDir, config.MediaBaseURL)
+		app.Static(config.MediaBaseURL, config.MediaDir)
+		logger.Info("Using local blob storage", "dir", config.MediaDir)
+	}
 
-		for msg := range ch {
-			// When a message comes in, we handle it
-			var event user.UserCreatedEvent
-			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
-				logger.Info("Error unmarshaling event", "error", err, "payload", msg.Payload)
-				continue
-			}
+	// Authentication and merchant authorization shared by the modules
+	// Revoked access tokens are shared through Redis so logout holds on every instance
+	jwtKeys, err := loadJWTKeys(config.JWTSigningKeyFile, config.JWTVerificationKeyFiles, logger)
+	if err != nil {
+		logger.Error("Invalid JWT keys", "error", err)
+		os.Exit(1)
+	}
+	// Other services verify access tokens with the public keys published here
+	app.Get("/.well-known/jwks.json", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+		return c.JSON(jwtKeys.JWKS())
+	})
+	tokenDenylist := middlerware.NewRedisTokenDenylist(redisClient)
+	requireAuth := middlerware.AuthRequire(jwtKeys, tokenDenylist)
+	adminIDs, err := parseAdminIDs(config.AdminUserIDs)
+	if err != nil {
+		logger.Error("Invalid ADMIN_USER_IDS", "error", err)
+		os.Exit(1)
+	}
 
-			// We call the same handler as before
-			_ = userSubscriber.HandleUserCreatedEvent(context.Background(), event)
+	// User module, before the merchant module, which grants users the merchant role
+	userRepo := user.NewPostgresUserRepository(dbpool)
+	refreshTokenRepo := user.NewPostgresRefreshTokenRepository(dbpool)
+	emailVerificationRepo := user.NewPostgresEmailVerificationRepository(dbpool)
+	// Failed logins and password reset requests are counted in Redis so that
+	// every instance enforces the same limits
+	loginAttempts := user.NewRedisLoginAttemptStore(redisClient)
+	loginGuard := user.NewLoginGuard(loginAttempts, user.LoginGuardConfig{
+		Window:             config.LoginFailureWindow,
+		MaxAccountFailures: config.LoginMaxAccountFailures,
+		MaxIPFailures:      config.LoginMaxIPFailures,
+		LockoutDuration:    config.LoginLockoutDuration,
+		FreeFailures:       config.LoginFreeFailures,
+		BaseDelay:          config.LoginBaseDelay,
+		MaxDelay:           config.LoginMaxDelay,
+	})
+	userUsecase := user.NewUserUsecase(userRepo, refreshTokenRepo, emailVerificationRepo, loginGuard, tokenDenylist, jwtKeys, eventBus, user.TokenConfig{
+		AccessTokenTTL:       config.AccessTokenTTL,
+		RefreshTokenTTL:      config.RefreshTokenTTL,
+		EmailVerificationTTL: config.EmailVerificationTTL,
+	})
+	passwordPolicy := validation.PasswordPolicy{
+		MinLength:     config.PasswordMinLength,
+		RequireUpper:  config.PasswordRequireUpper,
+		RequireLower:  config.PasswordRequireLower,
+		RequireDigit:  config.PasswordRequireDigit,
+		RequireSymbol: config.PasswordRequireSymbol,
+	}
+	userHandler := user.NewUserHandler(userUsecase, requireAuth, middlerware.RequirePermission(middlerware.PermManageUsers), passwordPolicy)
+	userHandler.RegisterRoutes(app)
+	passwordResetUsecase := user.NewPasswordResetUsecase(userRepo, user.NewPostgresPasswordResetRepository(dbpool), loginAttempts, userUsecase, eventBus, config.PasswordResetTTL, logger)
+	passwordResetHandler := user.NewPasswordResetHandler(passwordResetUsecase, passwordPolicy)
+	passwordResetHandler.RegisterRoutes(app)
+	profileUsecase := user.NewProfileUsecase(userRepo, emailVerificationRepo, loginGuard, userUsecase, eventBus, config.EmailVerificationTTL)
+	profileHandler := user.NewProfileHandler(profileUsecase, requireAuth, passwordPolicy)
+	profileHandler.RegisterRoutes(app)
+	oidcProviders, err := oidcProviderConfigs(config.OIDCProviders)
+	if err != nil {
+		logger.Error("Invalid OIDC provider configuration", "error", err)
+		os.Exit(1)
+	}
+	oidcUsecase := user.NewOIDCUsecase(oidcProviders, user.NewRedisOIDCStateStore(redisClient),
+		user.NewPostgresExternalIdentityRepository(dbpool), userRepo, userUsecase, eventBus)
+	user.NewOIDCHandler(oidcUsecase).RegisterRoutes(app)
+	// ADMIN_USER_IDS bootstraps the first admins, who can then promote others
+	for _, adminID := range adminIDs {
+		if err := userUsecase.GrantRole(ctx, adminID, middlerware.RoleAdmin); err != nil {
+			logger.Warn("Could not grant admin role", "user_id", adminID, "error", err)
 		}
-	}()
+	}
 
 	// Merchant module
 	merchantRepo := merchant.NewPostgresMerchantRepository(dbpool)
-	merchantUsecase := merchant.NewMerchantUsecase(merchantRepo)
-	merchantHandler := merchant.NewMerchantHandler(merchantUsecase)
+	membershipRepo := merchant.NewPostgresMembershipRepository(dbpool)
+	requireMerchantAccess := merchant.RequireAccess(merchant.NewMembershipAccessPolicy(merchantRepo, membershipRepo))
+	merchantUsecase := merchant.NewMerchantUsecase(merchantRepo, userUsecase, eventBus, logger)
+	// Before any /merchants/:merchantID route, so each of them takes a slug as well as an ID
+	app.Use("/merchants", merchant.ResolveSlugs(merchantRepo))
+	merchantHandler := merchant.NewMerchantHandler(merchantUsecase, requireAuth, requireMerchantAccess, middlerware.RequirePermission(middlerware.PermReviewMerchants))
 	merchantHandler.RegisterRoutes(app)
 
-	// User module
-	userRepo := user.NewPostgresUserRepository(dbpool)
-	userUsecase := user.NewUserUsecase(userRepo, eventBus, config.JwtSecret)
-	userHandler := user.NewUserHandler(userUsecase)
-	userHandler.RegisterRoutes(app)
+	// API keys for the merchants' own systems, such as their POS. The routes
+	// such systems call take a key as well as a JWT.
+	apiKeyUsecase := merchant.NewAPIKeyUsecase(merchantRepo, membershipRepo, merchant.NewPostgresAPIKeyRepository(dbpool))
+	merchant.NewAPIKeyHandler(apiKeyUsecase, requireAuth, requireMerchantAccess).RegisterRoutes(app)
+	requireAuthOrAPIKey := middlerware.Authenticate(jwtKeys, tokenDenylist, apiKeyUsecase)
 
-	// Order module
-	orderRepo := order.NewPostgresOrderRepository(dbpool)
-	orderUsecase := order.NewOrderUsecase(orderRepo)
-	orderHandler := order.NewOrderHandler(orderUsecase)
-	orderHandler.RegisterRoutes(app)
+	// Busy mode, with pauses in Redis so every instance stops taking orders at once
+	pauseStore := merchant.NewRedisPauseStore(redisClient)
+	busyHandler := merchant.NewBusyHandler(merchant.NewBusyUsecase(merchantRepo, pauseStore), requireAuthOrAPIKey, requireMerchantAccess)
+	busyHandler.RegisterRoutes(app)
+
+	// Merchant staff, who are users working for a merchant
+	membershipUsecase := merchant.NewMembershipUsecase(merchantRepo, membershipRepo, userRepo, userUsecase, eventBus, logger)
+	membershipHandler := merchant.NewMembershipHandler(membershipUsecase, requireAuth, requireMerchantAccess)
+	membershipHandler.RegisterRoutes(app)
 
 	// Menu module
-	menuRepo := menu.NewPostgresMenuRepository(dbpool)
-	menuUsecase := menu.NewMenuUsecase(menuRepo)
-	menuHandler := menu.NewMenuHandler(menuUsecase)
+	menuCache := menu.NewRedisMenuCache(redisClient, config.MenuCacheTTL)
+	menuRepo := menu.NewCachedMenuRepository(menu.NewPostgresMenuRepository(dbpool), menuCache, logger)
+	eventbus.SubscribeRedis[menu.MenuItemCreatedEvent](ctx, redisClient, menu.MenuItemCreatedTopic, menuRepo.HandleMenuEvent, logger)
+	eventbus.SubscribeRedis[menu.MenuItemUpdatedEvent](ctx, redisClient, menu.MenuItemUpdatedTopic, menuRepo.HandleMenuEvent, logger)
+	eventbus.SubscribeRedis[menu.MenuItemOutOfStockEvent](ctx, redisClient, menu.MenuItemOutOfStockTopic, menuRepo.HandleMenuEvent, logger)
+	eventbus.SubscribeRedis[menu.MenuPublishedEvent](ctx, redisClient, menu.MenuPublishedTopic, menuRepo.HandleMenuEvent, logger)
+	menuUsecase := menu.NewMenuUsecase(menuRepo, merchantRepo, blobs, eventBus, logger)
+	menuHandler := menu.NewMenuHandler(menuUsecase, requireAuthOrAPIKey, requireMerchantAccess)
 	menuHandler.RegisterRoutes(app)
 
-	api := app.Group("/api", middlerware.AuthRequire())
-
-	api.Get("/profile", func(c *fiber.Ctx) error {
-		// The middlerware has already validated the token and stored the user claims.
-		// We can safely access it from c.Locals.
-		userClaims := c.Locals("user").(jwt.MapClaims)
+	// Order module, settled into the merchant ledger as orders complete
+	orderRepo := order.NewPostgresOrderRepository(dbpool)
+	ledgerRepo := ledger.NewPostgresLedgerRepository(dbpool)
+	ledgerUsecase := ledger.NewLedgerUsecase(ledgerRepo, merchantRepo, orderRepo)
+	orderingPolicy := user.NewOrderingPolicy(userRepo, config.RequireVerifiedEmailToOrder)
+	orderUsecase := order.NewOrderUsecase(orderRepo, menuRepo, merchantRepo, pauseStore, ledgerUsecase, orderingPolicy, eventBus, logger)
+	orderHandler := order.NewOrderHandler(orderUsecase, requireAuthOrAPIKey, requireMerchantAccess, middlerware.RequirePermission(middlerware.PermPlaceOrders))
+	orderHandler.RegisterRoutes(app)
+	ledgerHandler := ledger.NewLedgerHandler(ledgerUsecase, requireAuthOrAPIKey, requireMerchantAccess, middlerware.RequirePermission(middlerware.PermManageLedger))
+	ledgerHandler.RegisterRoutes(app)
+
+	// Webhooks, pushing order events to the merchants' own systems
+	webhookUsecase := webhook.NewWebhookUsecase(webhook.NewPostgresWebhookRepository(dbpool),
+		webhook.NewHTTPClient(config.WebhookTimeout, config.WebhookAllowPrivateTargets))
+	eventbus.SubscribeRedis[order.OrderPlacedEvent](ctx, redisClient, order.OrderPlacedTopic, webhookUsecase.HandleOrderEvent, logger)
+	eventbus.SubscribeRedis[order.OrderStatusChangedEvent](ctx, redisClient, order.OrderStatusChangedTopic, webhookUsecase.HandleOrderEvent, logger)
+	go webhook.NewDispatcher(webhookUsecase, config.WebhookDispatchInterval, logger).Run(ctx)
+	webhookHandler := webhook.NewWebhookHandler(webhookUsecase, requireAuth, requireMerchantAccess)
+	webhookHandler.RegisterRoutes(app)
+
+	// Analytics module
+	var salesRepo analytics.SalesRepository
+	switch config.AnalyticsSource {
+	case "rollup":
+		salesRepo = analytics.NewPostgresRollupSalesRepository(dbpool)
+		go analytics.NewRollupJob(dbpool, config.AnalyticsRollupInterval, logger).Run(ctx)
+		logger.Info("Using rolled up sales analytics", "interval", config.AnalyticsRollupInterval)
+	default:
+		salesRepo = analytics.NewPostgresSalesRepository(dbpool)
+		logger.Info("Using live sales analytics")
+	}
+	analyticsUsecase := analytics.NewAnalyticsUsecase(salesRepo, merchantRepo)
+	analyticsHandler := analytics.NewAnalyticsHandler(analyticsUsecase, requireAuthOrAPIKey, requireMerchantAccess)
+	analyticsHandler.RegisterRoutes(app)
 
-		// You can now use the claims, for example, to fetch user details from the DB.
-		// For this example, we'll just return the claims.
-		return c.JSON(fiber.Map{
-			"message": "Welcome to your profile!",
-			"user_id": userClaims["sub"],
-			"email":   userClaims["email"],
-		})
+	// Cache statistics are internal, so only admins may read them
+	app.Get("/metrics/menu-cache", requireAuth, middlerware.RequirePermission(middlerware.PermViewMetrics), func(c *fiber.Ctx) error {
+		return c.JSON(menuRepo.Stats())
 	})
 
 	app.Get("/health", func(c *fiber.Ctx) error {
@@ -232,3 +554,95 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// splitList splits a comma-separated setting, dropping blank entries.
+func splitList(raw string) []string {
+	var items []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			items = append(items, part)
+		}
+	}
+	return items
+}
+
+// loadJWTKeys loads the key access tokens are signed with and the others they
+// may be verified with. Without a signing key, a key is generated that lasts
+// until the server stops and is known to this instance only.
+func loadJWTKeys(signingFile, verificationFiles string, logger *slog.Logger) (jwtkeys.KeyManager, error) {
+	if signingFile == "" {
+		logger.Warn("JWT_SIGNING_KEY_FILE is not set; signing access tokens with a temporary key that other instances cannot verify")
+		signing, err := jwtkeys.GenerateKey(jwtkeys.EdDSA)
+		if err != nil {
+			return nil, err
+		}
+		return jwtkeys.NewKeyManager(signing)
+	}
+
+	signing, err := loadJWTKey(signingFile)
+	if err != nil {
+		return nil, err
+	}
+	var verification []*jwtkeys.Key
+	for _, file := range splitList(verificationFiles) {
+		key, err := loadJWTKey(file)
+		if err != nil {
+			return nil, err
+		}
+		verification = append(verification, key)
+	}
+	return jwtkeys.NewKeyManager(signing, verification...)
+}
+
+func loadJWTKey(file string) (*jwtkeys.Key, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwtkeys.ParseKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return key, nil
+}
+
+// oidcProviderTimeout bounds each request to an OIDC provider.
+const oidcProviderTimeout = 10 * time.Second
+
+// oidcProviderConfigs creates the providers named in OIDC_PROVIDERS from
+// their OIDC_<NAME>_* settings.
+func oidcProviderConfigs(names string) ([]user.OIDCProvider, error) {
+	client := &http.Client{Timeout: oidcProviderTimeout}
+	var providers []user.OIDCProvider
+	for _, name := range splitList(names) {
+		name = strings.ToLower(name)
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		config := user.OIDCProviderConfig{
+			Name:         name,
+			Issuer:       viper.GetString(prefix + "ISSUER"),
+			ClientID:     viper.GetString(prefix + "CLIENT_ID"),
+			ClientSecret: viper.GetString(prefix + "CLIENT_SECRET"),
+			RedirectURL:  viper.GetString(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(viper.GetString(prefix + "SCOPES")),
+			ResponseMode: viper.GetString(prefix + "RESPONSE_MODE"),
+		}
+		if config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+			return nil, fmt.Errorf("%s: %sISSUER, %sCLIENT_ID and %sREDIRECT_URL are required", name, prefix, prefix, prefix)
+		}
+		providers = append(providers, user.NewOIDCProvider(config, client))
+	}
+	return providers, nil
+}
+
+// parseAdminIDs parses the comma-separated user IDs in ADMIN_USER_IDS.
+func parseAdminIDs(raw string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	for _, part := range splitList(raw) {
+		id, err := uuid.Parse(part)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", part, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,51 @@
+package analytics
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// WriteSalesCSV writes one row per bucket. Amounts are in major units, e.g. "12.50".
+func WriteSalesCSV(w io.Writer, report *Report) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"bucket_start", "order_count", "revenue", "average_order_value", "currency"}); err != nil {
+		return err
+	}
+	for _, bucket := range report.Buckets {
+		record := []string{
+			bucket.Start.Format(dateLayout),
+			strconv.FormatInt(bucket.OrderCount, 10),
+			bucket.Revenue.Decimal(),
+			bucket.AverageOrderValue.Decimal(),
+			string(report.Currency),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteTopItemsCSV writes the report's best sellers, best first.
+func WriteTopItemsCSV(w io.Writer, report *Report) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"menu_item_id", "name", "quantity", "revenue", "currency"}); err != nil {
+		return err
+	}
+	for _, item := range report.TopItems {
+		record := []string{
+			item.MenuItemID.String(),
+			item.Name,
+			strconv.FormatInt(item.Quantity, 10),
+			item.Revenue.Decimal(),
+			string(report.Currency),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,122 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"minimart/internal/shared/money"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrUnknownBucket = errors.New("unknown bucket")
+
+// Bucket is the period sales are grouped by.
+type Bucket string
+
+const (
+	BucketDay   Bucket = "day"
+	BucketWeek  Bucket = "week"
+	BucketMonth Bucket = "month"
+)
+
+// ParseBucket parses a bucket name such as "week".
+func ParseBucket(name string) (Bucket, error) {
+	switch bucket := Bucket(strings.ToLower(strings.TrimSpace(name))); bucket {
+	case BucketDay, BucketWeek, BucketMonth:
+		return bucket, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownBucket, name)
+}
+
+// Start returns the start of the bucket t falls in, in t's location. Weeks
+// start on Monday, as they do for Postgres' date_trunc.
+func (b Bucket) Start(t time.Time) time.Time {
+	year, month, day := t.Date()
+	switch b {
+	case BucketWeek:
+		sinceMonday := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-sinceMonday, 0, 0, 0, 0, t.Location())
+	case BucketMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// Next returns the start of the bucket after the one starting at start.
+func (b Bucket) Next(start time.Time) time.Time {
+	switch b {
+	case BucketWeek:
+		return start.AddDate(0, 0, 7)
+	case BucketMonth:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// Query selects a merchant's sales. From and To are local midnights in
+// Location; To is exclusive.
+type Query struct {
+	MerchantID uuid.UUID
+	Bucket     Bucket
+	From       time.Time
+	To         time.Time
+	Location   *time.Location
+}
+
+// SalesRow is one bucket of sales as aggregated by a repository. Start is in
+// the query's location and revenue is in the merchant's minor units.
+type SalesRow struct {
+	Start      time.Time
+	OrderCount int64
+	Revenue    int64
+}
+
+// ItemSalesRow is a menu item's sales over a whole query range.
+type ItemSalesRow struct {
+	MenuItemID uuid.UUID
+	Name       string
+	Quantity   int64
+	Revenue    int64
+}
+
+// BucketStats summarises the orders in one bucket. Cancelled orders are not counted.
+type BucketStats struct {
+	Start             time.Time   `json:"start"`
+	OrderCount        int64       `json:"order_count"`
+	Revenue           money.Money `json:"revenue"`
+	AverageOrderValue money.Money `json:"average_order_value"`
+}
+
+type TopItem struct {
+	MenuItemID uuid.UUID   `json:"menu_item_id"`
+	Name       string      `json:"name"`
+	Quantity   int64       `json:"quantity"`
+	Revenue    money.Money `json:"revenue"`
+}
+
+// Report is a merchant's sales between two dates, inclusive, in its time zone.
+type Report struct {
+	MerchantID uuid.UUID      `json:"merchant_id"`
+	Timezone   string         `json:"timezone"`
+	Currency   money.Currency `json:"currency"`
+	Bucket     Bucket         `json:"bucket"`
+	From       string         `json:"from"`
+	To         string         `json:"to"`
+	Totals     BucketStats    `json:"totals"`
+	Buckets    []BucketStats  `json:"buckets"`
+	TopItems   []TopItem      `json:"top_items"`
+}
+
+// newBucketStats fills in the average order value for a bucket's totals.
+func newBucketStats(start time.Time, orderCount int64, revenue money.Money) (BucketStats, error) {
+	average := money.Zero(revenue.Currency)
+	if orderCount > 0 {
+		var err error
+		if average, err = revenue.Divide(orderCount); err != nil {
+			return BucketStats{}, err
+		}
+	}
+	return BucketStats{Start: start, OrderCount: orderCount, Revenue: revenue, AverageOrderValue: average}, nil
+}
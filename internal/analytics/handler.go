@@ -0,0 +1,99 @@
+package analytics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"minimart/internal/merchant"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type AnalyticsHandler struct {
+	usecase AnalyticsUsecase
+	auth    fiber.Handler
+	access  merchant.AccessGuard
+}
+
+// NewAnalyticsHandler creates a new AnalyticsHandler. Reports run auth and then
+// access, normally middlerware.Authenticate and merchant.RequireAccess.
+func NewAnalyticsHandler(usecase AnalyticsUsecase, auth fiber.Handler, access merchant.AccessGuard) *AnalyticsHandler {
+	return &AnalyticsHandler{usecase: usecase, auth: auth, access: access}
+}
+
+func (h *AnalyticsHandler) RegisterRoutes(app *fiber.App) {
+	app.Get("/merchants/:merchantID/analytics", h.auth, h.access(merchant.PermViewAnalytics), h.GetSalesReport)
+}
+
+// GetSalesReport handles ?bucket=&from=&to=&top= reports, with from and to as
+// YYYY-MM-DD dates in the merchant's time zone. format=csv downloads the
+// buckets instead, or the best sellers with report=top_items.
+func (h *AnalyticsHandler) GetSalesReport(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	from, errFrom := parseDateQuery(c, "from")
+	to, errTo := parseDateQuery(c, "to")
+	if err := errors.Join(errFrom, errTo); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	request := ReportRequest{
+		Bucket: Bucket(c.Query("bucket")),
+		From:   from,
+		To:     to,
+		Top:    c.QueryInt("top", DefaultTopItems),
+	}
+
+	format := c.Query("format", "json")
+	kind := c.Query("report", "sales")
+	if format != "json" && format != "csv" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "format must be json or csv"})
+	}
+	if kind != "sales" && kind != "top_items" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "report must be sales or top_items"})
+	}
+
+	report, err := h.usecase.SalesReport(c.Context(), merchantID, request)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUnknownBucket), errors.Is(err, ErrInvalidRange), errors.Is(err, ErrRangeTooLong),
+			errors.Is(err, ErrInvalidTopItems):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, merchant.ErrMerchantNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if format == "json" {
+		return c.Status(fiber.StatusOK).JSON(report)
+	}
+
+	var body bytes.Buffer
+	write := WriteSalesCSV
+	if kind == "top_items" {
+		write = WriteTopItemsCSV
+	}
+	if err := write(&body, report); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	c.Attachment(fmt.Sprintf("%s-%s-%s.csv", kind, report.From, report.To))
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	return c.Status(fiber.StatusOK).Send(body.Bytes())
+}
+
+// parseDateQuery parses an optional YYYY-MM-DD query parameter.
+func parseDateQuery(c *fiber.Ctx, param string) (*time.Time, error) {
+	raw := c.Query(param)
+	if raw == "" {
+		return nil, nil
+	}
+	date, err := time.Parse(dateLayout, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a date like 2006-01-02", param)
+	}
+	return &date, nil
+}
@@ -0,0 +1,143 @@
+package analytics
+
+import (
+	"context"
+	"minimart/internal/order"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// dateLayout is how rollup days are passed to Postgres.
+const dateLayout = "2006-01-02"
+
+// PostgresSalesRepository aggregates the orders tables on every call, so it
+// is always up to date but gets slower as a merchant's history grows.
+type PostgresSalesRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresSalesRepository(db *pgxpool.Pool) SalesRepository {
+	return &PostgresSalesRepository{db: db}
+}
+
+func (r *PostgresSalesRepository) Sales(ctx context.Context, query Query) ([]SalesRow, error) {
+	// Truncating the local wall-clock time buckets orders by the merchant's days.
+	sql := `
+		SELECT date_trunc($2, o.created_at AT TIME ZONE $3) AS bucket, COUNT(*), COALESCE(SUM(o.total_amount), 0)::BIGINT
+		FROM orders o
+		WHERE o.merchant_id = $1 AND o.status <> $4 AND o.created_at >= $5 AND o.created_at < $6
+		GROUP BY bucket
+		ORDER BY bucket;
+	`
+	rows, err := r.db.Query(ctx, sql, query.MerchantID, string(query.Bucket), query.Location.String(), order.CANCELLED, query.From, query.To)
+	if err != nil {
+		return nil, err
+	}
+	return scanSalesRows(rows, query.Location)
+}
+
+func (r *PostgresSalesRepository) TopItems(ctx context.Context, query Query, limit int) ([]ItemSalesRow, error) {
+	sql := `
+		SELECT oi.menu_item_id, COALESCE(mi.name, ''), SUM(oi.quantity)::BIGINT, SUM(oi.quantity * oi.unit_price)::BIGINT AS revenue
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		LEFT JOIN menu_items mi ON mi.id = oi.menu_item_id
+		WHERE o.merchant_id = $1 AND o.status <> $2 AND o.created_at >= $3 AND o.created_at < $4
+		GROUP BY oi.menu_item_id, mi.name
+		ORDER BY 3 DESC, revenue DESC, oi.menu_item_id
+		LIMIT $5;
+	`
+	rows, err := r.db.Query(ctx, sql, query.MerchantID, order.CANCELLED, query.From, query.To, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanItemSalesRows(rows)
+}
+
+// PostgresRollupSalesRepository reads the daily rollups kept by RollupJob. It
+// stays fast over long ranges but lags behind new orders by up to one refresh.
+type PostgresRollupSalesRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRollupSalesRepository(db *pgxpool.Pool) SalesRepository {
+	return &PostgresRollupSalesRepository{db: db}
+}
+
+func (r *PostgresRollupSalesRepository) Sales(ctx context.Context, query Query) ([]SalesRow, error) {
+	sql := `
+		SELECT date_trunc($2, day::TIMESTAMP) AS bucket, SUM(order_count)::BIGINT, SUM(revenue)::BIGINT
+		FROM merchant_sales_daily
+		WHERE merchant_id = $1 AND day >= $3::DATE AND day < $4::DATE
+		GROUP BY bucket
+		ORDER BY bucket;
+	`
+	from, to := rollupDays(query)
+	rows, err := r.db.Query(ctx, sql, query.MerchantID, string(query.Bucket), from, to)
+	if err != nil {
+		return nil, err
+	}
+	return scanSalesRows(rows, query.Location)
+}
+
+func (r *PostgresRollupSalesRepository) TopItems(ctx context.Context, query Query, limit int) ([]ItemSalesRow, error) {
+	sql := `
+		SELECT s.menu_item_id, COALESCE(mi.name, ''), SUM(s.quantity)::BIGINT, SUM(s.revenue)::BIGINT AS revenue
+		FROM merchant_item_sales_daily s
+		LEFT JOIN menu_items mi ON mi.id = s.menu_item_id
+		WHERE s.merchant_id = $1 AND s.day >= $2::DATE AND s.day < $3::DATE
+		GROUP BY s.menu_item_id, mi.name
+		ORDER BY 3 DESC, revenue DESC, s.menu_item_id
+		LIMIT $4;
+	`
+	from, to := rollupDays(query)
+	rows, err := r.db.Query(ctx, sql, query.MerchantID, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanItemSalesRows(rows)
+}
+
+// rollupDays returns the query range as the local dates the rollups are keyed by.
+func rollupDays(query Query) (from, to string) {
+	return query.From.In(query.Location).Format(dateLayout), query.To.In(query.Location).Format(dateLayout)
+}
+
+func scanSalesRows(rows pgx.Rows, location *time.Location) ([]SalesRow, error) {
+	defer rows.Close()
+
+	sales := []SalesRow{}
+	for rows.Next() {
+		var row SalesRow
+		var wallClock time.Time
+		if err := rows.Scan(&wallClock, &row.OrderCount, &row.Revenue); err != nil {
+			return nil, err
+		}
+		// Postgres returns the bucket as a timestamp without time zone.
+		row.Start = time.Date(wallClock.Year(), wallClock.Month(), wallClock.Day(), 0, 0, 0, 0, location)
+		sales = append(sales, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sales, nil
+}
+
+func scanItemSalesRows(rows pgx.Rows) ([]ItemSalesRow, error) {
+	defer rows.Close()
+
+	items := []ItemSalesRow{}
+	for rows.Next() {
+		var row ItemSalesRow
+		if err := rows.Scan(&row.MenuItemID, &row.Name, &row.Quantity, &row.Revenue); err != nil {
+			return nil, err
+		}
+		items = append(items, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
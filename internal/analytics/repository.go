@@ -0,0 +1,120 @@
+package analytics
+
+import (
+	"context"
+	"minimart/internal/order"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SalesRepository interface {
+	// Sales returns the buckets in the query range that have orders, oldest first.
+	Sales(ctx context.Context, query Query) ([]SalesRow, error)
+
+	// TopItems returns the limit best-selling menu items in the query range,
+	// by quantity sold.
+	TopItems(ctx context.Context, query Query, limit int) ([]ItemSalesRow, error)
+}
+
+// OrderSource is the part of the order module the in-memory repository reads.
+type OrderSource interface {
+	GetByMerchantID(ctx context.Context, merchantID uuid.UUID) ([]*order.Order, error)
+}
+
+// InMemorySalesRepository aggregates orders on every call. Item names are left
+// empty since it has no menu to look them up in.
+type InMemorySalesRepository struct {
+	orders OrderSource
+}
+
+func NewInMemorySalesRepository(orders OrderSource) *InMemorySalesRepository {
+	return &InMemorySalesRepository{orders: orders}
+}
+
+func (r *InMemorySalesRepository) Sales(ctx context.Context, query Query) ([]SalesRow, error) {
+	orders, err := r.ordersIn(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	byStart := map[time.Time]*SalesRow{}
+	for _, o := range orders {
+		start := query.Bucket.Start(o.CreatedAt.In(query.Location))
+		row, exists := byStart[start]
+		if !exists {
+			row = &SalesRow{Start: start}
+			byStart[start] = row
+		}
+		row.OrderCount++
+		row.Revenue += o.Total.Amount
+	}
+
+	rows := make([]SalesRow, 0, len(byStart))
+	for _, row := range byStart {
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Start.Before(rows[j].Start) })
+	return rows, nil
+}
+
+func (r *InMemorySalesRepository) TopItems(ctx context.Context, query Query, limit int) ([]ItemSalesRow, error) {
+	orders, err := r.ordersIn(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	byItem := map[uuid.UUID]*ItemSalesRow{}
+	for _, o := range orders {
+		for _, item := range o.Items {
+			row, exists := byItem[item.MenuItemID]
+			if !exists {
+				row = &ItemSalesRow{MenuItemID: item.MenuItemID}
+				byItem[item.MenuItemID] = row
+			}
+			row.Quantity += int64(item.Quantity)
+			row.Revenue += int64(item.Quantity) * item.UnitPrice.Amount
+		}
+	}
+
+	rows := make([]ItemSalesRow, 0, len(byItem))
+	for _, row := range byItem {
+		rows = append(rows, *row)
+	}
+	sortItemRows(rows)
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
+
+// ordersIn returns the merchant's orders in the query range, leaving out cancelled ones.
+func (r *InMemorySalesRepository) ordersIn(ctx context.Context, query Query) ([]*order.Order, error) {
+	orders, err := r.orders.GetByMerchantID(ctx, query.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []*order.Order
+	for _, o := range orders {
+		if o.Status == order.CANCELLED || o.CreatedAt.Before(query.From) || !o.CreatedAt.Before(query.To) {
+			continue
+		}
+		matching = append(matching, o)
+	}
+	return matching, nil
+}
+
+// sortItemRows orders items best-selling first, the same way the SQL queries do.
+func sortItemRows(rows []ItemSalesRow) {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Quantity != rows[j].Quantity {
+			return rows[i].Quantity > rows[j].Quantity
+		}
+		if rows[i].Revenue != rows[j].Revenue {
+			return rows[i].Revenue > rows[j].Revenue
+		}
+		return rows[i].MenuItemID.String() < rows[j].MenuItemID.String()
+	})
+}
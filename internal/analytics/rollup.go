@@ -0,0 +1,129 @@
+package analytics
+
+import (
+	"context"
+	"log/slog"
+	"minimart/internal/order"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RollupLookbackDays is how many local days before today each refresh
+// recomputes, so late status changes such as cancellations are picked up.
+const RollupLookbackDays = 2
+
+// RollupJob keeps the daily sales rollups read by PostgresRollupSalesRepository
+// up to date.
+type RollupJob struct {
+	db       *pgxpool.Pool
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+func NewRollupJob(db *pgxpool.Pool, interval time.Duration, logger *slog.Logger) *RollupJob {
+	return &RollupJob{db: db, interval: interval, logger: logger}
+}
+
+// Run rebuilds every rollup, then refreshes the trailing days every interval
+// until ctx is cancelled. The full rebuild also repairs the days of merchants
+// who changed time zone since the last start.
+func (j *RollupJob) Run(ctx context.Context) {
+	if err := j.refresh(ctx, nil); err != nil {
+		j.logger.Error("Failed to rebuild sales rollups", "error", err)
+	}
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := j.Refresh(ctx); err != nil {
+				j.logger.Error("Failed to refresh sales rollups", "error", err)
+			}
+		}
+	}
+}
+
+// Refresh recomputes the rollups for today and the RollupLookbackDays before it.
+func (j *RollupJob) Refresh(ctx context.Context) error {
+	lookback := RollupLookbackDays
+	return j.refresh(ctx, &lookback)
+}
+
+// refresh recomputes the rollups of the last lookbackDays local days, or of
+// every day when lookbackDays is nil.
+func (j *RollupJob) refresh(ctx context.Context, lookbackDays *int) error {
+	started := time.Now()
+	tx, err := j.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Days are replaced rather than upserted so days whose orders were all
+	// cancelled since the last refresh drop out.
+	deletes := []string{
+		`
+		DELETE FROM merchant_sales_daily d
+		USING merchants m
+		WHERE m.id = d.merchant_id
+			AND ($1::INT IS NULL OR d.day >= (NOW() AT TIME ZONE m.timezone)::DATE - $1::INT);
+		`,
+		`
+		DELETE FROM merchant_item_sales_daily d
+		USING merchants m
+		WHERE m.id = d.merchant_id
+			AND ($1::INT IS NULL OR d.day >= (NOW() AT TIME ZONE m.timezone)::DATE - $1::INT);
+		`,
+	}
+	for _, statement := range deletes {
+		if _, err := tx.Exec(ctx, statement, lookbackDays); err != nil {
+			return err
+		}
+	}
+
+	inserts := []string{
+		`
+		INSERT INTO merchant_sales_daily (merchant_id, day, order_count, revenue, refreshed_at)
+		SELECT o.merchant_id, (o.created_at AT TIME ZONE m.timezone)::DATE AS day, COUNT(*), SUM(o.total_amount), NOW()
+		FROM orders o
+		JOIN merchants m ON m.id = o.merchant_id
+		WHERE o.status <> $2
+			AND ($1::INT IS NULL OR (o.created_at AT TIME ZONE m.timezone)::DATE >= (NOW() AT TIME ZONE m.timezone)::DATE - $1::INT)
+		GROUP BY o.merchant_id, day
+		ON CONFLICT (merchant_id, day) DO UPDATE
+		SET order_count = EXCLUDED.order_count, revenue = EXCLUDED.revenue, refreshed_at = EXCLUDED.refreshed_at;
+		`,
+		`
+		INSERT INTO merchant_item_sales_daily (merchant_id, day, menu_item_id, quantity, revenue)
+		SELECT o.merchant_id, (o.created_at AT TIME ZONE m.timezone)::DATE AS day, oi.menu_item_id,
+			SUM(oi.quantity), SUM(oi.quantity * oi.unit_price)
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		JOIN merchants m ON m.id = o.merchant_id
+		WHERE o.status <> $2
+			AND ($1::INT IS NULL OR (o.created_at AT TIME ZONE m.timezone)::DATE >= (NOW() AT TIME ZONE m.timezone)::DATE - $1::INT)
+		GROUP BY o.merchant_id, day, oi.menu_item_id
+		ON CONFLICT (merchant_id, day, menu_item_id) DO UPDATE
+		SET quantity = EXCLUDED.quantity, revenue = EXCLUDED.revenue;
+		`,
+	}
+	for _, statement := range inserts {
+		if _, err := tx.Exec(ctx, statement, lookbackDays, order.CANCELLED); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	if lookbackDays == nil {
+		j.logger.Info("Rebuilt sales rollups", "duration", time.Since(started))
+	} else {
+		j.logger.Info("Refreshed sales rollups", "lookback_days", *lookbackDays, "duration", time.Since(started))
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"minimart/internal/merchant"
+	"minimart/internal/shared/money"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultReportDays is the range reported when no dates are given, ending today.
+	DefaultReportDays = 30
+	// MaxReportDays is the longest range a single report can cover.
+	MaxReportDays = 366
+	// DefaultTopItems is how many best sellers a report lists by default.
+	DefaultTopItems = 10
+	MaxTopItems     = 50
+)
+
+var (
+	ErrInvalidRange    = errors.New("from must not be after to")
+	ErrRangeTooLong    = errors.New("report range must be at most 366 days")
+	ErrInvalidTopItems = errors.New("top must be between 1 and 50")
+)
+
+// ReportRequest selects the report to build. From and To are calendar dates
+// in the merchant's time zone; only their year, month and day are used.
+type ReportRequest struct {
+	Bucket Bucket
+	// From defaults to DefaultReportDays before To.
+	From *time.Time
+	// To is inclusive and defaults to today.
+	To *time.Time
+	// Top defaults to DefaultTopItems.
+	Top int
+}
+
+type AnalyticsUsecase interface {
+	// SalesReport summarises a merchant's sales per bucket along with its best
+	// sellers. Buckets without orders are included with zero totals.
+	SalesReport(ctx context.Context, merchantID uuid.UUID, request ReportRequest) (*Report, error)
+}
+
+// MerchantReader is the part of the merchant module reports need.
+type MerchantReader interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*merchant.Merchant, error)
+}
+
+type analyticsUsecase struct {
+	sales     SalesRepository
+	merchants MerchantReader
+	now       func() time.Time
+}
+
+func NewAnalyticsUsecase(sales SalesRepository, merchants MerchantReader) AnalyticsUsecase {
+	return &analyticsUsecase{sales: sales, merchants: merchants, now: time.Now}
+}
+
+func (u *analyticsUsecase) SalesReport(ctx context.Context, merchantID uuid.UUID, request ReportRequest) (*Report, error) {
+	if request.Bucket == "" {
+		request.Bucket = BucketDay
+	}
+	if _, err := ParseBucket(string(request.Bucket)); err != nil {
+		return nil, err
+	}
+	if request.Top == 0 {
+		request.Top = DefaultTopItems
+	}
+	if request.Top < 1 || request.Top > MaxTopItems {
+		return nil, ErrInvalidTopItems
+	}
+
+	m, err := u.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	location := m.Zone()
+
+	to := localDate(u.now().In(location), location)
+	if request.To != nil {
+		to = localDate(*request.To, location)
+	}
+	from := to.AddDate(0, 0, -(DefaultReportDays - 1))
+	if request.From != nil {
+		from = localDate(*request.From, location)
+	}
+	if from.After(to) {
+		return nil, ErrInvalidRange
+	}
+	if days := daysBetween(from, to) + 1; days > MaxReportDays {
+		return nil, ErrRangeTooLong
+	}
+
+	query := Query{
+		MerchantID: merchantID,
+		Bucket:     request.Bucket,
+		From:       from,
+		To:         to.AddDate(0, 0, 1),
+		Location:   location,
+	}
+	rows, err := u.sales.Sales(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	itemRows, err := u.sales.TopItems(ctx, query, request.Top)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets, totals, err := fillBuckets(query, rows, m.Currency)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]TopItem, 0, len(itemRows))
+	for _, row := range itemRows {
+		items = append(items, TopItem{
+			MenuItemID: row.MenuItemID,
+			Name:       row.Name,
+			Quantity:   row.Quantity,
+			Revenue:    money.New(row.Revenue, m.Currency),
+		})
+	}
+
+	return &Report{
+		MerchantID: merchantID,
+		Timezone:   location.String(),
+		Currency:   m.Currency,
+		Bucket:     request.Bucket,
+		From:       from.Format(dateLayout),
+		To:         to.Format(dateLayout),
+		Totals:     totals,
+		Buckets:    buckets,
+		TopItems:   items,
+	}, nil
+}
+
+// fillBuckets returns a bucket for every period in the query range, in order,
+// and the totals across all of them.
+func fillBuckets(query Query, rows []SalesRow, currency money.Currency) ([]BucketStats, BucketStats, error) {
+	byStart := make(map[int64]SalesRow, len(rows))
+	for _, row := range rows {
+		byStart[row.Start.Unix()] = row
+	}
+
+	var buckets []BucketStats
+	var orderCount, revenue int64
+	for start := query.Bucket.Start(query.From); start.Before(query.To); start = query.Bucket.Next(start) {
+		row := byStart[start.Unix()]
+		stats, err := newBucketStats(start, row.OrderCount, money.New(row.Revenue, currency))
+		if err != nil {
+			return nil, BucketStats{}, err
+		}
+		buckets = append(buckets, stats)
+		orderCount += row.OrderCount
+		revenue += row.Revenue
+	}
+
+	totals, err := newBucketStats(query.From, orderCount, money.New(revenue, currency))
+	if err != nil {
+		return nil, BucketStats{}, err
+	}
+	return buckets, totals, nil
+}
+
+// localDate returns midnight in location on t's calendar date.
+func localDate(t time.Time, location *time.Location) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, location)
+}
+
+// daysBetween counts calendar days from a to b, ignoring daylight saving shifts.
+func daysBetween(a, b time.Time) int {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return int(time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC).Sub(time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+}
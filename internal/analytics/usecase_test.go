@@ -0,0 +1,142 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"minimart/internal/merchant"
+	"minimart/internal/order"
+	"minimart/internal/shared/money"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket_Start(t *testing.T) {
+	bangkok, err := time.LoadLocation("Asia/Bangkok")
+	require.NoError(t, err)
+	// A Sunday evening in Bangkok
+	at := time.Date(2024, time.March, 17, 21, 30, 0, 0, bangkok)
+
+	assert.Equal(t, time.Date(2024, time.March, 17, 0, 0, 0, 0, bangkok), BucketDay.Start(at))
+	assert.Equal(t, time.Date(2024, time.March, 11, 0, 0, 0, 0, bangkok), BucketWeek.Start(at))
+	assert.Equal(t, time.Date(2024, time.March, 1, 0, 0, 0, 0, bangkok), BucketMonth.Start(at))
+	assert.Equal(t, time.Date(2024, time.April, 1, 0, 0, 0, 0, bangkok), BucketMonth.Next(BucketMonth.Start(at)))
+
+	_, err = ParseBucket("year")
+	assert.ErrorIs(t, err, ErrUnknownBucket)
+}
+
+func TestAnalyticsUsecase_SalesReport(t *testing.T) {
+	ctx := context.Background()
+	bangkok, err := time.LoadLocation("Asia/Bangkok")
+	require.NoError(t, err)
+
+	merchants := merchant.NewInMemoryMerchantRepository()
+	shop := merchant.NewMerchant(uuid.New(), "Noodle Bar", "", money.THB)
+	shop.Timezone = "Asia/Bangkok"
+	require.NoError(t, merchants.Save(ctx, shop))
+
+	orders := order.NewInMemoryOrderRepository()
+	noodles, tea := uuid.New(), uuid.New()
+	place := func(at time.Time, status order.OrderStatus, items ...order.OrderItem) {
+		var total int64
+		for i := range items {
+			items[i].UnitPrice.Currency = money.THB
+			total += int64(items[i].Quantity) * items[i].UnitPrice.Amount
+		}
+		require.NoError(t, orders.Save(ctx, &order.Order{
+			ID:         uuid.New(),
+			MerchantID: shop.ID,
+			Items:      items,
+			Total:      money.New(total, money.THB),
+			Status:     status,
+			CreatedAt:  at,
+		}))
+	}
+	// 23:30 UTC on March 4th is already March 5th in Bangkok
+	place(time.Date(2024, time.March, 4, 23, 30, 0, 0, time.UTC), order.COMPLETED,
+		order.OrderItem{MenuItemID: noodles, Quantity: 2, UnitPrice: money.Money{Amount: 6000}})
+	place(time.Date(2024, time.March, 5, 12, 0, 0, 0, bangkok), order.COMPLETED,
+		order.OrderItem{MenuItemID: tea, Quantity: 4, UnitPrice: money.Money{Amount: 2500}},
+		order.OrderItem{MenuItemID: noodles, Quantity: 1, UnitPrice: money.Money{Amount: 6000}})
+	place(time.Date(2024, time.March, 5, 13, 0, 0, 0, bangkok), order.CANCELLED,
+		order.OrderItem{MenuItemID: tea, Quantity: 10, UnitPrice: money.Money{Amount: 2500}})
+	place(time.Date(2024, time.March, 12, 9, 0, 0, 0, bangkok), order.NEW,
+		order.OrderItem{MenuItemID: tea, Quantity: 1, UnitPrice: money.Money{Amount: 2500}})
+
+	usecase := NewAnalyticsUsecase(NewInMemorySalesRepository(orders), merchants)
+	date := func(year int, month time.Month, day int) *time.Time {
+		d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+
+	t.Run("should bucket sales by the merchant's days", func(t *testing.T) {
+		report, err := usecase.SalesReport(ctx, shop.ID, ReportRequest{From: date(2024, time.March, 4), To: date(2024, time.March, 6)})
+		require.NoError(t, err)
+
+		assert.Equal(t, "Asia/Bangkok", report.Timezone)
+		require.Len(t, report.Buckets, 3)
+		assert.Equal(t, BucketStats{Start: time.Date(2024, time.March, 4, 0, 0, 0, 0, bangkok), Revenue: money.Zero(money.THB), AverageOrderValue: money.Zero(money.THB)}, report.Buckets[0])
+		assert.Equal(t, int64(2), report.Buckets[1].OrderCount)
+		assert.Equal(t, money.New(28000, money.THB), report.Buckets[1].Revenue)
+		assert.Equal(t, money.New(14000, money.THB), report.Buckets[1].AverageOrderValue)
+		assert.Equal(t, int64(2), report.Totals.OrderCount)
+
+		require.Len(t, report.TopItems, 2)
+		assert.Equal(t, TopItem{MenuItemID: tea, Quantity: 4, Revenue: money.New(10000, money.THB)}, report.TopItems[0])
+		assert.Equal(t, noodles, report.TopItems[1].MenuItemID)
+	})
+
+	t.Run("should group weeks from Monday", func(t *testing.T) {
+		report, err := usecase.SalesReport(ctx, shop.ID, ReportRequest{
+			Bucket: BucketWeek,
+			From:   date(2024, time.March, 1),
+			To:     date(2024, time.March, 14),
+			Top:    1,
+		})
+		require.NoError(t, err)
+
+		require.Len(t, report.Buckets, 3)
+		assert.Equal(t, "2024-02-26", report.Buckets[0].Start.Format(dateLayout))
+		assert.Equal(t, int64(2), report.Buckets[1].OrderCount)
+		assert.Equal(t, int64(1), report.Buckets[2].OrderCount)
+		assert.Equal(t, money.New(30500, money.THB), report.Totals.Revenue)
+		assert.Len(t, report.TopItems, 1)
+	})
+
+	t.Run("should export CSV", func(t *testing.T) {
+		report, err := usecase.SalesReport(ctx, shop.ID, ReportRequest{From: date(2024, time.March, 5), To: date(2024, time.March, 5)})
+		require.NoError(t, err)
+
+		var sales bytes.Buffer
+		require.NoError(t, WriteSalesCSV(&sales, report))
+		assert.Equal(t, "bucket_start,order_count,revenue,average_order_value,currency\n2024-03-05,2,280.00,140.00,THB\n", sales.String())
+
+		var items bytes.Buffer
+		require.NoError(t, WriteTopItemsCSV(&items, report))
+		lines := strings.Split(strings.TrimSpace(items.String()), "\n")
+		require.Len(t, lines, 3)
+		assert.Equal(t, tea.String()+",,4,100.00,THB", lines[1])
+	})
+
+	t.Run("should reject invalid reports", func(t *testing.T) {
+		_, err := usecase.SalesReport(ctx, shop.ID, ReportRequest{From: date(2024, time.March, 6), To: date(2024, time.March, 5)})
+		assert.ErrorIs(t, err, ErrInvalidRange)
+
+		_, err = usecase.SalesReport(ctx, shop.ID, ReportRequest{From: date(2023, time.January, 1), To: date(2024, time.March, 5)})
+		assert.ErrorIs(t, err, ErrRangeTooLong)
+
+		_, err = usecase.SalesReport(ctx, shop.ID, ReportRequest{Top: MaxTopItems + 1})
+		assert.ErrorIs(t, err, ErrInvalidTopItems)
+
+		_, err = usecase.SalesReport(ctx, shop.ID, ReportRequest{Bucket: "year"})
+		assert.ErrorIs(t, err, ErrUnknownBucket)
+
+		_, err = usecase.SalesReport(ctx, uuid.New(), ReportRequest{})
+		assert.ErrorIs(t, err, merchant.ErrMerchantNotFound)
+	})
+}
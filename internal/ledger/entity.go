@@ -0,0 +1,167 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"minimart/internal/shared/money"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnbalanced     = errors.New("ledger transaction does not balance")
+	ErrUnknownPeriod  = errors.New("unknown statement period")
+	ErrInvalidPosting = errors.New("ledger transaction needs at least two non-zero postings")
+)
+
+// Account is one of the accounts in a merchant's settlement ledger.
+type Account string
+
+const (
+	// AccountPlatformCash holds what customers paid and what was paid out to the merchant.
+	AccountPlatformCash Account = "platform_cash"
+	// AccountMerchantPayable is what the platform owes the merchant. Credits
+	// (negative postings) increase it.
+	AccountMerchantPayable     Account = "merchant_payable"
+	AccountPlatformCommission  Account = "platform_commission"
+	AccountPlatformAdjustments Account = "platform_adjustments"
+)
+
+// Kind is the business event a transaction records.
+type Kind string
+
+const (
+	KindOrderRevenue Kind = "order_revenue"
+	// KindCommission is the platform's cut of an order, or the reversal of
+	// part of it when the order is refunded.
+	KindCommission Kind = "commission"
+	KindRefund     Kind = "refund"
+	KindAdjustment Kind = "adjustment"
+	KindPayout     Kind = "payout"
+)
+
+// Posting is a debit (positive amount) or credit (negative amount) to an account.
+type Posting struct {
+	Account Account     `json:"account"`
+	Amount  money.Money `json:"amount"`
+}
+
+// Transaction is an immutable, balanced set of postings. Mistakes are
+// corrected by recording another transaction, never by changing one.
+type Transaction struct {
+	ID         uuid.UUID `json:"id"`
+	MerchantID uuid.UUID `json:"merchant_id"`
+	Kind       Kind      `json:"kind"`
+	// Reference ties the transaction to what caused it, e.g. an order ID.
+	Reference string    `json:"reference"`
+	Memo      string    `json:"memo"`
+	Postings  []Posting `json:"postings"`
+	// CreatedBy is the admin who recorded a manual entry, nil for entries
+	// recorded by the system.
+	CreatedBy *uuid.UUID `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NewTransaction creates a transaction whose postings sum to zero in a single currency.
+func NewTransaction(merchantID uuid.UUID, kind Kind, reference, memo string, postings ...Posting) (*Transaction, error) {
+	if len(postings) < 2 {
+		return nil, ErrInvalidPosting
+	}
+	sum := money.Zero(postings[0].Amount.Currency)
+	for _, posting := range postings {
+		if posting.Amount.IsZero() {
+			return nil, ErrInvalidPosting
+		}
+		var err error
+		if sum, err = sum.Add(posting.Amount); err != nil {
+			return nil, err
+		}
+	}
+	if !sum.IsZero() {
+		return nil, fmt.Errorf("%w: off by %s", ErrUnbalanced, sum)
+	}
+
+	return &Transaction{
+		ID:         uuid.New(),
+		MerchantID: merchantID,
+		Kind:       kind,
+		Reference:  reference,
+		Memo:       memo,
+		Postings:   postings,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// Currency returns the currency all of the transaction's postings are in.
+func (t *Transaction) Currency() money.Currency {
+	return t.Postings[0].Amount.Currency
+}
+
+// PayableEffect returns how much the transaction changes what the platform
+// owes the merchant. Positive amounts increase the merchant's balance.
+func (t *Transaction) PayableEffect() int64 {
+	var effect int64
+	for _, posting := range t.Postings {
+		if posting.Account == AccountMerchantPayable {
+			effect -= posting.Amount.Amount
+		}
+	}
+	return effect
+}
+
+// Period is how long each payout statement covers.
+type Period string
+
+const (
+	PeriodWeek  Period = "week"
+	PeriodMonth Period = "month"
+)
+
+// ParsePeriod parses a period name such as "week".
+func ParsePeriod(name string) (Period, error) {
+	switch period := Period(strings.ToLower(strings.TrimSpace(name))); period {
+	case PeriodWeek, PeriodMonth:
+		return period, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownPeriod, name)
+}
+
+// Start returns the start of the period t falls in, in t's location. Weeks
+// start on Monday, when merchants are paid.
+func (p Period) Start(t time.Time) time.Time {
+	year, month, day := t.Date()
+	if p == PeriodMonth {
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	}
+	sinceMonday := (int(t.Weekday()) + 6) % 7
+	return time.Date(year, month, day-sinceMonday, 0, 0, 0, 0, t.Location())
+}
+
+// Next returns the start of the period after the one starting at start.
+func (p Period) Next(start time.Time) time.Time {
+	if p == PeriodMonth {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 7)
+}
+
+// Statement is a merchant's payout statement for one period. Each line is
+// signed by its effect on the balance, so ClosingBalance is OpeningBalance
+// plus every line.
+type Statement struct {
+	MerchantID  uuid.UUID `json:"merchant_id"`
+	PeriodStart time.Time `json:"period_start"`
+	// PeriodEnd is exclusive.
+	PeriodEnd time.Time `json:"period_end"`
+	// Closed is false for the period still in progress.
+	Closed         bool        `json:"closed"`
+	OpeningBalance money.Money `json:"opening_balance"`
+	Revenue        money.Money `json:"revenue"`
+	Commission     money.Money `json:"commission"`
+	Refunds        money.Money `json:"refunds"`
+	Adjustments    money.Money `json:"adjustments"`
+	Payouts        money.Money `json:"payouts"`
+	ClosingBalance money.Money `json:"closing_balance"`
+}
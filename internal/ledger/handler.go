@@ -0,0 +1,202 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"minimart/internal/merchant"
+	"minimart/internal/order"
+	middlerware "minimart/internal/shared/middleware"
+	"minimart/internal/shared/money"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// dateLayout is how dates are given in query parameters.
+const dateLayout = "2006-01-02"
+
+type LedgerHandler struct {
+	usecase LedgerUsecase
+	auth    fiber.Handler
+	access  merchant.AccessGuard
+	admin   fiber.Handler
+}
+
+// NewLedgerHandler creates a new LedgerHandler. Merchants read their own
+// ledger through auth and access, normally middlerware.Authenticate and
+// merchant.RequireAccess; admin guards the entries recorded by hand and runs after auth.
+func NewLedgerHandler(usecase LedgerUsecase, auth fiber.Handler, access merchant.AccessGuard, admin fiber.Handler) *LedgerHandler {
+	return &LedgerHandler{usecase: usecase, auth: auth, access: access, admin: admin}
+}
+
+func (h *LedgerHandler) RegisterRoutes(app *fiber.App) {
+	viewStatements := h.access(merchant.PermViewStatements)
+	app.Get("/merchants/:merchantID/statements", h.auth, viewStatements, h.GetStatements)
+	app.Get("/merchants/:merchantID/ledger", h.auth, viewStatements, h.ListTransactions)
+
+	// Admins read any merchant's ledger and record the entries made by hand
+	app.Get("/admin/merchants/:merchantID/statements", h.auth, h.admin, h.GetStatements)
+	app.Get("/admin/merchants/:merchantID/ledger", h.auth, h.admin, h.ListTransactions)
+	app.Post("/admin/merchants/:merchantID/ledger/refunds", h.auth, h.admin, h.RecordRefund)
+	app.Post("/admin/merchants/:merchantID/ledger/adjustments", h.auth, h.admin, h.RecordAdjustment)
+	app.Post("/admin/merchants/:merchantID/ledger/payouts", h.auth, h.admin, h.RecordPayout)
+}
+
+// GetStatements handles ?period=&from=&to= requests for payout statements,
+// with from and to as YYYY-MM-DD dates in the merchant's time zone.
+func (h *LedgerHandler) GetStatements(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+	from, errFrom := parseDateQuery(c, "from")
+	to, errTo := parseDateQuery(c, "to")
+	if err := errors.Join(errFrom, errTo); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	statements, err := h.usecase.Statements(c.Context(), merchantID, StatementRequest{
+		Period: Period(c.Query("period")),
+		From:   from,
+		To:     to,
+	})
+	if err != nil {
+		return ledgerErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(statements)
+}
+
+// ListTransactions handles ?from=&to= requests for the ledger's raw entries.
+func (h *LedgerHandler) ListTransactions(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+	from, errFrom := parseDateQuery(c, "from")
+	to, errTo := parseDateQuery(c, "to")
+	if err := errors.Join(errFrom, errTo); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	transactions, err := h.usecase.ListTransactions(c.Context(), merchantID, from, to)
+	if err != nil {
+		return ledgerErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(transactions)
+}
+
+type RecordRefundRequest struct {
+	OrderID uuid.UUID   `json:"order_id"`
+	Amount  money.Money `json:"amount"`
+	Memo    string      `json:"memo"`
+}
+
+// RecordRefund handles an admin charging a merchant for a refund on one of its orders.
+func (h *LedgerHandler) RecordRefund(c *fiber.Ctx) error {
+	adminID, err := middlerware.UserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	var req RecordRefundRequest
+	if err := c.BodyParser(&req); err != nil || req.OrderID == uuid.Nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "order_id and amount are required"})
+	}
+
+	transactions, err := h.usecase.RecordRefund(c.Context(), adminID, merchantID, req.OrderID, req.Amount, req.Memo)
+	if err != nil {
+		return ledgerErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(transactions)
+}
+
+type RecordAdjustmentRequest struct {
+	// Amount is owed to the merchant when positive and by it when negative.
+	Amount money.Money `json:"amount"`
+	Memo   string      `json:"memo"`
+}
+
+// RecordAdjustment handles an admin correcting a merchant's balance.
+func (h *LedgerHandler) RecordAdjustment(c *fiber.Ctx) error {
+	adminID, err := middlerware.UserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	var req RecordAdjustmentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	transaction, err := h.usecase.RecordAdjustment(c.Context(), adminID, merchantID, req.Amount, req.Memo)
+	if err != nil {
+		return ledgerErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(transaction)
+}
+
+type RecordPayoutRequest struct {
+	Amount money.Money `json:"amount"`
+	// Reference identifies the bank transfer.
+	Reference string `json:"reference"`
+}
+
+// RecordPayout handles an admin recording money sent to a merchant.
+func (h *LedgerHandler) RecordPayout(c *fiber.Ctx) error {
+	adminID, err := middlerware.UserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	var req RecordPayoutRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	transaction, err := h.usecase.RecordPayout(c.Context(), adminID, merchantID, req.Amount, req.Reference)
+	if err != nil {
+		return ledgerErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(transaction)
+}
+
+// parseDateQuery parses an optional YYYY-MM-DD query parameter.
+func parseDateQuery(c *fiber.Ctx, param string) (*time.Time, error) {
+	raw := c.Query(param)
+	if raw == "" {
+		return nil, nil
+	}
+	date, err := time.Parse(dateLayout, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a date like 2006-01-02", param)
+	}
+	return &date, nil
+}
+
+// ledgerErrorResponse maps the errors shared by the ledger endpoints to HTTP responses.
+func ledgerErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, merchant.ErrMerchantNotFound), errors.Is(err, order.ErrOrderNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrInvalidAmount), errors.Is(err, ErrCurrencyMismatch), errors.Is(err, ErrMemoRequired),
+		errors.Is(err, ErrReferenceRequired), errors.Is(err, ErrUnknownPeriod), errors.Is(err, ErrInvalidRange),
+		errors.Is(err, ErrRangeTooLong):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrOrderNotCompleted), errors.Is(err, ErrRefundExceedsOrder), errors.Is(err, ErrInsufficientBalance):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
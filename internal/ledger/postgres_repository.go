@@ -0,0 +1,172 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"minimart/internal/shared/money"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresLedgerRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresLedgerRepository(db *pgxpool.Pool) LedgerRepository {
+	return &PostgresLedgerRepository{db: db}
+}
+
+// querier runs queries on the pool or inside a database transaction.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Append inserts the transactions and their postings in one database
+// transaction. The database rejects postings that do not balance at commit.
+func (r *PostgresLedgerRepository) Append(ctx context.Context, transactions ...*Transaction) error {
+	return r.AppendChecked(ctx, nil, transactions...)
+}
+
+// AppendChecked holds an advisory lock per merchant until the database
+// transaction ends, so checks and inserts for a merchant take turns.
+func (r *PostgresLedgerRepository) AppendChecked(ctx context.Context, check func(ledger LedgerReader) error, transactions ...*Transaction) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock in a fixed order so two appends never wait on each other
+	var merchantIDs []string
+	for _, transaction := range transactions {
+		merchantIDs = append(merchantIDs, transaction.MerchantID.String())
+	}
+	slices.Sort(merchantIDs)
+	for _, merchantID := range slices.Compact(merchantIDs) {
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", "ledger:"+merchantID); err != nil {
+			return err
+		}
+	}
+	if check != nil {
+		if err := check(postgresLedgerReader{db: tx}); err != nil {
+			return err
+		}
+	}
+
+	transactionQuery := `
+		INSERT INTO ledger_transactions (id, merchant_id, kind, reference, memo, currency, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+	`
+	postingQuery := "INSERT INTO ledger_postings (transaction_id, account, amount) VALUES ($1, $2, $3)"
+	for _, transaction := range transactions {
+		_, err := tx.Exec(ctx, transactionQuery, transaction.ID, transaction.MerchantID, transaction.Kind, transaction.Reference,
+			transaction.Memo, transaction.Currency(), transaction.CreatedBy, transaction.CreatedAt)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" { // Unique violation
+				return ErrAlreadyRecorded
+			}
+			return err
+		}
+		for _, posting := range transaction.Postings {
+			if _, err := tx.Exec(ctx, postingQuery, transaction.ID, posting.Account, posting.Amount.Amount); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresLedgerRepository) ListByReference(ctx context.Context, merchantID uuid.UUID, kind Kind, reference string) ([]*Transaction, error) {
+	return postgresLedgerReader{db: r.db}.ListByReference(ctx, merchantID, kind, reference)
+}
+
+func (r *PostgresLedgerRepository) PayableBalance(ctx context.Context, merchantID uuid.UUID, before time.Time) (int64, error) {
+	return postgresLedgerReader{db: r.db}.PayableBalance(ctx, merchantID, before)
+}
+
+func (r *PostgresLedgerRepository) List(ctx context.Context, merchantID uuid.UUID, from, to time.Time) ([]*Transaction, error) {
+	query := `
+		SELECT t.id, t.merchant_id, t.kind, t.reference, t.memo, t.currency, t.created_by, t.created_at, p.account, p.amount
+		FROM ledger_transactions t
+		JOIN ledger_postings p ON p.transaction_id = t.id
+		WHERE t.merchant_id = $1 AND t.created_at >= $2 AND t.created_at < $3
+		ORDER BY t.created_at, t.id, p.id;
+	`
+	rows, err := r.db.Query(ctx, query, merchantID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return scanTransactions(rows)
+}
+
+// postgresLedgerReader reads the ledger from the pool, or from inside the
+// database transaction of AppendChecked.
+type postgresLedgerReader struct {
+	db querier
+}
+
+func (l postgresLedgerReader) ListByReference(ctx context.Context, merchantID uuid.UUID, kind Kind, reference string) ([]*Transaction, error) {
+	query := `
+		SELECT t.id, t.merchant_id, t.kind, t.reference, t.memo, t.currency, t.created_by, t.created_at, p.account, p.amount
+		FROM ledger_transactions t
+		JOIN ledger_postings p ON p.transaction_id = t.id
+		WHERE t.merchant_id = $1 AND t.kind = $2 AND t.reference = $3
+		ORDER BY t.created_at, t.id, p.id;
+	`
+	rows, err := l.db.Query(ctx, query, merchantID, kind, reference)
+	if err != nil {
+		return nil, err
+	}
+	return scanTransactions(rows)
+}
+
+func (l postgresLedgerReader) PayableBalance(ctx context.Context, merchantID uuid.UUID, before time.Time) (int64, error) {
+	// Credits to the payable account are what the merchant is owed.
+	query := `
+		SELECT COALESCE(-SUM(p.amount), 0)::BIGINT
+		FROM ledger_postings p
+		JOIN ledger_transactions t ON t.id = p.transaction_id
+		WHERE t.merchant_id = $1 AND t.created_at < $2 AND p.account = $3;
+	`
+	var balance int64
+	if err := l.db.QueryRow(ctx, query, merchantID, before, AccountMerchantPayable).Scan(&balance); err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// scanTransactions groups rows of a transaction joined with its postings,
+// ordered by transaction.
+func scanTransactions(rows pgx.Rows) ([]*Transaction, error) {
+	defer rows.Close()
+
+	transactions := []*Transaction{}
+	var current *Transaction
+	for rows.Next() {
+		var transaction Transaction
+		var currency money.Currency
+		var posting Posting
+		if err := rows.Scan(&transaction.ID, &transaction.MerchantID, &transaction.Kind, &transaction.Reference, &transaction.Memo,
+			&currency, &transaction.CreatedBy, &transaction.CreatedAt, &posting.Account, &posting.Amount.Amount); err != nil {
+			return nil, err
+		}
+		posting.Amount.Currency = currency
+
+		if current == nil || current.ID != transaction.ID {
+			current = &transaction
+			transactions = append(transactions, current)
+		}
+		current.Postings = append(current.Postings, posting)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
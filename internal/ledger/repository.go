@@ -0,0 +1,136 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrAlreadyRecorded is returned when an order has already been settled.
+var ErrAlreadyRecorded = errors.New("ledger transaction already recorded")
+
+// LedgerReader reads what checks before recording a transaction depend on.
+type LedgerReader interface {
+	// ListByReference returns the merchant's transactions of a kind with the given reference, oldest first.
+	ListByReference(ctx context.Context, merchantID uuid.UUID, kind Kind, reference string) ([]*Transaction, error)
+
+	// PayableBalance returns what the platform owed the merchant just before
+	// the given time, in minor units.
+	PayableBalance(ctx context.Context, merchantID uuid.UUID, before time.Time) (int64, error)
+}
+
+// LedgerRepository only ever adds transactions; there is no way to change or
+// remove one.
+type LedgerRepository interface {
+	LedgerReader
+
+	// Append records the transactions atomically. It records none of them and
+	// returns ErrAlreadyRecorded if one settles an order that is already settled.
+	// Transactions are recorded for one merchant at a time.
+	Append(ctx context.Context, transactions ...*Transaction) error
+
+	// AppendChecked is Append, but first runs check against the ledger while
+	// no other transaction can be recorded for the transactions' merchants.
+	// If check returns an error, nothing is recorded and the error is returned.
+	AppendChecked(ctx context.Context, check func(ledger LedgerReader) error, transactions ...*Transaction) error
+
+	// List returns the merchant's transactions created in [from, to), oldest first.
+	List(ctx context.Context, merchantID uuid.UUID, from, to time.Time) ([]*Transaction, error)
+}
+
+type InMemoryLedgerRepository struct {
+	mu           sync.Mutex
+	transactions []*Transaction
+}
+
+func NewInMemoryLedgerRepository() *InMemoryLedgerRepository {
+	return &InMemoryLedgerRepository{}
+}
+
+func (r *InMemoryLedgerRepository) Append(ctx context.Context, transactions ...*Transaction) error {
+	return r.AppendChecked(ctx, nil, transactions...)
+}
+
+func (r *InMemoryLedgerRepository) AppendChecked(ctx context.Context, check func(ledger LedgerReader) error, transactions ...*Transaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if check != nil {
+		if err := check(unlockedLedger{r}); err != nil {
+			return err
+		}
+	}
+	for _, transaction := range transactions {
+		if !settlesOrder(transaction.Kind) {
+			continue
+		}
+		for _, existing := range r.transactions {
+			if existing.Kind == transaction.Kind && existing.Reference == transaction.Reference {
+				return ErrAlreadyRecorded
+			}
+		}
+	}
+	r.transactions = append(r.transactions, transactions...)
+	return nil
+}
+
+func (r *InMemoryLedgerRepository) ListByReference(ctx context.Context, merchantID uuid.UUID, kind Kind, reference string) ([]*Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return unlockedLedger{r}.ListByReference(ctx, merchantID, kind, reference)
+}
+
+func (r *InMemoryLedgerRepository) List(ctx context.Context, merchantID uuid.UUID, from, to time.Time) ([]*Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	matching := []*Transaction{}
+	for _, transaction := range r.transactions {
+		if transaction.MerchantID == merchantID && !transaction.CreatedAt.Before(from) && transaction.CreatedAt.Before(to) {
+			matching = append(matching, transaction)
+		}
+	}
+	sort.SliceStable(matching, func(i, j int) bool { return matching[i].CreatedAt.Before(matching[j].CreatedAt) })
+	return matching, nil
+}
+
+func (r *InMemoryLedgerRepository) PayableBalance(ctx context.Context, merchantID uuid.UUID, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return unlockedLedger{r}.PayableBalance(ctx, merchantID, before)
+}
+
+// unlockedLedger reads an InMemoryLedgerRepository whose lock is already held.
+type unlockedLedger struct {
+	r *InMemoryLedgerRepository
+}
+
+func (l unlockedLedger) ListByReference(ctx context.Context, merchantID uuid.UUID, kind Kind, reference string) ([]*Transaction, error) {
+	r := l.r
+	matching := []*Transaction{}
+	for _, transaction := range r.transactions {
+		if transaction.MerchantID == merchantID && transaction.Kind == kind && transaction.Reference == reference {
+			matching = append(matching, transaction)
+		}
+	}
+	return matching, nil
+}
+
+func (l unlockedLedger) PayableBalance(ctx context.Context, merchantID uuid.UUID, before time.Time) (int64, error) {
+	var balance int64
+	for _, transaction := range l.r.transactions {
+		if transaction.MerchantID == merchantID && transaction.CreatedAt.Before(before) {
+			balance += transaction.PayableEffect()
+		}
+	}
+	return balance, nil
+}
+
+// settlesOrder reports whether transactions of this kind are recorded once per order.
+func settlesOrder(kind Kind) bool {
+	return kind == KindOrderRevenue || kind == KindCommission
+}
@@ -0,0 +1,418 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"minimart/internal/merchant"
+	"minimart/internal/order"
+	"minimart/internal/shared/money"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultStatementPeriods is how many periods are returned when no dates are given, ending with the current one.
+	DefaultStatementPeriods = 4
+	// MaxStatementDays is the longest range statements can be requested for at once.
+	MaxStatementDays = 366
+	// DefaultTransactionDays is how far back transactions are listed by default.
+	DefaultTransactionDays = 30
+)
+
+var (
+	ErrInvalidAmount       = errors.New("amount must be greater than zero")
+	ErrCurrencyMismatch    = errors.New("amount must be in the merchant's currency")
+	ErrMemoRequired        = errors.New("a memo is required for adjustments")
+	ErrReferenceRequired   = errors.New("a transfer reference is required for payouts")
+	ErrOrderNotCompleted   = errors.New("only completed orders can be refunded")
+	ErrRefundExceedsOrder  = errors.New("refunds must not exceed the order total")
+	ErrInsufficientBalance = errors.New("payout exceeds the merchant's balance")
+	ErrInvalidRange        = errors.New("from must not be after to")
+	ErrRangeTooLong        = errors.New("statement range must be at most 366 days")
+)
+
+// StatementRequest selects the statements to generate. From and To are
+// calendar dates in the merchant's time zone; only their year, month and day
+// are used. Every period that overlaps the range is included in full.
+type StatementRequest struct {
+	Period Period
+	// From defaults to DefaultStatementPeriods periods before To.
+	From *time.Time
+	// To is inclusive and defaults to today.
+	To *time.Time
+}
+
+type LedgerUsecase interface {
+	// RecordOrderCompleted credits the merchant with a completed order's
+	// total and charges the merchant's commission on it. Recording the same
+	// order twice is a no-op.
+	RecordOrderCompleted(ctx context.Context, o *order.Order) error
+	// RecordRefund debits the merchant for a refund on one of its completed
+	// orders and gives back the matching share of the commission charged.
+	RecordRefund(ctx context.Context, adminID, merchantID, orderID uuid.UUID, amount money.Money, memo string) ([]*Transaction, error)
+	// RecordAdjustment corrects the merchant's balance. Positive amounts are
+	// owed to the merchant, negative ones are owed by it.
+	RecordAdjustment(ctx context.Context, adminID, merchantID uuid.UUID, amount money.Money, memo string) (*Transaction, error)
+	// RecordPayout records money sent to the merchant. reference identifies
+	// the bank transfer.
+	RecordPayout(ctx context.Context, adminID, merchantID uuid.UUID, amount money.Money, reference string) (*Transaction, error)
+
+	// ListTransactions returns the merchant's transactions between two dates
+	// in its time zone, inclusive, oldest first. The range defaults to the
+	// DefaultTransactionDays ending today.
+	ListTransactions(ctx context.Context, merchantID uuid.UUID, from, to *time.Time) ([]*Transaction, error)
+	// Statements returns the merchant's payout statements, oldest first. They
+	// are computed from the ledger's transactions alone.
+	Statements(ctx context.Context, merchantID uuid.UUID, request StatementRequest) ([]Statement, error)
+}
+
+// MerchantReader is the part of the merchant module settlement needs.
+type MerchantReader interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*merchant.Merchant, error)
+}
+
+// OrderReader is the part of the order module refunds are checked against.
+type OrderReader interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*order.Order, error)
+}
+
+type ledgerUsecase struct {
+	repo      LedgerRepository
+	merchants MerchantReader
+	orders    OrderReader
+	now       func() time.Time
+}
+
+func NewLedgerUsecase(repo LedgerRepository, merchants MerchantReader, orders OrderReader) LedgerUsecase {
+	return &ledgerUsecase{repo: repo, merchants: merchants, orders: orders, now: time.Now}
+}
+
+func (u *ledgerUsecase) RecordOrderCompleted(ctx context.Context, o *order.Order) error {
+	if o.Total.IsZero() {
+		return nil
+	}
+	m, err := u.merchants.GetByID(ctx, o.MerchantID)
+	if err != nil {
+		return err
+	}
+
+	reference := o.ID.String()
+	revenue, err := NewTransaction(m.ID, KindOrderRevenue, reference, "",
+		Posting{Account: AccountPlatformCash, Amount: o.Total},
+		Posting{Account: AccountMerchantPayable, Amount: o.Total.Negate()},
+	)
+	if err != nil {
+		return err
+	}
+	transactions := []*Transaction{revenue}
+
+	commission, err := commissionOn(o.Total, m.CommissionBps)
+	if err != nil {
+		return err
+	}
+	if !commission.IsZero() {
+		charge, err := NewTransaction(m.ID, KindCommission, reference, fmt.Sprintf("%d bps", m.CommissionBps),
+			Posting{Account: AccountMerchantPayable, Amount: commission},
+			Posting{Account: AccountPlatformCommission, Amount: commission.Negate()},
+		)
+		if err != nil {
+			return err
+		}
+		transactions = append(transactions, charge)
+	}
+
+	if err := u.repo.Append(ctx, transactions...); err != nil && !errors.Is(err, ErrAlreadyRecorded) {
+		return err
+	}
+	return nil
+}
+
+func (u *ledgerUsecase) RecordRefund(ctx context.Context, adminID, merchantID, orderID uuid.UUID, amount money.Money, memo string) ([]*Transaction, error) {
+	m, err := u.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if amount, err = inMerchantCurrency(amount, m); err != nil {
+		return nil, err
+	}
+	if amount.IsNegative() {
+		return nil, ErrInvalidAmount
+	}
+
+	o, err := u.orders.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	// Orders of other merchants are reported as missing rather than forbidden.
+	if o.MerchantID != merchantID {
+		return nil, order.ErrOrderNotFound
+	}
+	if o.Status != order.COMPLETED {
+		return nil, ErrOrderNotCompleted
+	}
+
+	reference := orderID.String()
+	refund, err := NewTransaction(merchantID, KindRefund, reference, strings.TrimSpace(memo),
+		Posting{Account: AccountMerchantPayable, Amount: amount},
+		Posting{Account: AccountPlatformCash, Amount: amount.Negate()},
+	)
+	if err != nil {
+		return nil, err
+	}
+	refund.CreatedBy = &adminID
+	transactions := []*Transaction{refund}
+
+	// Give back the same share of the commission that was actually charged,
+	// even if the merchant's rate has changed since.
+	charged, err := sumPayableEffect(ctx, u.repo, merchantID, KindCommission, reference)
+	if err != nil {
+		return nil, err
+	}
+	returned, err := money.New(-charged, amount.Currency).Multiply(amount.Amount)
+	if err != nil {
+		return nil, err
+	}
+	if returned, err = returned.Divide(o.Total.Amount); err != nil {
+		return nil, err
+	}
+	if !returned.IsZero() {
+		reversal, err := NewTransaction(merchantID, KindCommission, refund.ID.String(), "refund of "+reference,
+			Posting{Account: AccountPlatformCommission, Amount: returned},
+			Posting{Account: AccountMerchantPayable, Amount: returned.Negate()},
+		)
+		if err != nil {
+			return nil, err
+		}
+		reversal.CreatedBy = &adminID
+		transactions = append(transactions, reversal)
+	}
+
+	// Checked while no other refund can be recorded, so refunds made at
+	// the same time cannot add up to more than the order.
+	withinOrderTotal := func(ledger LedgerReader) error {
+		refunded, err := sumPayableEffect(ctx, ledger, merchantID, KindRefund, reference)
+		if err != nil {
+			return err
+		}
+		// Refunds decrease the balance, so their effect is negative.
+		if -refunded+amount.Amount > o.Total.Amount {
+			return ErrRefundExceedsOrder
+		}
+		return nil
+	}
+	if err := u.repo.AppendChecked(ctx, withinOrderTotal, transactions...); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+func (u *ledgerUsecase) RecordAdjustment(ctx context.Context, adminID, merchantID uuid.UUID, amount money.Money, memo string) (*Transaction, error) {
+	memo = strings.TrimSpace(memo)
+	if memo == "" {
+		return nil, ErrMemoRequired
+	}
+	m, err := u.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if amount, err = inMerchantCurrency(amount, m); err != nil {
+		return nil, err
+	}
+
+	adjustment, err := NewTransaction(merchantID, KindAdjustment, uuid.NewString(), memo,
+		Posting{Account: AccountPlatformAdjustments, Amount: amount},
+		Posting{Account: AccountMerchantPayable, Amount: amount.Negate()},
+	)
+	if err != nil {
+		return nil, err
+	}
+	adjustment.CreatedBy = &adminID
+	if err := u.repo.Append(ctx, adjustment); err != nil {
+		return nil, err
+	}
+	return adjustment, nil
+}
+
+func (u *ledgerUsecase) RecordPayout(ctx context.Context, adminID, merchantID uuid.UUID, amount money.Money, reference string) (*Transaction, error) {
+	reference = strings.TrimSpace(reference)
+	if reference == "" {
+		return nil, ErrReferenceRequired
+	}
+	m, err := u.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if amount, err = inMerchantCurrency(amount, m); err != nil {
+		return nil, err
+	}
+	if amount.IsNegative() {
+		return nil, ErrInvalidAmount
+	}
+
+	payout, err := NewTransaction(merchantID, KindPayout, reference, "",
+		Posting{Account: AccountMerchantPayable, Amount: amount},
+		Posting{Account: AccountPlatformCash, Amount: amount.Negate()},
+	)
+	if err != nil {
+		return nil, err
+	}
+	payout.CreatedBy = &adminID
+	// Checked while no other transaction can be recorded for the merchant,
+	// so payouts made at the same time cannot overdraw it.
+	withinBalance := func(ledger LedgerReader) error {
+		balance, err := ledger.PayableBalance(ctx, merchantID, u.now())
+		if err != nil {
+			return err
+		}
+		if amount.Amount > balance {
+			return ErrInsufficientBalance
+		}
+		return nil
+	}
+	if err := u.repo.AppendChecked(ctx, withinBalance, payout); err != nil {
+		return nil, err
+	}
+	return payout, nil
+}
+
+func (u *ledgerUsecase) ListTransactions(ctx context.Context, merchantID uuid.UUID, from, to *time.Time) ([]*Transaction, error) {
+	m, err := u.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	location := m.Zone()
+
+	end := localDate(u.now().In(location), location)
+	if to != nil {
+		end = localDate(*to, location)
+	}
+	start := end.AddDate(0, 0, -(DefaultTransactionDays - 1))
+	if from != nil {
+		start = localDate(*from, location)
+	}
+	if start.After(end) {
+		return nil, ErrInvalidRange
+	}
+	if start.AddDate(0, 0, MaxStatementDays).Before(end.AddDate(0, 0, 1)) {
+		return nil, ErrRangeTooLong
+	}
+	return u.repo.List(ctx, merchantID, start, end.AddDate(0, 0, 1))
+}
+
+func (u *ledgerUsecase) Statements(ctx context.Context, merchantID uuid.UUID, request StatementRequest) ([]Statement, error) {
+	if request.Period == "" {
+		request.Period = PeriodWeek
+	}
+	if _, err := ParsePeriod(string(request.Period)); err != nil {
+		return nil, err
+	}
+	m, err := u.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	location := m.Zone()
+	now := u.now()
+
+	to := localDate(now.In(location), location)
+	if request.To != nil {
+		to = localDate(*request.To, location)
+	}
+	from := request.Period.Start(to)
+	for i := 1; i < DefaultStatementPeriods; i++ {
+		from = request.Period.Start(from.AddDate(0, 0, -1))
+	}
+	if request.From != nil {
+		from = localDate(*request.From, location)
+	}
+	if from.After(to) {
+		return nil, ErrInvalidRange
+	}
+	if from.AddDate(0, 0, MaxStatementDays).Before(to.AddDate(0, 0, 1)) {
+		return nil, ErrRangeTooLong
+	}
+
+	start := request.Period.Start(from)
+	end := request.Period.Next(request.Period.Start(to))
+	balance, err := u.repo.PayableBalance(ctx, merchantID, start)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := u.repo.List(ctx, merchantID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var statements []Statement
+	next := 0
+	for periodStart := start; periodStart.Before(end); periodStart = request.Period.Next(periodStart) {
+		periodEnd := request.Period.Next(periodStart)
+		lines := map[Kind]int64{}
+		closing := balance
+		for ; next < len(transactions) && transactions[next].CreatedAt.Before(periodEnd); next++ {
+			effect := transactions[next].PayableEffect()
+			lines[transactions[next].Kind] += effect
+			closing += effect
+		}
+		statements = append(statements, Statement{
+			MerchantID:     merchantID,
+			PeriodStart:    periodStart,
+			PeriodEnd:      periodEnd,
+			Closed:         !now.Before(periodEnd),
+			OpeningBalance: money.New(balance, m.Currency),
+			Revenue:        money.New(lines[KindOrderRevenue], m.Currency),
+			Commission:     money.New(lines[KindCommission], m.Currency),
+			Refunds:        money.New(lines[KindRefund], m.Currency),
+			Adjustments:    money.New(lines[KindAdjustment], m.Currency),
+			Payouts:        money.New(lines[KindPayout], m.Currency),
+			ClosingBalance: money.New(closing, m.Currency),
+		})
+		balance = closing
+	}
+	return statements, nil
+}
+
+// sumPayableEffect adds up the payable effect of the merchant's transactions of a kind with the given reference.
+func sumPayableEffect(ctx context.Context, ledger LedgerReader, merchantID uuid.UUID, kind Kind, reference string) (int64, error) {
+	transactions, err := ledger.ListByReference(ctx, merchantID, kind, reference)
+	if err != nil {
+		return 0, err
+	}
+	var sum int64
+	for _, transaction := range transactions {
+		sum += transaction.PayableEffect()
+	}
+	return sum, nil
+}
+
+// commissionOn returns the commission on total at commissionBps, rounded to the nearest minor unit.
+func commissionOn(total money.Money, commissionBps int) (money.Money, error) {
+	commission, err := total.Multiply(int64(commissionBps))
+	if err != nil {
+		return money.Money{}, err
+	}
+	return commission.Divide(merchant.MaxCommissionBps)
+}
+
+// inMerchantCurrency checks a manually entered amount is non-zero and in the
+// merchant's currency, which it defaults to.
+func inMerchantCurrency(amount money.Money, m *merchant.Merchant) (money.Money, error) {
+	if amount.Currency == "" {
+		amount.Currency = m.Currency
+	}
+	if amount.IsZero() {
+		return money.Money{}, ErrInvalidAmount
+	}
+	if amount.Currency != m.Currency {
+		return money.Money{}, ErrCurrencyMismatch
+	}
+	return amount, nil
+}
+
+// localDate returns midnight in location on t's calendar date.
+func localDate(t time.Time, location *time.Location) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, location)
+}
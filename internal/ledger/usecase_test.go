@@ -0,0 +1,200 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"minimart/internal/merchant"
+	"minimart/internal/order"
+	"minimart/internal/shared/money"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTransaction(t *testing.T) {
+	merchantID := uuid.New()
+
+	_, err := NewTransaction(merchantID, KindAdjustment, "", "",
+		Posting{Account: AccountPlatformAdjustments, Amount: money.New(100, money.USD)},
+		Posting{Account: AccountMerchantPayable, Amount: money.New(-90, money.USD)},
+	)
+	assert.ErrorIs(t, err, ErrUnbalanced)
+
+	_, err = NewTransaction(merchantID, KindAdjustment, "", "",
+		Posting{Account: AccountPlatformAdjustments, Amount: money.New(100, money.USD)},
+		Posting{Account: AccountMerchantPayable, Amount: money.New(-100, money.THB)},
+	)
+	assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+
+	_, err = NewTransaction(merchantID, KindAdjustment, "", "", Posting{Account: AccountMerchantPayable, Amount: money.New(100, money.USD)})
+	assert.ErrorIs(t, err, ErrInvalidPosting)
+}
+
+func TestLedgerUsecase(t *testing.T) {
+	ctx := context.Background()
+	adminID := uuid.New()
+
+	merchants := merchant.NewInMemoryMerchantRepository()
+	shop := merchant.NewMerchant(uuid.New(), "Burger Barn", "", money.USD)
+	shop.CommissionBps = 1000
+	require.NoError(t, merchants.Save(ctx, shop))
+
+	orders := order.NewInMemoryOrderRepository()
+	placeOrder := func(total int64, status order.OrderStatus) *order.Order {
+		o := &order.Order{ID: uuid.New(), MerchantID: shop.ID, Total: money.New(total, money.USD), Status: status, CreatedAt: time.Now()}
+		require.NoError(t, orders.Save(ctx, o))
+		return o
+	}
+
+	repo := NewInMemoryLedgerRepository()
+	usecase := NewLedgerUsecase(repo, merchants, orders)
+	balance := func() int64 {
+		b, err := repo.PayableBalance(ctx, shop.ID, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		return b
+	}
+
+	completed := placeOrder(5000, order.COMPLETED)
+
+	t.Run("should credit completed orders less commission once", func(t *testing.T) {
+		require.NoError(t, usecase.RecordOrderCompleted(ctx, completed))
+		require.NoError(t, usecase.RecordOrderCompleted(ctx, completed))
+		assert.Equal(t, int64(4500), balance())
+	})
+
+	t.Run("should give back commission on refunds", func(t *testing.T) {
+		transactions, err := usecase.RecordRefund(ctx, adminID, shop.ID, completed.ID, money.New(2000, ""), "cold fries")
+		require.NoError(t, err)
+		require.Len(t, transactions, 2)
+		assert.Equal(t, &adminID, transactions[0].CreatedBy)
+		// 2000 refunded, 200 of the 500 commission returned
+		assert.Equal(t, int64(4500-2000+200), balance())
+
+		_, err = usecase.RecordRefund(ctx, adminID, shop.ID, completed.ID, money.New(3001, money.USD), "")
+		assert.ErrorIs(t, err, ErrRefundExceedsOrder)
+
+		_, err = usecase.RecordRefund(ctx, adminID, shop.ID, placeOrder(1000, order.PENDING).ID, money.New(100, money.USD), "")
+		assert.ErrorIs(t, err, ErrOrderNotCompleted)
+
+		_, err = usecase.RecordRefund(ctx, adminID, shop.ID, uuid.New(), money.New(100, money.USD), "")
+		assert.ErrorIs(t, err, order.ErrOrderNotFound)
+
+		_, err = usecase.RecordRefund(ctx, adminID, shop.ID, completed.ID, money.New(100, money.THB), "")
+		assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	})
+
+	t.Run("should record adjustments and payouts", func(t *testing.T) {
+		_, err := usecase.RecordAdjustment(ctx, adminID, shop.ID, money.New(-300, money.USD), " ")
+		assert.ErrorIs(t, err, ErrMemoRequired)
+
+		_, err = usecase.RecordAdjustment(ctx, adminID, shop.ID, money.New(-300, money.USD), "packaging fee")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2400), balance())
+
+		_, err = usecase.RecordPayout(ctx, adminID, shop.ID, money.New(2401, money.USD), "TRF-1")
+		assert.ErrorIs(t, err, ErrInsufficientBalance)
+
+		_, err = usecase.RecordPayout(ctx, adminID, shop.ID, money.New(2400, money.USD), "")
+		assert.ErrorIs(t, err, ErrReferenceRequired)
+
+		_, err = usecase.RecordPayout(ctx, adminID, shop.ID, money.New(2400, money.USD), "TRF-1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), balance())
+	})
+
+	t.Run("should build statements from the entries alone", func(t *testing.T) {
+		// An adjustment from three weeks ago carries into the opening balances
+		old, err := NewTransaction(shop.ID, KindAdjustment, "old", "migrated balance",
+			Posting{Account: AccountPlatformAdjustments, Amount: money.New(700, money.USD)},
+			Posting{Account: AccountMerchantPayable, Amount: money.New(-700, money.USD)},
+		)
+		require.NoError(t, err)
+		old.CreatedAt = time.Now().AddDate(0, 0, -21)
+		require.NoError(t, repo.Append(ctx, old))
+
+		statements, err := usecase.Statements(ctx, shop.ID, StatementRequest{})
+		require.NoError(t, err)
+		require.Len(t, statements, DefaultStatementPeriods)
+
+		for i, statement := range statements {
+			lines, err := money.Sum(money.USD, statement.OpeningBalance, statement.Revenue, statement.Commission,
+				statement.Refunds, statement.Adjustments, statement.Payouts)
+			require.NoError(t, err)
+			assert.Equal(t, lines, statement.ClosingBalance)
+			if i > 0 {
+				assert.Equal(t, statements[i-1].ClosingBalance, statement.OpeningBalance)
+				assert.Equal(t, statements[i-1].PeriodEnd, statement.PeriodStart)
+			}
+		}
+
+		current := statements[len(statements)-1]
+		assert.False(t, current.Closed)
+		assert.True(t, statements[0].Closed)
+		assert.Equal(t, money.New(700, money.USD), current.OpeningBalance)
+		assert.Equal(t, money.New(5000, money.USD), current.Revenue)
+		assert.Equal(t, money.New(-300, money.USD), current.Commission)
+		assert.Equal(t, money.New(-2000, money.USD), current.Refunds)
+		assert.Equal(t, money.New(-300, money.USD), current.Adjustments)
+		assert.Equal(t, money.New(-2400, money.USD), current.Payouts)
+		assert.Equal(t, money.New(balance(), money.USD), current.ClosingBalance)
+
+		_, err = usecase.Statements(ctx, shop.ID, StatementRequest{Period: "year"})
+		assert.ErrorIs(t, err, ErrUnknownPeriod)
+	})
+}
+
+func TestLedgerUsecase_ConcurrentWithdrawals(t *testing.T) {
+	ctx := context.Background()
+	adminID := uuid.New()
+
+	merchants := merchant.NewInMemoryMerchantRepository()
+	shop := merchant.NewMerchant(uuid.New(), "Burger Barn", "", money.USD)
+	shop.CommissionBps = 0
+	require.NoError(t, merchants.Save(ctx, shop))
+	orders := order.NewInMemoryOrderRepository()
+	completed := &order.Order{ID: uuid.New(), MerchantID: shop.ID, Total: money.New(1000, money.USD), Status: order.COMPLETED, CreatedAt: time.Now()}
+	require.NoError(t, orders.Save(ctx, completed))
+
+	repo := NewInMemoryLedgerRepository()
+	usecase := NewLedgerUsecase(repo, merchants, orders)
+	require.NoError(t, usecase.RecordOrderCompleted(ctx, completed))
+
+	// concurrently runs withdraw ten times at once and counts the successes
+	concurrently := func(withdraw func(i int) error) int {
+		var wg sync.WaitGroup
+		var succeeded atomic.Int32
+		for i := range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if withdraw(i) == nil {
+					succeeded.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		return int(succeeded.Load())
+	}
+
+	t.Run("should not refund more than the order at once", func(t *testing.T) {
+		refunds := concurrently(func(int) error {
+			_, err := usecase.RecordRefund(ctx, adminID, shop.ID, completed.ID, money.New(300, money.USD), "")
+			return err
+		})
+		assert.Equal(t, 3, refunds)
+	})
+
+	t.Run("should not pay out more than the balance at once", func(t *testing.T) {
+		// 100 is left after the refunds
+		payouts := concurrently(func(i int) error {
+			_, err := usecase.RecordPayout(ctx, adminID, shop.ID, money.New(60, money.USD), fmt.Sprintf("TRF-%d", i))
+			return err
+		})
+		assert.Equal(t, 1, payouts)
+	})
+}
@@ -0,0 +1,240 @@
+package menu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"minimart/internal/shared/eventbus"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// MenuCache stores the live menu version of each merchant, keyed by merchant ID.
+type MenuCache interface {
+	// Get returns the cached menu and whether it was found.
+	Get(ctx context.Context, merchantID uuid.UUID) (*MenuVersion, bool, error)
+	Set(ctx context.Context, merchantID uuid.UUID, version *MenuVersion) error
+	Delete(ctx context.Context, merchantID uuid.UUID) error
+}
+
+// CacheStats is a snapshot of the cache hit/miss counters.
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// CachedMenuRepository is a read-through caching decorator around a MenuRepository.
+// Live menus, which is what customers read, are served from the cache when
+// possible and dropped from it whenever the merchant's menu changes.
+type CachedMenuRepository struct {
+	MenuRepository
+	cache  MenuCache
+	logger *slog.Logger
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewCachedMenuRepository wraps repo with the given cache.
+func NewCachedMenuRepository(repo MenuRepository, cache MenuCache, logger *slog.Logger) *CachedMenuRepository {
+	return &CachedMenuRepository{
+		MenuRepository: repo,
+		cache:          cache,
+		logger:         logger,
+	}
+}
+
+// GetLiveVersion serves the live menu from the cache, falling back to the
+// underlying repository on a miss. Cache failures never fail the read.
+func (r *CachedMenuRepository) GetLiveVersion(ctx context.Context, merchantID uuid.UUID) (*MenuVersion, error) {
+	version, found, err := r.cache.Get(ctx, merchantID)
+	if err != nil {
+		r.logger.Warn("Menu cache read failed", "module", "menu", "merchant_id", merchantID, "error", err)
+	}
+	if found {
+		r.hits.Add(1)
+		return version, nil
+	}
+	r.misses.Add(1)
+
+	version, err = r.MenuRepository.GetLiveVersion(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.cache.Set(ctx, merchantID, version); err != nil {
+		r.logger.Warn("Menu cache write failed", "module", "menu", "merchant_id", merchantID, "error", err)
+	}
+	return version, nil
+}
+
+// Save stores the item and drops the merchant's cached menu.
+func (r *CachedMenuRepository) Save(ctx context.Context, item *MenuItem) error {
+	if err := r.MenuRepository.Save(ctx, item); err != nil {
+		return err
+	}
+	r.invalidate(ctx, item.MerchantID)
+	return nil
+}
+
+// Update stores the item and drops the merchant's cached menu.
+func (r *CachedMenuRepository) Update(ctx context.Context, item *MenuItem) error {
+	if err := r.MenuRepository.Update(ctx, item); err != nil {
+		return err
+	}
+	r.invalidate(ctx, item.MerchantID)
+	return nil
+}
+
+// PublishDraft publishes the draft and drops the merchant's cached menu.
+func (r *CachedMenuRepository) PublishDraft(ctx context.Context, merchantID uuid.UUID) (*MenuVersion, error) {
+	version, err := r.MenuRepository.PublishDraft(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	r.invalidate(ctx, merchantID)
+	return version, nil
+}
+
+// SetLiveVersion rolls the menu back and drops the merchant's cached menu.
+func (r *CachedMenuRepository) SetLiveVersion(ctx context.Context, merchantID uuid.UUID, number int) (*MenuVersion, error) {
+	version, err := r.MenuRepository.SetLiveVersion(ctx, merchantID, number)
+	if err != nil {
+		return nil, err
+	}
+	r.invalidate(ctx, merchantID)
+	return version, nil
+}
+
+// Stats returns the current hit/miss counters.
+func (r *CachedMenuRepository) Stats() CacheStats {
+	return CacheStats{
+		Hits:   r.hits.Load(),
+		Misses: r.misses.Load(),
+	}
+}
+
+// HandleMenuEvent invalidates the cached menu of the merchant the event refers to.
+// Writes through this decorator already invalidate locally; the events make
+// sure every other server instance (and any other writer) does the same.
+func (r *CachedMenuRepository) HandleMenuEvent(ctx context.Context, event eventbus.Event) error {
+	var rawMerchantID string
+	switch e := event.(type) {
+	case MenuItemCreatedEvent:
+		rawMerchantID = e.MerchantID
+	case MenuItemUpdatedEvent:
+		rawMerchantID = e.MerchantID
+	case MenuItemOutOfStockEvent:
+		rawMerchantID = e.MerchantID
+	case MenuPublishedEvent:
+		rawMerchantID = e.MerchantID
+	default:
+		return fmt.Errorf("unexpected event type %T", event)
+	}
+
+	merchantID, err := uuid.Parse(rawMerchantID)
+	if err != nil {
+		return fmt.Errorf("invalid merchant ID in %s event: %w", event.Topic(), err)
+	}
+	return r.cache.Delete(ctx, merchantID)
+}
+
+func (r *CachedMenuRepository) invalidate(ctx context.Context, merchantID uuid.UUID) {
+	if err := r.cache.Delete(ctx, merchantID); err != nil {
+		r.logger.Warn("Menu cache invalidation failed", "module", "menu", "merchant_id", merchantID, "error", err)
+	}
+}
+
+// RedisMenuCache is a MenuCache that stores menus as JSON in Redis with a TTL.
+type RedisMenuCache struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewRedisMenuCache creates a new RedisMenuCache.
+func NewRedisMenuCache(client *redis.Client, ttl time.Duration) MenuCache {
+	return &RedisMenuCache{client: client, ttl: ttl}
+}
+
+func (c *RedisMenuCache) Get(ctx context.Context, merchantID uuid.UUID) (*MenuVersion, bool, error) {
+	payload, err := c.client.Get(ctx, menuCacheKey(merchantID)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	var version MenuVersion
+	if err := json.Unmarshal(payload, &version); err != nil {
+		return nil, false, err
+	}
+	return &version, true, nil
+}
+
+func (c *RedisMenuCache) Set(ctx context.Context, merchantID uuid.UUID, version *MenuVersion) error {
+	payload, err := json.Marshal(version)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, menuCacheKey(merchantID), payload, c.ttl).Err()
+}
+
+func (c *RedisMenuCache) Delete(ctx context.Context, merchantID uuid.UUID) error {
+	return c.client.Del(ctx, menuCacheKey(merchantID)).Err()
+}
+
+func menuCacheKey(merchantID uuid.UUID) string {
+	return "menu:merchant:" + merchantID.String() + ":live"
+}
+
+// InMemoryMenuCache is a MenuCache for tests and single-instance setups.
+type InMemoryMenuCache struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	entries map[uuid.UUID]menuCacheEntry
+}
+
+type menuCacheEntry struct {
+	version   *MenuVersion
+	expiresAt time.Time
+}
+
+// NewInMemoryMenuCache creates a new InMemoryMenuCache.
+func NewInMemoryMenuCache(ttl time.Duration) MenuCache {
+	return &InMemoryMenuCache{
+		ttl:     ttl,
+		entries: make(map[uuid.UUID]menuCacheEntry),
+	}
+}
+
+func (c *InMemoryMenuCache) Get(ctx context.Context, merchantID uuid.UUID) (*MenuVersion, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, found := c.entries[merchantID]
+	if !found || time.Now().After(entry.expiresAt) {
+		return nil, false, nil
+	}
+	return entry.version, true, nil
+}
+
+func (c *InMemoryMenuCache) Set(ctx context.Context, merchantID uuid.UUID, version *MenuVersion) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[merchantID] = menuCacheEntry{version: version, expiresAt: time.Now().Add(c.ttl)}
+	return nil
+}
+
+func (c *InMemoryMenuCache) Delete(ctx context.Context, merchantID uuid.UUID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, merchantID)
+	return nil
+}
@@ -0,0 +1,94 @@
+package menu
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"minimart/internal/merchant"
+	"minimart/internal/shared/eventbus"
+	"minimart/internal/shared/money"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedMenuRepository(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	merchants := merchant.NewInMemoryMerchantRepository()
+	seededMerchant := merchant.NewMerchant(uuid.New(), "Fry Shack", "", money.USD)
+	seededMerchant.Status = merchant.StatusApproved
+	seededMerchant.IsActive = true
+	require.NoError(t, merchants.Save(ctx, seededMerchant))
+	merchantID := seededMerchant.ID
+
+	t.Run("should serve repeated reads from the cache", func(t *testing.T) {
+		// Arrange
+		repo := NewCachedMenuRepository(NewInMemoryMenuRepository(), NewInMemoryMenuCache(time.Minute), logger)
+		require.NoError(t, repo.Save(ctx, &MenuItem{ID: uuid.New(), MerchantID: merchantID, Name: "Fries"}))
+		_, err := repo.PublishDraft(ctx, merchantID)
+		require.NoError(t, err)
+
+		// Act
+		first, err := repo.GetLiveVersion(ctx, merchantID)
+		require.NoError(t, err)
+		second, err := repo.GetLiveVersion(ctx, merchantID)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, first, second)
+		assert.Equal(t, CacheStats{Hits: 1, Misses: 1}, repo.Stats())
+	})
+
+	t.Run("should invalidate the menu when an item goes out of stock", func(t *testing.T) {
+		// Arrange
+		cache := NewInMemoryMenuCache(time.Minute)
+		repo := NewCachedMenuRepository(NewInMemoryMenuRepository(), cache, logger)
+		usecase := NewMenuUsecase(repo, merchants, nil, eventbus.NewInMemoryEventBus(), logger)
+
+		item, err := usecase.CreateMenuItem(ctx, merchantID, "Fries", "Crispy", money.New(300, money.USD), DietaryInfo{})
+		require.NoError(t, err)
+		_, err = usecase.PublishMenu(ctx, merchantID)
+		require.NoError(t, err)
+		_, err = usecase.GetMenuForMerchant(ctx, merchantID, MenuFilter{})
+		require.NoError(t, err)
+
+		// Act
+		outOfStock := false
+		_, err = usecase.UpdateMenuItem(ctx, merchantID, item.ID, MenuItemChanges{InStock: &outOfStock})
+		require.NoError(t, err)
+
+		// Assert
+		_, found, err := cache.Get(ctx, merchantID)
+		require.NoError(t, err)
+		assert.False(t, found, "expected the cached menu to be dropped")
+
+		liveMenu, err := usecase.GetMenuForMerchant(ctx, merchantID, MenuFilter{})
+		require.NoError(t, err)
+		require.Len(t, liveMenu.Items, 1)
+		assert.False(t, liveMenu.Items[0].InStock)
+	})
+
+	t.Run("should invalidate the menu when an event arrives from another instance", func(t *testing.T) {
+		// Arrange
+		cache := NewInMemoryMenuCache(time.Minute)
+		repo := NewCachedMenuRepository(NewInMemoryMenuRepository(), cache, logger)
+		bus := eventbus.NewInMemoryEventBus()
+		require.NoError(t, bus.Subscribe(MenuPublishedTopic, repo.HandleMenuEvent))
+
+		require.NoError(t, cache.Set(ctx, merchantID, &MenuVersion{ID: uuid.New(), MerchantID: merchantID, Number: 1}))
+
+		// Act
+		err := bus.Publish(ctx, MenuPublishedEvent{MerchantID: merchantID.String(), VersionID: uuid.NewString(), Number: 2})
+		require.NoError(t, err)
+
+		// Assert: the in-memory bus delivers asynchronously
+		assert.Eventually(t, func() bool {
+			_, found, _ := cache.Get(ctx, merchantID)
+			return !found
+		}, time.Second, 10*time.Millisecond)
+	})
+}
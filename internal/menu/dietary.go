@@ -0,0 +1,180 @@
+package menu
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrUnknownDietaryTag = errors.New("unknown dietary tag")
+	ErrUnknownAllergen   = errors.New("unknown allergen")
+	ErrInvalidNutrition  = errors.New("nutrition facts must not be negative")
+)
+
+// DietaryTag marks a menu item as suitable for a particular diet.
+type DietaryTag string
+
+const (
+	TagVegan       DietaryTag = "vegan"
+	TagVegetarian  DietaryTag = "vegetarian"
+	TagHalal       DietaryTag = "halal"
+	TagKosher      DietaryTag = "kosher"
+	TagGlutenFree  DietaryTag = "gluten-free"
+	TagDairyFree   DietaryTag = "dairy-free"
+	TagNutFree     DietaryTag = "nut-free"
+	TagSpicy       DietaryTag = "spicy"
+	TagLowCalorie  DietaryTag = "low-calorie"
+	TagOrganic     DietaryTag = "organic"
+	TagPescatarian DietaryTag = "pescatarian"
+)
+
+var dietaryTags = []DietaryTag{
+	TagVegan, TagVegetarian, TagHalal, TagKosher, TagGlutenFree, TagDairyFree,
+	TagNutFree, TagSpicy, TagLowCalorie, TagOrganic, TagPescatarian,
+}
+
+// Allergen is one of the 14 allergens that must be declared under EU/UK food law.
+type Allergen string
+
+const (
+	AllergenCelery      Allergen = "celery"
+	AllergenGluten      Allergen = "gluten"
+	AllergenCrustaceans Allergen = "crustaceans"
+	AllergenEggs        Allergen = "eggs"
+	AllergenFish        Allergen = "fish"
+	AllergenLupin       Allergen = "lupin"
+	AllergenMilk        Allergen = "milk"
+	AllergenMolluscs    Allergen = "molluscs"
+	AllergenMustard     Allergen = "mustard"
+	AllergenTreeNuts    Allergen = "tree-nuts"
+	AllergenPeanuts     Allergen = "peanuts"
+	AllergenSesame      Allergen = "sesame"
+	AllergenSoya        Allergen = "soya"
+	AllergenSulphites   Allergen = "sulphites"
+)
+
+var allergens = []Allergen{
+	AllergenCelery, AllergenGluten, AllergenCrustaceans, AllergenEggs, AllergenFish,
+	AllergenLupin, AllergenMilk, AllergenMolluscs, AllergenMustard, AllergenTreeNuts,
+	AllergenPeanuts, AllergenSesame, AllergenSoya, AllergenSulphites,
+}
+
+// NutritionFacts are per-serving nutrition values.
+type NutritionFacts struct {
+	Calories          int     `json:"calories"`
+	ProteinGrams      float64 `json:"protein_g"`
+	CarbohydrateGrams float64 `json:"carbohydrates_g"`
+	FatGrams          float64 `json:"fat_g"`
+}
+
+// DietaryInfo describes who a menu item is suitable for and what it contains.
+type DietaryInfo struct {
+	DietaryTags []DietaryTag
+	Allergens   []Allergen
+	// Nutrition is optional; nil means the merchant has not provided it.
+	Nutrition *NutritionFacts
+}
+
+// Normalize lower-cases, de-duplicates and validates the tags and allergens.
+func (d *DietaryInfo) Normalize() error {
+	tags, err := normalizeValues(d.DietaryTags, dietaryTags, ErrUnknownDietaryTag)
+	if err != nil {
+		return err
+	}
+	contained, err := normalizeValues(d.Allergens, allergens, ErrUnknownAllergen)
+	if err != nil {
+		return err
+	}
+	if n := d.Nutrition; n != nil && (n.Calories < 0 || n.ProteinGrams < 0 || n.CarbohydrateGrams < 0 || n.FatGrams < 0) {
+		return ErrInvalidNutrition
+	}
+
+	d.DietaryTags = tags
+	d.Allergens = contained
+	return nil
+}
+
+// MenuFilter narrows a list of menu items down for customers.
+type MenuFilter struct {
+	// Query matches the name or description, case-insensitively.
+	Query string
+	// Tags must all be present on an item.
+	Tags []DietaryTag
+	// ExcludeAllergens must all be absent from an item.
+	ExcludeAllergens []Allergen
+}
+
+// ParseMenuFilter builds a filter from comma-separated query string values,
+// e.g. tags=vegan,halal and exclude_allergens=peanuts.
+func ParseMenuFilter(query, tags, excludeAllergens string) (MenuFilter, error) {
+	filter := MenuFilter{Query: strings.TrimSpace(query)}
+
+	var err error
+	if filter.Tags, err = normalizeValues(splitList[DietaryTag](tags), dietaryTags, ErrUnknownDietaryTag); err != nil {
+		return MenuFilter{}, err
+	}
+	if filter.ExcludeAllergens, err = normalizeValues(splitList[Allergen](excludeAllergens), allergens, ErrUnknownAllergen); err != nil {
+		return MenuFilter{}, err
+	}
+	return filter, nil
+}
+
+// Matches reports whether an item satisfies the filter.
+func (f MenuFilter) Matches(item *MenuItem) bool {
+	if f.Query != "" {
+		query := strings.ToLower(f.Query)
+		if !strings.Contains(strings.ToLower(item.Name), query) && !strings.Contains(strings.ToLower(item.Description), query) {
+			return false
+		}
+	}
+	for _, tag := range f.Tags {
+		if !slices.Contains(item.DietaryTags, tag) {
+			return false
+		}
+	}
+	for _, allergen := range f.ExcludeAllergens {
+		if slices.Contains(item.Allergens, allergen) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns the items that match the filter.
+func (f MenuFilter) Apply(items []*MenuItem) []*MenuItem {
+	matched := make([]*MenuItem, 0, len(items))
+	for _, item := range items {
+		if f.Matches(item) {
+			matched = append(matched, item)
+		}
+	}
+	return matched
+}
+
+func splitList[T ~string](raw string) []T {
+	var values []T
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, T(part))
+		}
+	}
+	return values
+}
+
+// normalizeValues lower-cases and de-duplicates values, rejecting unknown ones.
+// The result is never nil so it encodes as an empty JSON array / SQL array.
+func normalizeValues[T ~string](values, known []T, errUnknown error) ([]T, error) {
+	normalized := make([]T, 0, len(values))
+	for _, value := range values {
+		value = T(strings.ToLower(strings.TrimSpace(string(value))))
+		if !slices.Contains(known, value) {
+			return nil, fmt.Errorf("%w: %q", errUnknown, value)
+		}
+		if !slices.Contains(normalized, value) {
+			normalized = append(normalized, value)
+		}
+	}
+	return normalized, nil
+}
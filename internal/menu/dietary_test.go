@@ -0,0 +1,97 @@
+package menu
+
+import (
+	"context"
+	"minimart/internal/merchant"
+	"minimart/internal/shared/eventbus"
+	"minimart/internal/shared/money"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMenuFilter(t *testing.T) {
+	t.Run("should parse comma-separated tags and allergens", func(t *testing.T) {
+		filter, err := ParseMenuFilter(" tofu ", "Vegan, halal,vegan", "peanuts,,milk")
+		require.NoError(t, err)
+		assert.Equal(t, "tofu", filter.Query)
+		assert.Equal(t, []DietaryTag{TagVegan, TagHalal}, filter.Tags)
+		assert.Equal(t, []Allergen{AllergenPeanuts, AllergenMilk}, filter.ExcludeAllergens)
+	})
+
+	t.Run("should reject unknown values", func(t *testing.T) {
+		_, err := ParseMenuFilter("", "carnivore", "")
+		assert.ErrorIs(t, err, ErrUnknownDietaryTag)
+
+		_, err = ParseMenuFilter("", "", "nuts")
+		assert.ErrorIs(t, err, ErrUnknownAllergen)
+	})
+}
+
+func TestMenuUsecase_DietaryInfo(t *testing.T) {
+	ctx := context.Background()
+	merchants := merchant.NewInMemoryMerchantRepository()
+	seededMerchant := merchant.NewMerchant(uuid.New(), "Green Bowl", "", money.USD)
+	seededMerchant.Status = merchant.StatusApproved
+	seededMerchant.IsActive = true
+	require.NoError(t, merchants.Save(ctx, seededMerchant))
+	merchantID := seededMerchant.ID
+
+	usecase := NewMenuUsecase(NewInMemoryMenuRepository(), merchants, nil, eventbus.NewInMemoryEventBus(), discardLogger)
+
+	t.Run("should validate dietary info on create", func(t *testing.T) {
+		_, err := usecase.CreateMenuItem(ctx, merchantID, "Mystery Stew", "", money.New(500, money.USD), DietaryInfo{
+			Allergens: []Allergen{"unicorn"},
+		})
+		assert.ErrorIs(t, err, ErrUnknownAllergen)
+
+		_, err = usecase.CreateMenuItem(ctx, merchantID, "Negative Salad", "", money.New(500, money.USD), DietaryInfo{
+			Nutrition: &NutritionFacts{Calories: -10},
+		})
+		assert.ErrorIs(t, err, ErrInvalidNutrition)
+	})
+
+	t.Run("should filter the live menu and search results", func(t *testing.T) {
+		_, err := usecase.CreateMenuItem(ctx, merchantID, "Peanut Noodles", "Spicy", money.New(900, money.USD), DietaryInfo{
+			DietaryTags: []DietaryTag{TagVegan, TagSpicy},
+			Allergens:   []Allergen{AllergenPeanuts, AllergenGluten},
+		})
+		require.NoError(t, err)
+		cheeseToast, err := usecase.CreateMenuItem(ctx, merchantID, "Cheese Toast", "", money.New(400, money.USD), DietaryInfo{
+			DietaryTags: []DietaryTag{TagVegetarian},
+			Allergens:   []Allergen{AllergenMilk, AllergenGluten},
+		})
+		require.NoError(t, err)
+		_, err = usecase.PublishMenu(ctx, merchantID)
+		require.NoError(t, err)
+
+		liveMenu, err := usecase.GetMenuForMerchant(ctx, merchantID, MenuFilter{ExcludeAllergens: []Allergen{AllergenPeanuts}})
+		require.NoError(t, err)
+		require.Len(t, liveMenu.Items, 1)
+		assert.Equal(t, "Cheese Toast", liveMenu.Items[0].Name)
+
+		liveMenu, err = usecase.GetMenuForMerchant(ctx, merchantID, MenuFilter{Tags: []DietaryTag{TagVegan, TagSpicy}})
+		require.NoError(t, err)
+		require.Len(t, liveMenu.Items, 1)
+		assert.Equal(t, "Peanut Noodles", liveMenu.Items[0].Name)
+
+		results, err := usecase.SearchMenuItems(ctx, MenuFilter{Query: "TOAST"})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, cheeseToast.ID, results[0].ID)
+	})
+
+	t.Run("should update dietary info", func(t *testing.T) {
+		draft, err := usecase.GetDraftMenu(ctx, merchantID)
+		require.NoError(t, err)
+
+		tags := []DietaryTag{"Vegan"}
+		nutrition := &NutritionFacts{Calories: 350, FatGrams: 12.5}
+		item, err := usecase.UpdateMenuItem(ctx, merchantID, draft[0].ID, MenuItemChanges{DietaryTags: &tags, Nutrition: nutrition})
+		require.NoError(t, err)
+		assert.Equal(t, []DietaryTag{TagVegan}, item.DietaryTags)
+		assert.Equal(t, nutrition, item.Nutrition)
+	})
+}
@@ -1,13 +1,33 @@
 package menu
 
-import "github.com/google/uuid"
+import (
+	"minimart/internal/shared/money"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // MenuItem represents a product or service that can be ordered.
 type MenuItem struct {
+	ID           uuid.UUID
+	MerchantID   uuid.UUID
+	Name         string
+	Description  string
+	Price        money.Money
+	InStock      bool
+	ImageURL     string
+	ThumbnailURL string
+	DietaryInfo
+}
+
+// MenuVersion is an immutable, published snapshot of a merchant's draft menu.
+// Exactly one version per merchant is live and shown to customers; older
+// versions are kept so the merchant can roll back to them.
+type MenuVersion struct {
 	ID          uuid.UUID
 	MerchantID  uuid.UUID
-	Name        string
-	Description string
-	Price       int
-	InStock     bool
+	Number      int
+	IsLive      bool
+	PublishedAt time.Time
+	Items       []*MenuItem
 }
@@ -0,0 +1,48 @@
+package menu
+
+const (
+	MenuItemCreatedTopic    = "menu.item_created"
+	MenuItemUpdatedTopic    = "menu.item_updated"
+	MenuItemOutOfStockTopic = "menu.item_out_of_stock"
+)
+
+type MenuItemCreatedEvent struct {
+	ItemID     string `json:"item_id"`
+	MerchantID string `json:"merchant_id"`
+}
+
+func (e MenuItemCreatedEvent) Topic() string {
+	return MenuItemCreatedTopic
+}
+
+type MenuItemUpdatedEvent struct {
+	ItemID     string `json:"item_id"`
+	MerchantID string `json:"merchant_id"`
+}
+
+func (e MenuItemUpdatedEvent) Topic() string {
+	return MenuItemUpdatedTopic
+}
+
+type MenuItemOutOfStockEvent struct {
+	ItemID     string `json:"item_id"`
+	MerchantID string `json:"merchant_id"`
+}
+
+func (e MenuItemOutOfStockEvent) Topic() string {
+	return MenuItemOutOfStockTopic
+}
+
+const MenuPublishedTopic = "menu.published"
+
+// MenuPublishedEvent is emitted whenever a merchant's live menu version changes,
+// either by publishing the draft or by rolling back to an older version.
+type MenuPublishedEvent struct {
+	MerchantID string `json:"merchant_id"`
+	VersionID  string `json:"version_id"`
+	Number     int    `json:"number"`
+}
+
+func (e MenuPublishedEvent) Topic() string {
+	return MenuPublishedTopic
+}
@@ -1,6 +1,13 @@
 package menu
 
 import (
+	"errors"
+	"io"
+	"minimart/internal/merchant"
+	"minimart/internal/shared/money"
+	"minimart/internal/shared/validation"
+	"strconv"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
@@ -8,12 +15,18 @@ import (
 // MenuHandler is reposible for handling HTTP requests from menu items.
 type MenuHandler struct {
 	usecase MenuUsecase
+	auth    fiber.Handler
+	access  merchant.AccessGuard
 }
 
-// NewMenuHandler creates a new instance of MenuHandler.
-func NewMenuHandler(usecase MenuUsecase) *MenuHandler {
+// NewMenuHandler creates a new instance of MenuHandler. Routes that change a
+// merchant's menu or expose its draft run auth and then access, normally
+// middlerware.Authenticate and merchant.RequireAccess.
+func NewMenuHandler(usecase MenuUsecase, auth fiber.Handler, access merchant.AccessGuard) *MenuHandler {
 	return &MenuHandler{
 		usecase: usecase,
+		auth:    auth,
+		access:  access,
 	}
 }
 
@@ -21,15 +34,41 @@ func NewMenuHandler(usecase MenuUsecase) *MenuHandler {
 func (h *MenuHandler) RegisterRoutes(app *fiber.App) {
 	// Group routes for a specific merchant's menu
 	menuRoutes := app.Group("/merchants/:merchantID/menu")
-	menuRoutes.Post("/", h.CreateMenuItem)
 	menuRoutes.Get("/", h.GetMenuForMerchant)
+
+	// Everything else is only for staff who manage the menu
+	manage := h.access(merchant.PermManageMenu)
+	menuRoutes.Post("/", h.auth, manage, h.CreateMenuItem)
+	menuRoutes.Get("/draft", h.auth, manage, h.GetDraftMenu)
+	menuRoutes.Post("/publish", h.auth, manage, h.PublishMenu)
+	menuRoutes.Get("/versions", h.auth, manage, h.ListMenuVersions)
+	menuRoutes.Post("/versions/:version/rollback", h.auth, manage, h.RollbackMenu)
+	menuRoutes.Patch("/:itemID", h.auth, manage, h.UpdateMenuItem)
+	menuRoutes.Post("/:itemID/image", h.auth, manage, h.UploadMenuItemImage)
+
+	app.Get("/menu/search", h.SearchMenu)
 }
 
 // CreateMenuITemRequest defines the JSON request body for creating a menu item.
 type CreateMenuItemRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
-	Price       int    `json:"price"`
+	// Price is in minor units; the currency defaults to the merchant's.
+	Price       money.Money     `json:"price"`
+	DietaryTags []DietaryTag    `json:"dietary_tags"`
+	Allergens   []Allergen      `json:"allergens"`
+	Nutrition   *NutritionFacts `json:"nutrition"`
+}
+
+// Validate checks the fields that are wrong on their own. Whether the price is
+// in the merchant's currency is up to the usecase.
+func (r CreateMenuItemRequest) Validate() error {
+	var v validation.Validator
+	v.Required("name", r.Name)
+	v.MaxLength("name", r.Name, validation.MaxNameLength)
+	v.Check(!r.Price.IsNegative(), "price", "must not be negative")
+	validateDietaryInfo(&v, r.DietaryTags, r.Allergens, r.Nutrition)
+	return v.Err()
 }
 
 // CreateMenuItem handles the creation of a new menu item.
@@ -43,24 +82,257 @@ func (h *MenuHandler) CreateMenuItem(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
 
-	item, err := h.usecase.CreateMenuItem(c.Context(), merchantID, req.Name, req.Description, req.Price)
+	item, err := h.usecase.CreateMenuItem(c.Context(), merchantID, req.Name, req.Description, req.Price, DietaryInfo{
+		DietaryTags: req.DietaryTags,
+		Allergens:   req.Allergens,
+		Nutrition:   req.Nutrition,
+	})
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return menuErrorResponse(c, err)
 	}
 	return c.Status(fiber.StatusCreated).JSON(item)
 }
 
-// GetMenuForMerchant handles fetching the menu for a specific merchant.
+// GetMenuForMerchant handles fetching the live menu for a specific merchant.
+// The version number is returned in the X-Menu-Version header. Items can be
+// filtered with ?q=, ?tags=vegan,halal and ?exclude_allergens=peanuts,milk.
 func (h *MenuHandler) GetMenuForMerchant(c *fiber.Ctx) error {
 	merchantID, err := uuid.Parse(c.Params("merchantID"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
 	}
+	filter, err := ParseMenuFilter(c.Query("q"), c.Query("tags"), c.Query("exclude_allergens"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	version, err := h.usecase.GetMenuForMerchant(c.Context(), merchantID, filter)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+	if version.Number > 0 {
+		c.Set("X-Menu-Version", strconv.Itoa(version.Number))
+	}
+	return c.Status(fiber.StatusOK).JSON(version.Items)
+}
+
+// SearchMenu handles searching the live menus of all merchants. It takes the
+// same query parameters as GetMenuForMerchant.
+func (h *MenuHandler) SearchMenu(c *fiber.Ctx) error {
+	filter, err := ParseMenuFilter(c.Query("q"), c.Query("tags"), c.Query("exclude_allergens"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	items, err := h.usecase.SearchMenuItems(c.Context(), filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(items)
+}
+
+// GetDraftMenu handles fetching the draft menu the merchant is editing.
+func (h *MenuHandler) GetDraftMenu(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
 
-	items, err := h.usecase.GetMenuForMerchant(c.Context(), merchantID)
+	items, err := h.usecase.GetDraftMenu(c.Context(), merchantID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 	return c.Status(fiber.StatusOK).JSON(items)
 }
+
+// PublishMenu handles publishing the draft menu as the new live version.
+func (h *MenuHandler) PublishMenu(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	version, err := h.usecase.PublishMenu(c.Context(), merchantID)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(version)
+}
+
+// ListMenuVersions handles listing the published versions of a merchant's menu.
+func (h *MenuHandler) ListMenuVersions(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	versions, err := h.usecase.ListMenuVersions(c.Context(), merchantID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(versions)
+}
+
+// RollbackMenu handles making an earlier menu version live again.
+func (h *MenuHandler) RollbackMenu(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+	number, err := strconv.Atoi(c.Params("version"))
+	if err != nil || number < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid menu version"})
+	}
+
+	version, err := h.usecase.RollbackMenu(c.Context(), merchantID, number)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(version)
+}
+
+// UpdateMenuItemRequest defines the JSON request body for a partial menu item update.
+type UpdateMenuItemRequest struct {
+	Name        *string         `json:"name"`
+	Description *string         `json:"description"`
+	Price       *money.Money    `json:"price"`
+	InStock     *bool           `json:"in_stock"`
+	DietaryTags *[]DietaryTag   `json:"dietary_tags"`
+	Allergens   *[]Allergen     `json:"allergens"`
+	Nutrition   *NutritionFacts `json:"nutrition"`
+}
+
+// Validate checks the fields that are being changed.
+func (r UpdateMenuItemRequest) Validate() error {
+	var v validation.Validator
+	if r.Name != nil {
+		v.Required("name", *r.Name)
+		v.MaxLength("name", *r.Name, validation.MaxNameLength)
+	}
+	if r.Price != nil {
+		v.Check(!r.Price.IsNegative(), "price", "must not be negative")
+	}
+	var tags []DietaryTag
+	if r.DietaryTags != nil {
+		tags = *r.DietaryTags
+	}
+	var contained []Allergen
+	if r.Allergens != nil {
+		contained = *r.Allergens
+	}
+	validateDietaryInfo(&v, tags, contained, r.Nutrition)
+	return v.Err()
+}
+
+// validateDietaryInfo checks what DietaryInfo.Normalize would reject, reporting
+// each field separately.
+func validateDietaryInfo(v *validation.Validator, tags []DietaryTag, contained []Allergen, nutrition *NutritionFacts) {
+	if _, err := normalizeValues(tags, dietaryTags, ErrUnknownDietaryTag); err != nil {
+		v.Add("dietary_tags", err.Error())
+	}
+	if _, err := normalizeValues(contained, allergens, ErrUnknownAllergen); err != nil {
+		v.Add("allergens", err.Error())
+	}
+	if nutrition != nil && (nutrition.Calories < 0 || nutrition.ProteinGrams < 0 || nutrition.CarbohydrateGrams < 0 || nutrition.FatGrams < 0) {
+		v.Add("nutrition", ErrInvalidNutrition.Error())
+	}
+}
+
+// UpdateMenuItem handles partial updates of a menu item, including toggling stock.
+func (h *MenuHandler) UpdateMenuItem(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+	itemID, err := uuid.Parse(c.Params("itemID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid menu item ID"})
+	}
+
+	var req UpdateMenuItemRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
+
+	item, err := h.usecase.UpdateMenuItem(c.Context(), merchantID, itemID, MenuItemChanges{
+		Name:        req.Name,
+		Description: req.Description,
+		Price:       req.Price,
+		InStock:     req.InStock,
+		DietaryTags: req.DietaryTags,
+		Allergens:   req.Allergens,
+		Nutrition:   req.Nutrition,
+	})
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(item)
+}
+
+// UploadMenuItemImage handles a multipart image upload for a menu item.
+// The file is expected in the "image" form field.
+func (h *MenuHandler) UploadMenuItemImage(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+	itemID, err := uuid.Parse(c.Params("itemID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid menu item ID"})
+	}
+
+	fileHeader, err := c.FormFile("image")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing image file"})
+	}
+	if fileHeader.Size > MaxImageSize {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": ErrImageTooLarge.Error()})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Could not read image file"})
+	}
+	defer file.Close()
+
+	// Read one byte past the limit so oversized files are still detected
+	// when the multipart header lies about the size.
+	data, err := io.ReadAll(io.LimitReader(file, MaxImageSize+1))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Could not read image file"})
+	}
+
+	item, err := h.usecase.UploadMenuItemImage(c.Context(), merchantID, itemID, data)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrImageTooLarge), errors.Is(err, ErrImageTooManyPixels):
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, ErrUnsupportedImageType):
+			return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, ErrInvalidImage):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return menuErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(item)
+}
+
+// menuErrorResponse maps the errors shared by the menu endpoints to HTTP responses.
+func menuErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrMenuItemNotFound), errors.Is(err, ErrMenuVersionNotFound), errors.Is(err, merchant.ErrMerchantNotFound), errors.Is(err, merchant.ErrMerchantInactive):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrEmptyMenu):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, money.ErrCurrencyMismatch), errors.Is(err, ErrUnknownDietaryTag), errors.Is(err, ErrUnknownAllergen), errors.Is(err, ErrInvalidNutrition):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
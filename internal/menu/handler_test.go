@@ -5,9 +5,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
 	"io"
 	"log"
+	"mime/multipart"
 	"minimart/internal/merchant"
+	"minimart/internal/shared/blobstore"
+	"minimart/internal/shared/eventbus"
+	"minimart/internal/shared/jwtkeys"
+	middlerware "minimart/internal/shared/middleware"
+	"minimart/internal/shared/money"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -16,6 +25,8 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -62,6 +73,20 @@ func TestMain(m *testing.M) {
 	runMigration(ctx, "../../migrations/002_create_orders_tables.sql")
 	runMigration(ctx, "../../migrations/004_create_merchants_table.sql")
 	runMigration(ctx, "../../migrations/003_create_menu_items_table.sql")
+	runMigration(ctx, "../../migrations/005_add_menu_item_images.sql")
+	runMigration(ctx, "../../migrations/006_add_currency_columns.sql")
+	runMigration(ctx, "../../migrations/007_create_menu_versions_tables.sql")
+	runMigration(ctx, "../../migrations/008_add_menu_item_dietary_info.sql")
+	runMigration(ctx, "../../migrations/009_add_merchant_owner.sql")
+	runMigration(ctx, "../../migrations/010_add_merchant_onboarding.sql")
+	runMigration(ctx, "../../migrations/011_add_merchant_location.sql")
+	runMigration(ctx, "../../migrations/012_create_merchant_memberships.sql")
+	runMigration(ctx, "../../migrations/013_add_sales_analytics.sql")
+	runMigration(ctx, "../../migrations/014_create_settlement_ledger.sql")
+	runMigration(ctx, "../../migrations/015_add_merchant_busy_mode.sql")
+	runMigration(ctx, "../../migrations/016_create_webhooks.sql")
+	runMigration(ctx, "../../migrations/017_add_merchant_slugs.sql")
+	runMigration(ctx, "../../migrations/019_add_user_roles.sql")
 
 	// 6. Run the actual tests
 	exitCode := m.Run()
@@ -85,23 +110,34 @@ func runMigration(ctx context.Context, filePath string) {
 func TestMenuHandler_Integration(t *testing.T) {
 	// Arrange: Set up a full Fiber app with both Merchant and Menu handlers
 	app := fiber.New()
+	signingKey, err := jwtkeys.GenerateKey(jwtkeys.EdDSA)
+	require.NoError(t, err)
+	keys, err := jwtkeys.NewKeyManager(signingKey)
+	require.NoError(t, err)
+	requireAuth := middlerware.AuthRequire(keys, middlerware.NewInMemoryTokenDenylist())
 
 	// Merchant dependencies
 	merchantRepo := merchant.NewPostgresMerchantRepository(dbpool)
-	merchantUsecase := merchant.NewMerchantUsecase(merchantRepo)
-	merchantHandler := merchant.NewMerchantHandler(merchantUsecase)
+	requireAccess := merchant.RequireAccess(merchant.NewOwnerAccessPolicy(merchantRepo))
+	merchantUsecase := merchant.NewMerchantUsecase(merchantRepo, grantNoRoles{}, eventbus.NewInMemoryEventBus(), discardLogger)
+	merchantHandler := merchant.NewMerchantHandler(merchantUsecase, requireAuth, requireAccess, middlerware.RequirePermission(middlerware.PermReviewMerchants))
 	merchantHandler.RegisterRoutes(app)
 
 	// Menu dependencies
 	menuRepo := NewPostgresMenuRepository(dbpool)
-	menuUsecase := NewMenuUsecase(menuRepo)
-	menuHandler := NewMenuHandler(menuUsecase)
+	blobs := blobstore.NewLocalBlobStore(t.TempDir(), "/media")
+	menuUsecase := NewMenuUsecase(menuRepo, merchantRepo, blobs, eventbus.NewInMemoryEventBus(), discardLogger)
+	menuHandler := NewMenuHandler(menuUsecase, requireAuth, requireAccess)
 	menuHandler.RegisterRoutes(app)
 
 	// --- Seed a merchant to be the owner of the menu items ---
-	seededMerchant := merchant.NewMerchant("The Berger Joint", "Best burgers in town")
-	err := merchantRepo.Save(context.Background(), seededMerchant)
+	ownerID, ownerToken := seedUserWithToken(t, keys, "owner@example.com")
+	seededMerchant := merchant.NewMerchant(ownerID, "The Berger Joint", "Best burgers in town", money.THB)
+	seededMerchant.Status = merchant.StatusApproved
+	seededMerchant.IsActive = true
+	err = merchantRepo.Save(context.Background(), seededMerchant)
 	require.NoError(t, err)
+	ownerAuth := "Bearer " + ownerToken
 
 	// --- Test Case 1: Create a new menu item ---
 	t.Run("should create a new menu item", func(t *testing.T) {
@@ -109,7 +145,7 @@ func TestMenuHandler_Integration(t *testing.T) {
 		reqBody := CreateMenuItemRequest{
 			Name:        "Classic Burger",
 			Description: "A delicious classic burger",
-			Price:       1000,
+			Price:       money.Money{Amount: 1000},
 		}
 		bodyBytes, _ := json.Marshal(reqBody)
 
@@ -117,6 +153,7 @@ func TestMenuHandler_Integration(t *testing.T) {
 		url := fmt.Sprintf("/merchants/%s/menu", seededMerchant.ID)
 		req := httptest.NewRequest(http.MethodPost, url, bytes.NewReader(bodyBytes))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", ownerAuth)
 
 		// Assert
 		resp, err := app.Test(req)
@@ -129,23 +166,73 @@ func TestMenuHandler_Integration(t *testing.T) {
 		require.NoError(t, err)
 
 		assert.Equal(t, "Classic Burger", createdItem.Name)
-		assert.Equal(t, 1000, createdItem.Price)
+		assert.Equal(t, money.New(1000, money.THB), createdItem.Price)
 		assert.Equal(t, seededMerchant.ID, createdItem.MerchantID)
 	})
 
-	// --- Test Case 2: Get all menu items for the merchant ---
-	t.Run("should get all menu items for a merchant", func(t *testing.T) {
-		// Act
+	t.Run("should reject a price in another currency", func(t *testing.T) {
+		reqBody := CreateMenuItemRequest{
+			Name:  "Imported Burger",
+			Price: money.New(1000, money.USD),
+		}
+		bodyBytes, _ := json.Marshal(reqBody)
+
+		url := fmt.Sprintf("/merchants/%s/menu", seededMerchant.ID)
+		req := httptest.NewRequest(http.MethodPost, url, bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", ownerAuth)
+
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("should only let the owner change the menu", func(t *testing.T) {
+		bodyBytes, _ := json.Marshal(CreateMenuItemRequest{Name: "Sneaky Burger", Price: money.Money{Amount: 1}})
+		url := fmt.Sprintf("/merchants/%s/menu", seededMerchant.ID)
+
+		req := httptest.NewRequest(http.MethodPost, url, bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		_, strangerToken := seedUserWithToken(t, keys, "stranger@example.com")
+		req = httptest.NewRequest(http.MethodPost, url, bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+strangerToken)
+		resp, err = app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	// --- Test Case 2: Publish the draft and get the live menu ---
+	t.Run("should get all menu items for a merchant once published", func(t *testing.T) {
 		url := fmt.Sprintf("/merchants/%s/menu", seededMerchant.ID)
+
+		// Nothing is visible to customers before the first publish
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+		require.NoError(t, err)
+		respBody, _ := io.ReadAll(resp.Body)
+		assert.JSONEq(t, "[]", string(respBody))
+
+		publishReq := httptest.NewRequest(http.MethodPost, url+"/publish", nil)
+		publishReq.Header.Set("Authorization", ownerAuth)
+		resp, err = app.Test(publishReq)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		// Act
 		req := httptest.NewRequest(http.MethodGet, url, nil)
 
 		// Assert
-		resp, err := app.Test(req)
+		resp, err = app.Test(req)
 		require.NoError(t, err)
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "1", resp.Header.Get("X-Menu-Version"))
 
 		var items []*MenuItem
-		respBody, _ := io.ReadAll(resp.Body)
+		respBody, _ = io.ReadAll(resp.Body)
 		err = json.Unmarshal(respBody, &items)
 		require.NoError(t, err)
 
@@ -153,4 +240,166 @@ func TestMenuHandler_Integration(t *testing.T) {
 		assert.Equal(t, "Classic Burger", items[0].Name)
 	})
 
+	// --- Test Case 3: Upload an image for the menu item ---
+	t.Run("should upload an image and generate a thumbnail", func(t *testing.T) {
+		items, err := menuRepo.GetByMerchantID(context.Background(), seededMerchant.ID)
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+
+		// Act
+		req := newImageUploadRequest(t, seededMerchant.ID.String(), items[0].ID.String(), testPNG(t, 800, 400))
+		req.Header.Set("Authorization", ownerAuth)
+
+		// Assert
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var updatedItem MenuItem
+		respBody, _ := io.ReadAll(resp.Body)
+		err = json.Unmarshal(respBody, &updatedItem)
+		require.NoError(t, err)
+
+		assert.Regexp(t, `^/media/menu/.+\.png$`, updatedItem.ImageURL)
+		assert.Regexp(t, `^/media/menu/.+_thumb\.jpg$`, updatedItem.ThumbnailURL)
+	})
+
+	t.Run("should reject files that are not images", func(t *testing.T) {
+		items, err := menuRepo.GetByMerchantID(context.Background(), seededMerchant.ID)
+		require.NoError(t, err)
+
+		req := newImageUploadRequest(t, seededMerchant.ID.String(), items[0].ID.String(), []byte("definitely not an image"))
+		req.Header.Set("Authorization", ownerAuth)
+
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	})
+
+	// --- Test Case 4: Filter the live menu by dietary tags and allergens ---
+	t.Run("should filter the live menu by tags and allergens", func(t *testing.T) {
+		url := fmt.Sprintf("/merchants/%s/menu", seededMerchant.ID)
+		reqBody := CreateMenuItemRequest{
+			Name:        "Satay Tofu",
+			Price:       money.Money{Amount: 800},
+			DietaryTags: []DietaryTag{TagVegan},
+			Allergens:   []Allergen{AllergenPeanuts, AllergenSoya},
+			Nutrition:   &NutritionFacts{Calories: 420, ProteinGrams: 21},
+		}
+		bodyBytes, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPost, url, bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", ownerAuth)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		publishReq := httptest.NewRequest(http.MethodPost, url+"/publish", nil)
+		publishReq.Header.Set("Authorization", ownerAuth)
+		resp, err = app.Test(publishReq)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		getNames := func(query string) []string {
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, query, nil))
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var items []*MenuItem
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&items))
+			names := []string{}
+			for _, item := range items {
+				names = append(names, item.Name)
+			}
+			return names
+		}
+
+		assert.Equal(t, []string{"Satay Tofu"}, getNames(url+"?tags=vegan"))
+		assert.Equal(t, []string{"Classic Burger"}, getNames(url+"?exclude_allergens=peanuts"))
+		assert.Equal(t, []string{"Satay Tofu"}, getNames("/menu/search?q=tofu&tags=vegan"))
+		assert.Empty(t, getNames("/menu/search?q=tofu&exclude_allergens=soya"))
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, url+"?tags=carnivore", nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	// --- Test Case 5: Deactivating the merchant hides its menu ---
+	t.Run("should hide the menu of a deactivated merchant", func(t *testing.T) {
+		merchantURL := fmt.Sprintf("/merchants/%s", seededMerchant.ID)
+		req := httptest.NewRequest(http.MethodPost, merchantURL+"/deactivate", nil)
+		req.Header.Set("Authorization", ownerAuth)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, merchantURL+"/menu", nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		req = httptest.NewRequest(http.MethodPost, merchantURL+"/activate", nil)
+		req.Header.Set("Authorization", ownerAuth)
+		resp, err = app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, merchantURL+"/menu", nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
+
+// grantNoRoles stands in for the user module; seeded users are merchants already.
+type grantNoRoles struct{}
+
+func (grantNoRoles) GrantRole(ctx context.Context, userID uuid.UUID, role middlerware.Role) error {
+	return nil
+}
+
+// seedUserWithToken inserts a merchant user and returns their ID with a JWT
+// signed by keys.
+func seedUserWithToken(t *testing.T, keys jwtkeys.KeyManager, email string) (uuid.UUID, string) {
+	userID := uuid.New()
+	_, err := dbpool.Exec(context.Background(), "INSERT INTO users (id, name, email, password, role) VALUES ($1, $2, $3, $4, $5);", userID, "Test User", email, "password", middlerware.RoleMerchant)
+	require.NoError(t, err)
+
+	signed, err := keys.Sign(&middlerware.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID.String(),
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Role: middlerware.RoleMerchant,
+	})
+	require.NoError(t, err)
+	return userID, signed
+}
+
+// testPNG encodes a solid-colour PNG of the given size.
+func testPNG(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: 200, G: 80, B: 40, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// newImageUploadRequest builds a multipart request for the image upload endpoint.
+func newImageUploadRequest(t *testing.T, merchantID, itemID string, data []byte) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("image", "photo.png")
+	require.NoError(t, err)
+	_, err = part.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	url := fmt.Sprintf("/merchants/%s/menu/%s/image", merchantID, itemID)
+	req := httptest.NewRequest(http.MethodPost, url, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
 }
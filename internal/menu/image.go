@@ -0,0 +1,106 @@
+package menu
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png" // Register the PNG decoder for image.Decode
+	"net/http"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// MaxImageSize is the largest image upload we accept, in bytes.
+	MaxImageSize = 5 << 20
+
+	// MaxImagePixels is the most pixels an uploaded image may have. A small,
+	// well-compressed file can declare huge dimensions, and decoding it would
+	// allocate memory for every one of them.
+	MaxImagePixels = 5000 * 5000
+
+	// ThumbnailSize is the length of the longest side of a generated thumbnail, in pixels.
+	ThumbnailSize = 256
+)
+
+var (
+	ErrUnsupportedImageType = errors.New("image must be a JPEG or PNG")
+	ErrImageTooLarge        = errors.New("image exceeds the maximum upload size")
+	ErrImageTooManyPixels   = errors.New("image exceeds the maximum dimensions")
+	ErrInvalidImage         = errors.New("image could not be decoded")
+)
+
+// imageExtensions maps the accepted content types to file extensions.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+// detectImageType sniffs the content type from the data itself rather than
+// trusting the client-supplied header.
+func detectImageType(data []byte) (string, error) {
+	if len(data) > MaxImageSize {
+		return "", ErrImageTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	if _, ok := imageExtensions[contentType]; !ok {
+		return "", ErrUnsupportedImageType
+	}
+	return contentType, nil
+}
+
+// itemBlobKey returns the blob key of an image URL UploadMenuItemImage stored
+// for item, or "" for any other URL. Blob stores return the key at the end of
+// the URL, and every key of the item starts with its merchant and item IDs.
+func itemBlobKey(item *MenuItem, url string) string {
+	prefix := fmt.Sprintf("menu/%s/%s/", item.MerchantID, item.ID)
+	if i := strings.Index(url, prefix); i >= 0 {
+		return url[i:]
+	}
+	return ""
+}
+
+// makeThumbnail decodes the image and returns a JPEG no larger than
+// ThumbnailSize on its longest side, keeping the aspect ratio. The image's
+// dimensions are checked before it is decoded.
+func makeThumbnail(data []byte) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	width, height := config.Width, config.Height
+	if width <= 0 || height <= 0 {
+		return nil, ErrInvalidImage
+	}
+	if int64(width)*int64(height) > MaxImagePixels {
+		return nil, ErrImageTooManyPixels
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	thumbWidth, thumbHeight := width, height
+	if width > ThumbnailSize || height > ThumbnailSize {
+		if width >= height {
+			thumbWidth = ThumbnailSize
+			thumbHeight = max(1, height*ThumbnailSize/width)
+		} else {
+			thumbHeight = ThumbnailSize
+			thumbWidth = max(1, width*ThumbnailSize/height)
+		}
+	}
+
+	thumb := image.NewRGBA(image.Rect(0, 0, thumbWidth, thumbHeight))
+	draw.CatmullRom.Scale(thumb, thumb.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,49 @@
+package menu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMakeThumbnail(t *testing.T) {
+	encode := func(width, height int) []byte {
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))))
+		return buf.Bytes()
+	}
+
+	t.Run("should scale the longest side down to the thumbnail size", func(t *testing.T) {
+		thumbnail, err := makeThumbnail(encode(800, 400))
+		require.NoError(t, err)
+
+		decoded, err := jpeg.DecodeConfig(bytes.NewReader(thumbnail))
+		require.NoError(t, err)
+		assert.Equal(t, ThumbnailSize, decoded.Width)
+		assert.Equal(t, ThumbnailSize/2, decoded.Height)
+	})
+
+	t.Run("should reject images declaring too many pixels before decoding them", func(t *testing.T) {
+		// A tiny PNG whose header claims 100000 x 100000 pixels
+		bomb := encode(1, 1)
+		ihdr := bomb[8+4 : 8+4+4+13]
+		binary.BigEndian.PutUint32(ihdr[4:], 100000)
+		binary.BigEndian.PutUint32(ihdr[8:], 100000)
+		binary.BigEndian.PutUint32(bomb[8+4+4+13:], crc32.ChecksumIEEE(ihdr))
+
+		_, err := makeThumbnail(bomb)
+		assert.ErrorIs(t, err, ErrImageTooManyPixels)
+	})
+
+	t.Run("should reject data that is not an image", func(t *testing.T) {
+		_, err := makeThumbnail([]byte("definitely not an image"))
+		assert.ErrorIs(t, err, ErrInvalidImage)
+	})
+}
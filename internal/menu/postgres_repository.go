@@ -2,8 +2,10 @@ package menu
 
 import (
 	"context"
+	"errors"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -22,17 +24,54 @@ func NewPostgresMenuRepository(db *pgxpool.Pool) MenuRepository {
 // Save inserts a new menu item into the database.
 func (r *PostgresRepository) Save(ctx context.Context, item *MenuItem) error {
 	query := `
-		INSERT INTO menu_items (id, merchant_id, name, description, price, in_stock)
-		VALUES ($1, $2, $3, $4, $5, $6);
+		INSERT INTO menu_items (id, merchant_id, name, description, price, currency, in_stock, image_url, thumbnail_url, dietary_tags, allergens, nutrition)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);
 	`
-	_, err := r.db.Exec(ctx, query, item.ID, item.MerchantID, item.Name, item.Description, item.Price, item.InStock)
+	_, err := r.db.Exec(ctx, query, item.ID, item.MerchantID, item.Name, item.Description, item.Price.Amount, item.Price.Currency, item.InStock, item.ImageURL, item.ThumbnailURL,
+		toStrings(item.DietaryTags), toStrings(item.Allergens), item.Nutrition)
 	return err
 }
 
+// Update overwrites the mutable fields of an existing menu item.
+func (r *PostgresRepository) Update(ctx context.Context, item *MenuItem) error {
+	query := `
+		UPDATE menu_items
+		SET name = $2, description = $3, price = $4, currency = $5, in_stock = $6, image_url = $7, thumbnail_url = $8,
+		    dietary_tags = $9, allergens = $10, nutrition = $11
+		WHERE id = $1;
+	`
+	tag, err := r.db.Exec(ctx, query, item.ID, item.Name, item.Description, item.Price.Amount, item.Price.Currency, item.InStock, item.ImageURL, item.ThumbnailURL,
+		toStrings(item.DietaryTags), toStrings(item.Allergens), item.Nutrition)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMenuItemNotFound
+	}
+	return nil
+}
+
+// GetByID retrieves a single menu item by its ID.
+func (r *PostgresRepository) GetByID(ctx context.Context, id uuid.UUID) (*MenuItem, error) {
+	query := `
+		SELECT id, merchant_id, name, description, price, currency, in_stock, image_url, thumbnail_url, dietary_tags, allergens, nutrition
+		FROM menu_items
+		WHERE id = $1;
+	`
+	item, err := scanMenuItem(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMenuItemNotFound
+		}
+		return nil, err
+	}
+	return item, nil
+}
+
 // GetByMerchantID retrieves all menu items for a specific merchant.
 func (r *PostgresRepository) GetByMerchantID(ctx context.Context, merchantID uuid.UUID) ([]*MenuItem, error) {
 	query := `
-		SELECT id, merchant_id, name, description, price, in_stock
+		SELECT id, merchant_id, name, description, price, currency, in_stock, image_url, thumbnail_url, dietary_tags, allergens, nutrition
 		FROM menu_items
 		WHERE merchant_id = $1
 		ORDER BY name;
@@ -45,15 +84,7 @@ func (r *PostgresRepository) GetByMerchantID(ctx context.Context, merchantID uui
 
 	var items []*MenuItem
 	for rows.Next() {
-		item := &MenuItem{}
-		err := rows.Scan(
-			&item.ID,
-			&item.MerchantID,
-			&item.Name,
-			&item.Description,
-			&item.Price,
-			&item.InStock,
-		)
+		item, err := scanMenuItem(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -66,3 +97,277 @@ func (r *PostgresRepository) GetByMerchantID(ctx context.Context, merchantID uui
 	}
 	return items, nil
 }
+
+// PublishDraft copies the draft items into a new version and swaps it live in
+// a single transaction, so customers never see a half-published menu.
+func (r *PostgresRepository) PublishDraft(ctx context.Context, merchantID uuid.UUID) (*MenuVersion, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialise publishes per merchant so version numbers never collide.
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", merchantID.String()); err != nil {
+		return nil, err
+	}
+
+	var draftCount int
+	if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM menu_items WHERE merchant_id = $1", merchantID).Scan(&draftCount); err != nil {
+		return nil, err
+	}
+	if draftCount == 0 {
+		return nil, ErrEmptyMenu
+	}
+
+	version := &MenuVersion{
+		ID:         uuid.New(),
+		MerchantID: merchantID,
+		IsLive:     true,
+	}
+	err = tx.QueryRow(ctx, "SELECT COALESCE(MAX(number), 0) + 1 FROM menu_versions WHERE merchant_id = $1", merchantID).Scan(&version.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	// The previous live version must be demoted first to satisfy the partial unique index.
+	if _, err := tx.Exec(ctx, "UPDATE menu_versions SET is_live = FALSE WHERE merchant_id = $1 AND is_live", merchantID); err != nil {
+		return nil, err
+	}
+
+	versionQuery := `
+		INSERT INTO menu_versions (id, merchant_id, number, is_live)
+		VALUES ($1, $2, $3, TRUE)
+		RETURNING published_at;
+	`
+	if err := tx.QueryRow(ctx, versionQuery, version.ID, merchantID, version.Number).Scan(&version.PublishedAt); err != nil {
+		return nil, err
+	}
+
+	itemsQuery := `
+		INSERT INTO menu_version_items (version_id, item_id, merchant_id, name, description, price, currency, in_stock, image_url, thumbnail_url, dietary_tags, allergens, nutrition)
+		SELECT $1, id, merchant_id, name, description, price, currency, in_stock, image_url, thumbnail_url, dietary_tags, allergens, nutrition
+		FROM menu_items
+		WHERE merchant_id = $2;
+	`
+	if _, err := tx.Exec(ctx, itemsQuery, version.ID, merchantID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return r.getVersionItems(ctx, version)
+}
+
+// GetLiveVersion retrieves the merchant's live menu version and its items.
+func (r *PostgresRepository) GetLiveVersion(ctx context.Context, merchantID uuid.UUID) (*MenuVersion, error) {
+	query := `
+		SELECT id, merchant_id, number, is_live, published_at
+		FROM menu_versions
+		WHERE merchant_id = $1 AND is_live;
+	`
+	version := &MenuVersion{}
+	err := r.db.QueryRow(ctx, query, merchantID).Scan(&version.ID, &version.MerchantID, &version.Number, &version.IsLive, &version.PublishedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNoPublishedMenu
+		}
+		return nil, err
+	}
+	return r.getVersionItems(ctx, version)
+}
+
+// ListVersions retrieves the merchant's published versions, newest first.
+func (r *PostgresRepository) ListVersions(ctx context.Context, merchantID uuid.UUID) ([]*MenuVersion, error) {
+	query := `
+		SELECT id, merchant_id, number, is_live, published_at
+		FROM menu_versions
+		WHERE merchant_id = $1
+		ORDER BY number DESC;
+	`
+	rows, err := r.db.Query(ctx, query, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []*MenuVersion{}
+	for rows.Next() {
+		version := &MenuVersion{}
+		if err := rows.Scan(&version.ID, &version.MerchantID, &version.Number, &version.IsLive, &version.PublishedAt); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// SetLiveVersion atomically makes an earlier version live again.
+func (r *PostgresRepository) SetLiveVersion(ctx context.Context, merchantID uuid.UUID, number int) (*MenuVersion, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", merchantID.String()); err != nil {
+		return nil, err
+	}
+
+	version := &MenuVersion{}
+	query := `
+		SELECT id, merchant_id, number, published_at
+		FROM menu_versions
+		WHERE merchant_id = $1 AND number = $2;
+	`
+	err = tx.QueryRow(ctx, query, merchantID, number).Scan(&version.ID, &version.MerchantID, &version.Number, &version.PublishedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMenuVersionNotFound
+		}
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE menu_versions SET is_live = FALSE WHERE merchant_id = $1 AND is_live", merchantID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, "UPDATE menu_versions SET is_live = TRUE WHERE id = $1", version.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	version.IsLive = true
+	return r.getVersionItems(ctx, version)
+}
+
+// IsImagePublished checks whether any published version of the item references url.
+func (r *PostgresRepository) IsImagePublished(ctx context.Context, itemID uuid.UUID, url string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM menu_version_items
+			WHERE item_id = $1 AND (image_url = $2 OR thumbnail_url = $2)
+		);
+	`
+	var published bool
+	if err := r.db.QueryRow(ctx, query, itemID, url).Scan(&published); err != nil {
+		return false, err
+	}
+	return published, nil
+}
+
+// getVersionItems loads the items of a version, taking stock levels from the draft.
+func (r *PostgresRepository) getVersionItems(ctx context.Context, version *MenuVersion) (*MenuVersion, error) {
+	query := `
+		SELECT vi.item_id, vi.merchant_id, vi.name, vi.description, vi.price, vi.currency,
+		       COALESCE(mi.in_stock, vi.in_stock), vi.image_url, vi.thumbnail_url, vi.dietary_tags, vi.allergens, vi.nutrition
+		FROM menu_version_items vi
+		LEFT JOIN menu_items mi ON mi.id = vi.item_id
+		WHERE vi.version_id = $1
+		ORDER BY vi.name;
+	`
+	rows, err := r.db.Query(ctx, query, version.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	version.Items = []*MenuItem{}
+	for rows.Next() {
+		item, err := scanMenuItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		version.Items = append(version.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// SearchLiveItems searches the items of every live menu.
+func (r *PostgresRepository) SearchLiveItems(ctx context.Context, filter MenuFilter, limit int) ([]*MenuItem, error) {
+	query := `
+		SELECT vi.item_id, vi.merchant_id, vi.name, vi.description, vi.price, vi.currency,
+		       COALESCE(mi.in_stock, vi.in_stock), vi.image_url, vi.thumbnail_url, vi.dietary_tags, vi.allergens, vi.nutrition
+		FROM menu_versions v
+		JOIN menu_version_items vi ON vi.version_id = v.id
+		LEFT JOIN menu_items mi ON mi.id = vi.item_id
+		WHERE v.is_live
+		  AND ($1 = '' OR strpos(lower(vi.name), lower($1)) > 0 OR strpos(lower(COALESCE(vi.description, '')), lower($1)) > 0)
+		  AND vi.dietary_tags @> $2
+		  AND NOT vi.allergens && $3
+		ORDER BY vi.name, vi.item_id
+		LIMIT $4;
+	`
+	rows, err := r.db.Query(ctx, query, filter.Query, toStrings(filter.Tags), toStrings(filter.ExcludeAllergens), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*MenuItem{}
+	for rows.Next() {
+		item, err := scanMenuItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// scanMenuItem scans a row holding the menu item columns in the order
+// id, merchant_id, name, description, price, currency, in_stock, image_url,
+// thumbnail_url, dietary_tags, allergens, nutrition.
+func scanMenuItem(row pgx.Row) (*MenuItem, error) {
+	item := &MenuItem{}
+	var tags, contained []string
+	err := row.Scan(
+		&item.ID,
+		&item.MerchantID,
+		&item.Name,
+		&item.Description,
+		&item.Price.Amount,
+		&item.Price.Currency,
+		&item.InStock,
+		&item.ImageURL,
+		&item.ThumbnailURL,
+		&tags,
+		&contained,
+		&item.Nutrition,
+	)
+	if err != nil {
+		return nil, err
+	}
+	item.DietaryTags = fromStrings[DietaryTag](tags)
+	item.Allergens = fromStrings[Allergen](contained)
+	return item, nil
+}
+
+// toStrings converts typed values for a TEXT[] column. It never returns nil,
+// which pgx would encode as NULL rather than an empty array.
+func toStrings[T ~string](values []T) []string {
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		strs = append(strs, string(value))
+	}
+	return strs
+}
+
+func fromStrings[T ~string](strs []string) []T {
+	values := make([]T, 0, len(strs))
+	for _, str := range strs {
+		values = append(values, T(str))
+	}
+	return values
+}
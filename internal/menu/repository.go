@@ -2,26 +2,47 @@ package menu
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 // MenuRepository defines the interface for intreacting with menu item storage.
+// Items are the merchant's draft menu; versions are published snapshots of it.
 type MenuRepository interface {
 	Save(ctx context.Context, item *MenuItem) error
+	Update(ctx context.Context, item *MenuItem) error
+	GetByID(ctx context.Context, id uuid.UUID) (*MenuItem, error)
 	GetByMerchantID(ctx context.Context, merchantID uuid.UUID) ([]*MenuItem, error)
+
+	// PublishDraft snapshots the draft into a new version and makes it live atomically.
+	PublishDraft(ctx context.Context, merchantID uuid.UUID) (*MenuVersion, error)
+	// GetLiveVersion returns the live version with its items. Stock levels are
+	// taken from the draft, since availability is operational and not versioned.
+	GetLiveVersion(ctx context.Context, merchantID uuid.UUID) (*MenuVersion, error)
+	// ListVersions returns all published versions, newest first, without items.
+	ListVersions(ctx context.Context, merchantID uuid.UUID) ([]*MenuVersion, error)
+	// SetLiveVersion makes an earlier version live again.
+	SetLiveVersion(ctx context.Context, merchantID uuid.UUID, number int) (*MenuVersion, error)
+	// SearchLiveItems returns up to limit items across all live menus that match the filter, ordered by name.
+	SearchLiveItems(ctx context.Context, filter MenuFilter, limit int) ([]*MenuItem, error)
+	// IsImagePublished reports whether any published version of the item uses url as its image or thumbnail.
+	IsImagePublished(ctx context.Context, itemID uuid.UUID, url string) (bool, error)
 }
 
 // InMemoryMenuRepository is a simple in-memory implementation of MenuRepository.
 type InMemoryMenuRepository struct {
-	mu    sync.RWMutex
-	items map[uuid.UUID][]*MenuItem
+	mu       sync.RWMutex
+	items    map[uuid.UUID][]*MenuItem
+	versions map[uuid.UUID][]*MenuVersion
 }
 
 func NewInMemoryMenuRepository() MenuRepository {
 	return &InMemoryMenuRepository{
-		items: make(map[uuid.UUID][]*MenuItem),
+		items:    make(map[uuid.UUID][]*MenuItem),
+		versions: make(map[uuid.UUID][]*MenuVersion),
 	}
 }
 
@@ -33,8 +54,169 @@ func (r *InMemoryMenuRepository) Save(ctx context.Context, item *MenuItem) error
 	return nil
 }
 
+func (r *InMemoryMenuRepository) Update(ctx context.Context, item *MenuItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, existing := range r.items[item.MerchantID] {
+		if existing.ID == item.ID {
+			r.items[item.MerchantID][i] = item
+			return nil
+		}
+	}
+	return ErrMenuItemNotFound
+}
+
+func (r *InMemoryMenuRepository) GetByID(ctx context.Context, id uuid.UUID) (*MenuItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, items := range r.items {
+		for _, item := range items {
+			if item.ID == id {
+				return item, nil
+			}
+		}
+	}
+	return nil, ErrMenuItemNotFound
+}
+
 func (r *InMemoryMenuRepository) GetByMerchantID(ctx context.Context, merchantID uuid.UUID) ([]*MenuItem, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	return r.items[merchantID], nil
 }
+
+func (r *InMemoryMenuRepository) PublishDraft(ctx context.Context, merchantID uuid.UUID) (*MenuVersion, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	draft := r.items[merchantID]
+	if len(draft) == 0 {
+		return nil, ErrEmptyMenu
+	}
+
+	version := &MenuVersion{
+		ID:          uuid.New(),
+		MerchantID:  merchantID,
+		Number:      len(r.versions[merchantID]) + 1,
+		IsLive:      true,
+		PublishedAt: time.Now(),
+	}
+	for _, item := range draft {
+		snapshot := *item
+		version.Items = append(version.Items, &snapshot)
+	}
+	sort.Slice(version.Items, func(i, j int) bool { return version.Items[i].Name < version.Items[j].Name })
+
+	for _, existing := range r.versions[merchantID] {
+		existing.IsLive = false
+	}
+	r.versions[merchantID] = append(r.versions[merchantID], version)
+	return version, nil
+}
+
+func (r *InMemoryMenuRepository) GetLiveVersion(ctx context.Context, merchantID uuid.UUID) (*MenuVersion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, version := range r.versions[merchantID] {
+		if version.IsLive {
+			return r.withDraftStock(version), nil
+		}
+	}
+	return nil, ErrNoPublishedMenu
+}
+
+func (r *InMemoryMenuRepository) ListVersions(ctx context.Context, merchantID uuid.UUID) ([]*MenuVersion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := make([]*MenuVersion, 0, len(r.versions[merchantID]))
+	for i := len(r.versions[merchantID]) - 1; i >= 0; i-- {
+		summary := *r.versions[merchantID][i]
+		summary.Items = nil
+		versions = append(versions, &summary)
+	}
+	return versions, nil
+}
+
+func (r *InMemoryMenuRepository) SetLiveVersion(ctx context.Context, merchantID uuid.UUID, number int) (*MenuVersion, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var target *MenuVersion
+	for _, version := range r.versions[merchantID] {
+		if version.Number == number {
+			target = version
+		}
+	}
+	if target == nil {
+		return nil, ErrMenuVersionNotFound
+	}
+
+	for _, version := range r.versions[merchantID] {
+		version.IsLive = version == target
+	}
+	return r.withDraftStock(target), nil
+}
+
+func (r *InMemoryMenuRepository) SearchLiveItems(ctx context.Context, filter MenuFilter, limit int) ([]*MenuItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	items := []*MenuItem{}
+	for _, versions := range r.versions {
+		for _, version := range versions {
+			if version.IsLive {
+				items = append(items, filter.Apply(r.withDraftStock(version).Items)...)
+			}
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Name != items[j].Name {
+			return items[i].Name < items[j].Name
+		}
+		return items[i].ID.String() < items[j].ID.String()
+	})
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+func (r *InMemoryMenuRepository) IsImagePublished(ctx context.Context, itemID uuid.UUID, url string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, versions := range r.versions {
+		for _, version := range versions {
+			for _, item := range version.Items {
+				if item.ID == itemID && (item.ImageURL == url || item.ThumbnailURL == url) {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+// withDraftStock copies the version, overlaying stock levels from the draft.
+// Callers must hold the lock.
+func (r *InMemoryMenuRepository) withDraftStock(version *MenuVersion) *MenuVersion {
+	stock := make(map[uuid.UUID]bool)
+	for _, item := range r.items[version.MerchantID] {
+		stock[item.ID] = item.InStock
+	}
+
+	live := *version
+	live.Items = make([]*MenuItem, 0, len(version.Items))
+	for _, item := range version.Items {
+		liveItem := *item
+		if inStock, found := stock[item.ID]; found {
+			liveItem.InStock = inStock
+		}
+		live.Items = append(live.Items, &liveItem)
+	}
+	return &live
+}
@@ -2,28 +2,90 @@ package menu
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"minimart/internal/merchant"
+	"minimart/internal/shared/blobstore"
+	"minimart/internal/shared/eventbus"
+	"minimart/internal/shared/money"
 
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrMenuItemNotFound is returned when a menu item does not exist for the given merchant.
+	ErrMenuItemNotFound    = errors.New("menu item not found")
+	ErrMenuVersionNotFound = errors.New("menu version not found")
+	ErrNoPublishedMenu     = errors.New("merchant has no published menu")
+	ErrEmptyMenu           = errors.New("cannot publish an empty menu")
+)
+
+// MaxSearchResults caps the number of items a menu search returns.
+const MaxSearchResults = 50
+
 // MenuUsecase defines the interface for menu-related business logic.
 type MenuUsecase interface {
-	CreateMenuItem(ctx context.Context, merchantID uuid.UUID, name, description string, price int) (*MenuItem, error)
-	GetMenuForMerchant(ctx context.Context, merchantID uuid.UUID) ([]*MenuItem, error)
+	CreateMenuItem(ctx context.Context, merchantID uuid.UUID, name, description string, price money.Money, dietary DietaryInfo) (*MenuItem, error)
+	// GetMenuForMerchant returns the live menu customers order from, narrowed down by the filter.
+	// Deactivated merchants have no menu as far as customers are concerned.
+	GetMenuForMerchant(ctx context.Context, merchantID uuid.UUID, filter MenuFilter) (*MenuVersion, error)
+	// SearchMenuItems searches the live menus of all active merchants.
+	SearchMenuItems(ctx context.Context, filter MenuFilter) ([]*MenuItem, error)
+	// GetDraftMenu returns the menu the merchant is editing.
+	GetDraftMenu(ctx context.Context, merchantID uuid.UUID) ([]*MenuItem, error)
+	PublishMenu(ctx context.Context, merchantID uuid.UUID) (*MenuVersion, error)
+	ListMenuVersions(ctx context.Context, merchantID uuid.UUID) ([]*MenuVersion, error)
+	RollbackMenu(ctx context.Context, merchantID uuid.UUID, number int) (*MenuVersion, error)
+	UpdateMenuItem(ctx context.Context, merchantID, itemID uuid.UUID, changes MenuItemChanges) (*MenuItem, error)
+	UploadMenuItemImage(ctx context.Context, merchantID, itemID uuid.UUID, data []byte) (*MenuItem, error)
+}
+
+// MenuItemChanges holds the fields of a partial menu item update.
+// Nil fields are left untouched.
+type MenuItemChanges struct {
+	Name        *string
+	Description *string
+	Price       *money.Money
+	InStock     *bool
+	DietaryTags *[]DietaryTag
+	Allergens   *[]Allergen
+	Nutrition   *NutritionFacts
+}
+
+// MerchantReader is the part of the merchant module the menu depends on.
+type MerchantReader interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*merchant.Merchant, error)
 }
 
 type menuUsecase struct {
-	repo MenuRepository
+	repo      MenuRepository
+	merchants MerchantReader
+	blobs     blobstore.BlobStore
+	eventBus  eventbus.EventBus
+	logger    *slog.Logger
 }
 
 // NewMenuUsecase creates a new instance of MenuUsecase.
-func NewMenuUsecase(repo MenuRepository) MenuUsecase {
+func NewMenuUsecase(repo MenuRepository, merchants MerchantReader, blobs blobstore.BlobStore, eventBus eventbus.EventBus, logger *slog.Logger) MenuUsecase {
 	return &menuUsecase{
-		repo: repo,
+		repo:      repo,
+		merchants: merchants,
+		blobs:     blobs,
+		eventBus:  eventBus,
+		logger:    logger,
 	}
 }
 
-func (u *menuUsecase) CreateMenuItem(ctx context.Context, merchantID uuid.UUID, name, description string, price int) (*MenuItem, error) {
+func (u *menuUsecase) CreateMenuItem(ctx context.Context, merchantID uuid.UUID, name, description string, price money.Money, dietary DietaryInfo) (*MenuItem, error) {
+	if err := dietary.Normalize(); err != nil {
+		return nil, err
+	}
+	price, err := u.priceInMerchantCurrency(ctx, merchantID, price)
+	if err != nil {
+		return nil, err
+	}
+
 	item := &MenuItem{
 		ID:          uuid.New(),
 		MerchantID:  merchantID,
@@ -31,14 +93,277 @@ func (u *menuUsecase) CreateMenuItem(ctx context.Context, merchantID uuid.UUID,
 		Description: description,
 		Price:       price,
 		InStock:     true, // New items are in stock by default
+		DietaryInfo: dietary,
 	}
 
 	if err := u.repo.Save(ctx, item); err != nil {
 		return nil, err
 	}
+
+	u.publish(ctx, MenuItemCreatedEvent{
+		ItemID:     item.ID.String(),
+		MerchantID: item.MerchantID.String(),
+	})
 	return item, nil
 }
 
-func (u *menuUsecase) GetMenuForMerchant(ctx context.Context, merchantID uuid.UUID) ([]*MenuItem, error) {
+func (u *menuUsecase) GetMenuForMerchant(ctx context.Context, merchantID uuid.UUID, filter MenuFilter) (*MenuVersion, error) {
+	m, err := u.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if !m.AcceptsCustomers() {
+		return nil, merchant.ErrMerchantInactive
+	}
+
+	version, err := u.repo.GetLiveVersion(ctx, merchantID)
+	if errors.Is(err, ErrNoPublishedMenu) {
+		// Nothing published yet looks like an empty menu to customers.
+		return &MenuVersion{MerchantID: merchantID, Items: []*MenuItem{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The version may be shared with the cache, so filter a copy.
+	filtered := *version
+	filtered.Items = filter.Apply(version.Items)
+	return &filtered, nil
+}
+
+func (u *menuUsecase) SearchMenuItems(ctx context.Context, filter MenuFilter) ([]*MenuItem, error) {
+	items, err := u.repo.SearchLiveItems(ctx, filter, MaxSearchResults)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make(map[uuid.UUID]bool)
+	visible := make([]*MenuItem, 0, len(items))
+	for _, item := range items {
+		isActive, checked := active[item.MerchantID]
+		if !checked {
+			m, err := u.merchants.GetByID(ctx, item.MerchantID)
+			if err != nil && !errors.Is(err, merchant.ErrMerchantNotFound) {
+				return nil, err
+			}
+			isActive = err == nil && m.AcceptsCustomers()
+			active[item.MerchantID] = isActive
+		}
+		if isActive {
+			visible = append(visible, item)
+		}
+	}
+	return visible, nil
+}
+
+func (u *menuUsecase) GetDraftMenu(ctx context.Context, merchantID uuid.UUID) ([]*MenuItem, error) {
 	return u.repo.GetByMerchantID(ctx, merchantID)
 }
+
+// PublishMenu atomically replaces the live menu with a snapshot of the draft.
+func (u *menuUsecase) PublishMenu(ctx context.Context, merchantID uuid.UUID) (*MenuVersion, error) {
+	version, err := u.repo.PublishDraft(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	u.publishLiveVersionChanged(ctx, version)
+	return version, nil
+}
+
+func (u *menuUsecase) ListMenuVersions(ctx context.Context, merchantID uuid.UUID) ([]*MenuVersion, error) {
+	return u.repo.ListVersions(ctx, merchantID)
+}
+
+// RollbackMenu makes a previously published version live again. The draft is left untouched.
+func (u *menuUsecase) RollbackMenu(ctx context.Context, merchantID uuid.UUID, number int) (*MenuVersion, error) {
+	version, err := u.repo.SetLiveVersion(ctx, merchantID, number)
+	if err != nil {
+		return nil, err
+	}
+	u.publishLiveVersionChanged(ctx, version)
+	return version, nil
+}
+
+func (u *menuUsecase) publishLiveVersionChanged(ctx context.Context, version *MenuVersion) {
+	u.publish(ctx, MenuPublishedEvent{
+		MerchantID: version.MerchantID.String(),
+		VersionID:  version.ID.String(),
+		Number:     version.Number,
+	})
+}
+
+// publish announces a change that has already been stored. Failing the request
+// at this point would make clients retry a write that succeeded, so a failed
+// publish is logged instead; caches on other instances then catch up when their
+// entries expire.
+func (u *menuUsecase) publish(ctx context.Context, event eventbus.Event) {
+	if err := u.eventBus.Publish(ctx, event); err != nil {
+		u.logger.Error("Failed to publish menu event", "module", "menu", "topic", event.Topic(), "error", err)
+	}
+}
+
+// UpdateMenuItem applies a partial update to one of the merchant's menu items.
+func (u *menuUsecase) UpdateMenuItem(ctx context.Context, merchantID, itemID uuid.UUID, changes MenuItemChanges) (*MenuItem, error) {
+	item, err := u.getMerchantItem(ctx, merchantID, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	wasInStock := item.InStock
+	if changes.Name != nil {
+		item.Name = *changes.Name
+	}
+	if changes.Description != nil {
+		item.Description = *changes.Description
+	}
+	if changes.Price != nil {
+		price, err := u.priceInMerchantCurrency(ctx, merchantID, *changes.Price)
+		if err != nil {
+			return nil, err
+		}
+		item.Price = price
+	}
+	if changes.InStock != nil {
+		item.InStock = *changes.InStock
+	}
+	if changes.DietaryTags != nil {
+		item.DietaryTags = *changes.DietaryTags
+	}
+	if changes.Allergens != nil {
+		item.Allergens = *changes.Allergens
+	}
+	if changes.Nutrition != nil {
+		item.Nutrition = changes.Nutrition
+	}
+	if err := item.DietaryInfo.Normalize(); err != nil {
+		return nil, err
+	}
+
+	if err := u.repo.Update(ctx, item); err != nil {
+		return nil, err
+	}
+
+	var event eventbus.Event = MenuItemUpdatedEvent{
+		ItemID:     item.ID.String(),
+		MerchantID: item.MerchantID.String(),
+	}
+	if wasInStock && !item.InStock {
+		event = MenuItemOutOfStockEvent{
+			ItemID:     item.ID.String(),
+			MerchantID: item.MerchantID.String(),
+		}
+	}
+	u.publish(ctx, event)
+	return item, nil
+}
+
+// UploadMenuItemImage validates the image, stores it together with a thumbnail
+// and points the menu item at the new URLs. The image and thumbnail it
+// replaces are deleted unless a published version still shows them.
+func (u *menuUsecase) UploadMenuItemImage(ctx context.Context, merchantID, itemID uuid.UUID, data []byte) (*MenuItem, error) {
+	contentType, err := detectImageType(data)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := u.getMerchantItem(ctx, merchantID, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	thumbnail, err := makeThumbnail(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every upload gets a fresh key so CDNs and browsers never serve a stale image.
+	baseKey := fmt.Sprintf("menu/%s/%s/%s", merchantID, itemID, uuid.New())
+
+	imageKey, thumbnailKey := baseKey+imageExtensions[contentType], baseKey+"_thumb.jpg"
+	imageURL, err := u.blobs.Put(ctx, imageKey, data, contentType)
+	if err != nil {
+		return nil, err
+	}
+	thumbnailURL, err := u.blobs.Put(ctx, thumbnailKey, thumbnail, "image/jpeg")
+	if err != nil {
+		u.deleteBlobs(ctx, imageKey)
+		return nil, err
+	}
+
+	replaced := []string{item.ImageURL, item.ThumbnailURL}
+	item.ImageURL = imageURL
+	item.ThumbnailURL = thumbnailURL
+	if err := u.repo.Update(ctx, item); err != nil {
+		u.deleteBlobs(ctx, imageKey, thumbnailKey)
+		return nil, err
+	}
+	u.deleteUnpublishedImages(ctx, item, replaced...)
+
+	u.publish(ctx, MenuItemUpdatedEvent{
+		ItemID:     item.ID.String(),
+		MerchantID: item.MerchantID.String(),
+	})
+	return item, nil
+}
+
+// deleteBlobs deletes the blobs under keys, skipping empty ones. It runs after
+// the upload has failed or succeeded, so a blob it cannot delete is left behind
+// rather than failing the request.
+func (u *menuUsecase) deleteBlobs(ctx context.Context, keys ...string) {
+	ctx = context.WithoutCancel(ctx)
+	for _, key := range keys {
+		if key != "" {
+			_ = u.blobs.Delete(ctx, key)
+		}
+	}
+}
+
+// deleteUnpublishedImages deletes the blobs behind the item's former image
+// URLs that no published version references. Live and rollback versions keep
+// pointing at their images, so those blobs stay until the versions go away.
+func (u *menuUsecase) deleteUnpublishedImages(ctx context.Context, item *MenuItem, urls ...string) {
+	ctx = context.WithoutCancel(ctx)
+	for _, url := range urls {
+		key := itemBlobKey(item, url)
+		if key == "" {
+			continue
+		}
+		published, err := u.repo.IsImagePublished(ctx, item.ID, url)
+		if err != nil {
+			u.logger.Error("Failed to check whether a replaced image is published", "module", "menu", "item_id", item.ID, "error", err)
+			continue
+		}
+		if !published {
+			u.deleteBlobs(ctx, key)
+		}
+	}
+}
+
+// priceInMerchantCurrency makes sure a price is in the merchant's currency,
+// filling the currency in when the client omitted it.
+func (u *menuUsecase) priceInMerchantCurrency(ctx context.Context, merchantID uuid.UUID, price money.Money) (money.Money, error) {
+	m, err := u.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	if price.Currency == "" {
+		price.Currency = m.Currency
+	}
+	if price.Currency != m.Currency {
+		return money.Money{}, fmt.Errorf("%w: merchant prices are in %s", money.ErrCurrencyMismatch, m.Currency)
+	}
+	return price, nil
+}
+
+// getMerchantItem loads a menu item, treating items of other merchants as missing.
+func (u *menuUsecase) getMerchantItem(ctx context.Context, merchantID, itemID uuid.UUID) (*MenuItem, error) {
+	item, err := u.repo.GetByID(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if item.MerchantID != merchantID {
+		return nil, ErrMenuItemNotFound
+	}
+	return item, nil
+}
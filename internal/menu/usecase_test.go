@@ -0,0 +1,244 @@
+package menu
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"maps"
+	"minimart/internal/merchant"
+	"minimart/internal/shared/eventbus"
+	"minimart/internal/shared/money"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestMenuUsecase_DraftAndPublish(t *testing.T) {
+	ctx := context.Background()
+	merchants := merchant.NewInMemoryMerchantRepository()
+	seededMerchant := merchant.NewMerchant(uuid.New(), "Taco Stand", "", money.USD)
+	seededMerchant.Status = merchant.StatusApproved
+	seededMerchant.IsActive = true
+	require.NoError(t, merchants.Save(ctx, seededMerchant))
+	merchantID := seededMerchant.ID
+
+	usecase := NewMenuUsecase(NewInMemoryMenuRepository(), merchants, nil, eventbus.NewInMemoryEventBus(), discardLogger)
+
+	t.Run("should hide the draft until it is published", func(t *testing.T) {
+		_, err := usecase.CreateMenuItem(ctx, merchantID, "Taco", "", money.New(400, money.USD), DietaryInfo{})
+		require.NoError(t, err)
+
+		liveMenu, err := usecase.GetMenuForMerchant(ctx, merchantID, MenuFilter{})
+		require.NoError(t, err)
+		assert.Empty(t, liveMenu.Items)
+
+		version, err := usecase.PublishMenu(ctx, merchantID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, version.Number)
+
+		liveMenu, err = usecase.GetMenuForMerchant(ctx, merchantID, MenuFilter{})
+		require.NoError(t, err)
+		require.Len(t, liveMenu.Items, 1)
+		assert.Equal(t, "Taco", liveMenu.Items[0].Name)
+	})
+
+	t.Run("should keep serving the old version while the draft changes", func(t *testing.T) {
+		draft, err := usecase.GetDraftMenu(ctx, merchantID)
+		require.NoError(t, err)
+
+		newPrice := money.New(450, money.USD)
+		_, err = usecase.UpdateMenuItem(ctx, merchantID, draft[0].ID, MenuItemChanges{Price: &newPrice})
+		require.NoError(t, err)
+
+		liveMenu, err := usecase.GetMenuForMerchant(ctx, merchantID, MenuFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, money.New(400, money.USD), liveMenu.Items[0].Price)
+	})
+
+	t.Run("should roll back to an earlier version", func(t *testing.T) {
+		version, err := usecase.PublishMenu(ctx, merchantID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, version.Number)
+		assert.Equal(t, money.New(450, money.USD), version.Items[0].Price)
+
+		rolledBack, err := usecase.RollbackMenu(ctx, merchantID, 1)
+		require.NoError(t, err)
+		assert.Equal(t, money.New(400, money.USD), rolledBack.Items[0].Price)
+
+		versions, err := usecase.ListMenuVersions(ctx, merchantID)
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		assert.False(t, versions[0].IsLive)
+		assert.True(t, versions[1].IsLive)
+
+		_, err = usecase.RollbackMenu(ctx, merchantID, 7)
+		assert.ErrorIs(t, err, ErrMenuVersionNotFound)
+	})
+}
+
+func TestMenuUsecase_DeactivatedMerchant(t *testing.T) {
+	ctx := context.Background()
+	merchants := merchant.NewInMemoryMerchantRepository()
+	seededMerchant := merchant.NewMerchant(uuid.New(), "Closed Cafe", "", money.USD)
+	seededMerchant.Status = merchant.StatusApproved
+	seededMerchant.IsActive = true
+	require.NoError(t, merchants.Save(ctx, seededMerchant))
+
+	usecase := NewMenuUsecase(NewInMemoryMenuRepository(), merchants, nil, eventbus.NewInMemoryEventBus(), discardLogger)
+	_, err := usecase.CreateMenuItem(ctx, seededMerchant.ID, "Latte", "", money.New(350, money.USD), DietaryInfo{})
+	require.NoError(t, err)
+	_, err = usecase.PublishMenu(ctx, seededMerchant.ID)
+	require.NoError(t, err)
+
+	seededMerchant.IsActive = false
+
+	t.Run("should hide the menu from customers", func(t *testing.T) {
+		_, err := usecase.GetMenuForMerchant(ctx, seededMerchant.ID, MenuFilter{})
+		assert.ErrorIs(t, err, merchant.ErrMerchantInactive)
+	})
+
+	t.Run("should leave the merchant out of search results", func(t *testing.T) {
+		results, err := usecase.SearchMenuItems(ctx, MenuFilter{Query: "latte"})
+		require.NoError(t, err)
+		assert.Empty(t, results)
+	})
+}
+
+// unreachableEventBus fails every publish, like a Redis outage.
+type unreachableEventBus struct {
+	eventbus.EventBus
+}
+
+func (unreachableEventBus) Publish(ctx context.Context, event eventbus.Event) error {
+	return errors.New("connection refused")
+}
+
+func TestMenuUsecase_PublishFailure(t *testing.T) {
+	ctx := context.Background()
+	merchants := merchant.NewInMemoryMerchantRepository()
+	seededMerchant := merchant.NewMerchant(uuid.New(), "Flaky Diner", "", money.USD)
+	require.NoError(t, merchants.Save(ctx, seededMerchant))
+
+	repo := NewInMemoryMenuRepository()
+	usecase := NewMenuUsecase(repo, merchants, nil, unreachableEventBus{}, discardLogger)
+
+	t.Run("should keep the stored changes instead of failing the request", func(t *testing.T) {
+		item, err := usecase.CreateMenuItem(ctx, seededMerchant.ID, "Pancakes", "", money.New(600, money.USD), DietaryInfo{})
+		require.NoError(t, err)
+
+		name := "Blueberry Pancakes"
+		updated, err := usecase.UpdateMenuItem(ctx, seededMerchant.ID, item.ID, MenuItemChanges{Name: &name})
+		require.NoError(t, err)
+		assert.Equal(t, name, updated.Name)
+
+		version, err := usecase.PublishMenu(ctx, seededMerchant.ID)
+		require.NoError(t, err)
+		require.Len(t, version.Items, 1)
+		assert.Equal(t, name, version.Items[0].Name)
+	})
+}
+
+func TestMenuUsecase_UploadMenuItemImage(t *testing.T) {
+	ctx := context.Background()
+	merchants := merchant.NewInMemoryMerchantRepository()
+	seededMerchant := merchant.NewMerchant(uuid.New(), "Photo Deli", "", money.USD)
+	seededMerchant.Status = merchant.StatusApproved
+	seededMerchant.IsActive = true
+	require.NoError(t, merchants.Save(ctx, seededMerchant))
+
+	blobs := &memoryBlobStore{blobs: make(map[string][]byte)}
+	usecase := NewMenuUsecase(NewInMemoryMenuRepository(), merchants, blobs, eventbus.NewInMemoryEventBus(), discardLogger)
+	item, err := usecase.CreateMenuItem(ctx, seededMerchant.ID, "Bagel", "", money.New(300, money.USD), DietaryInfo{})
+	require.NoError(t, err)
+
+	var photo bytes.Buffer
+	require.NoError(t, png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 400, 300))))
+
+	t.Run("should delete the image and thumbnail it replaces", func(t *testing.T) {
+		first, err := usecase.UploadMenuItemImage(ctx, seededMerchant.ID, item.ID, photo.Bytes())
+		require.NoError(t, err)
+		firstURL := first.ImageURL
+		firstKeys := blobs.keys()
+		require.Len(t, firstKeys, 2)
+
+		second, err := usecase.UploadMenuItemImage(ctx, seededMerchant.ID, item.ID, photo.Bytes())
+		require.NoError(t, err)
+		assert.NotEqual(t, firstURL, second.ImageURL)
+		assert.Len(t, blobs.keys(), 2)
+		for _, key := range firstKeys {
+			assert.NotContains(t, blobs.keys(), key)
+		}
+	})
+
+	t.Run("should keep the images a published version still shows", func(t *testing.T) {
+		published, err := usecase.UploadMenuItemImage(ctx, seededMerchant.ID, item.ID, photo.Bytes())
+		require.NoError(t, err)
+		publishedImage, publishedThumbnail := published.ImageURL, published.ThumbnailURL
+		_, err = usecase.PublishMenu(ctx, seededMerchant.ID)
+		require.NoError(t, err)
+
+		replacement, err := usecase.UploadMenuItemImage(ctx, seededMerchant.ID, item.ID, photo.Bytes())
+		require.NoError(t, err)
+		draftImage, draftThumbnail := replacement.ImageURL, replacement.ThumbnailURL
+		assert.NotEqual(t, publishedImage, draftImage)
+
+		live, err := usecase.GetMenuForMerchant(ctx, seededMerchant.ID, MenuFilter{})
+		require.NoError(t, err)
+		require.Len(t, live.Items, 1)
+		assert.Equal(t, publishedImage, live.Items[0].ImageURL)
+		assert.Equal(t, publishedThumbnail, live.Items[0].ThumbnailURL)
+		assert.Contains(t, blobs.keys(), itemBlobKey(item, publishedImage))
+		assert.Contains(t, blobs.keys(), itemBlobKey(item, publishedThumbnail))
+
+		// An image only the draft used is still deleted once it is replaced.
+		_, err = usecase.UploadMenuItemImage(ctx, seededMerchant.ID, item.ID, photo.Bytes())
+		require.NoError(t, err)
+		assert.NotContains(t, blobs.keys(), itemBlobKey(item, draftImage))
+		assert.NotContains(t, blobs.keys(), itemBlobKey(item, draftThumbnail))
+		assert.Len(t, blobs.keys(), 4)
+	})
+
+	t.Run("should not leave the image behind when the thumbnail cannot be stored", func(t *testing.T) {
+		before := blobs.keys()
+		blobs.failPut = errors.New("storage unavailable")
+		defer func() { blobs.failPut = nil }()
+
+		_, err := usecase.UploadMenuItemImage(ctx, seededMerchant.ID, item.ID, photo.Bytes())
+		assert.ErrorIs(t, err, blobs.failPut)
+		assert.ElementsMatch(t, before, blobs.keys())
+	})
+}
+
+// memoryBlobStore keeps blobs in memory. With failPut set, it stores images
+// but fails to store thumbnails.
+type memoryBlobStore struct {
+	blobs   map[string][]byte
+	failPut error
+}
+
+func (s *memoryBlobStore) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	if s.failPut != nil && strings.HasSuffix(key, "_thumb.jpg") {
+		return "", s.failPut
+	}
+	s.blobs[key] = data
+	return "https://cdn.example.com/" + key, nil
+}
+
+func (s *memoryBlobStore) Delete(ctx context.Context, key string) error {
+	delete(s.blobs, key)
+	return nil
+}
+
+func (s *memoryBlobStore) keys() []string {
+	return slices.Collect(maps.Keys(s.blobs))
+}
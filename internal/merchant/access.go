@@ -0,0 +1,142 @@
+package merchant
+
+import (
+	"context"
+	"errors"
+	middlerware "minimart/internal/shared/middleware"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ErrForbidden is returned when a user may not manage a merchant.
+var ErrForbidden = errors.New("not allowed to manage this merchant")
+
+// AccessPolicy decides whether a user may take an action on a merchant.
+type AccessPolicy interface {
+	// Authorize returns nil when access is granted, ErrForbidden when it is not
+	// and ErrMerchantNotFound when the merchant does not exist.
+	Authorize(ctx context.Context, userID, merchantID uuid.UUID, permission Permission) error
+}
+
+type ownerAccessPolicy struct {
+	merchants MerchantRepository
+}
+
+// NewOwnerAccessPolicy creates an AccessPolicy that only lets a merchant's owner in.
+func NewOwnerAccessPolicy(merchants MerchantRepository) AccessPolicy {
+	return &ownerAccessPolicy{merchants: merchants}
+}
+
+func (p *ownerAccessPolicy) Authorize(ctx context.Context, userID, merchantID uuid.UUID, permission Permission) error {
+	merchant, err := p.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return err
+	}
+	if merchant.OwnerID == uuid.Nil || merchant.OwnerID != userID {
+		return ErrForbidden
+	}
+	return nil
+}
+
+type membershipAccessPolicy struct {
+	merchants MerchantRepository
+	members   MembershipRepository
+}
+
+// NewMembershipAccessPolicy creates an AccessPolicy that lets a merchant's
+// owner and staff in, each limited to what their role allows.
+func NewMembershipAccessPolicy(merchants MerchantRepository, members MembershipRepository) AccessPolicy {
+	return &membershipAccessPolicy{merchants: merchants, members: members}
+}
+
+func (p *membershipAccessPolicy) Authorize(ctx context.Context, userID, merchantID uuid.UUID, permission Permission) error {
+	merchant, err := p.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return err
+	}
+	role, err := roleOf(ctx, p.members, merchant, userID)
+	if err != nil {
+		return err
+	}
+	if !role.Can(permission) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// roleOf returns the user's role at the merchant, or ErrForbidden when they
+// do not work there.
+func roleOf(ctx context.Context, members MembershipRepository, merchant *Merchant, userID uuid.UUID) (Role, error) {
+	if merchant.OwnerID != uuid.Nil && merchant.OwnerID == userID {
+		return RoleOwner, nil
+	}
+	membership, err := members.GetMembership(ctx, merchant.ID, userID)
+	if errors.Is(err, ErrMembershipNotFound) {
+		return "", ErrForbidden
+	}
+	if err != nil {
+		return "", err
+	}
+	return membership.Role, nil
+}
+
+// AccessGuard returns a middleware that requires the given permission on the
+// merchant in the :merchantID route parameter.
+type AccessGuard func(permission Permission) fiber.Handler
+
+// RequireAccess creates an AccessGuard backed by policy. Only users whose
+// platform role may manage merchants get as far as the policy; API keys are
+// let through to their own merchant where a scope of theirs allows it. Its
+// middlewares must run after middlerware.AuthRequire or middlerware.Authenticate.
+func RequireAccess(policy AccessPolicy) AccessGuard {
+	return func(permission Permission) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			principal, err := middlerware.PrincipalFrom(c)
+			if err != nil {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+			}
+			if principal.Kind == middlerware.PrincipalAPIKey {
+				return requireAPIKeyAccess(c, principal, permission)
+			}
+			userID, err := middlerware.UserID(c)
+			if err != nil {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+			}
+			if !middlerware.Can(c, middlerware.PermManageMerchants) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Your role does not allow this"})
+			}
+			merchantID, err := uuid.Parse(c.Params("merchantID"))
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+			}
+
+			if err := policy.Authorize(c.Context(), userID, merchantID, permission); err != nil {
+				switch {
+				case errors.Is(err, ErrMerchantNotFound):
+					return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+				case errors.Is(err, ErrForbidden):
+					return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+				}
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+			}
+			return c.Next()
+		}
+	}
+}
+
+// requireAPIKeyAccess lets an API key through to its own merchant when one of
+// its scopes allows permission.
+func requireAPIKeyAccess(c *fiber.Ctx, principal *middlerware.Principal, permission Permission) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+	if merchantID != principal.MerchantID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": ErrForbidden.Error()})
+	}
+	if !scopesGrant(principal.Scopes, permission) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "API key scopes do not allow this"})
+	}
+	return c.Next()
+}
@@ -0,0 +1,130 @@
+package merchant
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	middlerware "minimart/internal/shared/middleware"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireAccess(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryMerchantRepository()
+	ownerID := uuid.New()
+	ownedMerchant := NewMerchant(ownerID, "Owned", "", DefaultCurrency)
+	require.NoError(t, repo.Save(ctx, ownedMerchant))
+
+	// Stand in for AuthRequire by storing the claims it would have stored.
+	authenticateAs := func(userID uuid.UUID, role middlerware.Role) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			c.Locals("user", &middlerware.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: userID.String()}, Role: role})
+			return c.Next()
+		}
+	}
+	newAppAs := func(userID uuid.UUID, role middlerware.Role) *fiber.App {
+		app := fiber.New()
+		app.Post("/merchants/:merchantID/menu", authenticateAs(userID, role), RequireAccess(NewOwnerAccessPolicy(repo))(PermManageMenu), func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusCreated)
+		})
+		return app
+	}
+	newApp := func(userID uuid.UUID) *fiber.App {
+		return newAppAs(userID, middlerware.RoleMerchant)
+	}
+	status := func(app *fiber.App, merchantID string) int {
+		resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/merchants/"+merchantID+"/menu", nil))
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	t.Run("should let the owner through", func(t *testing.T) {
+		assert.Equal(t, http.StatusCreated, status(newApp(ownerID), ownedMerchant.ID.String()))
+	})
+
+	t.Run("should forbid other users", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, status(newApp(uuid.New()), ownedMerchant.ID.String()))
+	})
+
+	t.Run("should forbid owners whose role does not allow managing merchants", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, status(newAppAs(ownerID, middlerware.RoleCustomer), ownedMerchant.ID.String()))
+	})
+
+	t.Run("should report unknown merchants", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, status(newApp(ownerID), uuid.New().String()))
+		assert.Equal(t, http.StatusBadRequest, status(newApp(ownerID), "not-a-uuid"))
+	})
+
+	t.Run("should reject unauthenticated requests", func(t *testing.T) {
+		app := fiber.New()
+		app.Post("/merchants/:merchantID/menu", RequireAccess(NewOwnerAccessPolicy(repo))(PermManageMenu), func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusCreated)
+		})
+		assert.Equal(t, http.StatusUnauthorized, status(app, ownedMerchant.ID.String()))
+	})
+
+	// Stand in for Authenticate by storing the principal of an API key.
+	newAppAsKey := func(merchantID uuid.UUID, scopes ...string) *fiber.App {
+		app := fiber.New()
+		app.Post("/merchants/:merchantID/menu", func(c *fiber.Ctx) error {
+			c.Locals("principal", &middlerware.Principal{Kind: middlerware.PrincipalAPIKey, ID: uuid.NewString(), MerchantID: merchantID, Scopes: scopes})
+			return c.Next()
+		}, RequireAccess(NewOwnerAccessPolicy(repo))(PermManageMenu), func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusCreated)
+		})
+		return app
+	}
+
+	t.Run("should let API keys with the scope through to their merchant", func(t *testing.T) {
+		assert.Equal(t, http.StatusCreated, status(newAppAsKey(ownedMerchant.ID, "orders:read", "menu:write"), ownedMerchant.ID.String()))
+	})
+
+	t.Run("should forbid API keys without the scope or of another merchant", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, status(newAppAsKey(ownedMerchant.ID, "orders:read"), ownedMerchant.ID.String()))
+		assert.Equal(t, http.StatusForbidden, status(newAppAsKey(uuid.New(), "menu:write"), ownedMerchant.ID.String()))
+	})
+}
+
+func TestMembershipAccessPolicy(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryMerchantRepository()
+	members := NewInMemoryMembershipRepository()
+	policy := NewMembershipAccessPolicy(repo, members)
+
+	ownerID := uuid.New()
+	shop := NewMerchant(ownerID, "Shop", "", DefaultCurrency)
+	require.NoError(t, repo.Save(ctx, shop))
+
+	join := func(role Role) uuid.UUID {
+		userID := uuid.New()
+		invite, _, err := NewInvite(shop.ID, userID.String()+"@example.com", role, ownerID)
+		require.NoError(t, err)
+		require.NoError(t, members.SaveInvite(ctx, invite))
+		require.NoError(t, members.AcceptInvite(ctx, invite, Membership{MerchantID: shop.ID, UserID: userID, Role: role}))
+		return userID
+	}
+	managerID, kitchenID := join(RoleManager), join(RoleKitchen)
+
+	t.Run("should grant each role its permissions", func(t *testing.T) {
+		assert.NoError(t, policy.Authorize(ctx, ownerID, shop.ID, PermManageStaff))
+		assert.NoError(t, policy.Authorize(ctx, managerID, shop.ID, PermManageMenu))
+		assert.NoError(t, policy.Authorize(ctx, kitchenID, shop.ID, PermUpdateOrders))
+	})
+
+	t.Run("should keep kitchen staff away from prices", func(t *testing.T) {
+		assert.ErrorIs(t, policy.Authorize(ctx, kitchenID, shop.ID, PermManageMenu), ErrForbidden)
+		assert.ErrorIs(t, policy.Authorize(ctx, kitchenID, shop.ID, PermManageStaff), ErrForbidden)
+	})
+
+	t.Run("should forbid users who do not work for the merchant", func(t *testing.T) {
+		assert.ErrorIs(t, policy.Authorize(ctx, uuid.New(), shop.ID, PermViewOrders), ErrForbidden)
+		assert.ErrorIs(t, policy.Authorize(ctx, ownerID, uuid.New(), PermViewOrders), ErrMerchantNotFound)
+	})
+}
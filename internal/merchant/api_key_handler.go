@@ -0,0 +1,144 @@
+package merchant
+
+import (
+	"errors"
+	"fmt"
+	middlerware "minimart/internal/shared/middleware"
+	"minimart/internal/shared/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type APIKeyHandler struct {
+	usecase APIKeyUsecase
+	auth    fiber.Handler
+	access  AccessGuard
+}
+
+// NewAPIKeyHandler creates a new APIKeyHandler. Its routes run auth and then
+// access, normally middlerware.AuthRequire and RequireAccess.
+func NewAPIKeyHandler(usecase APIKeyUsecase, auth fiber.Handler, access AccessGuard) *APIKeyHandler {
+	return &APIKeyHandler{usecase: usecase, auth: auth, access: access}
+}
+
+func (h *APIKeyHandler) RegisterRoutes(app *fiber.App) {
+	manageKeys := h.access(PermManageAPIKeys)
+	app.Post("/merchants/:merchantID/api-keys", h.auth, manageKeys, h.CreateAPIKey)
+	app.Get("/merchants/:merchantID/api-keys", h.auth, manageKeys, h.ListAPIKeys)
+	app.Post("/merchants/:merchantID/api-keys/:keyID/rotate", h.auth, manageKeys, h.RotateAPIKey)
+	app.Delete("/merchants/:merchantID/api-keys/:keyID", h.auth, manageKeys, h.RevokeAPIKey)
+}
+
+// CreateAPIKeyRequest defines the JSON request body for creating an API key.
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+func (r CreateAPIKeyRequest) Validate() error {
+	var v validation.Validator
+	v.Required("name", r.Name)
+	v.MaxLength("name", r.Name, validation.MaxNameLength)
+	v.Check(len(r.Scopes) > 0, "scopes", "must contain at least one scope")
+	for i, scope := range r.Scopes {
+		if _, err := ParseAPIKeyScope(scope); err != nil {
+			v.Add(fmt.Sprintf("scopes[%d]", i), err.Error())
+		}
+	}
+	return v.Err()
+}
+
+// CreateAPIKey handles creating an API key. The key is only ever part of
+// this response.
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	creatorID, err := middlerware.UserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
+	scopes := make([]APIKeyScope, 0, len(req.Scopes))
+	for _, name := range req.Scopes {
+		scope, _ := ParseAPIKeyScope(name)
+		scopes = append(scopes, scope)
+	}
+
+	apiKey, key, err := h.usecase.CreateAPIKey(c.Context(), creatorID, merchantID, req.Name, scopes)
+	if err != nil {
+		return apiKeyErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"api_key": apiKey, "key": key})
+}
+
+// ListAPIKeys handles listing a merchant's API keys, without their secrets.
+func (h *APIKeyHandler) ListAPIKeys(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	keys, err := h.usecase.ListAPIKeys(c.Context(), merchantID)
+	if err != nil {
+		return apiKeyErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(keys)
+}
+
+// RotateAPIKey handles replacing an API key's secret.
+func (h *APIKeyHandler) RotateAPIKey(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+	keyID, err := uuid.Parse(c.Params("keyID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid API key ID"})
+	}
+
+	apiKey, key, err := h.usecase.RotateAPIKey(c.Context(), merchantID, keyID)
+	if err != nil {
+		return apiKeyErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"api_key": apiKey, "key": key})
+}
+
+// RevokeAPIKey handles revoking an API key for good.
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+	keyID, err := uuid.Parse(c.Params("keyID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid API key ID"})
+	}
+
+	if err := h.usecase.RevokeAPIKey(c.Context(), merchantID, keyID); err != nil {
+		return apiKeyErrorResponse(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// apiKeyErrorResponse maps the errors of the API key endpoints to HTTP responses.
+func apiKeyErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrMerchantNotFound), errors.Is(err, ErrAPIKeyNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrAPIKeyRevoked):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
@@ -0,0 +1,123 @@
+package merchant
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type APIKeyRepository interface {
+	SaveAPIKey(ctx context.Context, key *APIKey) error
+	// GetAPIKey returns ErrAPIKeyNotFound when the merchant has no such key.
+	GetAPIKey(ctx context.Context, merchantID, keyID uuid.UUID) (*APIKey, error)
+	// GetAPIKeyByPrefix returns ErrAPIKeyNotFound when no key has the prefix.
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*APIKey, error)
+	// ListAPIKeys returns the merchant's keys, revoked ones included, oldest first.
+	ListAPIKeys(ctx context.Context, merchantID uuid.UUID) ([]APIKey, error)
+	// RotateAPIKey stores the key's new prefix and secret. It returns
+	// ErrAPIKeyRevoked when the key was revoked meanwhile.
+	RotateAPIKey(ctx context.Context, key *APIKey) error
+	// RevokeAPIKey revokes the key unless it already was. It returns
+	// ErrAPIKeyNotFound when the merchant has no such key.
+	RevokeAPIKey(ctx context.Context, merchantID, keyID uuid.UUID, at time.Time) error
+	// TouchAPIKey records that the key was used at the given time.
+	TouchAPIKey(ctx context.Context, keyID uuid.UUID, at time.Time) error
+}
+
+type InMemoryAPIKeyRepository struct {
+	mu   sync.Mutex
+	keys map[uuid.UUID]APIKey
+}
+
+func NewInMemoryAPIKeyRepository() *InMemoryAPIKeyRepository {
+	return &InMemoryAPIKeyRepository{keys: make(map[uuid.UUID]APIKey)}
+}
+
+func (r *InMemoryAPIKeyRepository) SaveAPIKey(ctx context.Context, key *APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[key.ID] = *key
+	return nil
+}
+
+func (r *InMemoryAPIKeyRepository) GetAPIKey(ctx context.Context, merchantID, keyID uuid.UUID) (*APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, exists := r.keys[keyID]
+	if !exists || key.MerchantID != merchantID {
+		return nil, ErrAPIKeyNotFound
+	}
+	return &key, nil
+}
+
+func (r *InMemoryAPIKeyRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range r.keys {
+		if key.Prefix == prefix {
+			return &key, nil
+		}
+	}
+	return nil, ErrAPIKeyNotFound
+}
+
+func (r *InMemoryAPIKeyRepository) ListAPIKeys(ctx context.Context, merchantID uuid.UUID) ([]APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := []APIKey{}
+	for _, key := range r.keys {
+		if key.MerchantID == merchantID {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+func (r *InMemoryAPIKeyRepository) RotateAPIKey(ctx context.Context, key *APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, exists := r.keys[key.ID]
+	if !exists {
+		return ErrAPIKeyNotFound
+	}
+	if stored.Revoked() {
+		return ErrAPIKeyRevoked
+	}
+	stored.Prefix = key.Prefix
+	stored.SecretHash = key.SecretHash
+	stored.RotatedAt = key.RotatedAt
+	r.keys[key.ID] = stored
+	return nil
+}
+
+func (r *InMemoryAPIKeyRepository) RevokeAPIKey(ctx context.Context, merchantID, keyID uuid.UUID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, exists := r.keys[keyID]
+	if !exists || key.MerchantID != merchantID {
+		return ErrAPIKeyNotFound
+	}
+	if key.RevokedAt == nil {
+		key.RevokedAt = &at
+		r.keys[keyID] = key
+	}
+	return nil
+}
+
+func (r *InMemoryAPIKeyRepository) TouchAPIKey(ctx context.Context, keyID uuid.UUID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, exists := r.keys[keyID]
+	if !exists {
+		return ErrAPIKeyNotFound
+	}
+	key.LastUsedAt = &at
+	r.keys[keyID] = key
+	return nil
+}
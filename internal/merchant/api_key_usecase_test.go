@@ -0,0 +1,134 @@
+package merchant
+
+import (
+	"context"
+	middlerware "minimart/internal/shared/middleware"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyUsecase(t *testing.T) {
+	ctx := context.Background()
+	merchants := NewInMemoryMerchantRepository()
+	members := NewInMemoryMembershipRepository()
+	keys := NewInMemoryAPIKeyRepository()
+	usecase := NewAPIKeyUsecase(merchants, members, keys).(*apiKeyUsecase)
+	clock := time.Now()
+	usecase.now = func() time.Time { return clock }
+
+	ownerID := uuid.New()
+	shop := NewMerchant(ownerID, "Shop", "", DefaultCurrency)
+	require.NoError(t, merchants.Save(ctx, shop))
+
+	t.Run("should authenticate new keys as their merchant", func(t *testing.T) {
+		apiKey, key, err := usecase.CreateAPIKey(ctx, ownerID, shop.ID, "POS", []APIKeyScope{ScopeReadOrders, ScopeWriteMenu})
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(key, "mk_"+apiKey.Prefix+"_"), key)
+
+		principal, err := usecase.AuthenticateAPIKey(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, &middlerware.Principal{
+			Kind:       middlerware.PrincipalAPIKey,
+			ID:         apiKey.ID.String(),
+			MerchantID: shop.ID,
+			Scopes:     []string{"orders:read", "menu:write"},
+		}, principal)
+	})
+
+	t.Run("should reject unknown and malformed keys", func(t *testing.T) {
+		_, key, err := usecase.CreateAPIKey(ctx, ownerID, shop.ID, "POS", []APIKeyScope{ScopeReadOrders})
+		require.NoError(t, err)
+
+		for _, wrong := range []string{key + "x", "mk_" + key[3:11] + "_guess", "not-a-key", ""} {
+			_, err := usecase.AuthenticateAPIKey(ctx, wrong)
+			assert.ErrorIs(t, err, middlerware.ErrInvalidAPIKey, wrong)
+		}
+	})
+
+	t.Run("should record when keys were last used", func(t *testing.T) {
+		apiKey, key, err := usecase.CreateAPIKey(ctx, ownerID, shop.ID, "Kiosk", []APIKeyScope{ScopeReadOrders})
+		require.NoError(t, err)
+		lastUsed := func() *time.Time {
+			stored, err := keys.GetAPIKey(ctx, shop.ID, apiKey.ID)
+			require.NoError(t, err)
+			return stored.LastUsedAt
+		}
+		assert.Nil(t, lastUsed())
+
+		first := clock
+		_, err = usecase.AuthenticateAPIKey(ctx, key)
+		require.NoError(t, err)
+		require.NotNil(t, lastUsed())
+		assert.Equal(t, first, *lastUsed())
+
+		defer func() { usecase.now = func() time.Time { return clock } }()
+		usecase.now = func() time.Time { return first.Add(APIKeyLastUsedInterval / 2) }
+		_, err = usecase.AuthenticateAPIKey(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, first, *lastUsed(), "uses close together are recorded once")
+
+		later := first.Add(APIKeyLastUsedInterval)
+		usecase.now = func() time.Time { return later }
+		_, err = usecase.AuthenticateAPIKey(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, later, *lastUsed())
+	})
+
+	t.Run("should stop accepting the old secret after rotation", func(t *testing.T) {
+		apiKey, oldKey, err := usecase.CreateAPIKey(ctx, ownerID, shop.ID, "POS", []APIKeyScope{ScopeWriteOrders})
+		require.NoError(t, err)
+
+		rotated, newKey, err := usecase.RotateAPIKey(ctx, shop.ID, apiKey.ID)
+		require.NoError(t, err)
+		assert.Equal(t, apiKey.ID, rotated.ID)
+		assert.NotEqual(t, oldKey, newKey)
+		require.NotNil(t, rotated.RotatedAt)
+
+		_, err = usecase.AuthenticateAPIKey(ctx, oldKey)
+		assert.ErrorIs(t, err, middlerware.ErrInvalidAPIKey)
+		principal, err := usecase.AuthenticateAPIKey(ctx, newKey)
+		require.NoError(t, err)
+		assert.Equal(t, apiKey.ID.String(), principal.ID)
+	})
+
+	t.Run("should stop accepting revoked keys", func(t *testing.T) {
+		apiKey, key, err := usecase.CreateAPIKey(ctx, ownerID, shop.ID, "Old POS", []APIKeyScope{ScopeReadOrders})
+		require.NoError(t, err)
+
+		require.NoError(t, usecase.RevokeAPIKey(ctx, shop.ID, apiKey.ID))
+		require.NoError(t, usecase.RevokeAPIKey(ctx, shop.ID, apiKey.ID), "revoking twice is harmless")
+		_, err = usecase.AuthenticateAPIKey(ctx, key)
+		assert.ErrorIs(t, err, middlerware.ErrInvalidAPIKey)
+
+		_, _, err = usecase.RotateAPIKey(ctx, shop.ID, apiKey.ID)
+		assert.ErrorIs(t, err, ErrAPIKeyRevoked)
+
+		list, err := usecase.ListAPIKeys(ctx, shop.ID)
+		require.NoError(t, err)
+		for _, listed := range list {
+			if listed.ID == apiKey.ID {
+				assert.NotNil(t, listed.RevokedAt)
+				return
+			}
+		}
+		t.Fatal("revoked keys stay listed")
+	})
+
+	t.Run("should keep keys to their own merchant", func(t *testing.T) {
+		other := NewMerchant(uuid.New(), "Other", "", DefaultCurrency)
+		require.NoError(t, merchants.Save(ctx, other))
+		apiKey, _, err := usecase.CreateAPIKey(ctx, ownerID, shop.ID, "POS", []APIKeyScope{ScopeReadOrders})
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, usecase.RevokeAPIKey(ctx, other.ID, apiKey.ID), ErrAPIKeyNotFound)
+		_, _, err = usecase.RotateAPIKey(ctx, other.ID, apiKey.ID)
+		assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+		_, _, err = usecase.CreateAPIKey(ctx, ownerID, other.ID, "POS", []APIKeyScope{ScopeReadOrders})
+		assert.ErrorIs(t, err, ErrForbidden)
+	})
+}
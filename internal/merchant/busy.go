@@ -0,0 +1,116 @@
+package merchant
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// MaxPauseDuration is the longest a merchant can stop taking orders for at once.
+const MaxPauseDuration = 12 * time.Hour
+
+var ErrInvalidPauseDuration = errors.New("pause must last between 1 minute and 12 hours")
+
+// BusyStatus is whether a merchant is taking orders right now.
+type BusyStatus struct {
+	MerchantID uuid.UUID
+	// PausedUntil is when a paused merchant starts taking orders again, or nil
+	// if it is not paused.
+	PausedUntil *time.Time
+	// MaxOpenOrders is the merchant's cap on open orders; zero means no cap.
+	MaxOpenOrders int
+}
+
+// PauseStore holds merchants' pauses. Pauses end by themselves once they
+// expire, and every server instance sees the same pauses.
+type PauseStore interface {
+	// Pause stops the merchant taking orders until the given time, replacing any earlier pause.
+	Pause(ctx context.Context, merchantID uuid.UUID, until time.Time) error
+	Resume(ctx context.Context, merchantID uuid.UUID) error
+	// PausedUntil returns when the merchant's pause ends and whether it is paused at all.
+	PausedUntil(ctx context.Context, merchantID uuid.UUID) (time.Time, bool, error)
+}
+
+// RedisPauseStore is a PauseStore that keeps each pause in a Redis key that
+// expires when the pause ends.
+type RedisPauseStore struct {
+	client *redis.Client
+}
+
+// NewRedisPauseStore creates a new RedisPauseStore.
+func NewRedisPauseStore(client *redis.Client) PauseStore {
+	return &RedisPauseStore{client: client}
+}
+
+func (s *RedisPauseStore) Pause(ctx context.Context, merchantID uuid.UUID, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return s.Resume(ctx, merchantID)
+	}
+	return s.client.Set(ctx, pauseKey(merchantID), until.UTC().Format(time.RFC3339Nano), ttl).Err()
+}
+
+func (s *RedisPauseStore) Resume(ctx context.Context, merchantID uuid.UUID) error {
+	return s.client.Del(ctx, pauseKey(merchantID)).Err()
+}
+
+func (s *RedisPauseStore) PausedUntil(ctx context.Context, merchantID uuid.UUID) (time.Time, bool, error) {
+	value, err := s.client.Get(ctx, pauseKey(merchantID)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return time.Time{}, false, nil
+		}
+		return time.Time{}, false, err
+	}
+	until, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return until, true, nil
+}
+
+func pauseKey(merchantID uuid.UUID) string {
+	return "merchant:" + merchantID.String() + ":paused"
+}
+
+// InMemoryPauseStore is a PauseStore for tests and single-instance setups.
+type InMemoryPauseStore struct {
+	mu     sync.RWMutex
+	pauses map[uuid.UUID]time.Time
+}
+
+// NewInMemoryPauseStore creates a new InMemoryPauseStore.
+func NewInMemoryPauseStore() *InMemoryPauseStore {
+	return &InMemoryPauseStore{pauses: make(map[uuid.UUID]time.Time)}
+}
+
+func (s *InMemoryPauseStore) Pause(ctx context.Context, merchantID uuid.UUID, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pauses[merchantID] = until
+	return nil
+}
+
+func (s *InMemoryPauseStore) Resume(ctx context.Context, merchantID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.pauses, merchantID)
+	return nil
+}
+
+func (s *InMemoryPauseStore) PausedUntil(ctx context.Context, merchantID uuid.UUID) (time.Time, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	until, found := s.pauses[merchantID]
+	if !found || !time.Now().Before(until) {
+		return time.Time{}, false, nil
+	}
+	return until, true, nil
+}
@@ -0,0 +1,105 @@
+package merchant
+
+import (
+	"errors"
+	"fmt"
+	"minimart/internal/shared/validation"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type BusyHandler struct {
+	usecase BusyUsecase
+	auth    fiber.Handler
+	access  AccessGuard
+}
+
+// NewBusyHandler creates a new BusyHandler. Pausing runs auth and then access,
+// normally middlerware.Authenticate and RequireAccess.
+func NewBusyHandler(usecase BusyUsecase, auth fiber.Handler, access AccessGuard) *BusyHandler {
+	return &BusyHandler{usecase: usecase, auth: auth, access: access}
+}
+
+func (h *BusyHandler) RegisterRoutes(app *fiber.App) {
+	// Customers can see whether a merchant is taking orders
+	app.Get("/merchants/:merchantID/busy", h.GetBusyStatus)
+
+	pause := h.access(PermPauseOrders)
+	app.Post("/merchants/:merchantID/pause", h.auth, pause, h.PauseOrders)
+	app.Post("/merchants/:merchantID/resume", h.auth, pause, h.ResumeOrders)
+}
+
+// PauseOrdersRequest defines the JSON request body for pausing a merchant.
+type PauseOrdersRequest struct {
+	DurationMinutes int `json:"duration_minutes"`
+}
+
+func (r PauseOrdersRequest) Validate() error {
+	var v validation.Validator
+	maxMinutes := int(MaxPauseDuration / time.Minute)
+	v.Check(r.DurationMinutes >= 1 && r.DurationMinutes <= maxMinutes, "duration_minutes", fmt.Sprintf("must be between 1 and %d", maxMinutes))
+	return v.Err()
+}
+
+// PauseOrders handles a merchant stopping new orders for a while.
+func (h *BusyHandler) PauseOrders(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	var req PauseOrdersRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
+
+	status, err := h.usecase.PauseOrders(c.Context(), merchantID, time.Duration(req.DurationMinutes)*time.Minute)
+	if err != nil {
+		return busyErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(status)
+}
+
+// ResumeOrders handles a merchant taking orders again before its pause ends.
+func (h *BusyHandler) ResumeOrders(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	status, err := h.usecase.ResumeOrders(c.Context(), merchantID)
+	if err != nil {
+		return busyErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(status)
+}
+
+// GetBusyStatus handles fetching whether a merchant is paused.
+func (h *BusyHandler) GetBusyStatus(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	status, err := h.usecase.GetBusyStatus(c.Context(), merchantID)
+	if err != nil {
+		return busyErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(status)
+}
+
+// busyErrorResponse maps the errors shared by the busy mode endpoints to HTTP responses.
+func busyErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrMerchantNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrInvalidPauseDuration):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
@@ -0,0 +1,72 @@
+package merchant
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BusyUsecase lets merchants stop taking orders for a while when the kitchen
+// cannot keep up. The cap on open orders is part of the merchant's profile.
+type BusyUsecase interface {
+	// PauseOrders stops the merchant taking orders for the given duration, after
+	// which it starts again by itself. Pausing again replaces the earlier pause.
+	PauseOrders(ctx context.Context, merchantID uuid.UUID, duration time.Duration) (*BusyStatus, error)
+	ResumeOrders(ctx context.Context, merchantID uuid.UUID) (*BusyStatus, error)
+	GetBusyStatus(ctx context.Context, merchantID uuid.UUID) (*BusyStatus, error)
+}
+
+type busyUsecase struct {
+	merchants MerchantRepository
+	pauses    PauseStore
+}
+
+func NewBusyUsecase(merchants MerchantRepository, pauses PauseStore) BusyUsecase {
+	return &busyUsecase{merchants: merchants, pauses: pauses}
+}
+
+func (u *busyUsecase) PauseOrders(ctx context.Context, merchantID uuid.UUID, duration time.Duration) (*BusyStatus, error) {
+	if duration < time.Minute || duration > MaxPauseDuration {
+		return nil, ErrInvalidPauseDuration
+	}
+	merchant, err := u.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+
+	until := time.Now().Add(duration)
+	if err := u.pauses.Pause(ctx, merchantID, until); err != nil {
+		return nil, err
+	}
+	return &BusyStatus{MerchantID: merchantID, PausedUntil: &until, MaxOpenOrders: merchant.MaxOpenOrders}, nil
+}
+
+func (u *busyUsecase) ResumeOrders(ctx context.Context, merchantID uuid.UUID) (*BusyStatus, error) {
+	merchant, err := u.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.pauses.Resume(ctx, merchantID); err != nil {
+		return nil, err
+	}
+	return &BusyStatus{MerchantID: merchantID, MaxOpenOrders: merchant.MaxOpenOrders}, nil
+}
+
+func (u *busyUsecase) GetBusyStatus(ctx context.Context, merchantID uuid.UUID) (*BusyStatus, error) {
+	merchant, err := u.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &BusyStatus{MerchantID: merchantID, MaxOpenOrders: merchant.MaxOpenOrders}
+	until, paused, err := u.pauses.PausedUntil(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if paused {
+		status.PausedUntil = &until
+	}
+	return status, nil
+}
@@ -0,0 +1,60 @@
+package merchant
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBusyUsecase(t *testing.T) {
+	ctx := context.Background()
+	merchants := NewInMemoryMerchantRepository()
+	pauses := NewInMemoryPauseStore()
+	usecase := NewBusyUsecase(merchants, pauses)
+
+	shop := NewMerchant(uuid.New(), "Shop", "", DefaultCurrency)
+	shop.MaxOpenOrders = 5
+	require.NoError(t, merchants.Save(ctx, shop))
+
+	t.Run("should pause until the chosen time and resume", func(t *testing.T) {
+		status, err := usecase.PauseOrders(ctx, shop.ID, 30*time.Minute)
+		require.NoError(t, err)
+		require.NotNil(t, status.PausedUntil)
+		assert.WithinDuration(t, time.Now().Add(30*time.Minute), *status.PausedUntil, time.Second)
+		assert.Equal(t, 5, status.MaxOpenOrders)
+
+		status, err = usecase.GetBusyStatus(ctx, shop.ID)
+		require.NoError(t, err)
+		assert.NotNil(t, status.PausedUntil)
+
+		status, err = usecase.ResumeOrders(ctx, shop.ID)
+		require.NoError(t, err)
+		assert.Nil(t, status.PausedUntil)
+
+		status, err = usecase.GetBusyStatus(ctx, shop.ID)
+		require.NoError(t, err)
+		assert.Nil(t, status.PausedUntil)
+	})
+
+	t.Run("should resume by itself once the pause ends", func(t *testing.T) {
+		require.NoError(t, pauses.Pause(ctx, shop.ID, time.Now().Add(-time.Second)))
+
+		status, err := usecase.GetBusyStatus(ctx, shop.ID)
+		require.NoError(t, err)
+		assert.Nil(t, status.PausedUntil)
+	})
+
+	t.Run("should reject invalid pauses", func(t *testing.T) {
+		for _, duration := range []time.Duration{0, 30 * time.Second, MaxPauseDuration + time.Minute} {
+			_, err := usecase.PauseOrders(ctx, shop.ID, duration)
+			assert.ErrorIs(t, err, ErrInvalidPauseDuration, duration)
+		}
+
+		_, err := usecase.PauseOrders(ctx, uuid.New(), time.Hour)
+		assert.ErrorIs(t, err, ErrMerchantNotFound)
+	})
+}
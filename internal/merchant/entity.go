@@ -1,19 +1,164 @@
 package merchant
 
-import "github.com/google/uuid"
+import (
+	"fmt"
+	"minimart/internal/shared/money"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultCurrency is used for merchants registered without an explicit currency.
+const DefaultCurrency = money.USD
+
+// DefaultTimezone is used for merchants registered without an explicit time zone.
+const DefaultTimezone = "UTC"
+
+const (
+	// DefaultCommissionBps is the platform's cut of new merchants' orders, in
+	// basis points (1/100 of a percent).
+	DefaultCommissionBps = 1500
+	MaxCommissionBps     = 10000
+)
+
+// MerchantStatus is where a merchant is in the onboarding workflow.
+type MerchantStatus string
+
+const (
+	StatusSubmitted   MerchantStatus = "submitted"
+	StatusUnderReview MerchantStatus = "under_review"
+	StatusApproved    MerchantStatus = "approved"
+	StatusRejected    MerchantStatus = "rejected"
+	StatusSuspended   MerchantStatus = "suspended"
+)
+
+// statusTransitions lists the statuses a merchant may move to from each status.
+// Rejected merchants go back to submitted once they have fixed their profile.
+var statusTransitions = map[MerchantStatus][]MerchantStatus{
+	StatusSubmitted:   {StatusUnderReview},
+	StatusUnderReview: {StatusApproved, StatusRejected},
+	StatusRejected:    {StatusSubmitted},
+	StatusApproved:    {StatusSuspended},
+	StatusSuspended:   {StatusApproved},
+}
+
+// ParseMerchantStatus parses a status name such as "approved".
+func ParseMerchantStatus(name string) (MerchantStatus, error) {
+	status := MerchantStatus(name)
+	if _, known := statusTransitions[status]; !known {
+		return "", fmt.Errorf("%w: %q", ErrUnknownStatus, name)
+	}
+	return status, nil
+}
+
+// CanTransitionTo reports whether a merchant in this status may move to next.
+func (s MerchantStatus) CanTransitionTo(next MerchantStatus) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
 
 type Merchant struct {
-	ID          uuid.UUID
-	Name        string
-	Description string
-	IsActive    bool
+	ID uuid.UUID
+	// OwnerID is the user who registered the merchant.
+	OwnerID uuid.UUID
+	// Slug is the merchant's unique, URL-safe name, e.g. "joes-pizza". It
+	// stands in for the ID in /merchants/... URLs; former slugs redirect.
+	Slug         string
+	Name         string
+	Description  string
+	ContactEmail string
+	ContactPhone string
+	Address      string
+	// Location is where the merchant delivers from. Merchants without one are
+	// left out of nearby searches.
+	Location *GeoPoint
+	// DeliveryRadiusKm is how far from Location the merchant delivers.
+	DeliveryRadiusKm float64
+	// IsActive is the merchant's own open/closed switch. It can only be
+	// turned on while the merchant is approved.
+	IsActive bool
+	Status   MerchantStatus
+	// StatusReason explains the latest status change, e.g. why it was rejected.
+	StatusReason string
+	// Currency is the ISO 4217 currency every price on the merchant's menu is in.
+	Currency money.Currency
+	// Timezone is the IANA time zone the merchant trades in, e.g. "Asia/Bangkok".
+	// Reports group sales by the merchant's local days.
+	Timezone string
+	// CommissionBps is the platform's cut of each completed order, in basis points.
+	CommissionBps int
+	// MaxOpenOrders caps how many orders may be open at once; zero means no cap.
+	MaxOpenOrders int
+	CreatedAt     time.Time
 }
 
-func NewMerchant(name, description string) *Merchant {
+// NewMerchant creates a merchant that has been submitted for review.
+// It stays hidden from customers until an admin approves it. Its slug comes
+// from its name and may still collide with another merchant's.
+func NewMerchant(ownerID uuid.UUID, name, description string, currency money.Currency) *Merchant {
 	return &Merchant{
-		ID:          uuid.New(),
-		Name:        name,
-		Description: description,
-		IsActive:    true,
+		ID:               uuid.New(),
+		OwnerID:          ownerID,
+		Slug:             nameSlug(name),
+		Name:             name,
+		Description:      description,
+		DeliveryRadiusKm: DefaultDeliveryRadiusKm,
+		IsActive:         false,
+		Status:           StatusSubmitted,
+		Currency:         currency,
+		Timezone:         DefaultTimezone,
+		CommissionBps:    DefaultCommissionBps,
+		CreatedAt:        time.Now(),
+	}
+}
+
+// Zone returns the merchant's time zone, falling back to UTC if it cannot be loaded.
+func (m *Merchant) Zone() *time.Location {
+	location, err := time.LoadLocation(m.Timezone)
+	if err != nil {
+		return time.UTC
 	}
+	return location
+}
+
+// AcceptsCustomers reports whether customers may see the merchant's menu and order from it.
+func (m *Merchant) AcceptsCustomers() bool {
+	return m.Status == StatusApproved && m.IsActive
+}
+
+// Delivers reports whether the merchant delivers to point.
+func (m *Merchant) Delivers(point GeoPoint) bool {
+	return m.Location != nil && DistanceKm(*m.Location, point) <= m.DeliveryRadiusKm
+}
+
+// MissingProfileFields returns the JSON names of the required profile fields
+// that are still empty.
+func (m *Merchant) MissingProfileFields() []string {
+	var missing []string
+	for _, field := range []struct{ name, value string }{
+		{"name", m.Name},
+		{"description", m.Description},
+		{"contact_email", m.ContactEmail},
+		{"contact_phone", m.ContactPhone},
+	} {
+		if field.value == "" {
+			missing = append(missing, field.name)
+		}
+	}
+	return missing
+}
+
+// StatusChange is one entry of a merchant's status history.
+type StatusChange struct {
+	MerchantID uuid.UUID
+	From       MerchantStatus
+	To         MerchantStatus
+	Reason     string
+	// ChangedBy is the admin or owner who made the change.
+	ChangedBy uuid.UUID
+	ChangedAt time.Time
 }
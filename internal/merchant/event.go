@@ -0,0 +1,38 @@
+package merchant
+
+import "time"
+
+const MerchantStatusChangedTopic = "merchant.status_changed"
+
+// MerchantStatusChangedEvent is published on every onboarding status
+// transition, including registration, which moves from "" to submitted.
+type MerchantStatusChangedEvent struct {
+	MerchantID string `json:"merchant_id"`
+	OwnerID    string `json:"owner_id"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Reason     string `json:"reason"`
+}
+
+func (e MerchantStatusChangedEvent) Topic() string {
+	return MerchantStatusChangedTopic
+}
+
+const MerchantInviteCreatedTopic = "merchant.invite_created"
+
+// MerchantInviteCreatedEvent is published when someone is invited to work for
+// a merchant. It carries the only copy of the invite token, for the email to
+// the invitee.
+type MerchantInviteCreatedEvent struct {
+	InviteID     string    `json:"invite_id"`
+	MerchantID   string    `json:"merchant_id"`
+	MerchantName string    `json:"merchant_name"`
+	Email        string    `json:"email"`
+	Role         string    `json:"role"`
+	Token        string    `json:"token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+func (e MerchantInviteCreatedEvent) Topic() string {
+	return MerchantInviteCreatedTopic
+}
@@ -0,0 +1,69 @@
+package merchant
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	// EarthRadiusKm is the mean Earth radius used for haversine distances.
+	EarthRadiusKm = 6371.0
+	// DefaultDeliveryRadiusKm is used for merchants that have not set a delivery radius.
+	DefaultDeliveryRadiusKm = 5.0
+	// MaxDeliveryRadiusKm caps how far a merchant may deliver.
+	MaxDeliveryRadiusKm = 50.0
+)
+
+var (
+	ErrInvalidLocation       = errors.New("latitude must be between -90 and 90 and longitude between -180 and 180")
+	ErrInvalidDeliveryRadius = errors.New("delivery radius must be greater than 0 and at most 50 km")
+)
+
+// GeoPoint is a WGS 84 coordinate in degrees.
+type GeoPoint struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lng"`
+}
+
+// Validate checks that the point is a real coordinate.
+func (p GeoPoint) Validate() error {
+	if math.IsNaN(p.Latitude) || math.IsNaN(p.Longitude) ||
+		p.Latitude < -90 || p.Latitude > 90 || p.Longitude < -180 || p.Longitude > 180 {
+		return ErrInvalidLocation
+	}
+	return nil
+}
+
+// DistanceKm returns the great-circle distance between two points using the
+// haversine formula. PostgresMerchantRepository computes the same in SQL.
+func DistanceKm(a, b GeoPoint) float64 {
+	lat1, lat2 := radians(a.Latitude), radians(b.Latitude)
+	dLat := lat2 - lat1
+	dLng := radians(b.Longitude - a.Longitude)
+
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLng/2), 2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// boundingBox returns the latitude and longitude ranges that contain every
+// point within radiusKm of center. It is used to narrow a search before
+// computing exact distances.
+func boundingBox(center GeoPoint, radiusKm float64) (minLat, maxLat, minLng, maxLng float64) {
+	dLat := degrees(radiusKm / EarthRadiusKm)
+	minLat, maxLat = center.Latitude-dLat, center.Latitude+dLat
+
+	// Near the poles, or when the box crosses the antimeridian, every longitude qualifies.
+	if minLat <= -90 || maxLat >= 90 {
+		return max(minLat, -90), min(maxLat, 90), -180, 180
+	}
+	dLng := degrees(math.Asin(math.Sin(radiusKm/EarthRadiusKm) / math.Cos(radians(center.Latitude))))
+	minLng, maxLng = center.Longitude-dLng, center.Longitude+dLng
+	if minLng < -180 || maxLng > 180 {
+		return minLat, maxLat, -180, 180
+	}
+	return minLat, maxLat, minLng, maxLng
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
@@ -0,0 +1,54 @@
+package merchant
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistanceKm(t *testing.T) {
+	london := GeoPoint{Latitude: 51.5074, Longitude: -0.1278}
+	paris := GeoPoint{Latitude: 48.8566, Longitude: 2.3522}
+
+	t.Run("should compute great-circle distances", func(t *testing.T) {
+		assert.InDelta(t, 343.5, DistanceKm(london, paris), 1)
+		assert.InDelta(t, DistanceKm(london, paris), DistanceKm(paris, london), 1e-9)
+		assert.Zero(t, DistanceKm(london, london))
+	})
+
+	t.Run("should measure across the antimeridian", func(t *testing.T) {
+		west := GeoPoint{Latitude: 0, Longitude: 179.99}
+		east := GeoPoint{Latitude: 0, Longitude: -179.99}
+		assert.InDelta(t, 2.2, DistanceKm(west, east), 0.1)
+	})
+}
+
+func TestBoundingBox(t *testing.T) {
+	t.Run("should contain every point within the radius", func(t *testing.T) {
+		center := GeoPoint{Latitude: 13.7563, Longitude: 100.5018}
+		minLat, maxLat, minLng, maxLng := boundingBox(center, 10)
+
+		for _, edge := range []GeoPoint{
+			{Latitude: center.Latitude + 0.089, Longitude: center.Longitude},
+			{Latitude: center.Latitude, Longitude: center.Longitude - 0.092},
+		} {
+			assert.Less(t, DistanceKm(center, edge), 10.0)
+			assert.True(t, edge.Latitude >= minLat && edge.Latitude <= maxLat, edge)
+			assert.True(t, edge.Longitude >= minLng && edge.Longitude <= maxLng, edge)
+		}
+	})
+
+	t.Run("should widen to every longitude near the poles and the antimeridian", func(t *testing.T) {
+		_, _, minLng, maxLng := boundingBox(GeoPoint{Latitude: 89.99, Longitude: 0}, 10)
+		assert.Equal(t, []float64{-180, 180}, []float64{minLng, maxLng})
+
+		_, _, minLng, maxLng = boundingBox(GeoPoint{Latitude: 0, Longitude: 179.99}, 10)
+		assert.Equal(t, []float64{-180, 180}, []float64{minLng, maxLng})
+	})
+}
+
+func TestGeoPoint_Validate(t *testing.T) {
+	assert.NoError(t, GeoPoint{Latitude: -90, Longitude: 180}.Validate())
+	assert.ErrorIs(t, GeoPoint{Latitude: 91}.Validate(), ErrInvalidLocation)
+	assert.ErrorIs(t, GeoPoint{Longitude: -180.5}.Validate(), ErrInvalidLocation)
+}
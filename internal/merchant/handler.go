@@ -1,36 +1,482 @@
 package merchant
 
 import (
+	"errors"
+	"fmt"
+	middlerware "minimart/internal/shared/middleware"
+	"minimart/internal/shared/money"
+	"minimart/internal/shared/validation"
+	"strconv"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 type MerchantHandler struct {
 	usecase MerchantUsecase
+	auth    fiber.Handler
+	access  AccessGuard
+	admin   fiber.Handler
 }
 
-func NewMerchantHandler(usecase MerchantUsecase) *MerchantHandler {
+// NewMerchantHandler creates a new MerchantHandler. auth authenticates the
+// user, normally middlerware.AuthRequire; access guards changes to an
+// existing merchant, normally RequireAccess; admin guards the review
+// endpoints and runs after auth.
+func NewMerchantHandler(usecase MerchantUsecase, auth fiber.Handler, access AccessGuard, admin fiber.Handler) *MerchantHandler {
 	return &MerchantHandler{
 		usecase: usecase,
+		auth:    auth,
+		access:  access,
+		admin:   admin,
 	}
 }
 
 func (h *MerchantHandler) RegisterRoutes(app *fiber.App) {
-	app.Post("merchants/register", h.CreateMerchant)
+	app.Post("merchants/register", h.auth, h.CreateMerchant)
+	app.Get("/merchants", h.ListMerchants)
+	// Registered before /merchants/:merchantID so "nearby" is not taken for an ID
+	app.Get("/merchants/nearby", h.ListNearbyMerchants)
+	app.Get("/merchants/:merchantID", h.GetMerchant)
+	manage := h.access(PermManageMerchant)
+	app.Patch("/merchants/:merchantID", h.auth, manage, h.UpdateMerchant)
+	app.Post("/merchants/:merchantID/activate", h.auth, manage, h.ActivateMerchant)
+	app.Post("/merchants/:merchantID/deactivate", h.auth, manage, h.DeactivateMerchant)
+	app.Post("/merchants/:merchantID/resubmit", h.auth, manage, h.ResubmitMerchant)
+	app.Put("/merchants/:merchantID/slug", h.auth, h.access(PermChangeSlug), h.ChangeSlug)
+	app.Get("/merchants/:merchantID/status-history", h.auth, manage, h.GetStatusHistory)
+
+	// Review workflow, for admins only
+	adminRoutes := app.Group("/admin/merchants", h.auth, h.admin)
+	adminRoutes.Get("/", h.AdminListMerchants)
+	adminRoutes.Patch("/:merchantID/status", h.ChangeMerchantStatus)
+	adminRoutes.Get("/:merchantID/status-history", h.GetStatusHistory)
+	adminRoutes.Patch("/:merchantID/commission", h.SetCommission)
+}
+
+// CreateMerchantRequest defines the JSON request body for registering a merchant.
+type CreateMerchantRequest struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	ContactEmail string `json:"contact_email"`
+	ContactPhone string `json:"contact_phone"`
+	Address      string `json:"address"`
+	// Location is optional at registration; merchants without one are
+	// left out of nearby searches.
+	Location         *GeoPoint `json:"location"`
+	DeliveryRadiusKm float64   `json:"delivery_radius_km"`
+	Currency         string    `json:"currency"`
+	Timezone         string    `json:"timezone"`
+}
+
+// Validate checks the fields that are wrong on their own. Whether the profile
+// is complete enough to submit for review is up to the usecase.
+func (r CreateMerchantRequest) Validate() error {
+	var v validation.Validator
+	v.Required("name", r.Name)
+	v.MaxLength("name", r.Name, validation.MaxNameLength)
+	v.Email("contact_email", r.ContactEmail)
+	if r.Location != nil {
+		v.Check(r.Location.Validate() == nil, "location", ErrInvalidLocation.Error())
+	}
+	if r.DeliveryRadiusKm != 0 {
+		v.Check(validateDeliveryRadius(r.DeliveryRadiusKm) == nil, "delivery_radius_km", ErrInvalidDeliveryRadius.Error())
+	}
+	return v.Err()
 }
 
+// CreateMerchant registers a merchant owned by the authenticated user.
 func (h *MerchantHandler) CreateMerchant(c *fiber.Ctx) error {
-	var req struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
+	ownerID, err := middlerware.UserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
 	}
+
+	var req CreateMerchantRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid request",
 		})
 	}
-	user, err := h.usecase.CreateMerchant(c.Context(), req.Name, req.Description)
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
+	user, err := h.usecase.CreateMerchant(c.Context(), ownerID, MerchantProfile{
+		Name:             req.Name,
+		Description:      req.Description,
+		ContactEmail:     req.ContactEmail,
+		ContactPhone:     req.ContactPhone,
+		Address:          req.Address,
+		Location:         req.Location,
+		DeliveryRadiusKm: req.DeliveryRadiusKm,
+		Timezone:         req.Timezone,
+	}, req.Currency)
 	if err != nil {
+		switch {
+		case errors.Is(err, money.ErrUnknownCurrency), errors.Is(err, ErrInvalidLocation), errors.Is(err, ErrInvalidDeliveryRadius),
+			errors.Is(err, ErrInvalidTimezone):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, ErrIncompleteProfile):
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
 	}
 	return c.Status(fiber.StatusCreated).JSON(user)
 }
+
+// ListMerchants handles the public merchant directory, which only lists
+// approved merchants. It supports ?active=true|false, ?sort=name|newest,
+// ?page= and ?page_size=.
+func (h *MerchantHandler) ListMerchants(c *fiber.Ctx) error {
+	query, err := parseListQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	approved := StatusApproved
+	query.Status = &approved
+
+	page, err := h.usecase.ListMerchants(c.Context(), query)
+	if err != nil {
+		return merchantErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(NewPublicMerchantPage(page))
+}
+
+// AdminListMerchants handles the admin view of the directory, which lists
+// merchants in every status. It also supports ?status=, e.g. the review queue
+// with ?status=under_review.
+func (h *MerchantHandler) AdminListMerchants(c *fiber.Ctx) error {
+	query, err := parseListQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if raw := c.Query("status"); raw != "" {
+		status, err := ParseMerchantStatus(raw)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		query.Status = &status
+	}
+
+	page, err := h.usecase.ListMerchants(c.Context(), query)
+	if err != nil {
+		return merchantErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(page)
+}
+
+func parseListQuery(c *fiber.Ctx) (ListQuery, error) {
+	query := ListQuery{
+		Sort:     SortOrder(c.Query("sort")),
+		Page:     c.QueryInt("page", 1),
+		PageSize: c.QueryInt("page_size", DefaultPageSize),
+	}
+	if raw := c.Query("active"); raw != "" {
+		active, err := strconv.ParseBool(raw)
+		if err != nil {
+			return ListQuery{}, errors.New("active must be true or false")
+		}
+		query.Active = &active
+	}
+	return query, nil
+}
+
+// ListNearbyMerchants handles ?lat=&lng=&radius= searches for the merchants
+// that deliver to the customer, closest first. radius is in km.
+func (h *MerchantHandler) ListNearbyMerchants(c *fiber.Ctx) error {
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+	if errLat != nil || errLng != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "lat and lng are required numbers"})
+	}
+	var radiusKm float64
+	if raw := c.Query("radius"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "radius must be a number"})
+		}
+		radiusKm = parsed
+	}
+
+	merchants, err := h.usecase.FindNearby(c.Context(), GeoPoint{Latitude: lat, Longitude: lng}, radiusKm)
+	if err != nil {
+		return merchantErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(NewPublicNearbyMerchants(merchants))
+}
+
+// GetMerchant handles fetching a single merchant's public profile. Like the
+// directory, it only shows approved merchants.
+func (h *MerchantHandler) GetMerchant(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	merchant, err := h.usecase.GetMerchant(c.Context(), merchantID)
+	if err != nil {
+		return merchantErrorResponse(c, err)
+	}
+	if merchant.Status != StatusApproved {
+		return merchantErrorResponse(c, ErrMerchantNotFound)
+	}
+	return c.Status(fiber.StatusOK).JSON(NewPublicMerchant(merchant))
+}
+
+// UpdateMerchantRequest defines the JSON request body for a partial merchant update.
+type UpdateMerchantRequest struct {
+	Name             *string   `json:"name"`
+	Description      *string   `json:"description"`
+	ContactEmail     *string   `json:"contact_email"`
+	ContactPhone     *string   `json:"contact_phone"`
+	Address          *string   `json:"address"`
+	Location         *GeoPoint `json:"location"`
+	DeliveryRadiusKm *float64  `json:"delivery_radius_km"`
+	Timezone         *string   `json:"timezone"`
+	MaxOpenOrders    *int      `json:"max_open_orders"`
+}
+
+// Validate checks the fields that are being changed.
+func (r UpdateMerchantRequest) Validate() error {
+	var v validation.Validator
+	if r.Name != nil {
+		v.Required("name", *r.Name)
+		v.MaxLength("name", *r.Name, validation.MaxNameLength)
+	}
+	if r.ContactEmail != nil {
+		v.Email("contact_email", *r.ContactEmail)
+	}
+	if r.Location != nil {
+		v.Check(r.Location.Validate() == nil, "location", ErrInvalidLocation.Error())
+	}
+	if r.DeliveryRadiusKm != nil {
+		v.Check(validateDeliveryRadius(*r.DeliveryRadiusKm) == nil, "delivery_radius_km", ErrInvalidDeliveryRadius.Error())
+	}
+	if r.MaxOpenOrders != nil {
+		v.Check(*r.MaxOpenOrders >= 0, "max_open_orders", "must not be negative")
+	}
+	return v.Err()
+}
+
+// UpdateMerchant handles partial updates of a merchant's profile.
+func (h *MerchantHandler) UpdateMerchant(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	var req UpdateMerchantRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
+
+	merchant, err := h.usecase.UpdateMerchant(c.Context(), merchantID, MerchantChanges{
+		Name:             req.Name,
+		Description:      req.Description,
+		ContactEmail:     req.ContactEmail,
+		ContactPhone:     req.ContactPhone,
+		Address:          req.Address,
+		Location:         req.Location,
+		DeliveryRadiusKm: req.DeliveryRadiusKm,
+		Timezone:         req.Timezone,
+		MaxOpenOrders:    req.MaxOpenOrders,
+	})
+	if err != nil {
+		return merchantErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(merchant)
+}
+
+// ChangeSlugRequest defines the JSON request body for choosing a slug.
+type ChangeSlugRequest struct {
+	Slug string `json:"slug"`
+}
+
+func (r ChangeSlugRequest) Validate() error {
+	var v validation.Validator
+	v.Required("slug", r.Slug)
+	if r.Slug != "" {
+		v.Check(ValidateSlug(r.Slug) == nil, "slug", ErrInvalidSlug.Error())
+	}
+	return v.Err()
+}
+
+// ChangeSlug handles an owner choosing the merchant's slug. Links with the
+// old slug keep working.
+func (h *MerchantHandler) ChangeSlug(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	var req ChangeSlugRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
+
+	merchant, err := h.usecase.ChangeSlug(c.Context(), merchantID, req.Slug)
+	if err != nil {
+		return merchantErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(merchant)
+}
+
+// ActivateMerchant handles making a merchant visible to customers again.
+func (h *MerchantHandler) ActivateMerchant(c *fiber.Ctx) error {
+	return h.setActive(c, true)
+}
+
+// DeactivateMerchant handles hiding a merchant and its menu from customers.
+func (h *MerchantHandler) DeactivateMerchant(c *fiber.Ctx) error {
+	return h.setActive(c, false)
+}
+
+func (h *MerchantHandler) setActive(c *fiber.Ctx, active bool) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	merchant, err := h.usecase.SetMerchantActive(c.Context(), merchantID, active)
+	if err != nil {
+		return merchantErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(merchant)
+}
+
+// ChangeMerchantStatusRequest defines the JSON request body for a review decision.
+type ChangeMerchantStatusRequest struct {
+	Status string `json:"status"`
+	// Reason is required when rejecting or suspending a merchant.
+	Reason string `json:"reason"`
+}
+
+// Validate checks that the status exists. Which statuses need a reason is
+// part of the review workflow and checked by the usecase.
+func (r ChangeMerchantStatusRequest) Validate() error {
+	var v validation.Validator
+	if _, err := ParseMerchantStatus(r.Status); err != nil {
+		v.Add("status", err.Error())
+	}
+	return v.Err()
+}
+
+// ChangeMerchantStatus handles an admin moving a merchant through the review workflow.
+func (h *MerchantHandler) ChangeMerchantStatus(c *fiber.Ctx) error {
+	adminID, err := middlerware.UserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	var req ChangeMerchantStatusRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
+	status, _ := ParseMerchantStatus(req.Status)
+
+	merchant, err := h.usecase.ChangeMerchantStatus(c.Context(), adminID, merchantID, status, req.Reason)
+	if err != nil {
+		return merchantErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(merchant)
+}
+
+// SetCommissionRequest defines the JSON request body for changing a commission.
+type SetCommissionRequest struct {
+	CommissionBps *int `json:"commission_bps"`
+}
+
+func (r SetCommissionRequest) Validate() error {
+	var v validation.Validator
+	if r.CommissionBps == nil {
+		v.Add("commission_bps", "is required")
+	} else {
+		v.Check(*r.CommissionBps >= 0 && *r.CommissionBps <= MaxCommissionBps, "commission_bps", fmt.Sprintf("must be between 0 and %d", MaxCommissionBps))
+	}
+	return v.Err()
+}
+
+// SetCommission handles an admin changing the platform's cut of a merchant's orders.
+func (h *MerchantHandler) SetCommission(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	var req SetCommissionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
+
+	merchant, err := h.usecase.SetCommission(c.Context(), merchantID, *req.CommissionBps)
+	if err != nil {
+		return merchantErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(merchant)
+}
+
+// ResubmitMerchant handles an owner sending a rejected merchant back for review.
+func (h *MerchantHandler) ResubmitMerchant(c *fiber.Ctx) error {
+	userID, err := middlerware.UserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	merchant, err := h.usecase.ResubmitMerchant(c.Context(), userID, merchantID)
+	if err != nil {
+		return merchantErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(merchant)
+}
+
+// GetStatusHistory handles listing a merchant's review decisions and their reasons.
+func (h *MerchantHandler) GetStatusHistory(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	history, err := h.usecase.GetStatusHistory(c.Context(), merchantID)
+	if err != nil {
+		return merchantErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(history)
+}
+
+// merchantErrorResponse maps the errors shared by the merchant endpoints to HTTP responses.
+func merchantErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrMerchantNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrInvalidMerchantName), errors.Is(err, ErrInvalidSortOrder), errors.Is(err, ErrReasonRequired),
+		errors.Is(err, ErrInvalidLocation), errors.Is(err, ErrInvalidDeliveryRadius), errors.Is(err, ErrInvalidSearchRadius),
+		errors.Is(err, ErrInvalidTimezone), errors.Is(err, ErrInvalidCommission),
+		errors.Is(err, ErrInvalidMaxOpenOrders), errors.Is(err, ErrInvalidSlug):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrInvalidStatusTransition), errors.Is(err, ErrMerchantNotApproved), errors.Is(err, ErrSlugTaken):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrIncompleteProfile):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
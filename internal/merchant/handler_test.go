@@ -0,0 +1,391 @@
+package merchant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"minimart/internal/shared/eventbus"
+	"minimart/internal/shared/jwtkeys"
+	middlerware "minimart/internal/shared/middleware"
+	"minimart/internal/shared/money"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var dbpool *pgxpool.Pool
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	pgContainer, err := postgres.Run(ctx,
+		"postgres:15-alpine",
+		postgres.WithDatabase("test-db"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").WithOccurrence(2).WithStartupTimeout(5*time.Second),
+		),
+	)
+
+	if err != nil {
+		log.Fatalf("could not start Postgres container: %s", err)
+	}
+
+	defer func() {
+		if err := pgContainer.Terminate(ctx); err != nil {
+			log.Fatalf("could not terminate postgres container: %s", err)
+		}
+	}()
+
+	connStr, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	require.NoError(nil, err)
+
+	dbpool, err = pgxpool.New(ctx, connStr)
+	if err != nil {
+		log.Fatalf("could not connect to database: %s", err)
+	}
+
+	// --- Run Migrations in Order ---
+	runMigration(ctx, "../../migrations/001_create_users_table.sql")
+	runMigration(ctx, "../../migrations/002_create_orders_tables.sql")
+	runMigration(ctx, "../../migrations/003_create_menu_items_table.sql")
+	runMigration(ctx, "../../migrations/004_create_merchants_table.sql")
+	runMigration(ctx, "../../migrations/005_add_menu_item_images.sql")
+	runMigration(ctx, "../../migrations/006_add_currency_columns.sql")
+	runMigration(ctx, "../../migrations/007_create_menu_versions_tables.sql")
+	runMigration(ctx, "../../migrations/008_add_menu_item_dietary_info.sql")
+	runMigration(ctx, "../../migrations/009_add_merchant_owner.sql")
+	runMigration(ctx, "../../migrations/010_add_merchant_onboarding.sql")
+	runMigration(ctx, "../../migrations/011_add_merchant_location.sql")
+	runMigration(ctx, "../../migrations/012_create_merchant_memberships.sql")
+	runMigration(ctx, "../../migrations/013_add_sales_analytics.sql")
+	runMigration(ctx, "../../migrations/014_create_settlement_ledger.sql")
+	runMigration(ctx, "../../migrations/015_add_merchant_busy_mode.sql")
+	runMigration(ctx, "../../migrations/016_create_webhooks.sql")
+	runMigration(ctx, "../../migrations/017_add_merchant_slugs.sql")
+	runMigration(ctx, "../../migrations/019_add_user_roles.sql")
+	runMigration(ctx, "../../migrations/024_create_merchant_api_keys.sql")
+
+	exitCode := m.Run()
+	os.Exit(exitCode)
+}
+
+func runMigration(ctx context.Context, filePath string) {
+	migrationsPath, _ := filepath.Abs(filePath)
+	migrationSQL, err := os.ReadFile(migrationsPath)
+	if err != nil {
+		log.Fatalf("could not read migration file: %s", err)
+	}
+	_, err = dbpool.Exec(ctx, string(migrationSQL))
+	if err != nil {
+		log.Fatalf("could not run migrations: %s", err)
+	}
+}
+
+func TestMerchantHandler_Directory_Integration(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := NewPostgresMerchantRepository(dbpool)
+
+	ownerID := uuid.New()
+	_, err := dbpool.Exec(ctx, "INSERT INTO users (id, name, email, password) VALUES ($1, $2, $3, $4);", ownerID, "Owner", "owner@example.com", "password")
+	require.NoError(t, err)
+
+	// Stand in for AuthRequire by storing the claims it would have stored.
+	// Requests act as the owner unless they name another user.
+	adminID := uuid.New()
+	authenticate := func(c *fiber.Ctx) error {
+		subject := ownerID.String()
+		if userID := c.Get("X-User-ID"); userID != "" {
+			subject = userID
+		}
+		role := middlerware.RoleMerchant
+		if subject == adminID.String() {
+			role = middlerware.RoleAdmin
+		}
+		c.Locals("user", &middlerware.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}, Role: role})
+		return c.Next()
+	}
+	app := fiber.New()
+	usecase := NewMerchantUsecase(repo, newStubRoles(), eventbus.NewInMemoryEventBus(), discardLogger)
+	NewMerchantHandler(usecase, authenticate, RequireAccess(NewOwnerAccessPolicy(repo)), middlerware.RequirePermission(middlerware.PermReviewMerchants)).RegisterRoutes(app)
+
+	approved := func(name string) *Merchant {
+		m := NewMerchant(ownerID, name, "Open daily", money.USD)
+		m.ContactEmail = "hello@example.com"
+		m.ContactPhone = "+1 555 0100"
+		m.Status = StatusApproved
+		m.IsActive = true
+		return m
+	}
+	bakery := approved("Bakery")
+	bakery.CreatedAt = time.Now().Add(-time.Hour)
+	cafe := approved("Cafe")
+	closed := approved("Arcade Diner")
+	closed.IsActive = false
+	pending := NewMerchant(ownerID, "Pending Pizza", "Wood-fired", money.USD)
+	pending.ContactEmail = "pizza@example.com"
+	pending.ContactPhone = "+1 555 0199"
+	for _, m := range []*Merchant{bakery, cafe, closed, pending} {
+		require.NoError(t, repo.Save(ctx, m))
+	}
+
+	listNames := func(query string) (names []string, total int) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/merchants"+query, nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var page PublicMerchantPage
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+		for _, m := range page.Merchants {
+			names = append(names, m.Name)
+		}
+		return names, page.Total
+	}
+
+	t.Run("should list, filter, sort and paginate merchants", func(t *testing.T) {
+		names, total := listNames("")
+		assert.Equal(t, []string{"Arcade Diner", "Bakery", "Cafe"}, names)
+		assert.Equal(t, 3, total)
+
+		names, total = listNames("?active=true&sort=newest")
+		assert.Equal(t, []string{"Cafe", "Bakery"}, names)
+		assert.Equal(t, 2, total)
+
+		names, total = listNames("?page=2&page_size=2")
+		assert.Equal(t, []string{"Cafe"}, names)
+		assert.Equal(t, 3, total)
+	})
+
+	t.Run("should get a single merchant", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/merchants/"+cafe.ID.String(), nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var fetched map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&fetched))
+		assert.Equal(t, cafe.ID.String(), fetched["id"])
+		assert.Equal(t, "Cafe", fetched["name"])
+		for _, private := range []string{"owner_id", "OwnerID", "contact_email", "ContactEmail", "commission_bps", "CommissionBps", "status_reason"} {
+			assert.NotContains(t, fetched, private)
+		}
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/merchants/"+uuid.New().String(), nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/merchants/"+pending.ID.String(), nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, "merchants under review stay hidden")
+	})
+
+	t.Run("should update and deactivate a merchant", func(t *testing.T) {
+		url := fmt.Sprintf("/merchants/%s", cafe.ID)
+		req := httptest.NewRequest(http.MethodPatch, url, strings.NewReader(`{"name": "Corner Cafe"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodPost, url+"/deactivate", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		stored, err := repo.GetByID(ctx, cafe.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Corner Cafe", stored.Name)
+		assert.False(t, stored.IsActive)
+	})
+
+	t.Run("should review merchants through the admin endpoints", func(t *testing.T) {
+		send := func(method, url, userID, body string) *http.Response {
+			req := httptest.NewRequest(method, url, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if userID != "" {
+				req.Header.Set("X-User-ID", userID)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			return resp
+		}
+		statusURL := fmt.Sprintf("/admin/merchants/%s/status", pending.ID)
+
+		// Owners cannot review their own merchants
+		resp := send(http.MethodPatch, statusURL, "", `{"status": "under_review"}`)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		resp = send(http.MethodGet, "/admin/merchants?status=submitted", adminID.String(), "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var queue MerchantPage
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&queue))
+		require.Len(t, queue.Merchants, 1)
+		assert.Equal(t, pending.ID, queue.Merchants[0].ID)
+
+		resp = send(http.MethodPatch, statusURL, adminID.String(), `{"status": "approved"}`)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+		resp = send(http.MethodPatch, statusURL, adminID.String(), `{"status": "under_review"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		resp = send(http.MethodPatch, statusURL, adminID.String(), `{"status": "rejected"}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		resp = send(http.MethodPatch, statusURL, adminID.String(), `{"status": "rejected", "reason": "Add opening photos"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = send(http.MethodPost, fmt.Sprintf("/merchants/%s/resubmit", pending.ID), "", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = send(http.MethodGet, fmt.Sprintf("/merchants/%s/status-history", pending.ID), "", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var history []StatusChange
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&history))
+		require.Len(t, history, 3)
+		assert.Equal(t, StatusRejected, history[1].To)
+		assert.Equal(t, "Add opening photos", history[1].Reason)
+		assert.Equal(t, adminID, history[1].ChangedBy)
+
+		// Pending merchants stay out of the public directory
+		names, _ := listNames("")
+		assert.NotContains(t, names, "Pending Pizza")
+	})
+
+	t.Run("should find nearby merchants that deliver to the customer", func(t *testing.T) {
+		located := func(name string, lat, lng, radiusKm float64) *Merchant {
+			m := approved(name)
+			m.Location = &GeoPoint{Latitude: lat, Longitude: lng}
+			m.DeliveryRadiusKm = radiusKm
+			require.NoError(t, repo.Save(ctx, m))
+			return m
+		}
+		corner := located("Corner Noodles", 13.7465, 100.5350, 2)
+		located("Riverside Grill", 13.7800, 100.5600, 8)
+		located("Short Range Sushi", 13.7700, 100.5340, 1)
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/merchants/nearby?lat=13.7460&lng=100.5340", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var nearby []PublicNearbyMerchant
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&nearby))
+		require.Len(t, nearby, 2)
+		assert.Equal(t, corner.ID, nearby[0].ID)
+		assert.InDelta(t, 0.12, nearby[0].DistanceKm, 0.05)
+		assert.Equal(t, "Riverside Grill", nearby[1].Name)
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/merchants/nearby?lat=13.7460&lng=100.5340&radius=1", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&nearby))
+		require.Len(t, nearby, 1)
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/merchants/nearby?lat=95&lng=100", nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestAPIKeyHandler_Integration(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := NewPostgresMerchantRepository(dbpool)
+
+	ownerID := uuid.New()
+	_, err := dbpool.Exec(ctx, "INSERT INTO users (id, name, email, password) VALUES ($1, $2, $3, $4);", ownerID, "Owner", "keys-owner@example.com", "password")
+	require.NoError(t, err)
+	shop := NewMerchant(ownerID, "Key Shop", "", money.USD)
+	require.NoError(t, repo.Save(ctx, shop))
+
+	// Stand in for AuthRequire on the key management routes, as the owner.
+	authenticate := func(c *fiber.Ctx) error {
+		c.Locals("user", &middlerware.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: ownerID.String()}, Role: middlerware.RoleMerchant})
+		return c.Next()
+	}
+	usecase := NewAPIKeyUsecase(repo, NewPostgresMembershipRepository(dbpool), NewPostgresAPIKeyRepository(dbpool))
+	access := RequireAccess(NewOwnerAccessPolicy(repo))
+	app := fiber.New()
+	NewAPIKeyHandler(usecase, authenticate, access).RegisterRoutes(app)
+	// A route an integration calls with its key
+	signingKey, err := jwtkeys.GenerateKey(jwtkeys.EdDSA)
+	require.NoError(t, err)
+	keys, err := jwtkeys.NewKeyManager(signingKey)
+	require.NoError(t, err)
+	app.Get("/merchants/:merchantID/orders", middlerware.Authenticate(keys, middlerware.NewInMemoryTokenDenylist(), usecase), access(PermViewOrders), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	keysURL := "/merchants/" + shop.ID.String() + "/api-keys"
+	type issuedKey struct {
+		APIKey APIKey `json:"api_key"`
+		Key    string `json:"key"`
+	}
+	send := func(method, url, body string) *http.Response {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+	listOrdersWith := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/merchants/"+shop.ID.String()+"/orders", nil)
+		req.Header.Set(middlerware.APIKeyHeader, key)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	// Act: create a key
+	resp := send(http.MethodPost, keysURL, `{"name": "Front counter POS", "scopes": ["orders:read", "menu:write"]}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var created issuedKey
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+
+	// Assert
+	assert.Equal(t, []APIKeyScope{ScopeReadOrders, ScopeWriteMenu}, created.APIKey.Scopes)
+	assert.Equal(t, http.StatusOK, listOrdersWith(created.Key))
+
+	t.Run("should list keys with their last use and without secrets", func(t *testing.T) {
+		resp := send(http.MethodGet, keysURL, "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var keys []map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&keys))
+		require.Len(t, keys, 1)
+		assert.Equal(t, created.APIKey.Prefix, keys[0]["prefix"])
+		assert.NotNil(t, keys[0]["last_used_at"])
+		assert.NotContains(t, keys[0], "secret_hash")
+	})
+
+	t.Run("should reject unknown scopes", func(t *testing.T) {
+		resp := send(http.MethodPost, keysURL, `{"name": "POS", "scopes": ["staff:manage"]}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	})
+
+	t.Run("should rotate and revoke keys", func(t *testing.T) {
+		keyURL := keysURL + "/" + created.APIKey.ID.String()
+		resp := send(http.MethodPost, keyURL+"/rotate", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var rotated issuedKey
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&rotated))
+		assert.Equal(t, http.StatusUnauthorized, listOrdersWith(created.Key))
+		assert.Equal(t, http.StatusOK, listOrdersWith(rotated.Key))
+
+		assert.Equal(t, http.StatusNoContent, send(http.MethodDelete, keyURL, "").StatusCode)
+		assert.Equal(t, http.StatusUnauthorized, listOrdersWith(rotated.Key))
+		assert.Equal(t, http.StatusConflict, send(http.MethodPost, keyURL+"/rotate", "").StatusCode)
+		assert.Equal(t, http.StatusNotFound, send(http.MethodDelete, keysURL+"/"+uuid.NewString(), "").StatusCode)
+	})
+}
@@ -0,0 +1,149 @@
+package merchant
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InviteTTL is how long an invite can be accepted for.
+const InviteTTL = 72 * time.Hour
+
+var (
+	ErrUnknownRole        = errors.New("unknown merchant role")
+	ErrMembershipNotFound = errors.New("membership not found")
+	ErrInviteNotFound     = errors.New("invite not found")
+	ErrInviteExpired      = errors.New("invite has expired")
+	ErrInviteUsed         = errors.New("invite has already been accepted")
+	// ErrInviteEmailMismatch is returned when a user accepts an invite sent to someone else.
+	ErrInviteEmailMismatch = errors.New("invite was sent to a different email address")
+)
+
+// Role is what a user does for a merchant.
+type Role string
+
+const (
+	RoleOwner   Role = "owner"
+	RoleManager Role = "manager"
+	RoleCashier Role = "cashier"
+	RoleKitchen Role = "kitchen"
+)
+
+// Permission is an action on a merchant that only some roles may take.
+type Permission string
+
+const (
+	// PermManageMerchant covers the merchant's profile, opening and closing, and review.
+	PermManageMerchant Permission = "merchant:manage"
+	// PermChangeSlug is kept apart from the profile: slugs end up in printed marketing.
+	PermChangeSlug  Permission = "merchant:slug"
+	PermManageStaff Permission = "staff:manage"
+	// PermManageMenu covers menu items, prices, images and publishing.
+	PermManageMenu   Permission = "menu:manage"
+	PermViewOrders   Permission = "orders:view"
+	PermUpdateOrders Permission = "orders:update"
+	// PermPauseOrders covers busy mode, so whoever is in the kitchen can stop new orders.
+	PermPauseOrders Permission = "orders:pause"
+	// PermViewAnalytics covers sales reports and their exports.
+	PermViewAnalytics Permission = "analytics:view"
+	// PermViewStatements covers the settlement ledger and payout statements.
+	PermViewStatements Permission = "statements:view"
+	// PermManageWebhooks covers webhook endpoints and their deliveries.
+	PermManageWebhooks Permission = "webhooks:manage"
+	// PermManageAPIKeys covers the API keys of the merchant's own systems.
+	PermManageAPIKeys Permission = "apikeys:manage"
+)
+
+// rolePermissions lists what each role may do.
+var rolePermissions = map[Role][]Permission{
+	RoleOwner:   {PermManageMerchant, PermChangeSlug, PermManageStaff, PermManageMenu, PermViewOrders, PermUpdateOrders, PermPauseOrders, PermViewAnalytics, PermViewStatements, PermManageWebhooks, PermManageAPIKeys},
+	RoleManager: {PermManageMerchant, PermManageStaff, PermManageMenu, PermViewOrders, PermUpdateOrders, PermPauseOrders, PermViewAnalytics, PermViewStatements, PermManageWebhooks, PermManageAPIKeys},
+	RoleCashier: {PermViewOrders, PermUpdateOrders, PermPauseOrders},
+	RoleKitchen: {PermViewOrders, PermUpdateOrders, PermPauseOrders},
+}
+
+// ParseRole parses a role name such as "kitchen".
+func ParseRole(name string) (Role, error) {
+	role := Role(name)
+	if _, known := rolePermissions[role]; !known {
+		return "", fmt.Errorf("%w: %q", ErrUnknownRole, name)
+	}
+	return role, nil
+}
+
+// Can reports whether the role grants permission.
+func (r Role) Can(permission Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CanGrant reports whether a member with this role may invite or remove
+// members with the other role. Only owners manage owners and managers.
+func (r Role) CanGrant(other Role) bool {
+	switch r {
+	case RoleOwner:
+		return true
+	case RoleManager:
+		return other == RoleCashier || other == RoleKitchen
+	}
+	return false
+}
+
+// Membership links a user to a merchant they work for.
+type Membership struct {
+	MerchantID uuid.UUID `json:"merchant_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	Role       Role      `json:"role"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Invite asks whoever owns Email to join a merchant. Only a hash of the
+// token is stored; the token itself is only ever sent to the invitee.
+type Invite struct {
+	ID         uuid.UUID  `json:"id"`
+	MerchantID uuid.UUID  `json:"merchant_id"`
+	Email      string     `json:"email"`
+	Role       Role       `json:"role"`
+	TokenHash  string     `json:"-"`
+	InvitedBy  uuid.UUID  `json:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NewInvite creates an invite and returns it with the token to send to the invitee.
+func NewInvite(merchantID uuid.UUID, email string, role Role, invitedBy uuid.UUID) (*Invite, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	return &Invite{
+		ID:         uuid.New(),
+		MerchantID: merchantID,
+		Email:      email,
+		Role:       role,
+		TokenHash:  HashInviteToken(token),
+		InvitedBy:  invitedBy,
+		ExpiresAt:  now.Add(InviteTTL),
+		CreatedAt:  now,
+	}, token, nil
+}
+
+// HashInviteToken returns the hash invites are stored and looked up by.
+func HashInviteToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,166 @@
+package merchant
+
+import (
+	"errors"
+	middlerware "minimart/internal/shared/middleware"
+	"minimart/internal/shared/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type MembershipHandler struct {
+	usecase MembershipUsecase
+	auth    fiber.Handler
+	access  AccessGuard
+}
+
+// NewMembershipHandler creates a new MembershipHandler. Staff routes run auth
+// and then access, normally middlerware.AuthRequire and RequireAccess.
+func NewMembershipHandler(usecase MembershipUsecase, auth fiber.Handler, access AccessGuard) *MembershipHandler {
+	return &MembershipHandler{
+		usecase: usecase,
+		auth:    auth,
+		access:  access,
+	}
+}
+
+func (h *MembershipHandler) RegisterRoutes(app *fiber.App) {
+	// Not a group: group middleware would also guard the public /merchants/:merchantID routes
+	manageStaff := h.access(PermManageStaff)
+	app.Post("/merchants/:merchantID/invites", h.auth, manageStaff, h.InviteMember)
+	app.Get("/merchants/:merchantID/members", h.auth, manageStaff, h.ListMembers)
+	app.Delete("/merchants/:merchantID/members/:userID", h.auth, manageStaff, h.RemoveMember)
+
+	// Anyone signed in can accept an invite sent to their email address
+	app.Post("/invites/accept", h.auth, h.AcceptInvite)
+}
+
+// InviteMemberRequest defines the JSON request body for inviting staff.
+type InviteMemberRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+func (r InviteMemberRequest) Validate() error {
+	var v validation.Validator
+	v.Required("email", r.Email)
+	v.Email("email", r.Email)
+	if _, err := ParseRole(r.Role); err != nil {
+		v.Add("role", err.Error())
+	}
+	return v.Err()
+}
+
+// AcceptInviteRequest defines the JSON request body for accepting an invite.
+type AcceptInviteRequest struct {
+	Token string `json:"token"`
+}
+
+func (r AcceptInviteRequest) Validate() error {
+	var v validation.Validator
+	v.Required("token", r.Token)
+	return v.Err()
+}
+
+// InviteMember handles inviting someone to work for a merchant. The invite
+// token is emailed to the invitee and is not part of the response.
+func (h *MembershipHandler) InviteMember(c *fiber.Ctx) error {
+	inviterID, err := middlerware.UserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	var req InviteMemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
+	role, _ := ParseRole(req.Role)
+
+	invite, err := h.usecase.InviteMember(c.Context(), inviterID, merchantID, req.Email, role)
+	if err != nil {
+		return membershipErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(invite)
+}
+
+// AcceptInvite handles the invitee accepting an invite with its token.
+func (h *MembershipHandler) AcceptInvite(c *fiber.Ctx) error {
+	userID, err := middlerware.UserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var req AcceptInviteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
+
+	membership, err := h.usecase.AcceptInvite(c.Context(), userID, req.Token)
+	if err != nil {
+		return membershipErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(membership)
+}
+
+// ListMembers handles listing a merchant's owner and staff.
+func (h *MembershipHandler) ListMembers(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	members, err := h.usecase.ListMembers(c.Context(), merchantID)
+	if err != nil {
+		return membershipErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(members)
+}
+
+// RemoveMember handles removing someone from a merchant's staff.
+func (h *MembershipHandler) RemoveMember(c *fiber.Ctx) error {
+	actorID, err := middlerware.UserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+	userID, err := uuid.Parse(c.Params("userID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	if err := h.usecase.RemoveMember(c.Context(), actorID, merchantID, userID); err != nil {
+		return membershipErrorResponse(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// membershipErrorResponse maps the errors shared by the staff endpoints to HTTP responses.
+func membershipErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrMerchantNotFound), errors.Is(err, ErrMembershipNotFound), errors.Is(err, ErrInviteNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrInvalidEmail), errors.Is(err, ErrUnknownRole):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrForbidden), errors.Is(err, ErrInviteEmailMismatch):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrInviteUsed):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrInviteExpired):
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
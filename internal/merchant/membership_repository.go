@@ -0,0 +1,113 @@
+package merchant
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type MembershipRepository interface {
+	// GetMembership returns ErrMembershipNotFound when the user does not work for the merchant.
+	GetMembership(ctx context.Context, merchantID, userID uuid.UUID) (*Membership, error)
+	// ListMembers returns the merchant's memberships, oldest first.
+	ListMembers(ctx context.Context, merchantID uuid.UUID) ([]Membership, error)
+	RemoveMember(ctx context.Context, merchantID, userID uuid.UUID) error
+
+	SaveInvite(ctx context.Context, invite *Invite) error
+	GetInviteByTokenHash(ctx context.Context, tokenHash string) (*Invite, error)
+	// AcceptInvite marks the invite accepted and stores the membership it grants
+	// in one step, replacing any earlier role the user had. It returns
+	// ErrInviteUsed when the invite was already accepted.
+	AcceptInvite(ctx context.Context, invite *Invite, membership Membership) error
+}
+
+type membershipKey struct {
+	merchantID uuid.UUID
+	userID     uuid.UUID
+}
+
+type InMemoryMembershipRepository struct {
+	mu          sync.Mutex
+	memberships map[membershipKey]Membership
+	invites     map[string]*Invite
+}
+
+func NewInMemoryMembershipRepository() *InMemoryMembershipRepository {
+	return &InMemoryMembershipRepository{
+		memberships: make(map[membershipKey]Membership),
+		invites:     make(map[string]*Invite),
+	}
+}
+
+func (r *InMemoryMembershipRepository) GetMembership(ctx context.Context, merchantID, userID uuid.UUID) (*Membership, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	membership, exists := r.memberships[membershipKey{merchantID, userID}]
+	if !exists {
+		return nil, ErrMembershipNotFound
+	}
+	return &membership, nil
+}
+
+func (r *InMemoryMembershipRepository) ListMembers(ctx context.Context, merchantID uuid.UUID) ([]Membership, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	members := []Membership{}
+	for key, membership := range r.memberships {
+		if key.merchantID == merchantID {
+			members = append(members, membership)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].CreatedAt.Before(members[j].CreatedAt)
+	})
+	return members, nil
+}
+
+func (r *InMemoryMembershipRepository) RemoveMember(ctx context.Context, merchantID, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := membershipKey{merchantID, userID}
+	if _, exists := r.memberships[key]; !exists {
+		return ErrMembershipNotFound
+	}
+	delete(r.memberships, key)
+	return nil
+}
+
+func (r *InMemoryMembershipRepository) SaveInvite(ctx context.Context, invite *Invite) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.invites[invite.TokenHash] = invite
+	return nil
+}
+
+func (r *InMemoryMembershipRepository) GetInviteByTokenHash(ctx context.Context, tokenHash string) (*Invite, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	invite, exists := r.invites[tokenHash]
+	if !exists {
+		return nil, ErrInviteNotFound
+	}
+	return invite, nil
+}
+
+func (r *InMemoryMembershipRepository) AcceptInvite(ctx context.Context, invite *Invite, membership Membership) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, exists := r.invites[invite.TokenHash]
+	if !exists {
+		return ErrInviteNotFound
+	}
+	if stored.AcceptedAt != nil {
+		return ErrInviteUsed
+	}
+	acceptedAt := time.Now()
+	stored.AcceptedAt = &acceptedAt
+	invite.AcceptedAt = &acceptedAt
+	r.memberships[membershipKey{membership.MerchantID, membership.UserID}] = membership
+	return nil
+}
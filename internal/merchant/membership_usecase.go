@@ -0,0 +1,183 @@
+package merchant
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"minimart/internal/shared/eventbus"
+	middlerware "minimart/internal/shared/middleware"
+	"minimart/internal/user"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidEmail = errors.New("invalid email address")
+
+type MembershipUsecase interface {
+	// InviteMember invites whoever owns email to work for the merchant. The
+	// inviter may only hand out roles their own role can grant.
+	InviteMember(ctx context.Context, inviterID, merchantID uuid.UUID, email string, role Role) (*Invite, error)
+	// AcceptInvite makes the user a member of the merchant the invite is for.
+	// The invite must have been sent to the user's email address.
+	AcceptInvite(ctx context.Context, userID uuid.UUID, token string) (*Membership, error)
+	// ListMembers returns everyone who works for the merchant, owner first.
+	ListMembers(ctx context.Context, merchantID uuid.UUID) ([]Membership, error)
+	RemoveMember(ctx context.Context, actorID, merchantID, userID uuid.UUID) error
+}
+
+// UserReader is the part of the user module invites are checked against.
+type UserReader interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*user.User, error)
+}
+
+// RoleGranter is the part of the user module that gives merchant owners and
+// staff the merchant role, normally user.UserUsecase.
+type RoleGranter interface {
+	GrantRole(ctx context.Context, userID uuid.UUID, role middlerware.Role) error
+}
+
+type membershipUsecase struct {
+	merchants MerchantRepository
+	members   MembershipRepository
+	users     UserReader
+	roles     RoleGranter
+	eventBus  eventbus.EventBus
+	logger    *slog.Logger
+}
+
+func NewMembershipUsecase(merchants MerchantRepository, members MembershipRepository, users UserReader, roles RoleGranter, eventBus eventbus.EventBus, logger *slog.Logger) MembershipUsecase {
+	return &membershipUsecase{
+		merchants: merchants,
+		members:   members,
+		users:     users,
+		roles:     roles,
+		eventBus:  eventBus,
+		logger:    logger,
+	}
+}
+
+func (u *membershipUsecase) InviteMember(ctx context.Context, inviterID, merchantID uuid.UUID, email string, role Role) (*Invite, error) {
+	address, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return nil, ErrInvalidEmail
+	}
+
+	merchant, err := u.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	inviterRole, err := roleOf(ctx, u.members, merchant, inviterID)
+	if err != nil {
+		return nil, err
+	}
+	if !inviterRole.CanGrant(role) {
+		return nil, ErrForbidden
+	}
+
+	invite, token, err := NewInvite(merchantID, strings.ToLower(address.Address), role, inviterID)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.members.SaveInvite(ctx, invite); err != nil {
+		return nil, err
+	}
+
+	event := MerchantInviteCreatedEvent{
+		InviteID:     invite.ID.String(),
+		MerchantID:   merchant.ID.String(),
+		MerchantName: merchant.Name,
+		Email:        invite.Email,
+		Role:         string(invite.Role),
+		Token:        token,
+		ExpiresAt:    invite.ExpiresAt,
+	}
+	// The invite is already stored. Failing now would have the inviter retry
+	// and pile up pending invites, so a failed publish is logged instead and
+	// the inviter can send the invite again once nobody receives it.
+	if err := u.eventBus.Publish(ctx, event); err != nil {
+		u.logger.Error("Failed to publish merchant invite", "module", "merchant", "invite_id", invite.ID, "error", err)
+	}
+	return invite, nil
+}
+
+func (u *membershipUsecase) AcceptInvite(ctx context.Context, userID uuid.UUID, token string) (*Membership, error) {
+	invite, err := u.members.GetInviteByTokenHash(ctx, HashInviteToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if invite.AcceptedAt != nil {
+		return nil, ErrInviteUsed
+	}
+	if time.Now().After(invite.ExpiresAt) {
+		return nil, ErrInviteExpired
+	}
+
+	invitee, err := u.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(invitee.Email, invite.Email) {
+		return nil, ErrInviteEmailMismatch
+	}
+
+	membership := Membership{
+		MerchantID: invite.MerchantID,
+		UserID:     userID,
+		Role:       invite.Role,
+		CreatedAt:  time.Now(),
+	}
+	if err := u.members.AcceptInvite(ctx, invite, membership); err != nil {
+		return nil, err
+	}
+	// Only members get the merchant role, which takes effect once their
+	// tokens are refreshed.
+	if err := u.roles.GrantRole(ctx, userID, middlerware.RoleMerchant); err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+func (u *membershipUsecase) ListMembers(ctx context.Context, merchantID uuid.UUID) ([]Membership, error) {
+	merchant, err := u.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := u.members.ListMembers(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The owner is recorded on the merchant rather than as a membership.
+	if merchant.OwnerID == uuid.Nil {
+		return members, nil
+	}
+	owner := Membership{MerchantID: merchant.ID, UserID: merchant.OwnerID, Role: RoleOwner, CreatedAt: merchant.CreatedAt}
+	return append([]Membership{owner}, members...), nil
+}
+
+func (u *membershipUsecase) RemoveMember(ctx context.Context, actorID, merchantID, userID uuid.UUID) error {
+	merchant, err := u.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return err
+	}
+	// The merchant's owner can only change by transferring the merchant.
+	if userID == merchant.OwnerID {
+		return ErrForbidden
+	}
+
+	actorRole, err := roleOf(ctx, u.members, merchant, actorID)
+	if err != nil {
+		return err
+	}
+	membership, err := u.members.GetMembership(ctx, merchantID, userID)
+	if err != nil {
+		return err
+	}
+	if !actorRole.CanGrant(membership.Role) {
+		return ErrForbidden
+	}
+	return u.members.RemoveMember(ctx, merchantID, userID)
+}
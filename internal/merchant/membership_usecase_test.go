@@ -0,0 +1,146 @@
+package merchant
+
+import (
+	"context"
+	"errors"
+	"minimart/internal/shared/eventbus"
+	middlerware "minimart/internal/shared/middleware"
+	"minimart/internal/user"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMembershipUsecase_Invites(t *testing.T) {
+	ctx := context.Background()
+	merchants := NewInMemoryMerchantRepository()
+	members := NewInMemoryMembershipRepository()
+	users := user.NewInMemoryUserRepository()
+
+	bus := eventbus.NewInMemoryEventBus()
+	tokens := make(chan string, 10)
+	require.NoError(t, bus.Subscribe(MerchantInviteCreatedTopic, func(ctx context.Context, event eventbus.Event) error {
+		tokens <- event.(MerchantInviteCreatedEvent).Token
+		return nil
+	}))
+	roles := newStubRoles()
+	usecase := NewMembershipUsecase(merchants, members, users, roles, bus, discardLogger)
+
+	newUser := func(email string) uuid.UUID {
+		u := &user.User{ID: uuid.New(), Name: email, Email: email, CreatedAt: time.Now()}
+		require.NoError(t, users.Save(ctx, u))
+		return u.ID
+	}
+	ownerID := newUser("owner@example.com")
+	shop := NewMerchant(ownerID, "Shop", "", DefaultCurrency)
+	require.NoError(t, merchants.Save(ctx, shop))
+
+	// invite sends an invite and returns the token the invitee would be emailed.
+	invite := func(inviterID uuid.UUID, email string, role Role) string {
+		_, err := usecase.InviteMember(ctx, inviterID, shop.ID, email, role)
+		require.NoError(t, err)
+		select {
+		case token := <-tokens:
+			return token
+		case <-time.After(time.Second):
+			t.Fatal("no invite event published")
+			return ""
+		}
+	}
+
+	managerID := newUser("manager@example.com")
+	cookID := newUser("cook@example.com")
+
+	t.Run("should add the invitee with the invited role", func(t *testing.T) {
+		token := invite(ownerID, " Manager@Example.com ", RoleManager)
+
+		membership, err := usecase.AcceptInvite(ctx, managerID, token)
+		require.NoError(t, err)
+		assert.Equal(t, RoleManager, membership.Role)
+		assert.Equal(t, middlerware.RoleMerchant, roles.granted[managerID])
+
+		_, err = usecase.AcceptInvite(ctx, managerID, token)
+		assert.ErrorIs(t, err, ErrInviteUsed)
+	})
+
+	t.Run("should only let the invited email accept", func(t *testing.T) {
+		token := invite(ownerID, "cook@example.com", RoleKitchen)
+
+		_, err := usecase.AcceptInvite(ctx, managerID, token)
+		assert.ErrorIs(t, err, ErrInviteEmailMismatch)
+
+		_, err = usecase.AcceptInvite(ctx, cookID, "not-a-token")
+		assert.ErrorIs(t, err, ErrInviteNotFound)
+
+		_, err = usecase.AcceptInvite(ctx, cookID, token)
+		require.NoError(t, err)
+	})
+
+	t.Run("should only make users merchants once they are members", func(t *testing.T) {
+		acceptFailed := errors.New("connection reset")
+		roles := newStubRoles()
+		failing := NewMembershipUsecase(merchants, failingAccepts{members, acceptFailed}, users, roles, bus, discardLogger)
+		token := invite(ownerID, "waiter@example.com", RoleCashier)
+
+		_, err := failing.AcceptInvite(ctx, newUser("waiter@example.com"), token)
+		assert.ErrorIs(t, err, acceptFailed)
+		assert.Empty(t, roles.granted)
+	})
+
+	t.Run("should return stored invites when they cannot be announced", func(t *testing.T) {
+		unannounced := NewMembershipUsecase(merchants, members, users, roles, unreachableEventBus{}, discardLogger)
+
+		sent, err := unannounced.InviteMember(ctx, ownerID, shop.ID, "host@example.com", RoleCashier)
+		require.NoError(t, err)
+		stored, err := members.GetInviteByTokenHash(ctx, sent.TokenHash)
+		require.NoError(t, err)
+		assert.Equal(t, sent.ID, stored.ID)
+	})
+
+	t.Run("should reject expired invites", func(t *testing.T) {
+		expired, token, err := NewInvite(shop.ID, "late@example.com", RoleCashier, ownerID)
+		require.NoError(t, err)
+		expired.ExpiresAt = time.Now().Add(-time.Minute)
+		require.NoError(t, members.SaveInvite(ctx, expired))
+
+		_, err = usecase.AcceptInvite(ctx, newUser("late@example.com"), token)
+		assert.ErrorIs(t, err, ErrInviteExpired)
+	})
+
+	t.Run("should limit what managers can grant", func(t *testing.T) {
+		_, err := usecase.InviteMember(ctx, managerID, shop.ID, "boss@example.com", RoleManager)
+		assert.ErrorIs(t, err, ErrForbidden)
+
+		_, err = usecase.InviteMember(ctx, cookID, shop.ID, "friend@example.com", RoleKitchen)
+		assert.ErrorIs(t, err, ErrForbidden)
+
+		_, err = usecase.InviteMember(ctx, ownerID, shop.ID, "not an email", RoleKitchen)
+		assert.ErrorIs(t, err, ErrInvalidEmail)
+	})
+
+	t.Run("should list and remove members", func(t *testing.T) {
+		list, err := usecase.ListMembers(ctx, shop.ID)
+		require.NoError(t, err)
+		require.Len(t, list, 3)
+		assert.Equal(t, Membership{MerchantID: shop.ID, UserID: ownerID, Role: RoleOwner, CreatedAt: shop.CreatedAt}, list[0])
+
+		assert.ErrorIs(t, usecase.RemoveMember(ctx, managerID, shop.ID, ownerID), ErrForbidden)
+		assert.ErrorIs(t, usecase.RemoveMember(ctx, cookID, shop.ID, managerID), ErrForbidden)
+
+		require.NoError(t, usecase.RemoveMember(ctx, managerID, shop.ID, cookID))
+		assert.ErrorIs(t, usecase.RemoveMember(ctx, ownerID, shop.ID, cookID), ErrMembershipNotFound)
+	})
+}
+
+// failingAccepts fails to accept any invite.
+type failingAccepts struct {
+	MembershipRepository
+	err error
+}
+
+func (r failingAccepts) AcceptInvite(ctx context.Context, invite *Invite, membership Membership) error {
+	return r.err
+}
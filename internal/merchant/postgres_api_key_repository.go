@@ -0,0 +1,132 @@
+package merchant
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresAPIKeyRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresAPIKeyRepository(db *pgxpool.Pool) APIKeyRepository {
+	return &PostgresAPIKeyRepository{db: db}
+}
+
+const apiKeyColumns = `id, merchant_id, name, prefix, secret_hash, scopes, created_by, created_at, rotated_at, last_used_at, revoked_at`
+
+func (r *PostgresAPIKeyRepository) SaveAPIKey(ctx context.Context, key *APIKey) error {
+	query := `
+		INSERT INTO merchant_api_keys (id, merchant_id, name, prefix, secret_hash, scopes, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+	`
+	_, err := r.db.Exec(ctx, query, key.ID, key.MerchantID, key.Name, key.Prefix, key.SecretHash,
+		scopeStrings(key.Scopes), key.CreatedBy, key.CreatedAt)
+	return err
+}
+
+func (r *PostgresAPIKeyRepository) GetAPIKey(ctx context.Context, merchantID, keyID uuid.UUID) (*APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM merchant_api_keys WHERE merchant_id = $1 AND id = $2;`
+	return scanAPIKey(r.db.QueryRow(ctx, query, merchantID, keyID))
+}
+
+func (r *PostgresAPIKeyRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM merchant_api_keys WHERE prefix = $1;`
+	return scanAPIKey(r.db.QueryRow(ctx, query, prefix))
+}
+
+func (r *PostgresAPIKeyRepository) ListAPIKeys(ctx context.Context, merchantID uuid.UUID) ([]APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM merchant_api_keys WHERE merchant_id = $1 ORDER BY created_at, id;`
+	rows, err := r.db.Query(ctx, query, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *PostgresAPIKeyRepository) RotateAPIKey(ctx context.Context, key *APIKey) error {
+	query := `
+		UPDATE merchant_api_keys
+		SET prefix = $2, secret_hash = $3, rotated_at = $4
+		WHERE id = $1 AND revoked_at IS NULL;
+	`
+	tag, err := r.db.Exec(ctx, query, key.ID, key.Prefix, key.SecretHash, key.RotatedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyRevoked
+	}
+	return nil
+}
+
+func (r *PostgresAPIKeyRepository) RevokeAPIKey(ctx context.Context, merchantID, keyID uuid.UUID, at time.Time) error {
+	query := `
+		UPDATE merchant_api_keys
+		SET revoked_at = COALESCE(revoked_at, $3)
+		WHERE merchant_id = $1 AND id = $2;
+	`
+	tag, err := r.db.Exec(ctx, query, merchantID, keyID, at)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r *PostgresAPIKeyRepository) TouchAPIKey(ctx context.Context, keyID uuid.UUID, at time.Time) error {
+	_, err := r.db.Exec(ctx, "UPDATE merchant_api_keys SET last_used_at = $2 WHERE id = $1;", keyID, at)
+	return err
+}
+
+func scanAPIKey(row pgx.Row) (*APIKey, error) {
+	var key APIKey
+	var scopes []string
+	var createdBy pgtype.UUID
+	err := row.Scan(&key.ID, &key.MerchantID, &key.Name, &key.Prefix, &key.SecretHash, &scopes,
+		&createdBy, &key.CreatedAt, &key.RotatedAt, &key.LastUsedAt, &key.RevokedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	// Whoever created the key may have deleted their account since.
+	key.CreatedBy = createdBy.Bytes
+	key.Scopes = make([]APIKeyScope, 0, len(scopes))
+	for _, scope := range scopes {
+		key.Scopes = append(key.Scopes, APIKeyScope(scope))
+	}
+	return &key, nil
+}
+
+// scopeStrings converts scopes for a TEXT[] column. It never returns nil,
+// which pgx would encode as NULL rather than an empty array.
+func scopeStrings(scopes []APIKeyScope) []string {
+	strs := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		strs = append(strs, string(scope))
+	}
+	return strs
+}
@@ -0,0 +1,137 @@
+package merchant
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresMembershipRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresMembershipRepository(db *pgxpool.Pool) MembershipRepository {
+	return &PostgresMembershipRepository{db: db}
+}
+
+func (r *PostgresMembershipRepository) GetMembership(ctx context.Context, merchantID, userID uuid.UUID) (*Membership, error) {
+	query := `
+		SELECT merchant_id, user_id, role, created_at
+		FROM merchant_memberships
+		WHERE merchant_id = $1 AND user_id = $2;
+	`
+	var membership Membership
+	err := r.db.QueryRow(ctx, query, merchantID, userID).Scan(&membership.MerchantID, &membership.UserID, &membership.Role, &membership.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMembershipNotFound
+		}
+		return nil, err
+	}
+	return &membership, nil
+}
+
+func (r *PostgresMembershipRepository) ListMembers(ctx context.Context, merchantID uuid.UUID) ([]Membership, error) {
+	query := `
+		SELECT merchant_id, user_id, role, created_at
+		FROM merchant_memberships
+		WHERE merchant_id = $1
+		ORDER BY created_at, user_id;
+	`
+	rows, err := r.db.Query(ctx, query, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []Membership{}
+	for rows.Next() {
+		var membership Membership
+		if err := rows.Scan(&membership.MerchantID, &membership.UserID, &membership.Role, &membership.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, membership)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *PostgresMembershipRepository) RemoveMember(ctx context.Context, merchantID, userID uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM merchant_memberships WHERE merchant_id = $1 AND user_id = $2;", merchantID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMembershipNotFound
+	}
+	return nil
+}
+
+func (r *PostgresMembershipRepository) SaveInvite(ctx context.Context, invite *Invite) error {
+	query := `
+		INSERT INTO merchant_invites (id, merchant_id, email, role, token_hash, invited_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+	`
+	_, err := r.db.Exec(ctx, query, invite.ID, invite.MerchantID, invite.Email, invite.Role, invite.TokenHash,
+		invite.InvitedBy, invite.ExpiresAt, invite.CreatedAt)
+	return err
+}
+
+func (r *PostgresMembershipRepository) GetInviteByTokenHash(ctx context.Context, tokenHash string) (*Invite, error) {
+	query := `
+		SELECT id, merchant_id, email, role, token_hash, invited_by, expires_at, accepted_at, created_at
+		FROM merchant_invites
+		WHERE token_hash = $1;
+	`
+	var invite Invite
+	var invitedBy pgtype.UUID
+	err := r.db.QueryRow(ctx, query, tokenHash).Scan(&invite.ID, &invite.MerchantID, &invite.Email, &invite.Role, &invite.TokenHash,
+		&invitedBy, &invite.ExpiresAt, &invite.AcceptedAt, &invite.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInviteNotFound
+		}
+		return nil, err
+	}
+	// The inviter may have deleted their account since.
+	invite.InvitedBy = invitedBy.Bytes
+	return &invite, nil
+}
+
+func (r *PostgresMembershipRepository) AcceptInvite(ctx context.Context, invite *Invite, membership Membership) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Only one of two concurrent accepts can claim the invite.
+	acceptQuery := `
+		UPDATE merchant_invites
+		SET accepted_at = NOW()
+		WHERE id = $1 AND accepted_at IS NULL
+		RETURNING accepted_at;
+	`
+	if err := tx.QueryRow(ctx, acceptQuery, invite.ID).Scan(&invite.AcceptedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInviteUsed
+		}
+		return err
+	}
+
+	memberQuery := `
+		INSERT INTO merchant_memberships (merchant_id, user_id, role, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (merchant_id, user_id) DO UPDATE SET role = EXCLUDED.role;
+	`
+	if _, err := tx.Exec(ctx, memberQuery, membership.MerchantID, membership.UserID, membership.Role, membership.CreatedAt); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -19,31 +21,312 @@ func NewPostgresMerchantRepository(db *pgxpool.Pool) MerchantRepository {
 
 func (r *PostgresMerchantRepository) Save(ctx context.Context, merchant *Merchant) error {
 	query := `
-		INSERT INTO merchants (id, name, description, is_active)
-		VALUES ($1, $2, $3, $4);
+		INSERT INTO merchants (id, owner_id, name, description, contact_email, contact_phone, address, latitude, longitude, delivery_radius_km,
+			is_active, status, status_reason, currency, timezone, commission_bps, max_open_orders, created_at, slug)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19);
 	`
-	_, err := r.db.Exec(ctx, query, merchant.ID, merchant.Name, merchant.Description, merchant.IsActive)
+	var ownerID *uuid.UUID
+	if merchant.OwnerID != uuid.Nil {
+		ownerID = &merchant.OwnerID
+	}
+	latitude, longitude := locationArgs(merchant.Location)
+	_, err := r.db.Exec(ctx, query, merchant.ID, ownerID, merchant.Name, merchant.Description, merchant.ContactEmail, merchant.ContactPhone,
+		merchant.Address, latitude, longitude, merchant.DeliveryRadiusKm,
+		merchant.IsActive, merchant.Status, merchant.StatusReason, merchant.Currency, merchant.Timezone, merchant.CommissionBps, merchant.MaxOpenOrders, merchant.CreatedAt,
+		merchant.Slug)
+	if err != nil {
+		return slugConflict(err)
+	}
+	return nil
+}
+
+func (r *PostgresMerchantRepository) Update(ctx context.Context, merchant *Merchant) error {
+	query := `
+		UPDATE merchants
+		SET name = $2, description = $3, contact_email = $4, contact_phone = $5,
+			address = $6, latitude = $7, longitude = $8, delivery_radius_km = $9, timezone = $10, is_active = $11,
+			commission_bps = $12, max_open_orders = $13
+		WHERE id = $1;
+	`
+	latitude, longitude := locationArgs(merchant.Location)
+	tag, err := r.db.Exec(ctx, query, merchant.ID, merchant.Name, merchant.Description, merchant.ContactEmail, merchant.ContactPhone,
+		merchant.Address, latitude, longitude, merchant.DeliveryRadiusKm, merchant.Timezone, merchant.IsActive, merchant.CommissionBps, merchant.MaxOpenOrders)
 	if err != nil {
 		return err
 	}
+	if tag.RowsAffected() == 0 {
+		return ErrMerchantNotFound
+	}
 	return nil
 }
 
 func (r *PostgresMerchantRepository) GetByID(ctx context.Context, id uuid.UUID) (*Merchant, error) {
 	query := `
-		SELECT id, name, description, is_active
-		FROM menu_items
+		SELECT ` + merchantColumns + `
+		FROM merchants
 		WHERE id = $1;
 	`
-	merchant := &Merchant{}
+	merchant, err := scanMerchant(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMerchantNotFound
+		}
+		return nil, err
+	}
 
-	err := r.db.QueryRow(ctx, query, id).Scan(&merchant.ID, &merchant.Name, &merchant.Description, &merchant.IsActive)
+	return merchant, nil
+}
+
+func (r *PostgresMerchantRepository) GetBySlug(ctx context.Context, slug string) (*Merchant, error) {
+	query := `
+		SELECT ` + merchantColumns + `
+		FROM merchants
+		WHERE id = COALESCE(
+			(SELECT id FROM merchants WHERE slug = $1),
+			(SELECT merchant_id FROM merchant_former_slugs WHERE slug = $1)
+		);
+	`
+	merchant, err := scanMerchant(r.db.QueryRow(ctx, query, slug))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, errors.New("order not found")
+			return nil, ErrMerchantNotFound
 		}
 		return nil, err
 	}
+	return merchant, nil
+}
+
+// ChangeSlug updates the slug and moves the previous one to the merchant's
+// former slugs in one transaction. Taking back one of its own former slugs
+// removes it from that list.
+func (r *PostgresMerchantRepository) ChangeSlug(ctx context.Context, merchant *Merchant, previous string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var formerlyOther bool
+	formerQuery := "SELECT EXISTS (SELECT 1 FROM merchant_former_slugs WHERE slug = $1 AND merchant_id <> $2);"
+	if err := tx.QueryRow(ctx, formerQuery, merchant.Slug, merchant.ID).Scan(&formerlyOther); err != nil {
+		return err
+	}
+	if formerlyOther {
+		return ErrSlugTaken
+	}
+
+	tag, err := tx.Exec(ctx, "UPDATE merchants SET slug = $2 WHERE id = $1;", merchant.ID, merchant.Slug)
+	if err != nil {
+		return slugConflict(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMerchantNotFound
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM merchant_former_slugs WHERE slug = $1;", merchant.Slug); err != nil {
+		return err
+	}
+	retireQuery := `
+		INSERT INTO merchant_former_slugs (slug, merchant_id)
+		VALUES ($1, $2)
+		ON CONFLICT (slug) DO NOTHING;
+	`
+	if _, err := tx.Exec(ctx, retireQuery, previous, merchant.ID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresMerchantRepository) List(ctx context.Context, opts ListOptions) ([]*Merchant, int, error) {
+	const listFilter = "($1::BOOLEAN IS NULL OR is_active = $1) AND ($2::TEXT IS NULL OR status = $2)"
+
+	// The ORDER BY is chosen from a fixed set, never from user input.
+	orderBy := "name, id"
+	if opts.Sort == SortByNewest {
+		orderBy = "created_at DESC, name, id"
+	}
+
+	query := `
+		SELECT ` + merchantColumns + `, COUNT(*) OVER ()
+		FROM merchants
+		WHERE ` + listFilter + `
+		ORDER BY ` + orderBy + `
+		LIMIT $3 OFFSET $4;
+	`
+	rows, err := r.db.Query(ctx, query, opts.Active, opts.Status, opts.Limit, opts.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
 
+	merchants := []*Merchant{}
+	total := 0
+	for rows.Next() {
+		merchant, err := scanMerchant(rows, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+		merchants = append(merchants, merchant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// A page past the end has no rows to carry the window count.
+	if len(merchants) == 0 && opts.Offset > 0 {
+		countQuery := "SELECT COUNT(*) FROM merchants WHERE " + listFilter
+		if err := r.db.QueryRow(ctx, countQuery, opts.Active, opts.Status).Scan(&total); err != nil {
+			return nil, 0, err
+		}
+	}
+	return merchants, total, nil
+}
+
+// ListNearby computes haversine distances in SQL. The bounding box lets the
+// location index discard far away merchants before any distance is computed.
+func (r *PostgresMerchantRepository) ListNearby(ctx context.Context, query NearbyQuery) ([]NearbyMerchant, error) {
+	minLat, maxLat, minLng, maxLng := boundingBox(query.Center, query.RadiusKm)
+	sqlQuery := `
+		SELECT ` + merchantColumns + `, distance_km
+		FROM (
+			SELECT *, 2 * $3::DOUBLE PRECISION * ASIN(LEAST(1, SQRT(
+				POWER(SIN(RADIANS(latitude - $1) / 2), 2) +
+				COS(RADIANS($1)) * COS(RADIANS(latitude)) * POWER(SIN(RADIANS(longitude - $2) / 2), 2)
+			))) AS distance_km
+			FROM merchants
+			WHERE status = $4 AND is_active
+				AND latitude BETWEEN $5 AND $6
+				AND longitude BETWEEN $7 AND $8
+		) AS candidates
+		WHERE distance_km <= LEAST(delivery_radius_km, $9)
+		ORDER BY distance_km, name, id
+		LIMIT $10;
+	`
+	rows, err := r.db.Query(ctx, sqlQuery, query.Center.Latitude, query.Center.Longitude, EarthRadiusKm, StatusApproved,
+		minLat, maxLat, minLng, maxLng, query.RadiusKm, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nearby := []NearbyMerchant{}
+	for rows.Next() {
+		var found NearbyMerchant
+		found.Merchant, err = scanMerchant(rows, &found.DistanceKm)
+		if err != nil {
+			return nil, err
+		}
+		nearby = append(nearby, found)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return nearby, nil
+}
+
+// ChangeStatus updates the merchant's status and appends to its history in one transaction.
+func (r *PostgresMerchantRepository) ChangeStatus(ctx context.Context, merchant *Merchant, change StatusChange) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE merchants
+		SET status = $2, status_reason = $3, is_active = $4
+		WHERE id = $1;
+	`
+	tag, err := tx.Exec(ctx, query, merchant.ID, merchant.Status, merchant.StatusReason, merchant.IsActive)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMerchantNotFound
+	}
+
+	var changedBy *uuid.UUID
+	if change.ChangedBy != uuid.Nil {
+		changedBy = &change.ChangedBy
+	}
+	historyQuery := `
+		INSERT INTO merchant_status_history (merchant_id, from_status, to_status, reason, changed_by, changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6);
+	`
+	if _, err := tx.Exec(ctx, historyQuery, change.MerchantID, change.From, change.To, change.Reason, changedBy, change.ChangedAt); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresMerchantRepository) ListStatusHistory(ctx context.Context, merchantID uuid.UUID) ([]StatusChange, error) {
+	query := `
+		SELECT merchant_id, from_status, to_status, reason, changed_by, changed_at
+		FROM merchant_status_history
+		WHERE merchant_id = $1
+		ORDER BY changed_at, id;
+	`
+	rows, err := r.db.Query(ctx, query, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []StatusChange{}
+	for rows.Next() {
+		var change StatusChange
+		var changedBy pgtype.UUID
+		if err := rows.Scan(&change.MerchantID, &change.From, &change.To, &change.Reason, &changedBy, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		change.ChangedBy = changedBy.Bytes
+		history = append(history, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// slugConflict turns a unique violation on the slug index into ErrSlugTaken.
+func slugConflict(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_merchants_slug" { // Unique violation
+		return ErrSlugTaken
+	}
+	return err
+}
+
+const merchantColumns = "id, owner_id, slug, name, description, contact_email, contact_phone, address, latitude, longitude, delivery_radius_km, " +
+	"is_active, status, status_reason, currency, timezone, commission_bps, max_open_orders, created_at"
+
+// scanMerchant scans the merchantColumns, followed by any extra columns.
+func scanMerchant(row pgx.Row, extra ...any) (*Merchant, error) {
+	merchant := &Merchant{}
+
+	// Merchants registered before ownership existed have no owner.
+	var ownerID pgtype.UUID
+	var latitude, longitude *float64
+	dest := append([]any{
+		&merchant.ID, &ownerID, &merchant.Slug, &merchant.Name, &merchant.Description, &merchant.ContactEmail, &merchant.ContactPhone,
+		&merchant.Address, &latitude, &longitude, &merchant.DeliveryRadiusKm,
+		&merchant.IsActive, &merchant.Status, &merchant.StatusReason, &merchant.Currency, &merchant.Timezone, &merchant.CommissionBps, &merchant.MaxOpenOrders, &merchant.CreatedAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	merchant.OwnerID = ownerID.Bytes
+	if latitude != nil && longitude != nil {
+		merchant.Location = &GeoPoint{Latitude: *latitude, Longitude: *longitude}
+	}
 	return merchant, nil
 }
+
+// locationArgs returns the latitude and longitude query arguments, which are
+// NULL for merchants without a location.
+func locationArgs(location *GeoPoint) (latitude, longitude *float64) {
+	if location == nil {
+		return nil, nil
+	}
+	return &location.Latitude, &location.Longitude
+}
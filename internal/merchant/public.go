@@ -0,0 +1,75 @@
+package merchant
+
+import (
+	"minimart/internal/shared/money"
+
+	"github.com/google/uuid"
+)
+
+// PublicMerchant is what customers see of a merchant. Its owner, contact
+// details, review status and commercial terms stay private.
+type PublicMerchant struct {
+	ID               uuid.UUID      `json:"id"`
+	Slug             string         `json:"slug"`
+	Name             string         `json:"name"`
+	Description      string         `json:"description"`
+	Address          string         `json:"address"`
+	Location         *GeoPoint      `json:"location,omitempty"`
+	DeliveryRadiusKm float64        `json:"delivery_radius_km"`
+	IsActive         bool           `json:"is_active"`
+	Currency         money.Currency `json:"currency"`
+	Timezone         string         `json:"timezone"`
+}
+
+// NewPublicMerchant returns the public view of m.
+func NewPublicMerchant(m *Merchant) PublicMerchant {
+	return PublicMerchant{
+		ID:               m.ID,
+		Slug:             m.Slug,
+		Name:             m.Name,
+		Description:      m.Description,
+		Address:          m.Address,
+		Location:         m.Location,
+		DeliveryRadiusKm: m.DeliveryRadiusKm,
+		IsActive:         m.IsActive,
+		Currency:         m.Currency,
+		Timezone:         m.Timezone,
+	}
+}
+
+// PublicMerchantPage is one page of the public merchant directory.
+type PublicMerchantPage struct {
+	Merchants []PublicMerchant `json:"merchants"`
+	Total     int              `json:"total"`
+	Page      int              `json:"page"`
+	PageSize  int              `json:"page_size"`
+}
+
+// NewPublicMerchantPage returns the public view of page.
+func NewPublicMerchantPage(page *MerchantPage) PublicMerchantPage {
+	public := PublicMerchantPage{
+		Merchants: make([]PublicMerchant, 0, len(page.Merchants)),
+		Total:     page.Total,
+		Page:      page.Page,
+		PageSize:  page.PageSize,
+	}
+	for _, m := range page.Merchants {
+		public.Merchants = append(public.Merchants, NewPublicMerchant(m))
+	}
+	return public
+}
+
+// PublicNearbyMerchant is a merchant found by a nearby search, as customers see it.
+type PublicNearbyMerchant struct {
+	PublicMerchant
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// NewPublicNearbyMerchants returns the public view of merchants.
+func NewPublicNearbyMerchants(merchants []NearbyMerchant) []PublicNearbyMerchant {
+	public := make([]PublicNearbyMerchant, 0, len(merchants))
+	for _, m := range merchants {
+		public = append(public, PublicNearbyMerchant{PublicMerchant: NewPublicMerchant(m.Merchant), DistanceKm: m.DistanceKm})
+	}
+	return public
+}
@@ -2,32 +2,100 @@ package merchant
 
 import (
 	"context"
+	"errors"
+	"sort"
+	"strings"
 
 	"github.com/google/uuid"
 )
 
+// ErrMerchantNotFound is returned when a merchant does not exist.
+var ErrMerchantNotFound = errors.New("merchant not found")
+
+// SortOrder is the order merchants are listed in.
+type SortOrder string
+
+const (
+	SortByName   SortOrder = "name"
+	SortByNewest SortOrder = "newest"
+)
+
+// ListOptions filters and paginates a merchant listing.
+type ListOptions struct {
+	// Active, when set, only lists merchants with that IsActive value.
+	Active *bool
+	// Status, when set, only lists merchants in that onboarding status.
+	Status *MerchantStatus
+	Sort   SortOrder
+	Limit  int
+	Offset int
+}
+
+// NearbyQuery finds merchants that deliver to Center within RadiusKm of it.
+type NearbyQuery struct {
+	Center   GeoPoint
+	RadiusKm float64
+	Limit    int
+}
+
+// NearbyMerchant is a merchant found by a nearby search.
+type NearbyMerchant struct {
+	*Merchant
+	DistanceKm float64 `json:"distance_km"`
+}
+
 type MerchantRepository interface {
 	GetByID(ctx context.Context, id uuid.UUID) (*Merchant, error)
+	// GetBySlug returns the merchant whose current or former slug is slug.
+	GetBySlug(ctx context.Context, slug string) (*Merchant, error)
+	// Save stores a new merchant, or returns ErrSlugTaken if its slug is in use.
 	Save(ctx context.Context, merchant *Merchant) error
+	// Update overwrites the mutable fields of an existing merchant, except its slug.
+	Update(ctx context.Context, merchant *Merchant) error
+	// ChangeSlug stores the merchant's new slug and keeps previous as a former
+	// slug of the merchant. It returns ErrSlugTaken if another merchant uses the new one.
+	ChangeSlug(ctx context.Context, merchant *Merchant, previous string) error
+	// List returns one page of merchants and the total number matching the filter.
+	List(ctx context.Context, opts ListOptions) ([]*Merchant, int, error)
+	// ListNearby returns the approved, open merchants that deliver to the
+	// query's center and are within its radius, closest first.
+	ListNearby(ctx context.Context, query NearbyQuery) ([]NearbyMerchant, error)
+	// ChangeStatus stores the merchant's new status and records the change in its history.
+	ChangeStatus(ctx context.Context, merchant *Merchant, change StatusChange) error
+	// ListStatusHistory returns the merchant's status changes, oldest first.
+	ListStatusHistory(ctx context.Context, merchantID uuid.UUID) ([]StatusChange, error)
 }
 
 type InMemoryMerchantRepository struct {
 	merchants map[uuid.UUID]*Merchant
+	history   map[uuid.UUID][]StatusChange
+	// formerSlugs maps slugs merchants have moved away from to their merchant.
+	formerSlugs map[string]uuid.UUID
 }
 
 func NewInMemoryMerchantRepository() *InMemoryMerchantRepository {
 	merchant1 := &Merchant{
-		ID:          uuid.New(),
-		Name:        "Merchant 1",
-		Description: "Merchant 1 description",
-		IsActive:    false,
+		ID:            uuid.New(),
+		Slug:          "merchant-1",
+		Name:          "Merchant 1",
+		Description:   "Merchant 1 description",
+		IsActive:      false,
+		Status:        StatusSubmitted,
+		Currency:      DefaultCurrency,
+		Timezone:      DefaultTimezone,
+		CommissionBps: DefaultCommissionBps,
 	}
 
 	merchant2 := &Merchant{
-		ID:          uuid.New(),
-		Name:        "Merchant 2",
-		Description: "Merchant 2 description",
-		IsActive:    true,
+		ID:            uuid.New(),
+		Slug:          "merchant-2",
+		Name:          "Merchant 2",
+		Description:   "Merchant 2 description",
+		IsActive:      true,
+		Status:        StatusApproved,
+		Currency:      DefaultCurrency,
+		Timezone:      DefaultTimezone,
+		CommissionBps: DefaultCommissionBps,
 	}
 
 	return &InMemoryMerchantRepository{
@@ -35,18 +103,129 @@ func NewInMemoryMerchantRepository() *InMemoryMerchantRepository {
 			merchant1.ID: merchant1,
 			merchant2.ID: merchant2,
 		},
+		history:     map[uuid.UUID][]StatusChange{},
+		formerSlugs: map[string]uuid.UUID{},
 	}
 }
 
 func (r *InMemoryMerchantRepository) GetByID(ctx context.Context, id uuid.UUID) (*Merchant, error) {
 	merchant, exists := r.merchants[id]
 	if !exists {
-		return nil, nil
+		return nil, ErrMerchantNotFound
 	}
 	return merchant, nil
 }
 
+func (r *InMemoryMerchantRepository) GetBySlug(ctx context.Context, slug string) (*Merchant, error) {
+	for _, merchant := range r.merchants {
+		if merchant.Slug == slug {
+			return merchant, nil
+		}
+	}
+	if id, found := r.formerSlugs[slug]; found {
+		return r.GetByID(ctx, id)
+	}
+	return nil, ErrMerchantNotFound
+}
+
 func (r *InMemoryMerchantRepository) Save(ctx context.Context, merchant *Merchant) error {
+	if r.slugTaken(merchant) {
+		return ErrSlugTaken
+	}
+	r.merchants[merchant.ID] = merchant
+	return nil
+}
+
+func (r *InMemoryMerchantRepository) Update(ctx context.Context, merchant *Merchant) error {
+	if _, exists := r.merchants[merchant.ID]; !exists {
+		return ErrMerchantNotFound
+	}
+	r.merchants[merchant.ID] = merchant
+	return nil
+}
+
+func (r *InMemoryMerchantRepository) ChangeSlug(ctx context.Context, merchant *Merchant, previous string) error {
+	if _, exists := r.merchants[merchant.ID]; !exists {
+		return ErrMerchantNotFound
+	}
+	if r.slugTaken(merchant) {
+		return ErrSlugTaken
+	}
 	r.merchants[merchant.ID] = merchant
+	delete(r.formerSlugs, merchant.Slug)
+	r.formerSlugs[previous] = merchant.ID
 	return nil
 }
+
+// slugTaken reports whether another merchant uses the merchant's slug now or used it before.
+func (r *InMemoryMerchantRepository) slugTaken(merchant *Merchant) bool {
+	if merchant.Slug == "" {
+		return false
+	}
+	for _, other := range r.merchants {
+		if other.ID != merchant.ID && other.Slug == merchant.Slug {
+			return true
+		}
+	}
+	id, found := r.formerSlugs[merchant.Slug]
+	return found && id != merchant.ID
+}
+
+func (r *InMemoryMerchantRepository) List(ctx context.Context, opts ListOptions) ([]*Merchant, int, error) {
+	matched := []*Merchant{}
+	for _, merchant := range r.merchants {
+		if (opts.Active == nil || merchant.IsActive == *opts.Active) && (opts.Status == nil || merchant.Status == *opts.Status) {
+			matched = append(matched, merchant)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if opts.Sort == SortByNewest && !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		if byName := strings.Compare(a.Name, b.Name); byName != 0 {
+			return byName < 0
+		}
+		return a.ID.String() < b.ID.String()
+	})
+
+	total := len(matched)
+	start := min(opts.Offset, total)
+	end := min(start+opts.Limit, total)
+	return matched[start:end], total, nil
+}
+
+func (r *InMemoryMerchantRepository) ListNearby(ctx context.Context, query NearbyQuery) ([]NearbyMerchant, error) {
+	nearby := []NearbyMerchant{}
+	for _, merchant := range r.merchants {
+		if !merchant.AcceptsCustomers() || !merchant.Delivers(query.Center) {
+			continue
+		}
+		distance := DistanceKm(*merchant.Location, query.Center)
+		if distance <= query.RadiusKm {
+			nearby = append(nearby, NearbyMerchant{Merchant: merchant, DistanceKm: distance})
+		}
+	}
+
+	sort.Slice(nearby, func(i, j int) bool {
+		if nearby[i].DistanceKm != nearby[j].DistanceKm {
+			return nearby[i].DistanceKm < nearby[j].DistanceKm
+		}
+		return nearby[i].Name < nearby[j].Name
+	})
+	return nearby[:min(query.Limit, len(nearby))], nil
+}
+
+func (r *InMemoryMerchantRepository) ChangeStatus(ctx context.Context, merchant *Merchant, change StatusChange) error {
+	if _, exists := r.merchants[merchant.ID]; !exists {
+		return ErrMerchantNotFound
+	}
+	r.merchants[merchant.ID] = merchant
+	r.history[merchant.ID] = append(r.history[merchant.ID], change)
+	return nil
+}
+
+func (r *InMemoryMerchantRepository) ListStatusHistory(ctx context.Context, merchantID uuid.UUID) ([]StatusChange, error) {
+	return append([]StatusChange{}, r.history[merchantID]...), nil
+}
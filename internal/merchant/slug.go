@@ -0,0 +1,146 @@
+package merchant
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	MinSlugLength = 3
+	MaxSlugLength = 60
+)
+
+var (
+	ErrInvalidSlug = errors.New("slug must be 3 to 60 lowercase letters, digits and single hyphens")
+	// ErrSlugTaken is returned when another merchant uses or used the slug.
+	ErrSlugTaken = errors.New("slug is already taken")
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// reservedSlugs are the /merchants/... routes that are not a merchant.
+var reservedSlugs = map[string]bool{
+	"register": true,
+	"nearby":   true,
+}
+
+// Slugify turns a merchant name into a URL-safe slug, e.g. "Café Olé!" into
+// "cafe-ole", and "Joe's" into "joes". The result may still be too short or reserved; see ValidateSlug.
+func Slugify(name string) string {
+	var slug strings.Builder
+	hyphen := false
+	// NFKD splits accented letters into the letter and its accent, which is dropped.
+	for _, r := range norm.NFKD.String(strings.ToLower(name)) {
+		switch {
+		case unicode.Is(unicode.Mn, r), r == '\'', r == '’':
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if hyphen && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			hyphen = false
+			slug.WriteRune(r)
+		default:
+			hyphen = true
+		}
+	}
+	return strings.TrimRight(truncate(slug.String(), MaxSlugLength), "-")
+}
+
+// nameSlug returns a valid slug for a merchant named name, padding slugs
+// that are too short or reserved.
+func nameSlug(name string) string {
+	slug := Slugify(name)
+	if slug == "" {
+		return "merchant"
+	}
+	if ValidateSlug(slug) != nil {
+		return withSuffix(slug, "1")
+	}
+	return slug
+}
+
+// ValidateSlug checks a slug chosen by an owner.
+func ValidateSlug(slug string) error {
+	if len(slug) < MinSlugLength || len(slug) > MaxSlugLength || !slugPattern.MatchString(slug) {
+		return ErrInvalidSlug
+	}
+	// A slug that parses as an ID would never be looked up.
+	if _, err := uuid.Parse(slug); err == nil || reservedSlugs[slug] {
+		return fmt.Errorf("%w: %q is reserved", ErrInvalidSlug, slug)
+	}
+	return nil
+}
+
+// withSuffix tells a slug apart from base, e.g. "joes-pizza-2", keeping it
+// within MaxSlugLength.
+func withSuffix(base, suffix string) string {
+	suffix = "-" + suffix
+	return strings.TrimRight(truncate(base, MaxSlugLength-len(suffix)), "-") + suffix
+}
+
+// slugCandidate returns the nth slug to try for a merchant named like base:
+// base itself, then base-2, base-3 and so on.
+func slugCandidate(base string, n int) string {
+	if n == 1 {
+		return base
+	}
+	return withSuffix(base, strconv.Itoa(n))
+}
+
+func truncate(s string, length int) string {
+	if len(s) > length {
+		return s[:length]
+	}
+	return s
+}
+
+// ResolveSlugs returns a middleware that lets every /merchants/:merchantID
+// route take a slug instead of an ID. Current slugs are rewritten to the ID
+// before routing; former slugs redirect GET requests to the current one and
+// are rewritten for other methods. It must be mounted on /merchants before
+// the merchant routes.
+func ResolveSlugs(merchants MerchantRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		path, found := strings.CutPrefix(c.Path(), "/merchants/")
+		if !found {
+			return c.Next()
+		}
+		ref, rest, _ := strings.Cut(path, "/")
+		if ref == "" || reservedSlugs[ref] {
+			return c.Next()
+		}
+		if _, err := uuid.Parse(ref); err == nil {
+			return c.Next()
+		}
+		if rest != "" {
+			rest = "/" + rest
+		}
+
+		merchant, err := merchants.GetBySlug(c.Context(), ref)
+		if err != nil {
+			if errors.Is(err, ErrMerchantNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		if merchant.Slug != ref && (c.Method() == http.MethodGet || c.Method() == http.MethodHead) {
+			location := "/merchants/" + merchant.Slug + rest
+			if query := string(c.Request().URI().QueryString()); query != "" {
+				location += "?" + query
+			}
+			return c.Redirect(location, fiber.StatusMovedPermanently)
+		}
+
+		c.Path("/merchants/" + merchant.ID.String() + rest)
+		return c.Next()
+	}
+}
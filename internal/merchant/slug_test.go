@@ -0,0 +1,135 @@
+package merchant
+
+import (
+	"context"
+	"io"
+	"minimart/internal/shared/eventbus"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlugify(t *testing.T) {
+	assert.Equal(t, "joes-pizza", Slugify("Joe's Pizza!"))
+	assert.Equal(t, "cafe-ole", Slugify("  Café   Olé "))
+	assert.Equal(t, "", Slugify("ร้านอาหาร"))
+	// Cut to length without a trailing hyphen
+	assert.Len(t, Slugify(strings.Repeat("abcd ", 20)), MaxSlugLength-1)
+
+	assert.Equal(t, "merchant", nameSlug("ร้านอาหาร"))
+	assert.Equal(t, "ab-1", nameSlug("AB"))
+	assert.Equal(t, "nearby-1", nameSlug("Nearby"))
+}
+
+func TestValidateSlug(t *testing.T) {
+	assert.NoError(t, ValidateSlug("joes-pizza-2"))
+	for _, slug := range []string{"jo", "Joes", "joes--pizza", "-joes", "joes pizza", "register", uuid.NewString(), strings.Repeat("a", 61)} {
+		assert.ErrorIs(t, ValidateSlug(slug), ErrInvalidSlug, slug)
+	}
+}
+
+func TestMerchantUsecase_Slugs(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryMerchantRepository()
+	usecase := NewMerchantUsecase(repo, newStubRoles(), eventbus.NewInMemoryEventBus(), discardLogger)
+
+	register := func(name string) *Merchant {
+		profile := completeProfile
+		profile.Name = name
+		m, err := usecase.CreateMerchant(ctx, uuid.New(), profile, "")
+		require.NoError(t, err)
+		return m
+	}
+
+	t.Run("should suffix slugs of merchants with the same name", func(t *testing.T) {
+		assert.Equal(t, "joes-pizza", register("Joe's Pizza").Slug)
+		assert.Equal(t, "joes-pizza-2", register("Joes Pizza").Slug)
+		assert.Equal(t, "joes-pizza-3", register("JOE'S PIZZA").Slug)
+	})
+
+	t.Run("should let owners change the slug and keep the old one", func(t *testing.T) {
+		shop := register("Corner Shop")
+		changed, err := usecase.ChangeSlug(ctx, shop.ID, " The-Corner ")
+		require.NoError(t, err)
+		assert.Equal(t, "the-corner", changed.Slug)
+
+		found, err := repo.GetBySlug(ctx, "corner-shop")
+		require.NoError(t, err)
+		assert.Equal(t, shop.ID, found.ID)
+
+		// The old slug stays with the merchant
+		assert.Equal(t, "corner-shop-2", register("Corner Shop").Slug)
+		_, err = usecase.ChangeSlug(ctx, shop.ID, "joes-pizza")
+		assert.ErrorIs(t, err, ErrSlugTaken)
+
+		// ...and can be taken back
+		changed, err = usecase.ChangeSlug(ctx, shop.ID, "corner-shop")
+		require.NoError(t, err)
+		assert.Equal(t, "corner-shop", changed.Slug)
+		found, err = repo.GetBySlug(ctx, "the-corner")
+		require.NoError(t, err)
+		assert.Equal(t, shop.ID, found.ID)
+
+		_, err = usecase.ChangeSlug(ctx, shop.ID, "nearby")
+		assert.ErrorIs(t, err, ErrInvalidSlug)
+	})
+}
+
+func TestResolveSlugs(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryMerchantRepository()
+	shop := NewMerchant(uuid.New(), "Noodle Bar", "", DefaultCurrency)
+	require.NoError(t, repo.Save(ctx, shop))
+	shop.Slug = "noodles"
+	require.NoError(t, repo.ChangeSlug(ctx, shop, "noodle-bar"))
+
+	app := fiber.New()
+	app.Use("/merchants", ResolveSlugs(repo))
+	app.Get("/merchants/nearby", func(c *fiber.Ctx) error {
+		return c.SendString("nearby")
+	})
+	app.Get("/merchants/:merchantID/menu", func(c *fiber.Ctx) error {
+		return c.SendString(c.Params("merchantID"))
+	})
+	app.Post("/merchants/:merchantID/orders", func(c *fiber.Ctx) error {
+		return c.SendString(c.Params("merchantID"))
+	})
+	request := func(method, target string) (*http.Response, string) {
+		resp, err := app.Test(httptest.NewRequest(method, target, nil))
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(body)
+	}
+
+	t.Run("should route slugs and IDs to the merchant's ID", func(t *testing.T) {
+		for _, ref := range []string{"noodles", shop.ID.String()} {
+			resp, body := request(http.MethodGet, "/merchants/"+ref+"/menu")
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, shop.ID.String(), body)
+		}
+		_, body := request(http.MethodGet, "/merchants/nearby")
+		assert.Equal(t, "nearby", body)
+	})
+
+	t.Run("should redirect reads of former slugs", func(t *testing.T) {
+		resp, _ := request(http.MethodGet, "/merchants/noodle-bar/menu?lang=th")
+		assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+		assert.Equal(t, "/merchants/noodles/menu?lang=th", resp.Header.Get("Location"))
+
+		resp, body := request(http.MethodPost, "/merchants/noodle-bar/orders")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, shop.ID.String(), body)
+	})
+
+	t.Run("should report unknown slugs", func(t *testing.T) {
+		resp, _ := request(http.MethodGet, "/merchants/no-such-shop/menu")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
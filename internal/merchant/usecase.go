@@ -2,27 +2,518 @@ package merchant
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"minimart/internal/shared/eventbus"
+	middlerware "minimart/internal/shared/middleware"
+	"minimart/internal/shared/money"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+
+	// DefaultNearbyRadiusKm is how far nearby searches look when the customer
+	// does not choose a radius.
+	DefaultNearbyRadiusKm = 10.0
+	MaxNearbyResults      = 50
+
+	// maxSlugCandidates is how many numbered slugs are tried for a new
+	// merchant before its ID is used to tell it apart.
+	maxSlugCandidates = 20
+	// maxSlugAttempts is how many times saving a new merchant is retried when
+	// another merchant takes its slug first.
+	maxSlugAttempts = 3
+)
+
+var (
+	ErrInvalidMerchantName = errors.New("merchant name must not be empty")
+	ErrInvalidSortOrder    = errors.New("sort must be name or newest")
+	// ErrMerchantInactive is returned when customers try to use a merchant that
+	// is not approved or has been deactivated.
+	ErrMerchantInactive        = errors.New("merchant is not active")
+	ErrUnknownStatus           = errors.New("unknown merchant status")
+	ErrInvalidStatusTransition = errors.New("merchant cannot move to that status")
+	ErrIncompleteProfile       = errors.New("merchant profile is missing required fields")
+	ErrReasonRequired          = errors.New("a reason is required for this status change")
+	ErrMerchantNotApproved     = errors.New("merchant has not been approved")
+	ErrInvalidSearchRadius     = errors.New("search radius must be greater than 0 and at most 50 km")
+	ErrInvalidTimezone         = errors.New("unknown time zone")
+	ErrInvalidCommission       = errors.New("commission must be between 0 and 10000 basis points")
+	ErrInvalidMaxOpenOrders    = errors.New("max open orders cannot be negative")
 )
 
 type MerchantUsecase interface {
-	CreateMerchant(ctx context.Context, name, description string) (*Merchant, error)
+	// CreateMerchant registers a new merchant owned by the given user and submits it for review.
+	// CreateMerchant also grants the owner the merchant role, which their
+	// access tokens carry from the next refresh. A failed grant is logged
+	// rather than failing the registration.
+	CreateMerchant(ctx context.Context, ownerID uuid.UUID, profile MerchantProfile, currency string) (*Merchant, error)
+	GetMerchant(ctx context.Context, id uuid.UUID) (*Merchant, error)
+	ListMerchants(ctx context.Context, query ListQuery) (*MerchantPage, error)
+	UpdateMerchant(ctx context.Context, id uuid.UUID, changes MerchantChanges) (*Merchant, error)
+	// SetMerchantActive opens or closes an approved merchant. Closed merchants
+	// keep their data but are hidden from customers.
+	SetMerchantActive(ctx context.Context, id uuid.UUID, active bool) (*Merchant, error)
+
+	// ChangeMerchantStatus moves a merchant through the review workflow on behalf of an admin.
+	ChangeMerchantStatus(ctx context.Context, adminID, merchantID uuid.UUID, status MerchantStatus, reason string) (*Merchant, error)
+	// ResubmitMerchant sends a rejected merchant back for review on behalf of its owner.
+	ResubmitMerchant(ctx context.Context, userID, merchantID uuid.UUID) (*Merchant, error)
+	GetStatusHistory(ctx context.Context, merchantID uuid.UUID) ([]StatusChange, error)
+	// ChangeSlug gives the merchant a new slug on behalf of its owner. The old
+	// slug keeps redirecting to the merchant.
+	ChangeSlug(ctx context.Context, merchantID uuid.UUID, slug string) (*Merchant, error)
+	// SetCommission changes the platform's cut of the merchant's future orders.
+	// Orders already settled keep the commission they were charged.
+	SetCommission(ctx context.Context, merchantID uuid.UUID, commissionBps int) (*Merchant, error)
+
+	// FindNearby returns the merchants that deliver to center, closest first.
+	// A zero radiusKm uses DefaultNearbyRadiusKm.
+	FindNearby(ctx context.Context, center GeoPoint, radiusKm float64) ([]NearbyMerchant, error)
+}
+
+// MerchantProfile holds the profile fields a merchant registers with.
+type MerchantProfile struct {
+	Name         string
+	Description  string
+	ContactEmail string
+	ContactPhone string
+	Address      string
+	Location     *GeoPoint
+	// DeliveryRadiusKm defaults to DefaultDeliveryRadiusKm when zero.
+	DeliveryRadiusKm float64
+	// Timezone defaults to DefaultTimezone when empty.
+	Timezone string
+}
+
+// ListQuery is a page request for the merchant directory. Page numbers start at 1.
+type ListQuery struct {
+	Active   *bool
+	Status   *MerchantStatus
+	Sort     SortOrder
+	Page     int
+	PageSize int
+}
+
+// MerchantPage is one page of the merchant directory.
+type MerchantPage struct {
+	Merchants []*Merchant `json:"merchants"`
+	Total     int         `json:"total"`
+	Page      int         `json:"page"`
+	PageSize  int         `json:"page_size"`
+}
+
+// MerchantChanges holds the fields of a partial merchant update.
+// Nil fields are left untouched.
+type MerchantChanges struct {
+	Name             *string
+	Description      *string
+	ContactEmail     *string
+	ContactPhone     *string
+	Address          *string
+	Location         *GeoPoint
+	DeliveryRadiusKm *float64
+	Timezone         *string
+	// MaxOpenOrders of zero removes the cap.
+	MaxOpenOrders *int
 }
 
 type merchantUsecase struct {
-	repo MerchantRepository
+	repo     MerchantRepository
+	roles    RoleGranter
+	eventBus eventbus.EventBus
+	logger   *slog.Logger
 }
 
-func NewMerchantUsecase(repo MerchantRepository) MerchantUsecase {
+func NewMerchantUsecase(repo MerchantRepository, roles RoleGranter, eventBus eventbus.EventBus, logger *slog.Logger) MerchantUsecase {
 	return &merchantUsecase{
-		repo: repo,
+		repo:     repo,
+		roles:    roles,
+		eventBus: eventBus,
+		logger:   logger,
+	}
+}
+
+func (u *merchantUsecase) CreateMerchant(ctx context.Context, ownerID uuid.UUID, profile MerchantProfile, currency string) (*Merchant, error) {
+	merchantCurrency := DefaultCurrency
+	if currency != "" {
+		parsed, err := money.ParseCurrency(currency)
+		if err != nil {
+			return nil, err
+		}
+		merchantCurrency = parsed
+	}
+
+	merchant := NewMerchant(ownerID, strings.TrimSpace(profile.Name), strings.TrimSpace(profile.Description), merchantCurrency)
+	merchant.ContactEmail = strings.TrimSpace(profile.ContactEmail)
+	merchant.ContactPhone = strings.TrimSpace(profile.ContactPhone)
+	merchant.Address = strings.TrimSpace(profile.Address)
+	merchant.Location = profile.Location
+	if profile.DeliveryRadiusKm != 0 {
+		merchant.DeliveryRadiusKm = profile.DeliveryRadiusKm
+	}
+	if timezone := strings.TrimSpace(profile.Timezone); timezone != "" {
+		if err := validateTimezone(timezone); err != nil {
+			return nil, err
+		}
+		merchant.Timezone = timezone
+	}
+	if err := requireCompleteProfile(merchant); err != nil {
+		return nil, err
+	}
+	if merchant.Location != nil {
+		if err := merchant.Location.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateDeliveryRadius(merchant.DeliveryRadiusKm); err != nil {
+		return nil, err
+	}
+
+	base := merchant.Slug
+	for attempt := 1; ; attempt++ {
+		slug, err := u.availableSlug(ctx, merchant.ID, base)
+		if err != nil {
+			return nil, err
+		}
+		merchant.Slug = slug
+		err = u.repo.Save(ctx, merchant)
+		if err == nil {
+			break
+		}
+		// Another merchant registered with the same slug since it was checked.
+		if !errors.Is(err, ErrSlugTaken) || attempt == maxSlugAttempts {
+			return nil, err
+		}
+	}
+
+	// The owner needs the merchant role to manage the merchant once their
+	// tokens are refreshed. The merchant is already stored, and failing now
+	// would have a retry register it twice, so a failed grant is logged for an
+	// admin to grant the role instead.
+	if err := u.roles.GrantRole(ctx, ownerID, middlerware.RoleMerchant); err != nil {
+		u.logger.Error("Failed to grant the merchant role to a new merchant's owner", "module", "merchant", "merchant_id", merchant.ID, "owner_id", ownerID, "error", err)
+	}
+
+	// Registering is the first transition, into submitted.
+	event := MerchantStatusChangedEvent{
+		MerchantID: merchant.ID.String(),
+		OwnerID:    merchant.OwnerID.String(),
+		To:         string(merchant.Status),
+	}
+	u.publish(ctx, event)
+	return merchant, nil
+}
+
+// publish announces a merchant change that has already been stored. Failing
+// the request at this point would make clients retry a write that succeeded,
+// registering the merchant twice, so a failed publish is logged instead.
+func (u *merchantUsecase) publish(ctx context.Context, event eventbus.Event) {
+	if err := u.eventBus.Publish(ctx, event); err != nil {
+		u.logger.Error("Failed to publish merchant event", "module", "merchant", "topic", event.Topic(), "error", err)
+	}
+}
+
+func (u *merchantUsecase) GetMerchant(ctx context.Context, id uuid.UUID) (*Merchant, error) {
+	return u.repo.GetByID(ctx, id)
+}
+
+func (u *merchantUsecase) ListMerchants(ctx context.Context, query ListQuery) (*MerchantPage, error) {
+	switch query.Sort {
+	case "":
+		query.Sort = SortByName
+	case SortByName, SortByNewest:
+	default:
+		return nil, ErrInvalidSortOrder
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = DefaultPageSize
+	}
+	query.PageSize = min(query.PageSize, MaxPageSize)
+
+	merchants, total, err := u.repo.List(ctx, ListOptions{
+		Active: query.Active,
+		Status: query.Status,
+		Sort:   query.Sort,
+		Limit:  query.PageSize,
+		Offset: (query.Page - 1) * query.PageSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &MerchantPage{
+		Merchants: merchants,
+		Total:     total,
+		Page:      query.Page,
+		PageSize:  query.PageSize,
+	}, nil
+}
+
+func (u *merchantUsecase) UpdateMerchant(ctx context.Context, id uuid.UUID, changes MerchantChanges) (*Merchant, error) {
+	merchant, err := u.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if changes.Name != nil {
+		name := strings.TrimSpace(*changes.Name)
+		if name == "" {
+			return nil, ErrInvalidMerchantName
+		}
+		merchant.Name = name
+	}
+	if changes.Description != nil {
+		merchant.Description = strings.TrimSpace(*changes.Description)
+	}
+	if changes.ContactEmail != nil {
+		merchant.ContactEmail = strings.TrimSpace(*changes.ContactEmail)
+	}
+	if changes.ContactPhone != nil {
+		merchant.ContactPhone = strings.TrimSpace(*changes.ContactPhone)
+	}
+	if changes.Address != nil {
+		merchant.Address = strings.TrimSpace(*changes.Address)
+	}
+	if changes.Location != nil {
+		if err := changes.Location.Validate(); err != nil {
+			return nil, err
+		}
+		merchant.Location = changes.Location
+	}
+	if changes.DeliveryRadiusKm != nil {
+		if err := validateDeliveryRadius(*changes.DeliveryRadiusKm); err != nil {
+			return nil, err
+		}
+		merchant.DeliveryRadiusKm = *changes.DeliveryRadiusKm
+	}
+	if changes.Timezone != nil {
+		timezone := strings.TrimSpace(*changes.Timezone)
+		if err := validateTimezone(timezone); err != nil {
+			return nil, err
+		}
+		merchant.Timezone = timezone
+	}
+	if changes.MaxOpenOrders != nil {
+		if *changes.MaxOpenOrders < 0 {
+			return nil, ErrInvalidMaxOpenOrders
+		}
+		merchant.MaxOpenOrders = *changes.MaxOpenOrders
+	}
+	// Merchants that customers can see must keep a complete profile.
+	if merchant.Status == StatusApproved {
+		if err := requireCompleteProfile(merchant); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := u.repo.Update(ctx, merchant); err != nil {
+		return nil, err
+	}
+	return merchant, nil
+}
+
+func (u *merchantUsecase) SetMerchantActive(ctx context.Context, id uuid.UUID, active bool) (*Merchant, error) {
+	merchant, err := u.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if active && merchant.Status != StatusApproved {
+		return nil, ErrMerchantNotApproved
 	}
+
+	merchant.IsActive = active
+	if err := u.repo.Update(ctx, merchant); err != nil {
+		return nil, err
+	}
+	return merchant, nil
 }
 
-func (u *merchantUsecase) CreateMerchant(ctx context.Context, name, description string) (*Merchant, error) {
-	merchant := NewMerchant(name, description)
-	err := u.repo.Save(ctx, merchant)
+func (u *merchantUsecase) SetCommission(ctx context.Context, merchantID uuid.UUID, commissionBps int) (*Merchant, error) {
+	if commissionBps < 0 || commissionBps > MaxCommissionBps {
+		return nil, ErrInvalidCommission
+	}
+	merchant, err := u.repo.GetByID(ctx, merchantID)
 	if err != nil {
 		return nil, err
 	}
+
+	merchant.CommissionBps = commissionBps
+	if err := u.repo.Update(ctx, merchant); err != nil {
+		return nil, err
+	}
+	return merchant, nil
+}
+
+func (u *merchantUsecase) ChangeSlug(ctx context.Context, merchantID uuid.UUID, slug string) (*Merchant, error) {
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	if err := ValidateSlug(slug); err != nil {
+		return nil, err
+	}
+	merchant, err := u.repo.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if merchant.Slug == slug {
+		return merchant, nil
+	}
+
+	holder, err := u.repo.GetBySlug(ctx, slug)
+	if err == nil && holder.ID != merchant.ID {
+		return nil, ErrSlugTaken
+	}
+	if err != nil && !errors.Is(err, ErrMerchantNotFound) {
+		return nil, err
+	}
+
+	previous := merchant.Slug
+	merchant.Slug = slug
+	if err := u.repo.ChangeSlug(ctx, merchant, previous); err != nil {
+		merchant.Slug = previous
+		return nil, err
+	}
 	return merchant, nil
 }
+
+func (u *merchantUsecase) ChangeMerchantStatus(ctx context.Context, adminID, merchantID uuid.UUID, status MerchantStatus, reason string) (*Merchant, error) {
+	// Going back to submitted is the owner's decision, see ResubmitMerchant.
+	if status == StatusSubmitted {
+		return nil, fmt.Errorf("%w: only the owner can resubmit", ErrInvalidStatusTransition)
+	}
+	reason = strings.TrimSpace(reason)
+	if (status == StatusRejected || status == StatusSuspended) && reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	merchant, err := u.repo.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if status == StatusApproved {
+		if err := requireCompleteProfile(merchant); err != nil {
+			return nil, err
+		}
+	}
+	return u.transition(ctx, merchant, status, reason, adminID)
+}
+
+func (u *merchantUsecase) ResubmitMerchant(ctx context.Context, userID, merchantID uuid.UUID) (*Merchant, error) {
+	merchant, err := u.repo.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireCompleteProfile(merchant); err != nil {
+		return nil, err
+	}
+	return u.transition(ctx, merchant, StatusSubmitted, "", userID)
+}
+
+func (u *merchantUsecase) GetStatusHistory(ctx context.Context, merchantID uuid.UUID) ([]StatusChange, error) {
+	if _, err := u.repo.GetByID(ctx, merchantID); err != nil {
+		return nil, err
+	}
+	return u.repo.ListStatusHistory(ctx, merchantID)
+}
+
+func (u *merchantUsecase) FindNearby(ctx context.Context, center GeoPoint, radiusKm float64) ([]NearbyMerchant, error) {
+	if err := center.Validate(); err != nil {
+		return nil, err
+	}
+	if radiusKm == 0 {
+		radiusKm = DefaultNearbyRadiusKm
+	}
+	if radiusKm < 0 || radiusKm > MaxDeliveryRadiusKm {
+		return nil, ErrInvalidSearchRadius
+	}
+
+	return u.repo.ListNearby(ctx, NearbyQuery{
+		Center:   center,
+		RadiusKm: radiusKm,
+		Limit:    MaxNearbyResults,
+	})
+}
+
+// transition validates and stores a status change and tells the rest of the system about it.
+func (u *merchantUsecase) transition(ctx context.Context, merchant *Merchant, status MerchantStatus, reason string, actorID uuid.UUID) (*Merchant, error) {
+	from := merchant.Status
+	if !from.CanTransitionTo(status) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidStatusTransition, from, status)
+	}
+
+	merchant.Status = status
+	merchant.StatusReason = reason
+	// Approval opens the merchant; every other status closes it.
+	merchant.IsActive = status == StatusApproved
+
+	change := StatusChange{
+		MerchantID: merchant.ID,
+		From:       from,
+		To:         status,
+		Reason:     reason,
+		ChangedBy:  actorID,
+		ChangedAt:  time.Now(),
+	}
+	if err := u.repo.ChangeStatus(ctx, merchant, change); err != nil {
+		return nil, err
+	}
+
+	event := MerchantStatusChangedEvent{
+		MerchantID: merchant.ID.String(),
+		OwnerID:    merchant.OwnerID.String(),
+		From:       string(from),
+		To:         string(status),
+		Reason:     reason,
+	}
+	u.publish(ctx, event)
+	return merchant, nil
+}
+
+// availableSlug returns the first candidate for base that no other merchant
+// uses or used.
+func (u *merchantUsecase) availableSlug(ctx context.Context, merchantID uuid.UUID, base string) (string, error) {
+	for n := 1; n <= maxSlugCandidates; n++ {
+		candidate := slugCandidate(base, n)
+		holder, err := u.repo.GetBySlug(ctx, candidate)
+		if errors.Is(err, ErrMerchantNotFound) || (err == nil && holder.ID == merchantID) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	// A popular name: the start of the ID is as good as unique.
+	return withSuffix(base, merchantID.String()[:8]), nil
+}
+
+func requireCompleteProfile(merchant *Merchant) error {
+	if missing := merchant.MissingProfileFields(); len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrIncompleteProfile, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func validateDeliveryRadius(radiusKm float64) error {
+	if radiusKm <= 0 || radiusKm > MaxDeliveryRadiusKm {
+		return ErrInvalidDeliveryRadius
+	}
+	return nil
+}
+
+func validateTimezone(timezone string) error {
+	// time.LoadLocation treats "" as UTC and "Local" as the server's zone; neither is a real choice.
+	if timezone == "" || timezone == "Local" {
+		return ErrInvalidTimezone
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidTimezone, timezone)
+	}
+	return nil
+}
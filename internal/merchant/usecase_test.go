@@ -0,0 +1,385 @@
+package merchant
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"minimart/internal/shared/eventbus"
+	middlerware "minimart/internal/shared/middleware"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+var completeProfile = MerchantProfile{
+	Name:         "Alpha Noodles",
+	Description:  "Hand-pulled noodles",
+	ContactEmail: "hello@alpha.example",
+	ContactPhone: "+1 555 0100",
+}
+
+// stubRoles stands in for the user module, recording the roles granted.
+type stubRoles struct {
+	mu      sync.Mutex
+	granted map[uuid.UUID]middlerware.Role
+}
+
+func newStubRoles() *stubRoles {
+	return &stubRoles{granted: map[uuid.UUID]middlerware.Role{}}
+}
+
+func (r *stubRoles) GrantRole(ctx context.Context, userID uuid.UUID, role middlerware.Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.granted[userID] = role
+	return nil
+}
+
+func TestMerchantUsecase_Directory(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryMerchantRepository() // seeds "Merchant 1" (submitted) and "Merchant 2" (approved)
+	roles := newStubRoles()
+	usecase := NewMerchantUsecase(repo, roles, eventbus.NewInMemoryEventBus(), discardLogger)
+
+	ownerID := uuid.New()
+	newest, err := usecase.CreateMerchant(ctx, ownerID, completeProfile, "")
+	require.NoError(t, err)
+	assert.Equal(t, middlerware.RoleMerchant, roles.granted[ownerID], "registering a merchant makes the owner a merchant")
+	newest.CreatedAt = time.Now().Add(time.Hour)
+
+	names := func(page *MerchantPage) []string {
+		var names []string
+		for _, m := range page.Merchants {
+			names = append(names, m.Name)
+		}
+		return names
+	}
+
+	t.Run("should sort and paginate", func(t *testing.T) {
+		page, err := usecase.ListMerchants(ctx, ListQuery{PageSize: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Alpha Noodles", "Merchant 1"}, names(page))
+		assert.Equal(t, 3, page.Total)
+
+		page, err = usecase.ListMerchants(ctx, ListQuery{Page: 2, PageSize: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Merchant 2"}, names(page))
+
+		page, err = usecase.ListMerchants(ctx, ListQuery{Sort: SortByNewest, PageSize: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Alpha Noodles"}, names(page))
+
+		_, err = usecase.ListMerchants(ctx, ListQuery{Sort: "popular"})
+		assert.ErrorIs(t, err, ErrInvalidSortOrder)
+	})
+
+	t.Run("should filter by active and status", func(t *testing.T) {
+		active := true
+		page, err := usecase.ListMerchants(ctx, ListQuery{Active: &active})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Merchant 2"}, names(page))
+
+		submitted := StatusSubmitted
+		page, err = usecase.ListMerchants(ctx, ListQuery{Status: &submitted})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Alpha Noodles", "Merchant 1"}, names(page))
+	})
+
+	t.Run("should update and deactivate", func(t *testing.T) {
+		name := "  Alpha Ramen "
+		updated, err := usecase.UpdateMerchant(ctx, newest.ID, MerchantChanges{Name: &name})
+		require.NoError(t, err)
+		assert.Equal(t, "Alpha Ramen", updated.Name)
+
+		blank := " "
+		_, err = usecase.UpdateMerchant(ctx, newest.ID, MerchantChanges{Name: &blank})
+		assert.ErrorIs(t, err, ErrInvalidMerchantName)
+
+		deactivated, err := usecase.SetMerchantActive(ctx, newest.ID, false)
+		require.NoError(t, err)
+		assert.False(t, deactivated.IsActive)
+
+		_, err = usecase.SetMerchantActive(ctx, uuid.New(), true)
+		assert.ErrorIs(t, err, ErrMerchantNotFound)
+	})
+
+	t.Run("should default and validate the time zone", func(t *testing.T) {
+		assert.Equal(t, DefaultTimezone, newest.Timezone)
+
+		bangkok := "Asia/Bangkok"
+		updated, err := usecase.UpdateMerchant(ctx, newest.ID, MerchantChanges{Timezone: &bangkok})
+		require.NoError(t, err)
+		assert.Equal(t, "Asia/Bangkok", updated.Zone().String())
+
+		for _, invalid := range []string{"Mars/Olympus", "Local", ""} {
+			_, err = usecase.UpdateMerchant(ctx, newest.ID, MerchantChanges{Timezone: &invalid})
+			assert.ErrorIs(t, err, ErrInvalidTimezone, invalid)
+		}
+		assert.Equal(t, "Asia/Bangkok", newest.Timezone)
+	})
+
+	t.Run("should default and validate the commission", func(t *testing.T) {
+		assert.Equal(t, DefaultCommissionBps, newest.CommissionBps)
+
+		updated, err := usecase.SetCommission(ctx, newest.ID, 1200)
+		require.NoError(t, err)
+		assert.Equal(t, 1200, updated.CommissionBps)
+
+		for _, invalid := range []int{-1, MaxCommissionBps + 1} {
+			_, err = usecase.SetCommission(ctx, newest.ID, invalid)
+			assert.ErrorIs(t, err, ErrInvalidCommission, invalid)
+		}
+		assert.Equal(t, 1200, newest.CommissionBps)
+	})
+
+	t.Run("should validate the cap on open orders", func(t *testing.T) {
+		limit := 8
+		updated, err := usecase.UpdateMerchant(ctx, newest.ID, MerchantChanges{MaxOpenOrders: &limit})
+		require.NoError(t, err)
+		assert.Equal(t, 8, updated.MaxOpenOrders)
+
+		negative := -1
+		_, err = usecase.UpdateMerchant(ctx, newest.ID, MerchantChanges{MaxOpenOrders: &negative})
+		assert.ErrorIs(t, err, ErrInvalidMaxOpenOrders)
+	})
+}
+
+func TestMerchantUsecase_Onboarding(t *testing.T) {
+	ctx := context.Background()
+	adminID := uuid.New()
+	ownerID := uuid.New()
+
+	setup := func(t *testing.T) (MerchantUsecase, *Merchant, chan MerchantStatusChangedEvent) {
+		bus := eventbus.NewInMemoryEventBus()
+		events := make(chan MerchantStatusChangedEvent, 10)
+		require.NoError(t, bus.Subscribe(MerchantStatusChangedTopic, func(ctx context.Context, event eventbus.Event) error {
+			events <- event.(MerchantStatusChangedEvent)
+			return nil
+		}))
+		usecase := NewMerchantUsecase(NewInMemoryMerchantRepository(), newStubRoles(), bus, discardLogger)
+
+		merchant, err := usecase.CreateMerchant(ctx, ownerID, completeProfile, "")
+		require.NoError(t, err)
+		return usecase, merchant, events
+	}
+
+	t.Run("should require a complete profile to register", func(t *testing.T) {
+		usecase, _, _ := setup(t)
+
+		_, err := usecase.CreateMerchant(ctx, ownerID, MerchantProfile{Name: "Half Done"}, "")
+		assert.ErrorIs(t, err, ErrIncompleteProfile)
+		assert.ErrorContains(t, err, "contact_email")
+	})
+
+	t.Run("should only make owners of saved merchants merchants", func(t *testing.T) {
+		roles := newStubRoles()
+		saveFailed := errors.New("connection reset")
+		usecase := NewMerchantUsecase(failingSaves{NewInMemoryMerchantRepository(), saveFailed}, roles, eventbus.NewInMemoryEventBus(), discardLogger)
+
+		_, err := usecase.CreateMerchant(ctx, ownerID, completeProfile, "")
+		assert.ErrorIs(t, err, saveFailed)
+		assert.Empty(t, roles.granted)
+	})
+
+	t.Run("should keep the registration when the role cannot be granted", func(t *testing.T) {
+		repo := NewInMemoryMerchantRepository()
+		usecase := NewMerchantUsecase(repo, failingRoles{errors.New("connection reset")}, eventbus.NewInMemoryEventBus(), discardLogger)
+
+		merchant, err := usecase.CreateMerchant(ctx, ownerID, completeProfile, "")
+		require.NoError(t, err)
+		_, err = repo.GetByID(ctx, merchant.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should keep new merchants hidden until approved", func(t *testing.T) {
+		usecase, merchant, events := setup(t)
+		assert.Equal(t, StatusSubmitted, merchant.Status)
+		assert.False(t, merchant.AcceptsCustomers())
+
+		_, err := usecase.SetMerchantActive(ctx, merchant.ID, true)
+		assert.ErrorIs(t, err, ErrMerchantNotApproved)
+
+		_, err = usecase.ChangeMerchantStatus(ctx, adminID, merchant.ID, StatusApproved, "")
+		assert.ErrorIs(t, err, ErrInvalidStatusTransition)
+
+		_, err = usecase.ChangeMerchantStatus(ctx, adminID, merchant.ID, StatusUnderReview, "")
+		require.NoError(t, err)
+		approved, err := usecase.ChangeMerchantStatus(ctx, adminID, merchant.ID, StatusApproved, "")
+		require.NoError(t, err)
+		assert.True(t, approved.AcceptsCustomers())
+
+		// The in-memory bus delivers asynchronously and in no particular order
+		var moves []string
+		for range 3 {
+			select {
+			case event := <-events:
+				assert.Equal(t, ownerID.String(), event.OwnerID)
+				moves = append(moves, event.From+"->"+event.To)
+			case <-time.After(time.Second):
+				t.Fatal("no status change event published")
+			}
+		}
+		assert.ElementsMatch(t, []string{"->submitted", "submitted->under_review", "under_review->approved"}, moves)
+	})
+
+	t.Run("should require a reason to reject and let the owner resubmit", func(t *testing.T) {
+		usecase, merchant, _ := setup(t)
+		_, err := usecase.ChangeMerchantStatus(ctx, adminID, merchant.ID, StatusUnderReview, "")
+		require.NoError(t, err)
+
+		_, err = usecase.ChangeMerchantStatus(ctx, adminID, merchant.ID, StatusRejected, " ")
+		assert.ErrorIs(t, err, ErrReasonRequired)
+
+		rejected, err := usecase.ChangeMerchantStatus(ctx, adminID, merchant.ID, StatusRejected, "Menu photos missing")
+		require.NoError(t, err)
+		assert.Equal(t, "Menu photos missing", rejected.StatusReason)
+
+		_, err = usecase.ChangeMerchantStatus(ctx, adminID, merchant.ID, StatusSubmitted, "")
+		assert.ErrorIs(t, err, ErrInvalidStatusTransition)
+
+		resubmitted, err := usecase.ResubmitMerchant(ctx, ownerID, merchant.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusSubmitted, resubmitted.Status)
+
+		history, err := usecase.GetStatusHistory(ctx, merchant.ID)
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+		assert.Equal(t, StatusRejected, history[1].To)
+		assert.Equal(t, "Menu photos missing", history[1].Reason)
+		assert.Equal(t, adminID, history[1].ChangedBy)
+		assert.Equal(t, ownerID, history[2].ChangedBy)
+	})
+
+	t.Run("should close suspended merchants", func(t *testing.T) {
+		usecase, merchant, _ := setup(t)
+		for _, status := range []MerchantStatus{StatusUnderReview, StatusApproved} {
+			_, err := usecase.ChangeMerchantStatus(ctx, adminID, merchant.ID, status, "")
+			require.NoError(t, err)
+		}
+
+		suspended, err := usecase.ChangeMerchantStatus(ctx, adminID, merchant.ID, StatusSuspended, "Health inspection failed")
+		require.NoError(t, err)
+		assert.False(t, suspended.AcceptsCustomers())
+
+		_, err = usecase.SetMerchantActive(ctx, merchant.ID, true)
+		assert.ErrorIs(t, err, ErrMerchantNotApproved)
+	})
+}
+
+func TestMerchantUsecase_FindNearby(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryMerchantRepository()
+	usecase := NewMerchantUsecase(repo, newStubRoles(), eventbus.NewInMemoryEventBus(), discardLogger)
+
+	customer := GeoPoint{Latitude: 13.7460, Longitude: 100.5340}
+	seed := func(name string, location GeoPoint, radiusKm float64, status MerchantStatus) {
+		m := NewMerchant(uuid.New(), name, "", DefaultCurrency)
+		m.Location = &location
+		m.DeliveryRadiusKm = radiusKm
+		m.Status = status
+		m.IsActive = status == StatusApproved
+		require.NoError(t, repo.Save(ctx, m))
+	}
+	seed("Around the Corner", GeoPoint{Latitude: 13.7465, Longitude: 100.5350}, 2, StatusApproved)         // ~0.1 km
+	seed("Across Town", GeoPoint{Latitude: 13.7800, Longitude: 100.5600}, 8, StatusApproved)               // ~4.7 km
+	seed("Short Range", GeoPoint{Latitude: 13.7700, Longitude: 100.5340}, 1, StatusApproved)               // ~2.7 km, delivers 1 km
+	seed("Still Reviewing", GeoPoint{Latitude: 13.7461, Longitude: 100.5341}, 5, StatusUnderReview)        // ~0 km
+	seed("Far Away", GeoPoint{Latitude: 18.7883, Longitude: 98.9853}, MaxDeliveryRadiusKm, StatusApproved) // Chiang Mai
+
+	names := func(nearby []NearbyMerchant) []string {
+		var names []string
+		for _, m := range nearby {
+			names = append(names, m.Name)
+		}
+		return names
+	}
+
+	t.Run("should return merchants that deliver to the customer, closest first", func(t *testing.T) {
+		nearby, err := usecase.FindNearby(ctx, customer, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Around the Corner", "Across Town"}, names(nearby))
+		assert.InDelta(t, 0.12, nearby[0].DistanceKm, 0.05)
+	})
+
+	t.Run("should limit the search to the radius", func(t *testing.T) {
+		nearby, err := usecase.FindNearby(ctx, customer, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Around the Corner"}, names(nearby))
+	})
+
+	t.Run("should reject invalid searches", func(t *testing.T) {
+		_, err := usecase.FindNearby(ctx, GeoPoint{Latitude: 100}, 0)
+		assert.ErrorIs(t, err, ErrInvalidLocation)
+
+		_, err = usecase.FindNearby(ctx, customer, MaxDeliveryRadiusKm+1)
+		assert.ErrorIs(t, err, ErrInvalidSearchRadius)
+	})
+
+	t.Run("should validate the delivery area on updates", func(t *testing.T) {
+		merchant, err := usecase.CreateMerchant(ctx, uuid.New(), completeProfile, "")
+		require.NoError(t, err)
+		assert.Equal(t, DefaultDeliveryRadiusKm, merchant.DeliveryRadiusKm)
+
+		_, err = usecase.UpdateMerchant(ctx, merchant.ID, MerchantChanges{Location: &GeoPoint{Latitude: -91}})
+		assert.ErrorIs(t, err, ErrInvalidLocation)
+
+		zero := 0.0
+		_, err = usecase.UpdateMerchant(ctx, merchant.ID, MerchantChanges{DeliveryRadiusKm: &zero})
+		assert.ErrorIs(t, err, ErrInvalidDeliveryRadius)
+	})
+}
+
+// failingSaves fails to save any merchant.
+type failingSaves struct {
+	MerchantRepository
+	err error
+}
+
+func (r failingSaves) Save(ctx context.Context, merchant *Merchant) error {
+	return r.err
+}
+
+// failingRoles fails to grant any role.
+type failingRoles struct {
+	err error
+}
+
+func (r failingRoles) GrantRole(ctx context.Context, userID uuid.UUID, role middlerware.Role) error {
+	return r.err
+}
+
+// unreachableEventBus fails every publish, like a Redis outage.
+type unreachableEventBus struct {
+	eventbus.EventBus
+}
+
+func (unreachableEventBus) Publish(ctx context.Context, event eventbus.Event) error {
+	return errors.New("connection refused")
+}
+
+func TestMerchantUsecase_PublishFailure(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryMerchantRepository()
+	usecase := NewMerchantUsecase(repo, newStubRoles(), unreachableEventBus{}, discardLogger)
+
+	t.Run("should return stored merchants instead of failing the request", func(t *testing.T) {
+		merchant, err := usecase.CreateMerchant(ctx, uuid.New(), completeProfile, "")
+		require.NoError(t, err)
+
+		reviewed, err := usecase.ChangeMerchantStatus(ctx, uuid.New(), merchant.ID, StatusUnderReview, "")
+		require.NoError(t, err)
+		assert.Equal(t, StatusUnderReview, reviewed.Status)
+
+		stored, err := repo.GetByID(ctx, merchant.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusUnderReview, stored.Status)
+		assert.Equal(t, merchant.Slug, stored.Slug)
+	})
+}
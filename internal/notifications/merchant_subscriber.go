@@ -0,0 +1,73 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"minimart/internal/merchant"
+	"minimart/internal/shared/eventbus"
+)
+
+// MerchantSubscriber is a dedicated subscriber for merchant-related events.
+type MerchantSubscriber struct {
+	logger *slog.Logger
+}
+
+// NewMerchantSubscriber creates a new instance of MerchantSubscriber.
+func NewMerchantSubscriber(logger *slog.Logger) *MerchantSubscriber {
+	return &MerchantSubscriber{logger: logger}
+}
+
+// HandleMerchantStatusChangedEvent notifies the owner when their merchant
+// moves through the review workflow.
+func (s *MerchantSubscriber) HandleMerchantStatusChangedEvent(ctx context.Context, event eventbus.Event) error {
+	statusEvent, ok := event.(merchant.MerchantStatusChangedEvent)
+	if !ok {
+		s.logger.Error(
+			"Unexpected event type received",
+			"module", "notifications",
+			"topic", event.Topic(),
+			"event_type", fmt.Sprintf("%T", event),
+		)
+		return nil
+	}
+
+	s.logger.Info(
+		"Notifying owner of merchant status change",
+		"module", "notifications",
+		"merchant_id", statusEvent.MerchantID,
+		"owner_id", statusEvent.OwnerID,
+		"from", statusEvent.From,
+		"to", statusEvent.To,
+		"reason", statusEvent.Reason,
+	)
+
+	return nil
+}
+
+// HandleMerchantInviteCreatedEvent notifies the invitee of their invite. The
+// token is left out of the log so that it only reaches the invitee.
+func (s *MerchantSubscriber) HandleMerchantInviteCreatedEvent(ctx context.Context, event eventbus.Event) error {
+	inviteEvent, ok := event.(merchant.MerchantInviteCreatedEvent)
+	if !ok {
+		s.logger.Error(
+			"Unexpected event type received",
+			"module", "notifications",
+			"topic", event.Topic(),
+			"event_type", fmt.Sprintf("%T", event),
+		)
+		return nil
+	}
+
+	s.logger.Info(
+		"Sending merchant staff invite",
+		"module", "notifications",
+		"invite_id", inviteEvent.InviteID,
+		"merchant_id", inviteEvent.MerchantID,
+		"email", inviteEvent.Email,
+		"role", inviteEvent.Role,
+		"expires_at", inviteEvent.ExpiresAt,
+	)
+
+	return nil
+}
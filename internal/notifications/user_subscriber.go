@@ -5,20 +5,36 @@ import (
 	"fmt"
 	"log/slog"
 	"minimart/internal/shared/eventbus"
+	"minimart/internal/shared/mailer"
 	"minimart/internal/user"
+	"net/url"
+	"time"
 )
 
+// UserLinks are the pages of the web app that emails to users link to.
+type UserLinks struct {
+	// PasswordReset is the page that takes the token query parameter and asks
+	// for a new password.
+	PasswordReset string
+	// EmailVerification verifies the email in the token query parameter,
+	// normally the GET /users/verify endpoint.
+	EmailVerification string
+}
+
 // UserSubscriber is a dedicated subscriber for user-related events.
 type UserSubscriber struct {
 	logger *slog.Logger
+	mailer mailer.Mailer
+	links  UserLinks
 }
 
 // NewUserSubscriber creates a new instance of UserSubscriber.
-func NewUserSubscriber(logger *slog.Logger) *UserSubscriber {
-	return &UserSubscriber{logger: logger}
+func NewUserSubscriber(logger *slog.Logger, mailer mailer.Mailer, links UserLinks) *UserSubscriber {
+	return &UserSubscriber{logger: logger, mailer: mailer, links: links}
 }
 
-// HandleUserCreatedEvent is the handler for the UserCreatedEvent.
+// HandleUserCreatedEvent is the handler for the UserCreatedEvent. It sends
+// the new user the link that verifies their email.
 func (s *UserSubscriber) HandleUserCreatedEvent(ctx context.Context, event eventbus.Event) error {
 	// Type assert the event to the specifiic UserCreatedEvent
 	userEvent, ok := event.(user.UserCreatedEvent)
@@ -38,6 +54,154 @@ func (s *UserSubscriber) HandleUserCreatedEvent(ctx context.Context, event event
 		"user_id", userEvent.UserID,
 		"email", userEvent.Email,
 	)
+	if userEvent.VerificationToken == "" {
+		// Signed up with an identity provider, which verified the email
+		return nil
+	}
+
+	return s.sendVerification(ctx, userEvent.UserID, userEvent.Name, userEvent.Email, userEvent.VerificationToken, userEvent.VerificationExpiresAt)
+}
+
+// HandleEmailVerificationRequestedEvent sends a user who asked for it a new
+// verification link.
+func (s *UserSubscriber) HandleEmailVerificationRequestedEvent(ctx context.Context, event eventbus.Event) error {
+	verificationEvent, ok := event.(user.EmailVerificationRequestedEvent)
+	if !ok {
+		s.logger.Error(
+			"Unexpected event type received",
+			"module", "notifications",
+			"topic", event.Topic(),
+			"event_type", fmt.Sprintf("%T", event),
+		)
+		return nil
+	}
+
+	return s.sendVerification(ctx, verificationEvent.UserID, verificationEvent.Name, verificationEvent.Email, verificationEvent.Token, verificationEvent.ExpiresAt)
+}
+
+// sendVerification emails a verification link. The token is left out of the
+// log so that it only reaches the user.
+func (s *UserSubscriber) sendVerification(ctx context.Context, userID, name, email, token string, expiresAt time.Time) error {
+	link, err := withToken(s.links.EmailVerification, token)
+	if err != nil {
+		return err
+	}
+	message := mailer.Message{
+		To:      email,
+		Subject: "Verify your MiniMart email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm that this is your email address by opening this link before %s:\n\n%s\n\n"+
+			"If you did not create a MiniMart account, you can ignore this email.\n",
+			name, expiresAt.UTC().Format(time.RFC1123), link),
+	}
+	if err := s.mailer.Send(ctx, message); err != nil {
+		s.logger.Error(
+			"Could not send verification email",
+			"module", "notifications",
+			"user_id", userID,
+			"error", err,
+		)
+		return err
+	}
+
+	s.logger.Info(
+		"Sent verification email",
+		"module", "notifications",
+		"user_id", userID,
+	)
+	return nil
+}
+
+// HandlePasswordResetRequestedEvent emails the user their reset link. The
+// token is left out of the log so that it only reaches the user.
+func (s *UserSubscriber) HandlePasswordResetRequestedEvent(ctx context.Context, event eventbus.Event) error {
+	resetEvent, ok := event.(user.PasswordResetRequestedEvent)
+	if !ok {
+		s.logger.Error(
+			"Unexpected event type received",
+			"module", "notifications",
+			"topic", event.Topic(),
+			"event_type", fmt.Sprintf("%T", event),
+		)
+		return nil
+	}
+
+	link, err := withToken(s.links.PasswordReset, resetEvent.Token)
+	if err != nil {
+		return err
+	}
+	message := mailer.Message{
+		To:      resetEvent.Email,
+		Subject: "Reset your MiniMart password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your MiniMart account. "+
+			"To choose a new one, open this link before %s:\n\n%s\n\n"+
+			"If it wasn't you, you can ignore this email and your password will stay the same.\n",
+			resetEvent.Name, resetEvent.ExpiresAt.UTC().Format(time.RFC1123), link),
+	}
+	if err := s.mailer.Send(ctx, message); err != nil {
+		s.logger.Error(
+			"Could not send password reset email",
+			"module", "notifications",
+			"user_id", resetEvent.UserID,
+			"error", err,
+		)
+		return err
+	}
+
+	s.logger.Info(
+		"Sent password reset email",
+		"module", "notifications",
+		"user_id", resetEvent.UserID,
+	)
+	return nil
+}
+
+// HandleAccountLockedEvent tells users that failed logins locked their account.
+func (s *UserSubscriber) HandleAccountLockedEvent(ctx context.Context, event eventbus.Event) error {
+	lockedEvent, ok := event.(user.AccountLockedEvent)
+	if !ok {
+		s.logger.Error(
+			"Unexpected event type received",
+			"module", "notifications",
+			"topic", event.Topic(),
+			"event_type", fmt.Sprintf("%T", event),
+		)
+		return nil
+	}
 
+	message := mailer.Message{
+		To:      lockedEvent.Email,
+		Subject: "Your MiniMart account was locked",
+		Body: fmt.Sprintf("Hi %s,\n\nThere were too many failed attempts to log in to your MiniMart account, "+
+			"so we have locked it until %s.\n\n"+
+			"If it wasn't you, someone may be trying to guess your password, and you may want to choose a new one with \"Forgot password\".\n",
+			lockedEvent.Name, lockedEvent.LockedUntil.UTC().Format(time.RFC1123)),
+	}
+	if err := s.mailer.Send(ctx, message); err != nil {
+		s.logger.Error(
+			"Could not send account locked email",
+			"module", "notifications",
+			"user_id", lockedEvent.UserID,
+			"error", err,
+		)
+		return err
+	}
+
+	s.logger.Info(
+		"Sent account locked email",
+		"module", "notifications",
+		"user_id", lockedEvent.UserID,
+	)
 	return nil
 }
+
+// withToken adds the token query parameter to the page URL.
+func withToken(page, token string) (string, error) {
+	link, err := url.Parse(page)
+	if err != nil {
+		return "", err
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
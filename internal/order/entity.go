@@ -1,6 +1,8 @@
 package order
 
 import (
+	"fmt"
+	"minimart/internal/shared/money"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,14 +11,26 @@ import (
 type Order struct {
 	ID         uuid.UUID
 	CustomerID uuid.UUID
-	Items      []OrderItem
-	Status     OrderStatus
-	CreatedAt  time.Time
+	MerchantID uuid.UUID
+	// MenuVersionID is the published menu version the order was priced against.
+	MenuVersionID uuid.UUID
+	Items         []OrderItem
+	// Total is the sum of all line totals, in the merchant's currency.
+	Total     money.Money
+	Status    OrderStatus
+	CreatedAt time.Time
 }
 
 type OrderItem struct {
-	MenuItemID uuid.UUID
-	Quantity   int
+	MenuItemID uuid.UUID `json:"menu_item_id"`
+	Quantity   int       `json:"quantity"`
+	// UnitPrice is the menu price at the time the order was placed.
+	UnitPrice money.Money `json:"unit_price"`
+}
+
+// LineTotal returns the unit price multiplied by the quantity.
+func (i OrderItem) LineTotal() (money.Money, error) {
+	return i.UnitPrice.Multiply(int64(i.Quantity))
 }
 
 type OrderStatus int
@@ -28,6 +42,39 @@ const (
 	CANCELLED
 )
 
+var orderStatusNames = []string{"NEW", "PENDING", "COMPLETED", "CANCELLED"}
+
 func (s OrderStatus) String() string {
-	return []string{"NEW", "PENDING", "COMPLETED", "CANCELLED"}[s]
+	return orderStatusNames[s]
+}
+
+// ParseOrderStatus parses a status name such as "COMPLETED".
+func ParseOrderStatus(name string) (OrderStatus, error) {
+	for i, statusName := range orderStatusNames {
+		if statusName == name {
+			return OrderStatus(i), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrUnknownOrderStatus, name)
+}
+
+// IsOpen reports whether an order in this status is still being worked on.
+func (s OrderStatus) IsOpen() bool {
+	return s == NEW || s == PENDING
+}
+
+// orderTransitions lists the statuses an order may move to from each status.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	NEW:     {PENDING, CANCELLED},
+	PENDING: {COMPLETED, CANCELLED},
+}
+
+// CanTransitionTo reports whether an order in this status may move to next.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,47 @@
+package order
+
+import "time"
+
+const OrderPlacedTopic = "order.placed"
+
+// OrderPlacedEvent is published when a customer places an order. EventID is
+// unique per event, so subscribers that see it more than once can tell.
+type OrderPlacedEvent struct {
+	EventID     string           `json:"event_id"`
+	OrderID     string           `json:"order_id"`
+	MerchantID  string           `json:"merchant_id"`
+	CustomerID  string           `json:"customer_id"`
+	Status      string           `json:"status"`
+	Items       []OrderEventItem `json:"items"`
+	TotalAmount int64            `json:"total_amount"`
+	Currency    string           `json:"currency"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+// OrderEventItem is an order line as carried in order events, with the unit
+// price in minor units of the order's currency.
+type OrderEventItem struct {
+	MenuItemID string `json:"menu_item_id"`
+	Quantity   int    `json:"quantity"`
+	UnitPrice  int64  `json:"unit_price"`
+}
+
+func (e OrderPlacedEvent) Topic() string {
+	return OrderPlacedTopic
+}
+
+const OrderStatusChangedTopic = "order.status_changed"
+
+// OrderStatusChangedEvent is published whenever a merchant moves an order to a new status.
+type OrderStatusChangedEvent struct {
+	EventID    string    `json:"event_id"`
+	OrderID    string    `json:"order_id"`
+	MerchantID string    `json:"merchant_id"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+func (e OrderStatusChangedEvent) Topic() string {
+	return OrderStatusChangedTopic
+}
@@ -1,52 +1,181 @@
 package order
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"minimart/internal/menu"
+	"minimart/internal/merchant"
+	middlerware "minimart/internal/shared/middleware"
+	"minimart/internal/shared/validation"
+	"minimart/internal/user"
+	"strconv"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
 type OrderHandler struct {
-	usecase OrderUsecase
+	usecase     OrderUsecase
+	auth        fiber.Handler
+	access      merchant.AccessGuard
+	placeOrders fiber.Handler
 }
 
-func NewOrderHandler(usecase OrderUsecase) *OrderHandler {
-	return &OrderHandler{usecase: usecase}
+// NewOrderHandler creates a new OrderHandler. Every route runs auth, normally
+// middlerware.Authenticate; the merchant-facing routes then run access,
+// normally merchant.RequireAccess, and placing orders runs placeOrders,
+// normally middlerware.RequirePermission(middlerware.PermPlaceOrders).
+func NewOrderHandler(usecase OrderUsecase, auth fiber.Handler, access merchant.AccessGuard, placeOrders fiber.Handler) *OrderHandler {
+	return &OrderHandler{usecase: usecase, auth: auth, access: access, placeOrders: placeOrders}
 }
 
 func (h *OrderHandler) RegisterRoutes(app *fiber.App) {
-	app.Post("/orders", h.PlaceOrder)
+	app.Post("/orders", h.auth, h.placeOrders, h.PlaceOrder)
+
+	merchantRoutes := app.Group("/merchants/:merchantID/orders", h.auth)
+	merchantRoutes.Get("/", h.access(merchant.PermViewOrders), h.ListMerchantOrders)
+	merchantRoutes.Patch("/:orderID/status", h.access(merchant.PermUpdateOrders), h.UpdateOrderStatus)
 }
 
+// PlaceOrderRequest is an order placed by the authenticated customer.
 type PlaceOrderRequest struct {
-	CustomerID uuid.UUID   `json:"customer_id"`
+	MerchantID uuid.UUID   `json:"merchant_id"`
 	Items      []OrderItem `json:"items"`
 }
 
+// Validate checks the request's shape. Whether the items can be ordered is up
+// to the usecase.
+func (r PlaceOrderRequest) Validate() error {
+	var v validation.Validator
+	v.Check(r.MerchantID != uuid.Nil, "merchant_id", "is required")
+	v.Check(len(r.Items) > 0, "items", "must contain at least one item")
+	for i, item := range r.Items {
+		v.Check(item.MenuItemID != uuid.Nil, fmt.Sprintf("items[%d].menu_item_id", i), "is required")
+		v.Check(item.Quantity > 0, fmt.Sprintf("items[%d].quantity", i), "must be greater than 0")
+	}
+	return v.Err()
+}
+
+// PlaceOrder handles a customer placing an order. Orders are placed by users,
+// never by API keys.
 func (h *OrderHandler) PlaceOrder(c *fiber.Ctx) error {
+	principal, err := middlerware.PrincipalFrom(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	if principal.Kind != middlerware.PrincipalUser {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Orders can only be placed by users"})
+	}
+	customerID, err := uuid.Parse(principal.ID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": middlerware.ErrUnauthenticated.Error()})
+	}
+
 	var req PlaceOrderRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid request body",
 		})
 	}
-
-	if req.CustomerID == uuid.Nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Missing customer_id",
-		})
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
 	}
 
-	if len(req.Items) == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Order must contain at least one item",
-		})
-	}
-
-	order, err := h.usecase.PlaceOrder(c.Context(), req.CustomerID, req.Items)
+	order, err := h.usecase.PlaceOrder(c.Context(), customerID, req.MerchantID, req.Items)
 	if err != nil {
+		if errors.Is(err, ErrEmptyOrder) || errors.Is(err, ErrInvalidQuantity) || errors.Is(err, ErrMenuItemUnavailable) || errors.Is(err, menu.ErrNoPublishedMenu) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if errors.Is(err, ErrMerchantNotAcceptingOrders) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		var busy *MerchantBusyError
+		if errors.As(err, &busy) {
+			retryAfter := int(math.Ceil(busy.RetryAfter.Seconds()))
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error":               err.Error(),
+				"retry_after_seconds": retryAfter,
+			})
+		}
+		if errors.Is(err, merchant.ErrMerchantNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if errors.Is(err, user.ErrEmailNotVerified) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 	return c.Status(fiber.StatusCreated).JSON(order)
 }
+
+// ListMerchantOrders handles listing the orders placed with a merchant.
+func (h *OrderHandler) ListMerchantOrders(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	orders, err := h.usecase.ListMerchantOrders(c.Context(), merchantID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(orders)
+}
+
+type UpdateOrderStatusRequest struct {
+	// Status is the status name, e.g. "COMPLETED".
+	Status string `json:"status"`
+}
+
+func (r UpdateOrderStatusRequest) Validate() error {
+	var v validation.Validator
+	if _, err := ParseOrderStatus(r.Status); err != nil {
+		v.Add("status", err.Error())
+	}
+	return v.Err()
+}
+
+// UpdateOrderStatus handles a merchant moving one of its orders to a new status.
+func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+	orderID, err := uuid.Parse(c.Params("orderID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid order ID"})
+	}
+
+	var req UpdateOrderStatusRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
+	status, _ := ParseOrderStatus(req.Status)
+
+	order, err := h.usecase.UpdateOrderStatus(c.Context(), merchantID, orderID, status)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrOrderNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, ErrInvalidTransition):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(order)
+}
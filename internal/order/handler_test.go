@@ -6,6 +6,11 @@ import (
 	"encoding/json"
 	"io"
 	"log"
+	"minimart/internal/menu"
+	"minimart/internal/merchant"
+	"minimart/internal/shared/eventbus"
+	middlerware "minimart/internal/shared/middleware"
+	"minimart/internal/shared/money"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -14,6 +19,7 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/assert"
@@ -59,6 +65,22 @@ func TestMain(m *testing.M) {
 	// --- Run Migrations in Order ---
 	runMigration(ctx, "../../migrations/001_create_users_table.sql")
 	runMigration(ctx, "../../migrations/002_create_orders_tables.sql")
+	runMigration(ctx, "../../migrations/003_create_menu_items_table.sql")
+	runMigration(ctx, "../../migrations/004_create_merchants_table.sql")
+	runMigration(ctx, "../../migrations/005_add_menu_item_images.sql")
+	runMigration(ctx, "../../migrations/006_add_currency_columns.sql")
+	runMigration(ctx, "../../migrations/007_create_menu_versions_tables.sql")
+	runMigration(ctx, "../../migrations/008_add_menu_item_dietary_info.sql")
+	runMigration(ctx, "../../migrations/009_add_merchant_owner.sql")
+	runMigration(ctx, "../../migrations/010_add_merchant_onboarding.sql")
+	runMigration(ctx, "../../migrations/011_add_merchant_location.sql")
+	runMigration(ctx, "../../migrations/012_create_merchant_memberships.sql")
+	runMigration(ctx, "../../migrations/013_add_sales_analytics.sql")
+	runMigration(ctx, "../../migrations/014_create_settlement_ledger.sql")
+	runMigration(ctx, "../../migrations/015_add_merchant_busy_mode.sql")
+	runMigration(ctx, "../../migrations/016_create_webhooks.sql")
+	runMigration(ctx, "../../migrations/017_add_merchant_slugs.sql")
+	runMigration(ctx, "../../migrations/019_add_user_roles.sql")
 
 	// 6. Run the actual tests
 	exitCode := m.Run()
@@ -82,25 +104,52 @@ func runMigration(ctx context.Context, filePath string) {
 func TestOrderHandler_PlaceOrder_Integration(t *testing.T) {
 	// Arrange
 	// 1. Setup the application using the real Postgres repository
+	menuRepo := menu.NewPostgresMenuRepository(dbpool)
 	orderRepo := NewPostgresOrderRepository(dbpool)
-	orderUsecase := NewOrderUsecase(orderRepo)
-	orderHandler := NewOrderHandler(orderUsecase)
+	// Orders are only placed here, never completed, so nothing is settled
+	orderUsecase := NewOrderUsecase(orderRepo, menuRepo, merchant.NewPostgresMerchantRepository(dbpool), merchant.NewInMemoryPauseStore(), nil, anyCustomer, eventbus.NewInMemoryEventBus(), discardLogger)
+	// 2. Seed a user in ther database to act as ther customer
+	customerID := uuid.New()
+	_, err := dbpool.Exec(context.Background(), "INSERT INTO users (id, name, email, password) VALUES ($1, $2, $3, $4);", customerID, "Test Customer", "customer@example.com", "password")
+	require.NoError(t, err)
+
+	// Requests are authenticated as the customer, or as an API key when they carry one.
+	// Only the customer-facing route is exercised here, so merchant access is never checked
+	auth := func(c *fiber.Ctx) error {
+		if c.Get(middlerware.APIKeyHeader) != "" {
+			c.Locals("principal", &middlerware.Principal{Kind: middlerware.PrincipalAPIKey, ID: uuid.NewString()})
+			return c.Next()
+		}
+		c.Locals("user", &middlerware.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: customerID.String()}, Role: middlerware.RoleCustomer})
+		return c.Next()
+	}
+	denyAll := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusForbidden) }
+	orderHandler := NewOrderHandler(orderUsecase, auth, func(merchant.Permission) fiber.Handler { return denyAll }, middlerware.RequirePermission(middlerware.PermPlaceOrders))
 
 	app := fiber.New()
 	orderHandler.RegisterRoutes(app)
 
-	// 2. Seed a user in ther database to act as ther customer
-	customerID := uuid.New()
-	_, err := dbpool.Exec(context.Background(), "INSERT INTO users (id, name, email, password) VALUES ($1, $2, $3, $4);", customerID, "Test Customer", "customer@example.com", "password")
+	// 3. Seed a merchant with two menu items to order from
+	seededMerchant := merchant.NewMerchant(uuid.Nil, "Noodle Bar", "", money.THB)
+	seededMerchant.Status = merchant.StatusApproved
+	seededMerchant.IsActive = true
+	err = merchant.NewPostgresMerchantRepository(dbpool).Save(context.Background(), seededMerchant)
+	require.NoError(t, err)
+
+	noodles := &menu.MenuItem{ID: uuid.New(), MerchantID: seededMerchant.ID, Name: "Noodles", Price: money.New(8000, money.THB), InStock: true}
+	tea := &menu.MenuItem{ID: uuid.New(), MerchantID: seededMerchant.ID, Name: "Iced Tea", Price: money.New(3500, money.THB), InStock: true}
+	require.NoError(t, menuRepo.Save(context.Background(), noodles))
+	require.NoError(t, menuRepo.Save(context.Background(), tea))
+	liveMenu, err := menuRepo.PublishDraft(context.Background(), seededMerchant.ID)
 	require.NoError(t, err)
 
 	// Act
-	// 3. Create the HTTP request to place an order
+	// 4. Create the HTTP request to place an order
 	reqBody := PlaceOrderRequest{
-		CustomerID: customerID,
+		MerchantID: seededMerchant.ID,
 		Items: []OrderItem{
-			{MenuItemID: uuid.New(), Quantity: 2},
-			{MenuItemID: uuid.New(), Quantity: 1},
+			{MenuItemID: noodles.ID, Quantity: 2},
+			{MenuItemID: tea.ID, Quantity: 1},
 		},
 	}
 	bodyBytes, _ := json.Marshal(reqBody)
@@ -120,6 +169,17 @@ func TestOrderHandler_PlaceOrder_Integration(t *testing.T) {
 
 	assert.Equal(t, customerID, createdOrder.CustomerID)
 	assert.Len(t, createdOrder.Items, 2)
+	assert.Equal(t, money.New(19500, money.THB), createdOrder.Total)
+	assert.Equal(t, seededMerchant.ID, createdOrder.MerchantID)
+	assert.Equal(t, liveMenu.ID, createdOrder.MenuVersionID)
 	assert.Equal(t, NEW, createdOrder.Status)
 	assert.NotEmpty(t, createdOrder.ID)
+
+	// API keys act for a merchant, never as a customer
+	req = httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middlerware.APIKeyHeader, "mk_key")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
 }
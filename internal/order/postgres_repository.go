@@ -3,6 +3,7 @@ package order
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -30,17 +31,20 @@ func (r *PostgresOrderRepository) Save(ctx context.Context, order *Order) error
 	defer tx.Rollback(ctx)
 
 	// Insert into the 'orders' table
-	orderQuery := "INSERT INTO orders (id, customer_id, status, created_at) VALUES ($1, $2, $3, $4)"
+	orderQuery := `
+		INSERT INTO orders (id, customer_id, merchant_id, menu_version_id, status, total_amount, currency, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
 
-	_, err = tx.Exec(ctx, orderQuery, order.ID, order.CustomerID, order.Status, order.CreatedAt)
+	_, err = tx.Exec(ctx, orderQuery, order.ID, order.CustomerID, order.MerchantID, order.MenuVersionID, order.Status, order.Total.Amount, order.Total.Currency, order.CreatedAt)
 	if err != nil {
 		return err
 	}
 
 	// Insert each item into the 'order_items' table
 	for _, item := range order.Items {
-		itemQuery := "INSERT INTO order_items (order_id, menu_item_id, quantity) VALUES ($1, $2, $3)"
-		_, err = tx.Exec(ctx, itemQuery, order.ID, item.MenuItemID, item.Quantity)
+		itemQuery := "INSERT INTO order_items (order_id, menu_item_id, quantity, unit_price, currency) VALUES ($1, $2, $3, $4, $5)"
+		_, err = tx.Exec(ctx, itemQuery, order.ID, item.MenuItemID, item.Quantity, item.UnitPrice.Amount, item.UnitPrice.Currency)
 		if err != nil {
 			return err
 		}
@@ -51,17 +55,30 @@ func (r *PostgresOrderRepository) Save(ctx context.Context, order *Order) error
 
 func (r *PostgresOrderRepository) GetByID(ctx context.Context, id uuid.UUID) (*Order, error) {
 	order := &Order{}
-	orderQuery := "SELECT id, customer_id, status, created_at FROM orders WHERE id = $1"
+	orderQuery := `
+		SELECT id, customer_id, merchant_id, menu_version_id, status, total_amount, currency, created_at
+		FROM orders
+		WHERE id = $1
+	`
 
-	err := r.db.QueryRow(ctx, orderQuery, id).Scan(&order.ID, &order.CustomerID, &order.Status, &order.Status, &order.CreatedAt)
+	err := r.db.QueryRow(ctx, orderQuery, id).Scan(
+		&order.ID,
+		&order.CustomerID,
+		&order.MerchantID,
+		&order.MenuVersionID,
+		&order.Status,
+		&order.Total.Amount,
+		&order.Total.Currency,
+		&order.CreatedAt,
+	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, errors.New("order not found")
+			return nil, ErrOrderNotFound
 		}
 		return nil, err
 	}
 
-	itemsQuery := "SELECT menu_item_id, quantity FROM order_items WHERE order_id = $1"
+	itemsQuery := "SELECT menu_item_id, quantity, unit_price, currency FROM order_items WHERE order_id = $1 ORDER BY id"
 	rows, err := r.db.Query(ctx, itemsQuery, id)
 	if err != nil {
 		return nil, err
@@ -71,7 +88,7 @@ func (r *PostgresOrderRepository) GetByID(ctx context.Context, id uuid.UUID) (*O
 	var items []OrderItem
 	for rows.Next() {
 		var item OrderItem
-		if err := rows.Scan(&item.MenuItemID, &item.Quantity); err != nil {
+		if err := rows.Scan(&item.MenuItemID, &item.Quantity, &item.UnitPrice.Amount, &item.UnitPrice.Currency); err != nil {
 			return nil, err
 		}
 		items = append(items, item)
@@ -80,3 +97,113 @@ func (r *PostgresOrderRepository) GetByID(ctx context.Context, id uuid.UUID) (*O
 	order.Items = items
 	return order, nil
 }
+
+// GetByMerchantID retrieves a merchant's orders with their items, newest first.
+func (r *PostgresOrderRepository) GetByMerchantID(ctx context.Context, merchantID uuid.UUID) ([]*Order, error) {
+	orderQuery := `
+		SELECT id, customer_id, merchant_id, menu_version_id, status, total_amount, currency, created_at
+		FROM orders
+		WHERE merchant_id = $1
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.Query(ctx, orderQuery, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []*Order{}
+	ordersByID := make(map[uuid.UUID]*Order)
+	for rows.Next() {
+		order := &Order{}
+		err := rows.Scan(
+			&order.ID,
+			&order.CustomerID,
+			&order.MerchantID,
+			&order.MenuVersionID,
+			&order.Status,
+			&order.Total.Amount,
+			&order.Total.Currency,
+			&order.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+		ordersByID[order.ID] = order
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	itemsQuery := `
+		SELECT oi.order_id, oi.menu_item_id, oi.quantity, oi.unit_price, oi.currency
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		WHERE o.merchant_id = $1
+		ORDER BY oi.id
+	`
+	itemRows, err := r.db.Query(ctx, itemsQuery, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var orderID uuid.UUID
+		var item OrderItem
+		if err := itemRows.Scan(&orderID, &item.MenuItemID, &item.Quantity, &item.UnitPrice.Amount, &item.UnitPrice.Currency); err != nil {
+			return nil, err
+		}
+		// Orders placed between the two queries are not in the map yet.
+		if order, found := ordersByID[orderID]; found {
+			order.Items = append(order.Items, item)
+		}
+	}
+	if err := itemRows.Err(); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (r *PostgresOrderRepository) GetOpenOrders(ctx context.Context, merchantID uuid.UUID) (OpenOrders, error) {
+	query := `
+		SELECT COUNT(*), MIN(created_at)
+		FROM orders
+		WHERE merchant_id = $1 AND status IN ($2, $3)
+	`
+	var open OpenOrders
+	var oldest *time.Time
+	if err := r.db.QueryRow(ctx, query, merchantID, NEW, PENDING).Scan(&open.Count, &oldest); err != nil {
+		return OpenOrders{}, err
+	}
+	if oldest != nil {
+		open.OldestCreatedAt = *oldest
+	}
+	return open, nil
+}
+
+// UpdateStatus changes the status of an order that is still in status from.
+// The UPDATE locks the order row until the transaction ends, so a concurrent
+// change waits for beforeCommit and then finds the order in its new status.
+func (r *PostgresOrderRepository) UpdateStatus(ctx context.Context, id uuid.UUID, from, to OrderStatus, beforeCommit func(ctx context.Context) error) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, "UPDATE orders SET status = $2 WHERE id = $1 AND status = $3", id, to, from)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvalidTransition
+	}
+	if beforeCommit != nil {
+		if err := beforeCommit(ctx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
@@ -2,19 +2,46 @@ package order
 
 import (
 	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrOrderNotFound is returned when an order does not exist.
+var ErrOrderNotFound = errors.New("order not found")
+
+// OpenOrders summarises a merchant's NEW and PENDING orders.
+type OpenOrders struct {
+	Count int
+	// OldestCreatedAt is zero when there are no open orders.
+	OldestCreatedAt time.Time
+}
+
 type OrderRepository interface {
 	// Save creates or updates an order in the repositroy
 	Save(ctx context.Context, order *Order) error
 
 	// GetByID retrieves an order by its ID.
 	GetByID(ctx context.Context, id uuid.UUID) (*Order, error)
+
+	// GetByMerchantID retrieves a merchant's orders, newest first.
+	GetByMerchantID(ctx context.Context, merchantID uuid.UUID) ([]*Order, error)
+
+	// UpdateStatus moves an order from status from to status to, failing with
+	// ErrInvalidTransition if the order is no longer in status from. The order
+	// stays locked while beforeCommit runs, and the change is rolled back if
+	// beforeCommit fails. beforeCommit may be nil.
+	UpdateStatus(ctx context.Context, id uuid.UUID, from, to OrderStatus, beforeCommit func(ctx context.Context) error) error
+
+	// GetOpenOrders summarises the merchant's orders that are not yet completed or cancelled.
+	GetOpenOrders(ctx context.Context, merchantID uuid.UUID) (OpenOrders, error)
 }
 
 type InMemoryOrderRepository struct {
+	mu     sync.Mutex
 	orders map[uuid.UUID]*Order
 }
 
@@ -25,14 +52,74 @@ func NewInMemoryOrderRepository() *InMemoryOrderRepository {
 }
 
 func (r *InMemoryOrderRepository) GetByID(ctx context.Context, id uuid.UUID) (*Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	order, exists := r.orders[id]
 	if !exists {
-		return nil, nil
+		return nil, ErrOrderNotFound
 	}
-	return order, nil
+	found := *order
+	return &found, nil
+}
+
+func (r *InMemoryOrderRepository) GetByMerchantID(ctx context.Context, merchantID uuid.UUID) ([]*Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	orders := []*Order{}
+	for _, order := range r.orders {
+		if order.MerchantID == merchantID {
+			found := *order
+			orders = append(orders, &found)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].CreatedAt.After(orders[j].CreatedAt) })
+	return orders, nil
+}
+
+func (r *InMemoryOrderRepository) GetOpenOrders(ctx context.Context, merchantID uuid.UUID) (OpenOrders, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var open OpenOrders
+	for _, order := range r.orders {
+		if order.MerchantID != merchantID || !order.Status.IsOpen() {
+			continue
+		}
+		open.Count++
+		if open.OldestCreatedAt.IsZero() || order.CreatedAt.Before(open.OldestCreatedAt) {
+			open.OldestCreatedAt = order.CreatedAt
+		}
+	}
+	return open, nil
+}
+
+func (r *InMemoryOrderRepository) UpdateStatus(ctx context.Context, id uuid.UUID, from, to OrderStatus, beforeCommit func(ctx context.Context) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order, exists := r.orders[id]
+	if !exists {
+		return ErrOrderNotFound
+	}
+	if order.Status != from {
+		return ErrInvalidTransition
+	}
+	if beforeCommit != nil {
+		if err := beforeCommit(ctx); err != nil {
+			return err
+		}
+	}
+	order.Status = to
+	return nil
 }
 
 func (r *InMemoryOrderRepository) Save(ctx context.Context, order *Order) error {
-	r.orders[order.ID] = order
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *order
+	r.orders[order.ID] = &stored
 	return nil
 }
@@ -2,35 +2,290 @@ package order
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"minimart/internal/menu"
+	"minimart/internal/merchant"
+	"minimart/internal/shared/eventbus"
+	"minimart/internal/shared/money"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+var (
+	ErrEmptyOrder          = errors.New("order must contain at least one item")
+	ErrInvalidQuantity     = errors.New("item quantity must be at least 1")
+	ErrMenuItemUnavailable = errors.New("menu item is not available")
+	ErrUnknownOrderStatus  = errors.New("unknown order status")
+	ErrInvalidTransition   = errors.New("order cannot move to that status")
+	// ErrMerchantNotAcceptingOrders is returned for merchants that are not
+	// approved or are closed.
+	ErrMerchantNotAcceptingOrders = errors.New("merchant is not accepting orders")
+	// ErrMerchantBusy is returned, wrapped in a *MerchantBusyError, while a
+	// merchant is paused or has as many open orders as it takes.
+	ErrMerchantBusy = errors.New("merchant is busy")
+)
+
+const (
+	// EstimatedPrepTime is how long an order is expected to stay open. Busy
+	// merchants are expected to free up once their oldest open order is done.
+	EstimatedPrepTime = 15 * time.Minute
+	// MinBusyRetryAfter is the shortest wait suggested to customers of busy merchants.
+	MinBusyRetryAfter = time.Minute
+)
+
+// MerchantBusyError tells customers of a busy merchant roughly how long to wait.
+type MerchantBusyError struct {
+	RetryAfter time.Duration
+}
+
+func (e *MerchantBusyError) Error() string {
+	return fmt.Sprintf("%s, try again in %s", ErrMerchantBusy, e.RetryAfter.Round(time.Minute))
+}
+
+func (e *MerchantBusyError) Unwrap() error {
+	return ErrMerchantBusy
+}
+
 type OrderUsecase interface {
-	// PlaceOrder creates a new order for a given customer with a list of items.
-	PlaceOrder(ctx context.Context, customerID uuid.UUID, items []OrderItem) (*Order, error)
+	// PlaceOrder creates a new order for a given customer with a list of items
+	// from a single merchant's menu.
+	PlaceOrder(ctx context.Context, customerID, merchantID uuid.UUID, items []OrderItem) (*Order, error)
+	// ListMerchantOrders returns the merchant's orders, newest first.
+	ListMerchantOrders(ctx context.Context, merchantID uuid.UUID) ([]*Order, error)
+	// UpdateOrderStatus moves one of the merchant's orders to a new status.
+	UpdateOrderStatus(ctx context.Context, merchantID, orderID uuid.UUID, status OrderStatus) (*Order, error)
+}
+
+// MenuReader is the part of the menu module orders are priced from.
+type MenuReader interface {
+	GetLiveVersion(ctx context.Context, merchantID uuid.UUID) (*menu.MenuVersion, error)
+}
+
+// MerchantReader is the part of the merchant module orders check the merchant against.
+type MerchantReader interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*merchant.Merchant, error)
+}
+
+// PauseReader is the part of the merchant module's busy mode orders are checked against.
+type PauseReader interface {
+	PausedUntil(ctx context.Context, merchantID uuid.UUID) (time.Time, bool, error)
+}
+
+// Settlement records completed orders in the merchant's settlement ledger.
+// Recording the same order again must be a no-op.
+type Settlement interface {
+	RecordOrderCompleted(ctx context.Context, order *Order) error
+}
+
+// CustomerPolicy decides whether a customer may place orders at all,
+// normally user.OrderingPolicy.
+type CustomerPolicy interface {
+	CanPlaceOrders(ctx context.Context, customerID uuid.UUID) error
 }
 
 type orderUsecase struct {
-	repo OrderRepository
+	repo       OrderRepository
+	menus      MenuReader
+	merchants  MerchantReader
+	pauses     PauseReader
+	settlement Settlement
+	customers  CustomerPolicy
+	eventBus   eventbus.EventBus
+	logger     *slog.Logger
 }
 
-func NewOrderUsecase(repo OrderRepository) OrderUsecase {
-	return &orderUsecase{repo: repo}
+func NewOrderUsecase(repo OrderRepository, menus MenuReader, merchants MerchantReader, pauses PauseReader, settlement Settlement, customers CustomerPolicy, eventBus eventbus.EventBus, logger *slog.Logger) OrderUsecase {
+	return &orderUsecase{
+		repo:       repo,
+		menus:      menus,
+		merchants:  merchants,
+		pauses:     pauses,
+		settlement: settlement,
+		customers:  customers,
+		eventBus:   eventBus,
+		logger:     logger,
+	}
 }
 
-func (u *orderUsecase) PlaceOrder(ctx context.Context, customerID uuid.UUID, items []OrderItem) (*Order, error) {
+func (u *orderUsecase) PlaceOrder(ctx context.Context, customerID, merchantID uuid.UUID, items []OrderItem) (*Order, error) {
+	if len(items) == 0 {
+		return nil, ErrEmptyOrder
+	}
+	if err := u.customers.CanPlaceOrders(ctx, customerID); err != nil {
+		return nil, err
+	}
+
+	m, err := u.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if !m.AcceptsCustomers() {
+		return nil, ErrMerchantNotAcceptingOrders
+	}
+	if err := u.checkBusy(ctx, m); err != nil {
+		return nil, err
+	}
+
+	// Orders are priced against the live menu, never the merchant's draft.
+	liveMenu, err := u.menus.GetLiveVersion(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	menuByID := make(map[uuid.UUID]*menu.MenuItem, len(liveMenu.Items))
+	for _, menuItem := range liveMenu.Items {
+		menuByID[menuItem.ID] = menuItem
+	}
+
+	// Prices always come from the menu, never from the client.
+	pricedItems := make([]OrderItem, 0, len(items))
+	var total money.Money
+	for i, item := range items {
+		if item.Quantity < 1 {
+			return nil, ErrInvalidQuantity
+		}
+		menuItem, found := menuByID[item.MenuItemID]
+		if !found || !menuItem.InStock {
+			return nil, fmt.Errorf("%w: %s", ErrMenuItemUnavailable, item.MenuItemID)
+		}
+
+		priced := OrderItem{
+			MenuItemID: item.MenuItemID,
+			Quantity:   item.Quantity,
+			UnitPrice:  menuItem.Price,
+		}
+		lineTotal, err := priced.LineTotal()
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			total = money.Zero(lineTotal.Currency)
+		}
+		if total, err = total.Add(lineTotal); err != nil {
+			return nil, err
+		}
+		pricedItems = append(pricedItems, priced)
+	}
+
 	order := &Order{
-		ID:         uuid.New(),
-		CustomerID: customerID,
-		Items:      items,
-		Status:     NEW,
-		CreatedAt:  time.Now(),
+		ID:            uuid.New(),
+		CustomerID:    customerID,
+		MerchantID:    merchantID,
+		MenuVersionID: liveMenu.ID,
+		Items:         pricedItems,
+		Total:         total,
+		Status:        NEW,
+		CreatedAt:     time.Now(),
 	}
 
 	if err := u.repo.Save(ctx, order); err != nil {
 		return nil, err
 	}
+
+	eventItems := make([]OrderEventItem, 0, len(order.Items))
+	for _, item := range order.Items {
+		eventItems = append(eventItems, OrderEventItem{
+			MenuItemID: item.MenuItemID.String(),
+			Quantity:   item.Quantity,
+			UnitPrice:  item.UnitPrice.Amount,
+		})
+	}
+	event := OrderPlacedEvent{
+		EventID:     uuid.NewString(),
+		OrderID:     order.ID.String(),
+		MerchantID:  order.MerchantID.String(),
+		CustomerID:  order.CustomerID.String(),
+		Status:      order.Status.String(),
+		Items:       eventItems,
+		TotalAmount: order.Total.Amount,
+		Currency:    string(order.Total.Currency),
+		CreatedAt:   order.CreatedAt,
+	}
+	u.publish(ctx, event)
+	return order, nil
+}
+
+// publish announces an order change that has already been stored. Failing the
+// request at this point would make clients retry and place the order twice or
+// repeat the status change, so a failed publish is logged instead, and the
+// webhooks for that event are not sent.
+func (u *orderUsecase) publish(ctx context.Context, event eventbus.Event) {
+	if err := u.eventBus.Publish(ctx, event); err != nil {
+		u.logger.Error("Failed to publish order event", "module", "order", "topic", event.Topic(), "error", err)
+	}
+}
+
+// checkBusy returns a *MerchantBusyError if the merchant is paused or at its
+// cap on open orders. Orders placed at the same moment may overshoot the cap.
+func (u *orderUsecase) checkBusy(ctx context.Context, m *merchant.Merchant) error {
+	now := time.Now()
+	until, paused, err := u.pauses.PausedUntil(ctx, m.ID)
+	if err != nil {
+		return err
+	}
+	if paused {
+		return &MerchantBusyError{RetryAfter: max(until.Sub(now), MinBusyRetryAfter)}
+	}
+
+	if m.MaxOpenOrders == 0 {
+		return nil
+	}
+	open, err := u.repo.GetOpenOrders(ctx, m.ID)
+	if err != nil {
+		return err
+	}
+	if open.Count < m.MaxOpenOrders {
+		return nil
+	}
+	return &MerchantBusyError{RetryAfter: max(open.OldestCreatedAt.Add(EstimatedPrepTime).Sub(now), MinBusyRetryAfter)}
+}
+
+func (u *orderUsecase) ListMerchantOrders(ctx context.Context, merchantID uuid.UUID) ([]*Order, error) {
+	return u.repo.GetByMerchantID(ctx, merchantID)
+}
+
+func (u *orderUsecase) UpdateOrderStatus(ctx context.Context, merchantID, orderID uuid.UUID, status OrderStatus) (*Order, error) {
+	order, err := u.repo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	// Orders of other merchants are reported as missing rather than forbidden.
+	if order.MerchantID != merchantID {
+		return nil, ErrOrderNotFound
+	}
+	if !order.Status.CanTransitionTo(status) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, order.Status, status)
+	}
+
+	// Completed orders are settled while the order is locked in its old status,
+	// so a concurrent cancellation cannot win after the revenue is recorded and
+	// a failed settlement leaves the order as it was. Should the status change
+	// still fail to commit, retrying settles again as a no-op.
+	var settle func(ctx context.Context) error
+	if status == COMPLETED {
+		settle = func(ctx context.Context) error {
+			return u.settlement.RecordOrderCompleted(ctx, order)
+		}
+	}
+	from := order.Status
+	if err := u.repo.UpdateStatus(ctx, orderID, from, status, settle); err != nil {
+		if errors.Is(err, ErrInvalidTransition) {
+			return nil, fmt.Errorf("%w: order is no longer %s", ErrInvalidTransition, from)
+		}
+		return nil, err
+	}
+
+	event := OrderStatusChangedEvent{
+		EventID:    uuid.NewString(),
+		OrderID:    order.ID.String(),
+		MerchantID: order.MerchantID.String(),
+		From:       from.String(),
+		To:         status.String(),
+		ChangedAt:  time.Now(),
+	}
+	order.Status = status
+	u.publish(ctx, event)
 	return order, nil
 }
@@ -0,0 +1,361 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"minimart/internal/menu"
+	"minimart/internal/merchant"
+	"minimart/internal/shared/eventbus"
+	"minimart/internal/shared/money"
+	"minimart/internal/shared/validation"
+	"minimart/internal/user"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// seedMerchant stores an approved, open merchant unless the caller changes it.
+func seedMerchant(t *testing.T, repo merchant.MerchantRepository, configure ...func(*merchant.Merchant)) uuid.UUID {
+	m := merchant.NewMerchant(uuid.New(), "Burger Barn", "", money.USD)
+	m.Slug += "-" + m.ID.String()[:8]
+	m.Status = merchant.StatusApproved
+	m.IsActive = true
+	for _, fn := range configure {
+		fn(m)
+	}
+	require.NoError(t, repo.Save(context.Background(), m))
+	return m.ID
+}
+
+// settlementFunc adapts a function to the Settlement interface.
+type settlementFunc func(ctx context.Context, order *Order) error
+
+func (f settlementFunc) RecordOrderCompleted(ctx context.Context, order *Order) error {
+	return f(ctx, order)
+}
+
+var noSettlement = settlementFunc(func(context.Context, *Order) error { return nil })
+
+// customerPolicyFunc adapts a function to the CustomerPolicy interface.
+type customerPolicyFunc func(ctx context.Context, customerID uuid.UUID) error
+
+func (f customerPolicyFunc) CanPlaceOrders(ctx context.Context, customerID uuid.UUID) error {
+	return f(ctx, customerID)
+}
+
+var anyCustomer = customerPolicyFunc(func(context.Context, uuid.UUID) error { return nil })
+
+func TestOrderUsecase_PlaceOrder(t *testing.T) {
+	ctx := context.Background()
+	merchants := merchant.NewInMemoryMerchantRepository()
+	merchantID := seedMerchant(t, merchants)
+
+	menuRepo := menu.NewInMemoryMenuRepository()
+	burger := &menu.MenuItem{ID: uuid.New(), MerchantID: merchantID, Name: "Burger", Price: money.New(1250, money.USD), InStock: true}
+	shake := &menu.MenuItem{ID: uuid.New(), MerchantID: merchantID, Name: "Shake", Price: money.New(499, money.USD), InStock: false}
+	require.NoError(t, menuRepo.Save(ctx, burger))
+	require.NoError(t, menuRepo.Save(ctx, shake))
+	liveMenu, err := menuRepo.PublishDraft(ctx, merchantID)
+	require.NoError(t, err)
+
+	pauses := merchant.NewInMemoryPauseStore()
+	unverifiedID := uuid.New()
+	customers := customerPolicyFunc(func(ctx context.Context, customerID uuid.UUID) error {
+		if customerID == unverifiedID {
+			return user.ErrEmailNotVerified
+		}
+		return nil
+	})
+	usecase := NewOrderUsecase(NewInMemoryOrderRepository(), menuRepo, merchants, pauses, noSettlement, customers, eventbus.NewInMemoryEventBus(), discardLogger)
+
+	t.Run("should refuse customers the policy does not allow to order", func(t *testing.T) {
+		_, err := usecase.PlaceOrder(ctx, unverifiedID, merchantID, []OrderItem{{MenuItemID: burger.ID, Quantity: 1}})
+		assert.ErrorIs(t, err, user.ErrEmailNotVerified)
+	})
+
+	t.Run("should price the order from the menu", func(t *testing.T) {
+		// The client-supplied price must be ignored
+		items := []OrderItem{{MenuItemID: burger.ID, Quantity: 3, UnitPrice: money.New(1, money.USD)}}
+
+		order, err := usecase.PlaceOrder(ctx, uuid.New(), merchantID, items)
+		require.NoError(t, err)
+
+		assert.Equal(t, money.New(3750, money.USD), order.Total)
+		assert.Equal(t, money.New(1250, money.USD), order.Items[0].UnitPrice)
+		assert.Equal(t, liveMenu.ID, order.MenuVersionID)
+	})
+
+	t.Run("should reject out of stock and unknown items", func(t *testing.T) {
+		_, err := usecase.PlaceOrder(ctx, uuid.New(), merchantID, []OrderItem{{MenuItemID: shake.ID, Quantity: 1}})
+		assert.ErrorIs(t, err, ErrMenuItemUnavailable)
+
+		_, err = usecase.PlaceOrder(ctx, uuid.New(), merchantID, []OrderItem{{MenuItemID: uuid.New(), Quantity: 1}})
+		assert.ErrorIs(t, err, ErrMenuItemUnavailable)
+	})
+
+	t.Run("should refuse orders for merchants without a published menu", func(t *testing.T) {
+		_, err := usecase.PlaceOrder(ctx, uuid.New(), seedMerchant(t, merchants), []OrderItem{{MenuItemID: burger.ID, Quantity: 1}})
+		assert.ErrorIs(t, err, menu.ErrNoPublishedMenu)
+	})
+
+	t.Run("should refuse orders for merchants that are not approved", func(t *testing.T) {
+		for _, status := range []merchant.MerchantStatus{merchant.StatusSubmitted, merchant.StatusRejected, merchant.StatusSuspended} {
+			merchantID := seedMerchant(t, merchants, func(m *merchant.Merchant) { m.Status = status })
+			_, err := usecase.PlaceOrder(ctx, uuid.New(), merchantID, []OrderItem{{MenuItemID: burger.ID, Quantity: 1}})
+			assert.ErrorIs(t, err, ErrMerchantNotAcceptingOrders, status)
+		}
+
+		closedID := seedMerchant(t, merchants, func(m *merchant.Merchant) { m.IsActive = false })
+		_, err := usecase.PlaceOrder(ctx, uuid.New(), closedID, []OrderItem{{MenuItemID: burger.ID, Quantity: 1}})
+		assert.ErrorIs(t, err, ErrMerchantNotAcceptingOrders)
+
+		_, err = usecase.PlaceOrder(ctx, uuid.New(), uuid.New(), []OrderItem{{MenuItemID: burger.ID, Quantity: 1}})
+		assert.ErrorIs(t, err, merchant.ErrMerchantNotFound)
+	})
+
+	t.Run("should reject invalid quantities", func(t *testing.T) {
+		_, err := usecase.PlaceOrder(ctx, uuid.New(), merchantID, []OrderItem{{MenuItemID: burger.ID, Quantity: 0}})
+		assert.ErrorIs(t, err, ErrInvalidQuantity)
+	})
+
+	t.Run("should refuse orders while the merchant is paused", func(t *testing.T) {
+		require.NoError(t, pauses.Pause(ctx, merchantID, time.Now().Add(20*time.Minute)))
+
+		_, err := usecase.PlaceOrder(ctx, uuid.New(), merchantID, []OrderItem{{MenuItemID: burger.ID, Quantity: 1}})
+		var busy *MerchantBusyError
+		require.ErrorAs(t, err, &busy)
+		assert.ErrorIs(t, err, ErrMerchantBusy)
+		assert.InDelta(t, 20*time.Minute, busy.RetryAfter, float64(time.Second))
+
+		require.NoError(t, pauses.Resume(ctx, merchantID))
+		_, err = usecase.PlaceOrder(ctx, uuid.New(), merchantID, []OrderItem{{MenuItemID: burger.ID, Quantity: 1}})
+		assert.NoError(t, err)
+	})
+
+	t.Run("should refuse orders beyond the cap on open orders", func(t *testing.T) {
+		cappedID := seedMerchant(t, merchants, func(m *merchant.Merchant) { m.MaxOpenOrders = 2 })
+		fries := &menu.MenuItem{ID: uuid.New(), MerchantID: cappedID, Name: "Fries", Price: money.New(300, money.USD), InStock: true}
+		require.NoError(t, menuRepo.Save(ctx, fries))
+		_, err := menuRepo.PublishDraft(ctx, cappedID)
+		require.NoError(t, err)
+
+		first, err := usecase.PlaceOrder(ctx, uuid.New(), cappedID, []OrderItem{{MenuItemID: fries.ID, Quantity: 1}})
+		require.NoError(t, err)
+		_, err = usecase.PlaceOrder(ctx, uuid.New(), cappedID, []OrderItem{{MenuItemID: fries.ID, Quantity: 1}})
+		require.NoError(t, err)
+
+		_, err = usecase.PlaceOrder(ctx, uuid.New(), cappedID, []OrderItem{{MenuItemID: fries.ID, Quantity: 1}})
+		var busy *MerchantBusyError
+		require.ErrorAs(t, err, &busy)
+		assert.InDelta(t, EstimatedPrepTime, busy.RetryAfter, float64(time.Second))
+
+		// Finishing an order frees a slot
+		_, err = usecase.UpdateOrderStatus(ctx, cappedID, first.ID, CANCELLED)
+		require.NoError(t, err)
+		_, err = usecase.PlaceOrder(ctx, uuid.New(), cappedID, []OrderItem{{MenuItemID: fries.ID, Quantity: 1}})
+		assert.NoError(t, err)
+	})
+}
+
+func TestOrderUsecase_UpdateOrderStatus(t *testing.T) {
+	ctx := context.Background()
+	merchants := merchant.NewInMemoryMerchantRepository()
+	merchantID := seedMerchant(t, merchants)
+
+	menuRepo := menu.NewInMemoryMenuRepository()
+	burger := &menu.MenuItem{ID: uuid.New(), MerchantID: merchantID, Name: "Burger", Price: money.New(1250, money.USD), InStock: true}
+	require.NoError(t, menuRepo.Save(ctx, burger))
+	_, err := menuRepo.PublishDraft(ctx, merchantID)
+	require.NoError(t, err)
+
+	var settled []uuid.UUID
+	settlement := settlementFunc(func(ctx context.Context, order *Order) error {
+		settled = append(settled, order.ID)
+		return nil
+	})
+	bus := eventbus.NewInMemoryEventBus()
+	transitions := make(chan string, 10)
+	require.NoError(t, bus.Subscribe(OrderStatusChangedTopic, func(ctx context.Context, event eventbus.Event) error {
+		changed := event.(OrderStatusChangedEvent)
+		transitions <- changed.From + "->" + changed.To
+		return nil
+	}))
+	usecase := NewOrderUsecase(NewInMemoryOrderRepository(), menuRepo, merchants, merchant.NewInMemoryPauseStore(), settlement, anyCustomer, bus, discardLogger)
+	order, err := usecase.PlaceOrder(ctx, uuid.New(), merchantID, []OrderItem{{MenuItemID: burger.ID, Quantity: 1}})
+	require.NoError(t, err)
+
+	t.Run("should move the order through its lifecycle", func(t *testing.T) {
+		updated, err := usecase.UpdateOrderStatus(ctx, merchantID, order.ID, PENDING)
+		require.NoError(t, err)
+		assert.Equal(t, PENDING, updated.Status)
+		assert.Empty(t, settled)
+
+		updated, err = usecase.UpdateOrderStatus(ctx, merchantID, order.ID, COMPLETED)
+		require.NoError(t, err)
+		assert.Equal(t, COMPLETED, updated.Status)
+		assert.Equal(t, []uuid.UUID{order.ID}, settled)
+
+		orders, err := usecase.ListMerchantOrders(ctx, merchantID)
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		assert.Equal(t, COMPLETED, orders[0].Status)
+
+		assert.ElementsMatch(t, []string{"NEW->PENDING", "PENDING->COMPLETED"}, []string{<-transitions, <-transitions})
+	})
+
+	t.Run("should reject invalid transitions", func(t *testing.T) {
+		_, err := usecase.UpdateOrderStatus(ctx, merchantID, order.ID, NEW)
+		assert.ErrorIs(t, err, ErrInvalidTransition)
+	})
+
+	t.Run("should hide orders of other merchants", func(t *testing.T) {
+		_, err := usecase.UpdateOrderStatus(ctx, uuid.New(), order.ID, CANCELLED)
+		assert.ErrorIs(t, err, ErrOrderNotFound)
+	})
+}
+
+func TestOrderUsecase_UpdateOrderStatus_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	merchants := merchant.NewInMemoryMerchantRepository()
+	merchantID := seedMerchant(t, merchants)
+
+	menuRepo := menu.NewInMemoryMenuRepository()
+	burger := &menu.MenuItem{ID: uuid.New(), MerchantID: merchantID, Name: "Burger", Price: money.New(1250, money.USD), InStock: true}
+	require.NoError(t, menuRepo.Save(ctx, burger))
+	_, err := menuRepo.PublishDraft(ctx, merchantID)
+	require.NoError(t, err)
+
+	var settled []uuid.UUID
+	failSettlement := false
+	settlement := settlementFunc(func(ctx context.Context, order *Order) error {
+		if failSettlement {
+			return errors.New("ledger unavailable")
+		}
+		settled = append(settled, order.ID)
+		return nil
+	})
+	usecase := NewOrderUsecase(NewInMemoryOrderRepository(), menuRepo, merchants, merchant.NewInMemoryPauseStore(), settlement, anyCustomer, eventbus.NewInMemoryEventBus(), discardLogger)
+
+	pendingOrder := func(t *testing.T) *Order {
+		order, err := usecase.PlaceOrder(ctx, uuid.New(), merchantID, []OrderItem{{MenuItemID: burger.ID, Quantity: 1}})
+		require.NoError(t, err)
+		_, err = usecase.UpdateOrderStatus(ctx, merchantID, order.ID, PENDING)
+		require.NoError(t, err)
+		return order
+	}
+
+	t.Run("should let only one of two racing transitions win", func(t *testing.T) {
+		for range 20 {
+			settled = nil
+			order := pendingOrder(t)
+
+			var wg sync.WaitGroup
+			errs := make(map[OrderStatus]error)
+			var mu sync.Mutex
+			for _, status := range []OrderStatus{COMPLETED, CANCELLED} {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := usecase.UpdateOrderStatus(ctx, merchantID, order.ID, status)
+					mu.Lock()
+					errs[status] = err
+					mu.Unlock()
+				}()
+			}
+			wg.Wait()
+
+			orders, err := usecase.ListMerchantOrders(ctx, merchantID)
+			require.NoError(t, err)
+			var final OrderStatus
+			for _, o := range orders {
+				if o.ID == order.ID {
+					final = o.Status
+				}
+			}
+
+			loser := CANCELLED
+			if final == CANCELLED {
+				loser = COMPLETED
+			}
+			require.NoError(t, errs[final])
+			assert.ErrorIs(t, errs[loser], ErrInvalidTransition)
+			if final == COMPLETED {
+				assert.Equal(t, []uuid.UUID{order.ID}, settled)
+			} else {
+				assert.Empty(t, settled, "a cancelled order must not be settled")
+			}
+		}
+	})
+
+	t.Run("should leave the order open when it cannot be settled", func(t *testing.T) {
+		order := pendingOrder(t)
+		failSettlement = true
+		_, err := usecase.UpdateOrderStatus(ctx, merchantID, order.ID, COMPLETED)
+		failSettlement = false
+		require.Error(t, err)
+
+		updated, err := usecase.UpdateOrderStatus(ctx, merchantID, order.ID, CANCELLED)
+		require.NoError(t, err)
+		assert.Equal(t, CANCELLED, updated.Status)
+	})
+}
+
+// unreachableEventBus fails every publish, like a Redis outage.
+type unreachableEventBus struct {
+	eventbus.EventBus
+}
+
+func (unreachableEventBus) Publish(ctx context.Context, event eventbus.Event) error {
+	return errors.New("connection refused")
+}
+
+func TestOrderUsecase_PublishFailure(t *testing.T) {
+	ctx := context.Background()
+	merchants := merchant.NewInMemoryMerchantRepository()
+	merchantID := seedMerchant(t, merchants)
+
+	menuRepo := menu.NewInMemoryMenuRepository()
+	burger := &menu.MenuItem{ID: uuid.New(), MerchantID: merchantID, Name: "Burger", Price: money.New(1250, money.USD), InStock: true}
+	require.NoError(t, menuRepo.Save(ctx, burger))
+	_, err := menuRepo.PublishDraft(ctx, merchantID)
+	require.NoError(t, err)
+
+	repo := NewInMemoryOrderRepository()
+	usecase := NewOrderUsecase(repo, menuRepo, merchants, merchant.NewInMemoryPauseStore(), noSettlement, anyCustomer, unreachableEventBus{}, discardLogger)
+
+	t.Run("should return stored orders instead of failing the request", func(t *testing.T) {
+		order, err := usecase.PlaceOrder(ctx, uuid.New(), merchantID, []OrderItem{{MenuItemID: burger.ID, Quantity: 1}})
+		require.NoError(t, err)
+
+		updated, err := usecase.UpdateOrderStatus(ctx, merchantID, order.ID, PENDING)
+		require.NoError(t, err)
+		assert.Equal(t, PENDING, updated.Status)
+
+		orders, err := usecase.ListMerchantOrders(ctx, merchantID)
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		assert.Equal(t, PENDING, orders[0].Status)
+	})
+}
+
+func TestPlaceOrderRequest_Validate(t *testing.T) {
+	req := PlaceOrderRequest{
+		MerchantID: uuid.New(),
+		Items:      []OrderItem{{MenuItemID: uuid.New(), Quantity: 1}, {Quantity: 0}},
+	}
+
+	var fieldErrors validation.Errors
+	require.ErrorAs(t, req.Validate(), &fieldErrors)
+	assert.Equal(t, validation.Errors{
+		{Field: "items[1].menu_item_id", Message: "is required"},
+		{Field: "items[1].quantity", Message: "must be greater than 0"},
+	}, fieldErrors)
+}
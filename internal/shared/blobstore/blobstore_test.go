@@ -0,0 +1,132 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalBlobStore(t *testing.T) {
+	root := t.TempDir()
+	store := NewLocalBlobStore(root, "/media/")
+	ctx := context.Background()
+
+	t.Run("should write the object and return its URL", func(t *testing.T) {
+		url, err := store.Put(ctx, "menu/item/photo.jpg", []byte("jpeg-bytes"), "image/jpeg")
+		require.NoError(t, err)
+		assert.Equal(t, "/media/menu/item/photo.jpg", url)
+
+		data, err := os.ReadFile(filepath.Join(root, "menu", "item", "photo.jpg"))
+		require.NoError(t, err)
+		assert.Equal(t, "jpeg-bytes", string(data))
+	})
+
+	t.Run("should delete the object", func(t *testing.T) {
+		require.NoError(t, store.Delete(ctx, "menu/item/photo.jpg"))
+		_, err := os.Stat(filepath.Join(root, "menu", "item", "photo.jpg"))
+		assert.True(t, os.IsNotExist(err))
+
+		// Deleting twice is fine
+		assert.NoError(t, store.Delete(ctx, "menu/item/photo.jpg"))
+	})
+
+	t.Run("should reject keys escaping the root", func(t *testing.T) {
+		_, err := store.Put(ctx, "../outside.jpg", []byte("x"), "image/jpeg")
+		assert.ErrorIs(t, err, ErrInvalidKey)
+
+		_, err = store.Put(ctx, "", []byte("x"), "image/jpeg")
+		assert.ErrorIs(t, err, ErrInvalidKey)
+	})
+}
+
+// fakeS3 is a tiny stand-in for an S3-compatible server.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	headers map[string]http.Header
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=test-key/") {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(body)
+		if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+			http.Error(w, "XAmzContentSHA256Mismatch", http.StatusBadRequest)
+			return
+		}
+		f.objects[r.URL.Path] = body
+		f.headers[r.URL.Path] = r.Header.Clone()
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3BlobStore(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}, headers: map[string]http.Header{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store := NewS3BlobStore(S3Config{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          "minimart",
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+		PublicURL:       "https://cdn.example.com",
+	}, server.Client())
+	ctx := context.Background()
+
+	t.Run("should upload a signed object", func(t *testing.T) {
+		url, err := store.Put(ctx, "menu/a b/photo.png", []byte("png-bytes"), "image/png")
+		require.NoError(t, err)
+		assert.Equal(t, "https://cdn.example.com/menu/a%20b/photo.png", url)
+
+		assert.Equal(t, []byte("png-bytes"), fake.objects["/minimart/menu/a b/photo.png"])
+		headers := fake.headers["/minimart/menu/a b/photo.png"]
+		assert.Equal(t, "image/png", headers.Get("Content-Type"))
+		assert.Contains(t, headers.Get("Authorization"), "/us-east-1/s3/aws4_request")
+		assert.Contains(t, headers.Get("Authorization"), "SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date")
+	})
+
+	t.Run("should delete an object", func(t *testing.T) {
+		require.NoError(t, store.Delete(ctx, "menu/a b/photo.png"))
+		assert.NotContains(t, fake.objects, "/minimart/menu/a b/photo.png")
+	})
+
+	t.Run("should surface server errors", func(t *testing.T) {
+		badStore := NewS3BlobStore(S3Config{
+			Endpoint:        server.URL,
+			Region:          "us-east-1",
+			Bucket:          "minimart",
+			AccessKeyID:     "wrong-key",
+			SecretAccessKey: "test-secret",
+		}, server.Client())
+
+		_, err := badStore.Put(ctx, "photo.png", []byte("x"), "image/png")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "403")
+	})
+}
@@ -0,0 +1,18 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrInvalidKey is returned when an object key is empty or tries to escape the store.
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// BlobStore defines the interface for storing binary objects such as images.
+type BlobStore interface {
+	// Put stores data under the given key and returns the public URL of the object.
+	Put(ctx context.Context, key string, data []byte, contentType string) (string, error)
+
+	// Delete removes the object stored under the given key.
+	Delete(ctx context.Context, key string) error
+}
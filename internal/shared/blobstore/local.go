@@ -0,0 +1,63 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalBlobStore is a BlobStore that keeps objects on the local filesystem.
+// The files are expected to be served under baseURL (e.g. with app.Static).
+type LocalBlobStore struct {
+	root    string
+	baseURL string
+}
+
+// NewLocalBlobStore creates a new LocalBlobStore rooted at the given directory.
+func NewLocalBlobStore(root, baseURL string) BlobStore {
+	return &LocalBlobStore{
+		root:    root,
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// Put writes the object to disk, creating any missing directories.
+func (s *LocalBlobStore) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	filePath, err := s.pathFor(key)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filePath, data, 0o644); err != nil {
+		return "", err
+	}
+	return s.baseURL + "/" + key, nil
+}
+
+// Delete removes the object from disk. Deleting a missing object is not an error.
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	filePath, err := s.pathFor(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// pathFor maps a key to a file path, refusing keys that would escape the root.
+func (s *LocalBlobStore) pathFor(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if key == "" || cleaned == "/" || cleaned != "/"+key {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.root, filepath.FromSlash(cleaned)), nil
+}
@@ -0,0 +1,184 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config holds the settings for an S3-compatible object store
+// (AWS S3, MinIO, Cloudflare R2, ...).
+type S3Config struct {
+	Endpoint        string // e.g. https://s3.eu-central-1.amazonaws.com or http://localhost:9000
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PublicURL is the base URL objects are served from. Defaults to Endpoint/Bucket.
+	PublicURL string
+}
+
+// S3BlobStore is a BlobStore backed by an S3-compatible API.
+// It uses path-style addressing and AWS Signature Version 4, so it works
+// against MinIO and similar local stand-ins without extra configuration.
+type S3BlobStore struct {
+	config S3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3BlobStore creates a new S3BlobStore.
+func NewS3BlobStore(config S3Config, client *http.Client) BlobStore {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	config.Endpoint = strings.TrimRight(config.Endpoint, "/")
+	if config.PublicURL == "" {
+		config.PublicURL = config.Endpoint + "/" + config.Bucket
+	}
+	config.PublicURL = strings.TrimRight(config.PublicURL, "/")
+
+	return &S3BlobStore{
+		config: config,
+		client: client,
+		now:    time.Now,
+	}
+}
+
+// Put uploads the object with a PUT request.
+func (s *S3BlobStore) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	if key == "" {
+		return "", ErrInvalidKey
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	s.sign(req, data)
+
+	if err := s.do(req); err != nil {
+		return "", err
+	}
+	return s.config.PublicURL + "/" + escapePath(key), nil
+}
+
+// Delete removes the object with a DELETE request.
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	if key == "" {
+		return ErrInvalidKey
+	}
+
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	s.sign(req, nil)
+
+	return s.do(req)
+}
+
+func (s *S3BlobStore) newRequest(ctx context.Context, method, key string, data []byte) (*http.Request, error) {
+	rawURL := fmt.Sprintf("%s/%s/%s", s.config.Endpoint, s.config.Bucket, escapePath(key))
+	return http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(data))
+}
+
+func (s *S3BlobStore) do(req *http.Request) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("s3 %s request failed: %w", req.Method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("s3 %s returned %d: %s", req.Method, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to the request.
+func (s *S3BlobStore) sign(req *http.Request, payload []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	shortDate := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Canonical headers must be lower-case and sorted by name.
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", shortDate, s.config.Region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.config.SecretAccessKey), shortDate)
+	signingKey = hmacSHA256(signingKey, s.config.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+// escapePath URI-encodes every segment of an object key, keeping the slashes.
+func escapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 
 	"github.com/go-redis/redis/v8"
 )
@@ -39,3 +40,28 @@ func (b *RedisEventBus) Subscribe(topic string, handler Handler) error {
 	// We will handle subscriptions differently for Redis.
 	return fmt.Errorf("Subscribe is not implemented for RedisEventBus; subscriptions should be handled by a dedicated worker process")
 }
+
+// SubscribeRedis starts a background worker that listens on a Redis topic and
+// hands every message, decoded as T, to the handler. The payload only carries
+// JSON, so the caller has to name the concrete event type.
+func SubscribeRedis[T Event](ctx context.Context, client *redis.Client, topic string, handler Handler, logger *slog.Logger) {
+	go func() {
+		pubsub := client.Subscribe(ctx, topic)
+		defer pubsub.Close()
+
+		ch := pubsub.Channel()
+		logger.Info("Subscribed to Redis topic", "topic", topic)
+
+		for msg := range ch {
+			var event T
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				logger.Info("Error unmarshaling event", "error", err, "payload", msg.Payload)
+				continue
+			}
+
+			if err := handler(ctx, event); err != nil {
+				logger.Error("Error handling event", "topic", topic, "error", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,184 @@
+// Package jwtkeys signs and verifies the access tokens of the platform with
+// asymmetric keys, so that other services can verify them from the public
+// keys published as a JWKS.
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Algorithm is the JWS algorithm a key signs with.
+type Algorithm string
+
+const (
+	RS256 Algorithm = "RS256"
+	EdDSA Algorithm = "EdDSA"
+)
+
+// MinRSABits is the smallest RSA key accepted.
+const MinRSABits = 2048
+
+var (
+	ErrUnsupportedKey = errors.New("key must be an RSA or Ed25519 key")
+	ErrInvalidPEM     = errors.New("no PEM encoded key found")
+)
+
+// Key is a key tokens are verified with and, when it has its private half,
+// signed with. Its ID is the RFC 7638 thumbprint of its public key, so every
+// instance loading the same key names it alike.
+type Key struct {
+	ID        string
+	Algorithm Algorithm
+	private   crypto.Signer
+	public    crypto.PublicKey
+}
+
+// NewKey wraps a private key (*rsa.PrivateKey or ed25519.PrivateKey), or a
+// public key (*rsa.PublicKey or ed25519.PublicKey) to verify with only.
+func NewKey(key any) (*Key, error) {
+	k := &Key{}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		k.private, k.public = key, &key.PublicKey
+	case ed25519.PrivateKey:
+		k.private, k.public = key, key.Public()
+	case *rsa.PublicKey, ed25519.PublicKey:
+		k.public = key
+	default:
+		return nil, ErrUnsupportedKey
+	}
+
+	switch public := k.public.(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < MinRSABits {
+			return nil, fmt.Errorf("RSA keys must have at least %d bits", MinRSABits)
+		}
+		k.Algorithm = RS256
+	case ed25519.PublicKey:
+		k.Algorithm = EdDSA
+	}
+	id, err := thumbprint(k.JWK())
+	if err != nil {
+		return nil, err
+	}
+	k.ID = id
+	return k, nil
+}
+
+// ParseKeyPEM parses a PEM encoded PKCS #8 or PKCS #1 private key, or a PKIX
+// public key.
+func ParseKeyPEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidPEM
+	}
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewKey(key)
+}
+
+// GenerateKey creates a new key for algorithm.
+func GenerateKey(algorithm Algorithm) (*Key, error) {
+	switch algorithm {
+	case RS256:
+		key, err := rsa.GenerateKey(rand.Reader, MinRSABits)
+		if err != nil {
+			return nil, err
+		}
+		return NewKey(key)
+	case EdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewKey(key)
+	}
+	return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+}
+
+// CanSign reports whether the key has its private half.
+func (k *Key) CanSign() bool {
+	return k.private != nil
+}
+
+// JWK is a public key as published in a JWKS (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JWK returns the public half of the key.
+func (k *Key) JWK() JWK {
+	jwk := JWK{KeyID: k.ID, Use: "sig", Algorithm: string(k.Algorithm)}
+	switch public := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
+
+// thumbprint returns the RFC 7638 thumbprint of jwk: the hash of its required
+// members, in lexical order and without whitespace.
+func thumbprint(jwk JWK) (string, error) {
+	var members any
+	switch jwk.KeyType {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	default:
+		return "", ErrUnsupportedKey
+	}
+	canonical, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,102 @@
+package jwtkeys
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrNoSigningKey is returned when the signing key has no private half.
+	ErrNoSigningKey = errors.New("signing key must be a private key")
+	// ErrUnknownKey is returned for tokens whose kid is not a verification key.
+	ErrUnknownKey = errors.New("token was signed by an unknown key")
+)
+
+// JWKS is a set of public keys as served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeyManager signs access tokens with one key and verifies them with any of
+// several, so that keys can be rotated: publish the next key for
+// verification first, then sign with it, and drop the old key once the
+// tokens it signed have expired.
+type KeyManager interface {
+	// Sign signs claims with the signing key, naming it in the kid header.
+	Sign(claims jwt.Claims) (string, error)
+	// Parse verifies a token with the key its kid names and decodes its
+	// claims into claims.
+	Parse(token string, claims jwt.Claims) error
+	// JWKS returns the public halves of the verification keys.
+	JWKS() JWKS
+}
+
+type keyManager struct {
+	signing *Key
+	keys    map[string]*Key
+	// order keeps the JWKS stable, signing key first.
+	order   []*Key
+	methods []string
+}
+
+// NewKeyManager creates a KeyManager that signs with signing and verifies
+// with it and the other keys.
+func NewKeyManager(signing *Key, verification ...*Key) (KeyManager, error) {
+	if signing == nil || !signing.CanSign() {
+		return nil, ErrNoSigningKey
+	}
+	m := &keyManager{signing: signing, keys: make(map[string]*Key)}
+	for _, key := range append([]*Key{signing}, verification...) {
+		if _, duplicate := m.keys[key.ID]; duplicate {
+			continue
+		}
+		m.keys[key.ID] = key
+		m.order = append(m.order, key)
+		if !slices.Contains(m.methods, string(key.Algorithm)) {
+			m.methods = append(m.methods, string(key.Algorithm))
+		}
+	}
+	return m, nil
+}
+
+func (m *keyManager) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(string(m.signing.Algorithm)), claims)
+	token.Header["kid"] = m.signing.ID
+	return token.SignedString(m.signing.private)
+}
+
+func (m *keyManager) Parse(token string, claims jwt.Claims) error {
+	parsed, err := jwt.ParseWithClaims(token, claims, m.verificationKey, jwt.WithValidMethods(m.methods))
+	if err != nil {
+		return err
+	}
+	if !parsed.Valid {
+		return jwt.ErrTokenSignatureInvalid
+	}
+	return nil
+}
+
+// verificationKey finds the key a token names, which must sign with the
+// token's algorithm: an attacker picks the header, we pick the algorithm.
+func (m *keyManager) verificationKey(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, found := m.keys[kid]
+	if !found {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != string(key.Algorithm) {
+		return nil, fmt.Errorf("key %s does not sign with %s", kid, token.Method.Alg())
+	}
+	return key.public, nil
+}
+
+func (m *keyManager) JWKS() JWKS {
+	jwks := JWKS{Keys: make([]JWK, 0, len(m.order))}
+	for _, key := range m.order {
+		jwks.Keys = append(jwks.Keys, key.JWK())
+	}
+	return jwks
+}
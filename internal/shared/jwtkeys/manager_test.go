@@ -0,0 +1,137 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "user-123",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+func TestKeyManager(t *testing.T) {
+	for _, algorithm := range []Algorithm{RS256, EdDSA} {
+		t.Run("should sign and verify with "+string(algorithm), func(t *testing.T) {
+			key, err := GenerateKey(algorithm)
+			require.NoError(t, err)
+			keys, err := NewKeyManager(key)
+			require.NoError(t, err)
+
+			claims := testClaims()
+			token, err := keys.Sign(claims)
+			require.NoError(t, err)
+
+			var parsed jwt.RegisteredClaims
+			require.NoError(t, keys.Parse(token, &parsed))
+			assert.Equal(t, "user-123", parsed.Subject)
+
+			header, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, key.ID, header.Header["kid"])
+			assert.Equal(t, string(algorithm), header.Header["alg"])
+		})
+	}
+
+	t.Run("should verify tokens of older keys during a rotation", func(t *testing.T) {
+		oldKey, err := GenerateKey(EdDSA)
+		require.NoError(t, err)
+		newKey, err := GenerateKey(EdDSA)
+		require.NoError(t, err)
+		before, err := NewKeyManager(oldKey)
+		require.NoError(t, err)
+		token, err := before.Sign(testClaims())
+		require.NoError(t, err)
+
+		during, err := NewKeyManager(newKey, oldKey)
+		require.NoError(t, err)
+		assert.NoError(t, during.Parse(token, &jwt.RegisteredClaims{}))
+		assert.Len(t, during.JWKS().Keys, 2)
+
+		after, err := NewKeyManager(newKey)
+		require.NoError(t, err)
+		assert.ErrorIs(t, after.Parse(token, &jwt.RegisteredClaims{}), ErrUnknownKey)
+	})
+
+	t.Run("should verify with keys of another algorithm", func(t *testing.T) {
+		rsaKey, err := GenerateKey(RS256)
+		require.NoError(t, err)
+		edKey, err := GenerateKey(EdDSA)
+		require.NoError(t, err)
+		before, err := NewKeyManager(rsaKey)
+		require.NoError(t, err)
+		token, err := before.Sign(testClaims())
+		require.NoError(t, err)
+
+		during, err := NewKeyManager(edKey, rsaKey)
+		require.NoError(t, err)
+		assert.NoError(t, during.Parse(token, &jwt.RegisteredClaims{}))
+	})
+
+	t.Run("should not let the token pick another algorithm", func(t *testing.T) {
+		key, err := GenerateKey(EdDSA)
+		require.NoError(t, err)
+		keys, err := NewKeyManager(key)
+		require.NoError(t, err)
+
+		// A token "signed" with the public key as an HMAC secret
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+		forged.Header["kid"] = key.ID
+		token, err := forged.SignedString([]byte(key.JWK().X))
+		require.NoError(t, err)
+		assert.Error(t, keys.Parse(token, &jwt.RegisteredClaims{}))
+	})
+
+	t.Run("should only sign with private keys", func(t *testing.T) {
+		key, err := GenerateKey(EdDSA)
+		require.NoError(t, err)
+		public, err := NewKey(key.public)
+		require.NoError(t, err)
+		assert.False(t, public.CanSign())
+		assert.Equal(t, key.ID, public.ID)
+
+		_, err = NewKeyManager(public)
+		assert.ErrorIs(t, err, ErrNoSigningKey)
+	})
+}
+
+func TestParseKeyPEM(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+
+	key, err := ParseKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+	assert.True(t, key.CanSign())
+	assert.Equal(t, EdDSA, key.Algorithm)
+
+	der, err = x509.MarshalPKIXPublicKey(private.Public())
+	require.NoError(t, err)
+	public, err := ParseKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, err)
+	assert.False(t, public.CanSign())
+	assert.Equal(t, key.ID, public.ID)
+
+	_, err = ParseKeyPEM([]byte("not a key"))
+	assert.ErrorIs(t, err, ErrInvalidPEM)
+}
+
+func TestKey_ID(t *testing.T) {
+	// The Ed25519 example of RFC 8037, appendix A.3
+	x, err := base64.RawURLEncoding.DecodeString("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")
+	require.NoError(t, err)
+	key, err := NewKey(ed25519.PublicKey(x))
+	require.NoError(t, err)
+	assert.Equal(t, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", key.ID)
+}
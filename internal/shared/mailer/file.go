@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileMailer is a Mailer for development that writes each message to a .eml
+// file in a directory instead of sending it.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer creates a new FileMailer writing to the given directory.
+func NewFileMailer(dir, from string) Mailer {
+	return &FileMailer{dir: dir, from: from}
+}
+
+// Send writes the message to a file named after the time it was sent.
+func (m *FileMailer) Send(ctx context.Context, message Message) error {
+	if err := message.validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405"), uuid.NewString()[:8])
+	return os.WriteFile(filepath.Join(m.dir, name), compose(m.from, message, now), 0o644)
+}
@@ -0,0 +1,32 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidMessage is returned for messages without a recipient or subject,
+// or with line breaks in a header.
+var ErrInvalidMessage = errors.New("invalid mail message")
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer defines the interface for sending email.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// validate rejects messages that cannot be sent, including headers that
+// would inject further headers.
+func (m Message) validate() error {
+	if m.To == "" || m.Subject == "" || strings.ContainsAny(m.To+m.Subject, "\r\n") {
+		return ErrInvalidMessage
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	mailer := NewFileMailer(dir, "MiniMart <no-reply@minimart.test>")
+	ctx := context.Background()
+
+	t.Run("should write the message to a file", func(t *testing.T) {
+		require.NoError(t, mailer.Send(ctx, Message{To: "jane@example.com", Subject: "Hello", Body: "Line one\nLine two"}))
+
+		files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		data, err := os.ReadFile(files[0])
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "To: jane@example.com\r\n")
+		assert.Contains(t, string(data), "Subject: Hello\r\n")
+		assert.Contains(t, string(data), "\r\n\r\nLine one\r\nLine two\r\n")
+	})
+
+	t.Run("should refuse header injection", func(t *testing.T) {
+		err := mailer.Send(ctx, Message{To: "jane@example.com", Subject: "Hi\r\nBcc: eve@example.com"})
+		assert.ErrorIs(t, err, ErrInvalidMessage)
+	})
+}
+
+// smtpStandIn is a minimal SMTP server that records what it receives.
+type smtpStandIn struct {
+	listener net.Listener
+	mu       sync.Mutex
+	commands []string
+	data     string
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &smtpStandIn{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go server.serve()
+	return server
+}
+
+func (s *smtpStandIn) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpStandIn) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpStandIn) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 stand-in ready")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimRight(line, "\r\n")
+		s.mu.Lock()
+		s.commands = append(s.commands, command)
+		s.mu.Unlock()
+
+		switch verb := strings.ToUpper(strings.SplitN(command, " ", 2)[0]); verb {
+		case "EHLO":
+			reply("250-stand-in")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			reply("235 authenticated")
+		case "MAIL", "RCPT":
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.mu.Lock()
+			s.data = data.String()
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	server := newSMTPStandIn(t)
+	mailer := NewSMTPMailer(SMTPConfig{
+		Host:     "127.0.0.1",
+		Port:     server.port(),
+		Username: "minimart",
+		Password: "secret",
+		From:     "no-reply@minimart.test",
+	})
+
+	require.NoError(t, mailer.Send(context.Background(), Message{To: "jane@example.com", Subject: "Reset your password", Body: "Follow the link"}))
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Contains(t, server.commands, "MAIL FROM:<no-reply@minimart.test>")
+	assert.Contains(t, server.commands, "RCPT TO:<jane@example.com>")
+	assert.Contains(t, strings.Join(server.commands, "\n"), "AUTH PLAIN")
+	assert.Contains(t, server.data, "Subject: Reset your password\r\n")
+	assert.Contains(t, server.data, "Follow the link\r\n")
+}
@@ -1,15 +1,33 @@
 package middlerware
 
 import (
+	"errors"
+	"minimart/internal/shared/jwtkeys"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/spf13/viper"
+	"github.com/google/uuid"
 )
 
+// ErrUnauthenticated is returned when a request carries no authenticated user.
+var ErrUnauthenticated = errors.New("request is not authenticated")
+
+// Claims are the claims of an access token. AuthRequire stores them in the
+// request locals; read them with ClaimsFrom.
+type Claims struct {
+	jwt.RegisteredClaims
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	Role  Role   `json:"role,omitempty"`
+	// SessionID is the login session the token was issued for.
+	SessionID string `json:"sid,omitempty"`
+}
+
 // AuthRequired is a middleware to protect routes that require a valid JWT.
-func AuthRequire() fiber.Handler {
+// Tokens must be signed by one of the keys of keys and carry a jti, so that
+// they can be revoked; those whose jti is on denylist are rejected.
+func AuthRequire(keys jwtkeys.KeyManager, denylist TokenDenylist) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// 1. Get the Authorization header
 		authHeader := c.Get("Authorization")
@@ -29,33 +47,79 @@ func AuthRequire() fiber.Handler {
 		tokenString := parts[1]
 
 		// 3. Parse and validate the token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Check the signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fiber.NewError(fiber.StatusUnauthorized, "Unexpected signing method")
-			}
-
-			// Return the secret key from environment variable
-			return []byte(viper.GetString("JWT_SECRET")), nil
-		})
-		if err != nil || !token.Valid {
+		claims := &Claims{}
+		if err := keys.Parse(tokenString, claims); err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "Invalid or expired JWT",
 			})
 		}
 
-		// 4. Extract claims and store them in context
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
+		// 4. Reject tokens revoked by logout or refresh token reuse, and those
+		// that could not be revoked
+		if claims.ID == "" {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid JWT claims",
+				"error": "Invalid or expired JWT",
+			})
+		}
+		denied, err := denylist.IsDenied(c.Context(), claims.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Could not check JWT revocation",
+			})
+		}
+		if denied {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "JWT has been revoked",
 			})
 		}
 
-		// You can store the entire claims map or specific values
 		c.Locals("user", claims)
 
 		// 5. Continue to the next handler
 		return c.Next()
 	}
 }
+
+// ClaimsFrom returns the claims stored by AuthRequire.
+func ClaimsFrom(c *fiber.Ctx) (*Claims, error) {
+	claims, ok := c.Locals("user").(*Claims)
+	if !ok || claims == nil {
+		return nil, ErrUnauthenticated
+	}
+	return claims, nil
+}
+
+// Can reports whether the authenticated user's role grants permission. API
+// keys have no role, so it is false for them.
+func Can(c *fiber.Ctx, permission Permission) bool {
+	principal, err := PrincipalFrom(c)
+	return err == nil && principal.Role.Can(permission)
+}
+
+// UserID returns the ID of the authenticated user from the subject claim
+// stored by AuthRequire.
+func UserID(c *fiber.Ctx) (uuid.UUID, error) {
+	claims, err := ClaimsFrom(c)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, ErrUnauthenticated
+	}
+	return userID, nil
+}
+
+// SessionID returns the login session (sid claim) of the access token stored
+// by AuthRequire. Every token refreshed from one login shares the session.
+func SessionID(c *fiber.Ctx) (uuid.UUID, error) {
+	claims, err := ClaimsFrom(c)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return uuid.Nil, ErrUnauthenticated
+	}
+	return sessionID, nil
+}
@@ -1,7 +1,10 @@
 package middlerware
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"minimart/internal/shared/jwtkeys"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -9,42 +12,65 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/spf13/viper"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// newTestKeys creates a key manager with a new signing key.
+func newTestKeys(t *testing.T) jwtkeys.KeyManager {
+	key, err := jwtkeys.GenerateKey(jwtkeys.EdDSA)
+	require.NoError(t, err)
+	keys, err := jwtkeys.NewKeyManager(key)
+	require.NoError(t, err)
+	return keys
+}
+
 // Helper function to generate a valid JWT for testing
-func generateTestToken(userID, email, secret string) (string, error) {
-	claims := jwt.MapClaims{
-		"sub":   userID,
-		"email": email,
-		"exp":   time.Now().Add(time.Hour * 1).Unix(),
+func generateTestToken(userID, email string, keys jwtkeys.KeyManager) (string, error) {
+	return generateTestTokenWithID(userID, email, keys, uuid.NewString())
+}
+
+func generateTestTokenWithID(userID, email string, keys jwtkeys.KeyManager, jti string) (string, error) {
+	return generateTestTokenWithRole(userID, email, keys, jti, RoleCustomer)
+}
+
+func generateTestTokenWithRole(userID, email string, keys jwtkeys.KeyManager, jti string, role Role) (string, error) {
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 1)),
+		},
+		Email: email,
+		Role:  role,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return keys.Sign(claims)
 }
 
 func TestAuthRequired(t *testing.T) {
-	// Set a dummy JWT secret for testing
-	viper.Set("jwt.secret", "test-secret")
-	jwtSecret := viper.GetString("jwt.secret")
+	keys := newTestKeys(t)
+	denylist := NewInMemoryTokenDenylist()
 
 	// Create a new Fiber app for testing
 	app := fiber.New()
 
 	// Create a test route protected by the middlerware
-	app.Get("/test", AuthRequire(), func(c *fiber.Ctx) error {
+	app.Get("/test", AuthRequire(keys, denylist), func(c *fiber.Ctx) error {
 		// This handler should onlly be reached if the middleware succeeds
-		userClaims := c.Locals("user").(jwt.MapClaims)
+		userClaims, err := ClaimsFrom(c)
+		if err != nil {
+			return err
+		}
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"user_id": userClaims["sub"],
+			"user_id": userClaims.Subject,
+			"role":    userClaims.Role,
 		})
 	})
 
 	t.Run("should return 200 OK with valid token", func(t *testing.T) {
 		// Arrange
-		token, err := generateTestToken("user-123", "test@example.com", jwtSecret)
+		token, err := generateTestToken("user-123", "test@example.com", keys)
 		require.NoError(t, err)
 
 		req := httptest.NewRequest(http.MethodGet, "/test", nil)
@@ -56,6 +82,10 @@ func TestAuthRequired(t *testing.T) {
 
 		// Assert
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "user-123", body["user_id"])
+		assert.Equal(t, string(RoleCustomer), body["role"])
 	})
 
 	t.Run("should return 401 Unauthorized without token", func(t *testing.T) {
@@ -85,8 +115,7 @@ func TestAuthRequired(t *testing.T) {
 
 	t.Run("should return 401 Unauthorized with token signed by wrong key", func(t *testing.T) {
 		// Arrange
-		wrongSecret := "another-secret"
-		token, err := generateTestToken("user-123", "test@example.com", wrongSecret)
+		token, err := generateTestToken("user-123", "test@example.com", newTestKeys(t))
 		require.NoError(t, err)
 
 		req := httptest.NewRequest(http.MethodGet, "/test", nil)
@@ -99,4 +128,109 @@ func TestAuthRequired(t *testing.T) {
 		// Assert
 		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
 	})
+
+	t.Run("should return 401 Unauthorized with a token that cannot be revoked", func(t *testing.T) {
+		// Arrange
+		token, err := generateTestTokenWithID("user-123", "test@example.com", keys, "")
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		// Act
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("should return 401 Unauthorized with a revoked token", func(t *testing.T) {
+		// Arrange
+		token, err := generateTestTokenWithID("user-123", "test@example.com", keys, "revoked-jti")
+		require.NoError(t, err)
+		require.NoError(t, denylist.Deny(context.Background(), "revoked-jti", time.Now().Add(time.Hour)))
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		// Act
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+// stubAPIKeys knows a single API key.
+type stubAPIKeys struct {
+	key       string
+	principal *Principal
+}
+
+func (s stubAPIKeys) AuthenticateAPIKey(ctx context.Context, key string) (*Principal, error) {
+	if key != s.key {
+		return nil, ErrInvalidAPIKey
+	}
+	return s.principal, nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	keys := newTestKeys(t)
+	merchantID := uuid.New()
+	apiKeys := stubAPIKeys{
+		key:       "mk_test_secret",
+		principal: &Principal{Kind: PrincipalAPIKey, ID: uuid.NewString(), MerchantID: merchantID, Scopes: []string{"orders:read"}},
+	}
+
+	app := fiber.New()
+	app.Get("/test", Authenticate(keys, NewInMemoryTokenDenylist(), apiKeys), func(c *fiber.Ctx) error {
+		principal, err := PrincipalFrom(c)
+		if err != nil {
+			return err
+		}
+		return c.JSON(fiber.Map{"kind": principal.Kind, "id": principal.ID})
+	})
+	app.Get("/admin", Authenticate(keys, NewInMemoryTokenDenylist(), apiKeys), RequirePermission(PermPlaceOrders), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	send := func(path string, header, value string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(header, value)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+	principalOf := func(resp *http.Response) map[string]string {
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return body
+	}
+
+	t.Run("should authenticate users by JWT", func(t *testing.T) {
+		token, err := generateTestToken("user-123", "test@example.com", keys)
+		require.NoError(t, err)
+
+		body := principalOf(send("/test", "Authorization", "Bearer "+token))
+		assert.Equal(t, string(PrincipalUser), body["kind"])
+		assert.Equal(t, "user-123", body["id"])
+	})
+
+	t.Run("should authenticate API keys", func(t *testing.T) {
+		body := principalOf(send("/test", APIKeyHeader, "mk_test_secret"))
+		assert.Equal(t, string(PrincipalAPIKey), body["kind"])
+		assert.Equal(t, apiKeys.principal.ID, body["id"])
+	})
+
+	t.Run("should reject unknown API keys", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, send("/test", APIKeyHeader, "mk_test_wrong").StatusCode)
+		assert.Equal(t, http.StatusUnauthorized, send("/test", "Authorization", "").StatusCode)
+	})
+
+	t.Run("should give API keys no platform role", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, send("/admin", APIKeyHeader, "mk_test_secret").StatusCode)
+	})
 }
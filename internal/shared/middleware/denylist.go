@@ -0,0 +1,73 @@
+package middlerware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// TokenDenylist holds the IDs (jti claims) of access tokens that were revoked
+// before they expired.
+type TokenDenylist interface {
+	// Deny rejects the token until it expires anyway. Tokens that have
+	// already expired need not be denied.
+	Deny(ctx context.Context, jti string, expiresAt time.Time) error
+	IsDenied(ctx context.Context, jti string) (bool, error)
+}
+
+type redisTokenDenylist struct {
+	client *redis.Client
+}
+
+// NewRedisTokenDenylist creates a TokenDenylist shared by every server
+// instance. Entries expire with their tokens.
+func NewRedisTokenDenylist(client *redis.Client) TokenDenylist {
+	return &redisTokenDenylist{client: client}
+}
+
+func (d *redisTokenDenylist) Deny(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return d.client.Set(ctx, denylistKey(jti), 1, ttl).Err()
+}
+
+func (d *redisTokenDenylist) IsDenied(ctx context.Context, jti string) (bool, error) {
+	count, err := d.client.Exists(ctx, denylistKey(jti)).Result()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func denylistKey(jti string) string {
+	return "auth:denied:" + jti
+}
+
+type InMemoryTokenDenylist struct {
+	mu     sync.Mutex
+	denied map[string]time.Time
+}
+
+func NewInMemoryTokenDenylist() *InMemoryTokenDenylist {
+	return &InMemoryTokenDenylist{denied: map[string]time.Time{}}
+}
+
+func (d *InMemoryTokenDenylist) Deny(ctx context.Context, jti string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if time.Now().Before(expiresAt) {
+		d.denied[jti] = expiresAt
+	}
+	return nil
+}
+
+func (d *InMemoryTokenDenylist) IsDenied(ctx context.Context, jti string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	expiresAt, found := d.denied[jti]
+	return found && time.Now().Before(expiresAt), nil
+}
@@ -0,0 +1,89 @@
+package middlerware
+
+import (
+	"context"
+	"errors"
+	"minimart/internal/shared/jwtkeys"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// APIKeyHeader is the header API keys are sent in.
+const APIKeyHeader = "X-API-Key"
+
+// ErrInvalidAPIKey is returned for API keys that do not exist or were revoked.
+var ErrInvalidAPIKey = errors.New("invalid or revoked API key")
+
+// PrincipalKind tells users and API keys apart.
+type PrincipalKind string
+
+const (
+	PrincipalUser   PrincipalKind = "user"
+	PrincipalAPIKey PrincipalKind = "api_key"
+)
+
+// Principal is whoever a request was authenticated as, a user with a JWT or
+// a merchant's integration with an API key. Read it with PrincipalFrom.
+type Principal struct {
+	Kind PrincipalKind
+	// ID is the user's ID (the subject claim), or the API key's.
+	ID string
+	// Role is the user's platform role. API keys have none, so platform
+	// permissions are never theirs.
+	Role Role
+	// MerchantID is the merchant an API key belongs to.
+	MerchantID uuid.UUID
+	// Scopes are what an API key may do at its merchant.
+	Scopes []string
+}
+
+// HasScope reports whether the principal is an API key granted scope.
+func (p *Principal) HasScope(scope string) bool {
+	return p.Kind == PrincipalAPIKey && slices.Contains(p.Scopes, scope)
+}
+
+// APIKeyAuthenticator looks up the principal of an API key, normally
+// merchant.APIKeyUsecase.
+type APIKeyAuthenticator interface {
+	// AuthenticateAPIKey returns ErrInvalidAPIKey for keys that do not exist
+	// or were revoked.
+	AuthenticateAPIKey(ctx context.Context, key string) (*Principal, error)
+}
+
+// Authenticate is AuthRequire for routes merchant integrations may call as
+// well: requests carrying an API key in the X-API-Key header are let through
+// as the key, and all others need a valid JWT.
+func Authenticate(keys jwtkeys.KeyManager, denylist TokenDenylist, apiKeys APIKeyAuthenticator) fiber.Handler {
+	requireJWT := AuthRequire(keys, denylist)
+	return func(c *fiber.Ctx) error {
+		key := c.Get(APIKeyHeader)
+		if key == "" {
+			return requireJWT(c)
+		}
+
+		principal, err := apiKeys.AuthenticateAPIKey(c.Context(), key)
+		if err != nil {
+			if errors.Is(err, ErrInvalidAPIKey) {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not check API key"})
+		}
+		c.Locals("principal", principal)
+		return c.Next()
+	}
+}
+
+// PrincipalFrom returns the principal stored by Authenticate, or the user
+// whose claims AuthRequire stored.
+func PrincipalFrom(c *fiber.Ctx) (*Principal, error) {
+	if principal, ok := c.Locals("principal").(*Principal); ok && principal != nil {
+		return principal, nil
+	}
+	claims, err := ClaimsFrom(c)
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{Kind: PrincipalUser, ID: claims.Subject, Role: claims.Role}, nil
+}
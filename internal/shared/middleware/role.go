@@ -0,0 +1,105 @@
+package middlerware
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Role is what a user is on the platform. It is stored on the user and
+// carried in the role claim of their access tokens. What a user may do at a
+// particular merchant is decided by their merchant.Role there.
+type Role string
+
+const (
+	RoleCustomer Role = "customer"
+	// RoleMerchant is given to merchant owners and staff.
+	RoleMerchant Role = "merchant"
+	RoleAdmin    Role = "admin"
+)
+
+// Permission is a platform-wide action only some roles may take.
+type Permission string
+
+const (
+	PermPlaceOrders Permission = "orders:place"
+	// PermManageMerchants covers the merchant dashboard routes; which
+	// merchants a user may manage is up to the merchant module.
+	PermManageMerchants Permission = "merchants:manage"
+	PermReviewMerchants Permission = "merchants:review"
+	PermManageLedger    Permission = "ledger:manage"
+	PermManageUsers     Permission = "users:manage"
+	PermViewMetrics     Permission = "metrics:view"
+)
+
+// rolePermissions lists what each role may do. Each role may do everything
+// the roles before it may.
+var rolePermissions = map[Role][]Permission{
+	RoleCustomer: {PermPlaceOrders},
+	RoleMerchant: {PermPlaceOrders, PermManageMerchants},
+	RoleAdmin:    {PermPlaceOrders, PermManageMerchants, PermReviewMerchants, PermManageLedger, PermManageUsers, PermViewMetrics},
+}
+
+// ErrUnknownRole is returned when parsing a role that does not exist.
+var ErrUnknownRole = fmt.Errorf("role must be %s, %s or %s", RoleCustomer, RoleMerchant, RoleAdmin)
+
+// ParseRole parses a role name such as "merchant".
+func ParseRole(name string) (Role, error) {
+	role := Role(name)
+	if _, known := rolePermissions[role]; !known {
+		return "", fmt.Errorf("%w: %q", ErrUnknownRole, name)
+	}
+	return role, nil
+}
+
+// Can reports whether the role grants permission.
+func (r Role) Can(permission Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Includes reports whether the role may do everything other may.
+func (r Role) Includes(other Role) bool {
+	for _, permission := range rolePermissions[other] {
+		if !r.Can(permission) {
+			return false
+		}
+	}
+	return true
+}
+
+// RequireRole only lets through users with one of roles. It must run after
+// AuthRequire or Authenticate.
+func RequireRole(roles ...Role) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, err := PrincipalFrom(c)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		}
+		for _, role := range roles {
+			if principal.Role == role {
+				return c.Next()
+			}
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Your role does not allow this"})
+	}
+}
+
+// RequirePermission only lets through users whose role grants permission. It
+// must run after AuthRequire or Authenticate.
+func RequirePermission(permission Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, err := PrincipalFrom(c)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		}
+		if !principal.Role.Can(permission) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Your role does not allow this"})
+		}
+		return c.Next()
+	}
+}
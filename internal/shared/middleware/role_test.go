@@ -0,0 +1,80 @@
+package middlerware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRole(t *testing.T) {
+	t.Run("should grant each role what the roles below it may do", func(t *testing.T) {
+		assert.True(t, RoleAdmin.Includes(RoleMerchant))
+		assert.True(t, RoleMerchant.Includes(RoleCustomer))
+		assert.False(t, RoleCustomer.Includes(RoleMerchant))
+		assert.False(t, RoleMerchant.Includes(RoleAdmin))
+
+		assert.True(t, RoleCustomer.Can(PermPlaceOrders))
+		assert.False(t, RoleCustomer.Can(PermManageMerchants))
+		assert.True(t, RoleMerchant.Can(PermManageMerchants))
+		assert.False(t, RoleMerchant.Can(PermManageLedger))
+		assert.True(t, RoleAdmin.Can(PermManageUsers))
+		assert.True(t, RoleAdmin.Can(PermViewMetrics))
+		assert.False(t, RoleMerchant.Can(PermViewMetrics))
+	})
+
+	t.Run("should parse known roles only", func(t *testing.T) {
+		role, err := ParseRole("merchant")
+		require.NoError(t, err)
+		assert.Equal(t, RoleMerchant, role)
+
+		_, err = ParseRole("owner")
+		assert.ErrorIs(t, err, ErrUnknownRole)
+	})
+}
+
+func TestRequireRoleAndPermission(t *testing.T) {
+	keys := newTestKeys(t)
+	auth := AuthRequire(keys, NewInMemoryTokenDenylist())
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+
+	app := fiber.New()
+	app.Get("/dashboard", auth, RequireRole(RoleMerchant, RoleAdmin), ok)
+	app.Get("/orders", auth, RequirePermission(PermPlaceOrders), ok)
+	app.Get("/ledger", auth, RequirePermission(PermManageLedger), ok)
+	app.Get("/unauthenticated", RequirePermission(PermPlaceOrders), ok)
+
+	status := func(path string, role Role) int {
+		token, err := generateTestTokenWithRole("user-123", "test@example.com", keys, uuid.NewString(), role)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	t.Run("should only let listed roles through", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, status("/dashboard", RoleMerchant))
+		assert.Equal(t, http.StatusOK, status("/dashboard", RoleAdmin))
+		assert.Equal(t, http.StatusForbidden, status("/dashboard", RoleCustomer))
+		assert.Equal(t, http.StatusForbidden, status("/dashboard", ""))
+	})
+
+	t.Run("should only let roles with the permission through", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, status("/ledger", RoleAdmin))
+		assert.Equal(t, http.StatusForbidden, status("/ledger", RoleMerchant))
+		assert.Equal(t, http.StatusOK, status("/orders", RoleCustomer))
+		assert.Equal(t, http.StatusOK, status("/orders", RoleMerchant))
+		assert.Equal(t, http.StatusForbidden, status("/orders", ""), "tokens without a role may do nothing")
+	})
+
+	t.Run("should reject requests without claims", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, status("/unauthenticated", RoleAdmin))
+	})
+}
@@ -0,0 +1,212 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var (
+	ErrCurrencyMismatch = errors.New("money: currency mismatch")
+	ErrUnknownCurrency  = errors.New("money: unknown currency")
+	ErrOverflow         = errors.New("money: amount overflow")
+	ErrDivideByZero     = errors.New("money: division by zero")
+)
+
+// Currency is an ISO 4217 currency code such as "USD" or "THB".
+type Currency string
+
+const (
+	USD Currency = "USD"
+	EUR Currency = "EUR"
+	GBP Currency = "GBP"
+	THB Currency = "THB"
+	SGD Currency = "SGD"
+	MYR Currency = "MYR"
+	IDR Currency = "IDR"
+	PHP Currency = "PHP"
+	VND Currency = "VND"
+	JPY Currency = "JPY"
+	KRW Currency = "KRW"
+	AUD Currency = "AUD"
+)
+
+// minorUnits lists the supported currencies and how many decimal places
+// their minor unit has (2 for cents, 0 for currencies without subunits).
+var minorUnits = map[Currency]int{
+	USD: 2, EUR: 2, GBP: 2, THB: 2, SGD: 2, MYR: 2,
+	IDR: 2, PHP: 2, VND: 0, JPY: 0, KRW: 0, AUD: 2,
+}
+
+// ParseCurrency normalises and validates an ISO 4217 code.
+func ParseCurrency(code string) (Currency, error) {
+	currency := Currency(strings.ToUpper(strings.TrimSpace(code)))
+	if !currency.Valid() {
+		return "", fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+	}
+	return currency, nil
+}
+
+// Valid reports whether the currency is one we support.
+func (c Currency) Valid() bool {
+	_, ok := minorUnits[c]
+	return ok
+}
+
+// MinorUnits returns the number of decimal places of the currency's minor unit.
+func (c Currency) MinorUnits() int {
+	return minorUnits[c]
+}
+
+// Money is an amount in the minor unit of its currency (e.g. cents for USD).
+// Amounts are never stored as floats, so arithmetic is exact.
+type Money struct {
+	Amount   int64    `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+// New creates a Money value from an amount in minor units.
+func New(amount int64, currency Currency) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Zero returns a zero amount in the given currency.
+func Zero(currency Currency) Money {
+	return Money{Currency: currency}
+}
+
+// Add returns m + other. Both values must share a currency.
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	if (other.Amount > 0 && m.Amount > math.MaxInt64-other.Amount) ||
+		(other.Amount < 0 && m.Amount < math.MinInt64-other.Amount) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+// Sub returns m - other. Both values must share a currency.
+func (m Money) Sub(other Money) (Money, error) {
+	if other.Amount == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return m.Add(other.Negate())
+}
+
+// Multiply returns m * factor, e.g. a unit price times a quantity.
+func (m Money) Multiply(factor int64) (Money, error) {
+	if m.Amount != 0 && factor != 0 {
+		result := m.Amount * factor
+		if result/factor != m.Amount || (m.Amount == -1 && factor == math.MinInt64) {
+			return Money{}, ErrOverflow
+		}
+		return Money{Amount: result, Currency: m.Currency}, nil
+	}
+	return Zero(m.Currency), nil
+}
+
+// Divide returns m / divisor rounded to the nearest minor unit, with halves
+// rounded away from zero, e.g. an average order value.
+func (m Money) Divide(divisor int64) (Money, error) {
+	if divisor == 0 {
+		return Money{}, ErrDivideByZero
+	}
+	if m.Amount == math.MinInt64 && divisor == -1 {
+		return Money{}, ErrOverflow
+	}
+	quotient, remainder := m.Amount/divisor, m.Amount%divisor
+	// Round up when 2*|remainder| >= |divisor|, compared without overflowing.
+	absRemainder, absDivisor := abs(remainder), abs(divisor)
+	if absRemainder >= absDivisor-absRemainder {
+		if (m.Amount < 0) != (divisor < 0) {
+			quotient--
+		} else {
+			quotient++
+		}
+	}
+	return Money{Amount: quotient, Currency: m.Currency}, nil
+}
+
+// abs returns |n| as a uint64 so that math.MinInt64 is representable.
+func abs(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
+
+// Negate returns -m.
+func (m Money) Negate() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsNegative reports whether the amount is below zero.
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// String formats the amount in major units, e.g. "12.50 USD".
+func (m Money) String() string {
+	return m.Decimal() + " " + string(m.Currency)
+}
+
+// Decimal formats the amount in major units without the currency, e.g. "12.50".
+func (m Money) Decimal() string {
+	digits := m.Currency.MinorUnits()
+	if digits == 0 {
+		return fmt.Sprintf("%d", m.Amount)
+	}
+
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	scale := int64(math.Pow10(digits))
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/scale, digits, amount%scale)
+}
+
+// Sum adds up the given amounts, which must all be in the given currency.
+func Sum(currency Currency, amounts ...Money) (Money, error) {
+	total := Zero(currency)
+	for _, amount := range amounts {
+		var err error
+		if total, err = total.Add(amount); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// UnmarshalJSON decodes {"amount": ..., "currency": ...}, normalising the
+// currency code. An omitted currency is allowed so callers can default it.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Amount   int64  `json:"amount"`
+		Currency string `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	m.Amount = raw.Amount
+	m.Currency = ""
+	if raw.Currency != "" {
+		currency, err := ParseCurrency(raw.Currency)
+		if err != nil {
+			return err
+		}
+		m.Currency = currency
+	}
+	return nil
+}
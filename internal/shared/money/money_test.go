@@ -0,0 +1,91 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoney_Arithmetic(t *testing.T) {
+	t.Run("should add and subtract amounts in the same currency", func(t *testing.T) {
+		sum, err := New(1050, USD).Add(New(250, USD))
+		require.NoError(t, err)
+		assert.Equal(t, New(1300, USD), sum)
+
+		diff, err := sum.Sub(New(1500, USD))
+		require.NoError(t, err)
+		assert.Equal(t, New(-200, USD), diff)
+	})
+
+	t.Run("should refuse to mix currencies", func(t *testing.T) {
+		_, err := New(100, USD).Add(New(100, THB))
+		assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+		_, err = Sum(USD, New(100, USD), New(100, EUR))
+		assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	})
+
+	t.Run("should multiply by a quantity", func(t *testing.T) {
+		total, err := New(350, THB).Multiply(3)
+		require.NoError(t, err)
+		assert.Equal(t, New(1050, THB), total)
+	})
+
+	t.Run("should divide and round to the nearest minor unit", func(t *testing.T) {
+		average, err := New(1000, USD).Divide(3)
+		require.NoError(t, err)
+		assert.Equal(t, New(333, USD), average)
+
+		average, err = New(1001, USD).Divide(2)
+		require.NoError(t, err)
+		assert.Equal(t, New(501, USD), average)
+
+		average, err = New(-1001, USD).Divide(2)
+		require.NoError(t, err)
+		assert.Equal(t, New(-501, USD), average)
+
+		average, err = New(math.MinInt64, USD).Divide(math.MinInt64)
+		require.NoError(t, err)
+		assert.Equal(t, New(1, USD), average)
+
+		_, err = New(100, USD).Divide(0)
+		assert.ErrorIs(t, err, ErrDivideByZero)
+	})
+
+	t.Run("should detect overflow", func(t *testing.T) {
+		_, err := New(math.MaxInt64, USD).Add(New(1, USD))
+		assert.ErrorIs(t, err, ErrOverflow)
+
+		_, err = New(math.MaxInt64/2+1, USD).Multiply(2)
+		assert.ErrorIs(t, err, ErrOverflow)
+	})
+}
+
+func TestMoney_String(t *testing.T) {
+	assert.Equal(t, "12.50 USD", New(1250, USD).String())
+	assert.Equal(t, "-0.05 THB", New(-5, THB).String())
+	assert.Equal(t, "1500 JPY", New(1500, JPY).String())
+}
+
+func TestMoney_JSON(t *testing.T) {
+	t.Run("should encode as amount and currency", func(t *testing.T) {
+		data, err := json.Marshal(New(1000, USD))
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"amount":1000,"currency":"USD"}`, string(data))
+	})
+
+	t.Run("should normalise the currency code when decoding", func(t *testing.T) {
+		var m Money
+		require.NoError(t, json.Unmarshal([]byte(`{"amount":990,"currency":"thb"}`), &m))
+		assert.Equal(t, New(990, THB), m)
+	})
+
+	t.Run("should reject unknown currencies", func(t *testing.T) {
+		var m Money
+		err := json.Unmarshal([]byte(`{"amount":990,"currency":"XYZ"}`), &m)
+		assert.ErrorIs(t, err, ErrUnknownCurrency)
+	})
+}
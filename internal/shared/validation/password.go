@@ -0,0 +1,55 @@
+package validation
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// MaxPasswordBytes is the most bcrypt can hash; longer passwords are refused
+// rather than silently cut short.
+const MaxPasswordBytes = 72
+
+// PasswordPolicy is what new passwords must contain. Zero MinLength means
+// DefaultPasswordPolicy.MinLength.
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// DefaultPasswordPolicy only asks for a length.
+var DefaultPasswordPolicy = PasswordPolicy{MinLength: 8}
+
+// Password checks a new password against the policy.
+func (v *Validator) Password(field, password string, policy PasswordPolicy) {
+	minLength := policy.MinLength
+	if minLength <= 0 {
+		minLength = DefaultPasswordPolicy.MinLength
+	}
+	if len([]rune(password)) < minLength {
+		v.Add(field, fmt.Sprintf("must be at least %d characters", minLength))
+	}
+	if len(password) > MaxPasswordBytes {
+		v.Add(field, fmt.Sprintf("must be at most %d bytes", MaxPasswordBytes))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	v.Check(upper || !policy.RequireUpper, field, "must contain an upper-case letter")
+	v.Check(lower || !policy.RequireLower, field, "must contain a lower-case letter")
+	v.Check(digit || !policy.RequireDigit, field, "must contain a digit")
+	v.Check(symbol || !policy.RequireSymbol, field, "must contain a symbol")
+}
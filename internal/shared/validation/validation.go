@@ -0,0 +1,94 @@
+// Package validation checks request bodies field by field, so that every
+// handler reports invalid input the same way: 422 with the list of fields
+// that are wrong and why.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MaxNameLength is the longest name or email the VARCHAR(255) columns hold.
+const MaxNameLength = 255
+
+// FieldError is what is wrong with one field of a request. Field is the
+// field's JSON name; nested fields are written like "items[0].quantity".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors lists the field errors of a request. It is returned as an error by
+// Validator.Err and the Validate methods of request types.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fieldError := range e {
+		parts[i] = fieldError.Field + " " + fieldError.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Validator collects field errors. The zero value is ready to use.
+type Validator struct {
+	errors Errors
+}
+
+// Add records that field is wrong.
+func (v *Validator) Add(field, message string) {
+	v.errors = append(v.errors, FieldError{Field: field, Message: message})
+}
+
+// Check records message for field unless ok.
+func (v *Validator) Check(ok bool, field, message string) {
+	if !ok {
+		v.Add(field, message)
+	}
+}
+
+// Required checks that a string field is not blank.
+func (v *Validator) Required(field, value string) {
+	v.Check(strings.TrimSpace(value) != "", field, "is required")
+}
+
+// MaxLength checks that a string field has at most max characters.
+func (v *Validator) MaxLength(field, value string, max int) {
+	v.Check(utf8.RuneCountInString(value) <= max, field, fmt.Sprintf("must be at most %d characters", max))
+}
+
+// Email checks that a field, if set, is a bare email address such as
+// "jane@example.com".
+func (v *Validator) Email(field, value string) {
+	if value == "" {
+		return
+	}
+	address, err := mail.ParseAddress(value)
+	v.Check(err == nil && address.Address == value, field, "must be a valid email address")
+}
+
+// Err returns the errors collected so far as Errors, or nil if there are none.
+func (v *Validator) Err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return v.errors
+}
+
+// Respond answers a request that failed validation with 422 and its field
+// errors. Other errors are answered with 400.
+func Respond(c *fiber.Ctx, err error) error {
+	var fieldErrors Errors
+	if !errors.As(err, &fieldErrors) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+		"error":  "Validation failed",
+		"fields": fieldErrors,
+	})
+}
@@ -0,0 +1,106 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidator(t *testing.T) {
+	t.Run("should pass valid input", func(t *testing.T) {
+		var v Validator
+		v.Required("name", "Jane")
+		v.MaxLength("name", "Jane", 4)
+		v.Email("email", "jane@example.com")
+		v.Email("backup_email", "")
+		assert.NoError(t, v.Err())
+	})
+
+	t.Run("should collect every field error in order", func(t *testing.T) {
+		var v Validator
+		v.Required("name", "  ")
+		v.MaxLength("city", strings.Repeat("é", 5), 4)
+		v.Email("email", "Jane <jane@example.com>")
+		v.Check(false, "items[0].quantity", "must be greater than 0")
+
+		var fieldErrors Errors
+		require.ErrorAs(t, v.Err(), &fieldErrors)
+		assert.Equal(t, Errors{
+			{Field: "name", Message: "is required"},
+			{Field: "city", Message: "must be at most 4 characters"},
+			{Field: "email", Message: "must be a valid email address"},
+			{Field: "items[0].quantity", Message: "must be greater than 0"},
+		}, fieldErrors)
+	})
+}
+
+func TestValidator_Password(t *testing.T) {
+	check := func(password string, policy PasswordPolicy) []string {
+		var v Validator
+		v.Password("password", password, policy)
+		var messages []string
+		for _, fieldError := range v.errors {
+			messages = append(messages, fieldError.Message)
+		}
+		return messages
+	}
+
+	t.Run("should default to a minimum length", func(t *testing.T) {
+		assert.Equal(t, []string{"must be at least 8 characters"}, check("short", PasswordPolicy{}))
+		assert.Empty(t, check("password", PasswordPolicy{}))
+	})
+
+	t.Run("should refuse what bcrypt would cut short", func(t *testing.T) {
+		assert.Equal(t, []string{"must be at most 72 bytes"}, check(strings.Repeat("a", 73), DefaultPasswordPolicy))
+	})
+
+	t.Run("should require the configured kinds of characters", func(t *testing.T) {
+		strict := PasswordPolicy{MinLength: 10, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+		assert.Equal(t, []string{
+			"must be at least 10 characters",
+			"must contain an upper-case letter",
+			"must contain a digit",
+			"must contain a symbol",
+		}, check("password", strict))
+		assert.Empty(t, check("Correct-Horse-9", strict))
+	})
+}
+
+func TestRespond(t *testing.T) {
+	app := fiber.New()
+	app.Get("/fields", func(c *fiber.Ctx) error {
+		var v Validator
+		v.Required("email", "")
+		return Respond(c, v.Err())
+	})
+	app.Get("/other", func(c *fiber.Ctx) error {
+		return Respond(c, errors.New("something else"))
+	})
+
+	t.Run("should answer field errors with 422", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/fields", nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+		var body struct {
+			Error  string `json:"error"`
+			Fields Errors `json:"fields"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "Validation failed", body.Error)
+		assert.Equal(t, Errors{{Field: "email", Message: "is required"}}, body.Fields)
+	})
+
+	t.Run("should answer other errors with 400", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/other", nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
@@ -0,0 +1,106 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultEmailVerificationTTL = 48 * time.Hour
+	// VerificationResendInterval is how long a user waits between verification emails.
+	VerificationResendInterval = time.Minute
+	// MaxVerificationsPerDay caps the verification emails sent to one user in 24 hours.
+	MaxVerificationsPerDay = 5
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("email verification token is invalid or expired")
+	ErrEmailAlreadyVerified     = errors.New("email is already verified")
+	// ErrEmailNotVerified is returned when the user must verify their email first.
+	ErrEmailNotVerified = errors.New("email address has not been verified")
+	// ErrVerificationRateLimited is returned, wrapped in a
+	// *VerificationRateLimitError, when verification emails are resent too often.
+	ErrVerificationRateLimited = errors.New("too many verification emails")
+)
+
+// VerificationRateLimitError tells the user how long to wait before asking
+// for another verification email.
+type VerificationRateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *VerificationRateLimitError) Error() string {
+	return fmt.Sprintf("%s, try again in %s", ErrVerificationRateLimited, e.RetryAfter.Round(time.Second))
+}
+
+func (e *VerificationRateLimitError) Unwrap() error {
+	return ErrVerificationRateLimited
+}
+
+// EmailVerification proves that whoever holds its token can read mail sent
+// to Email. Only a hash of the token is stored.
+type EmailVerification struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	// Email is the address the token was sent to. It only verifies the user
+	// while they still have that address.
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+	// UsedAt is set once the token was used, or another of the user's tokens was.
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// newEmailVerification creates a verification for the user's current email
+// and returns it with the token to send them.
+func newEmailVerification(user *User, now time.Time, ttl time.Duration) (*EmailVerification, string, error) {
+	token, err := newSecretToken()
+	if err != nil {
+		return nil, "", err
+	}
+	return &EmailVerification{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: HashVerificationToken(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}, token, nil
+}
+
+// HashVerificationToken returns the hash verification tokens are stored and looked up by.
+func HashVerificationToken(token string) string {
+	return hashSecretToken(token)
+}
+
+// Usable reports whether the token may be used at now.
+func (v *EmailVerification) Usable(now time.Time) bool {
+	return v.UsedAt == nil && now.Before(v.ExpiresAt)
+}
+
+// resendWait returns how long the user must wait before another verification
+// email, given the ones sent in the last 24 hours.
+func resendWait(recent []*EmailVerification, now time.Time) time.Duration {
+	var wait time.Duration
+	for _, verification := range recent {
+		if until := verification.CreatedAt.Add(VerificationResendInterval).Sub(now); until > wait {
+			wait = until
+		}
+	}
+	if len(recent) >= MaxVerificationsPerDay {
+		oldest := recent[0].CreatedAt
+		for _, verification := range recent {
+			if verification.CreatedAt.Before(oldest) {
+				oldest = verification.CreatedAt
+			}
+		}
+		if until := oldest.Add(24 * time.Hour).Sub(now); until > wait {
+			wait = until
+		}
+	}
+	return wait
+}
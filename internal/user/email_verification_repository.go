@@ -0,0 +1,80 @@
+package user
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EmailVerificationRepository interface {
+	SaveEmailVerification(ctx context.Context, verification *EmailVerification) error
+	// FindEmailVerification returns the verification with the token hash, or
+	// ErrInvalidVerificationToken.
+	FindEmailVerification(ctx context.Context, tokenHash string) (*EmailVerification, error)
+	// ListEmailVerificationsSince returns the user's verifications created at
+	// or after since, used or not.
+	ListEmailVerificationsSince(ctx context.Context, userID uuid.UUID, since time.Time) ([]*EmailVerification, error)
+	// UseEmailVerifications marks every unused verification of the user as
+	// used. It returns false if the one with id was used in the meantime.
+	UseEmailVerifications(ctx context.Context, userID, id uuid.UUID, usedAt time.Time) (bool, error)
+}
+
+type InMemoryEmailVerificationRepository struct {
+	mu            sync.Mutex
+	verifications map[uuid.UUID]*EmailVerification
+}
+
+func NewInMemoryEmailVerificationRepository() *InMemoryEmailVerificationRepository {
+	return &InMemoryEmailVerificationRepository{verifications: map[uuid.UUID]*EmailVerification{}}
+}
+
+func (r *InMemoryEmailVerificationRepository) SaveEmailVerification(ctx context.Context, verification *EmailVerification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *verification
+	r.verifications[verification.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryEmailVerificationRepository) FindEmailVerification(ctx context.Context, tokenHash string) (*EmailVerification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, verification := range r.verifications {
+		if verification.TokenHash == tokenHash {
+			found := *verification
+			return &found, nil
+		}
+	}
+	return nil, ErrInvalidVerificationToken
+}
+
+func (r *InMemoryEmailVerificationRepository) ListEmailVerificationsSince(ctx context.Context, userID uuid.UUID, since time.Time) ([]*EmailVerification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recent := []*EmailVerification{}
+	for _, verification := range r.verifications {
+		if verification.UserID == userID && !verification.CreatedAt.Before(since) {
+			found := *verification
+			recent = append(recent, &found)
+		}
+	}
+	return recent, nil
+}
+
+func (r *InMemoryEmailVerificationRepository) UseEmailVerifications(ctx context.Context, userID, id uuid.UUID, usedAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	used := false
+	for _, verification := range r.verifications {
+		if verification.UserID != userID || verification.UsedAt != nil {
+			continue
+		}
+		verification.UsedAt = &usedAt
+		if verification.ID == id {
+			used = true
+		}
+	}
+	return used, nil
+}
@@ -1,15 +1,34 @@
 package user
 
 import (
+	middlerware "minimart/internal/shared/middleware"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type User struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"`
-	CreatedAt time.Time `json:"created_at"`
+	ID       uuid.UUID        `json:"id"`
+	Name     string           `json:"name"`
+	Email    string           `json:"email"`
+	Password string           `json:"-"`
+	Role     middlerware.Role `json:"role"`
+	// EmailVerifiedAt is when the user proved they can read mail sent to
+	// Email, or nil if they have not yet.
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// DeletedUserName is the name deleted users are left with. Their rows stay
+// so that their orders still add up.
+const DeletedUserName = "Deleted user"
+
+// deletedUserEmail is the unique, undeliverable email a deleted user is left with.
+func deletedUserEmail(id uuid.UUID) string {
+	return "deleted-" + id.String() + "@deleted.invalid"
+}
+
+// EmailVerified reports whether the user has verified their current email.
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
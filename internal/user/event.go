@@ -4,13 +4,67 @@ import "time"
 
 const UserCreatedTopic = "user.created"
 
+// UserCreatedEvent carries the token that verifies the new user's email, to
+// be emailed to them. The token must not be logged. Users who signed up with
+// an identity provider have verified their email already and get no token.
 type UserCreatedEvent struct {
+	UserID                string    `json:"user_id"`
+	Name                  string    `json:"name"`
+	Email                 string    `json:"email"`
+	VerificationToken     string    `json:"verification_token,omitempty"`
+	VerificationExpiresAt time.Time `json:"verification_expires_at"`
+	// IdentityProvider is the provider the user signed up with, if any.
+	IdentityProvider string    `json:"identity_provider,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+func (e UserCreatedEvent) Topic() string {
+	return UserCreatedTopic
+}
+
+const PasswordResetRequestedTopic = "user.password_reset_requested"
+
+// PasswordResetRequestedEvent carries a reset token to be emailed to the
+// user. It must not be logged.
+type PasswordResetRequestedEvent struct {
 	UserID    string    `json:"user_id"`
 	Name      string    `json:"name"`
 	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
-func (e UserCreatedEvent) Topic() string {
-	return UserCreatedTopic
+func (e PasswordResetRequestedEvent) Topic() string {
+	return PasswordResetRequestedTopic
+}
+
+const EmailVerificationRequestedTopic = "user.email_verification_requested"
+
+// EmailVerificationRequestedEvent carries a new verification token for a user
+// who asked for the email again. It must not be logged.
+type EmailVerificationRequestedEvent struct {
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (e EmailVerificationRequestedEvent) Topic() string {
+	return EmailVerificationRequestedTopic
+}
+
+const AccountLockedTopic = "user.account_locked"
+
+// AccountLockedEvent is published when too many failed logins lock an
+// account, so that its owner can be told.
+type AccountLockedEvent struct {
+	UserID      string    `json:"user_id"`
+	Name        string    `json:"name"`
+	Email       string    `json:"email"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+func (e AccountLockedEvent) Topic() string {
+	return AccountLockedTopic
 }
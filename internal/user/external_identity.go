@@ -0,0 +1,23 @@
+package user
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrExternalIdentityNotFound = errors.New("external identity not found")
+
+// ExternalIdentity links a user to their account at an identity provider,
+// so that they can sign in with it.
+type ExternalIdentity struct {
+	Provider string
+	// Subject is the user's ID at the provider. Their email there may change;
+	// the subject does not.
+	Subject string
+	UserID  uuid.UUID
+	// Email is the provider's email for the user when they were linked.
+	Email     string
+	CreatedAt time.Time
+}
@@ -0,0 +1,43 @@
+package user
+
+import (
+	"context"
+	"sync"
+)
+
+type ExternalIdentityRepository interface {
+	// FindExternalIdentity returns the identity with the provider and
+	// subject, or ErrExternalIdentityNotFound.
+	FindExternalIdentity(ctx context.Context, provider, subject string) (*ExternalIdentity, error)
+	// LinkExternalIdentity saves the identity, moving it to identity.UserID if
+	// it was linked to another user.
+	LinkExternalIdentity(ctx context.Context, identity *ExternalIdentity) error
+}
+
+type InMemoryExternalIdentityRepository struct {
+	mu         sync.Mutex
+	identities map[[2]string]*ExternalIdentity
+}
+
+func NewInMemoryExternalIdentityRepository() *InMemoryExternalIdentityRepository {
+	return &InMemoryExternalIdentityRepository{identities: map[[2]string]*ExternalIdentity{}}
+}
+
+func (r *InMemoryExternalIdentityRepository) FindExternalIdentity(ctx context.Context, provider, subject string) (*ExternalIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	identity, found := r.identities[[2]string{provider, subject}]
+	if !found {
+		return nil, ErrExternalIdentityNotFound
+	}
+	stored := *identity
+	return &stored, nil
+}
+
+func (r *InMemoryExternalIdentityRepository) LinkExternalIdentity(ctx context.Context, identity *ExternalIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *identity
+	r.identities[[2]string{identity.Provider, identity.Subject}] = &stored
+	return nil
+}
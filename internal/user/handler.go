@@ -2,23 +2,46 @@ package user
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	middlerware "minimart/internal/shared/middleware"
+	"minimart/internal/shared/validation"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 type UserHandler struct {
-	usecase UserUsecase
+	usecase   UserUsecase
+	auth      fiber.Handler
+	admin     fiber.Handler
+	passwords validation.PasswordPolicy
 }
 
-func NewUserHandler(usecase UserUsecase) *UserHandler {
+// NewUserHandler creates a new UserHandler. auth guards logout, normally
+// middlerware.AuthRequire; admin guards user management and runs after auth.
+// New users' passwords must meet passwords.
+func NewUserHandler(usecase UserUsecase, auth fiber.Handler, admin fiber.Handler, passwords validation.PasswordPolicy) *UserHandler {
 	return &UserHandler{
-		usecase: usecase,
+		usecase:   usecase,
+		auth:      auth,
+		admin:     admin,
+		passwords: passwords,
 	}
 }
 
 func (h *UserHandler) RegisterRoutes(app *fiber.App) {
 	app.Post("users/register", h.RegisterUser)
 	app.Post("users/login", h.Login)
+	app.Post("users/refresh", h.Refresh)
+	app.Post("users/logout", h.auth, h.Logout)
+	app.Get("users/verify", h.VerifyEmail)
+	app.Post("users/verify/resend", h.auth, h.ResendVerification)
+
+	app.Patch("/admin/users/:userID/role", h.auth, h.admin, h.ChangeRole)
+	app.Post("/admin/users/:userID/unlock", h.auth, h.admin, h.UnlockLogin)
 }
 
 type registerUserRequest struct {
@@ -27,6 +50,17 @@ type registerUserRequest struct {
 	Password string `json:"password"`
 }
 
+func (r registerUserRequest) Validate(passwords validation.PasswordPolicy) error {
+	var v validation.Validator
+	v.Required("name", r.Name)
+	v.MaxLength("name", r.Name, validation.MaxNameLength)
+	v.Required("email", r.Email)
+	v.Email("email", r.Email)
+	v.MaxLength("email", r.Email, validation.MaxNameLength)
+	v.Password("password", r.Password, passwords)
+	return v.Err()
+}
+
 func (h *UserHandler) RegisterUser(c *fiber.Ctx) error {
 	var req registerUserRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -34,6 +68,9 @@ func (h *UserHandler) RegisterUser(c *fiber.Ctx) error {
 			"error": "Invalid request",
 		})
 	}
+	if err := req.Validate(h.passwords); err != nil {
+		return validation.Respond(c, err)
+	}
 	user, err := h.usecase.RegisterUser(c.Context(), req.Name, req.Email, req.Password)
 	if err != nil {
 		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Could not register user"})
@@ -46,6 +83,13 @@ type loginRequest struct {
 	Password string `json:"password"`
 }
 
+func (r loginRequest) Validate() error {
+	var v validation.Validator
+	v.Required("email", r.Email)
+	v.Check(r.Password != "", "password", "is required")
+	return v.Err()
+}
+
 func (h *UserHandler) Login(c *fiber.Ctx) error {
 	var req loginRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -53,13 +97,188 @@ func (h *UserHandler) Login(c *fiber.Ctx) error {
 			"error": "Invalid request",
 		})
 	}
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
 
-	token, err := h.usecase.Login(c.Context(), req.Email, req.Password)
+	tokens, err := h.usecase.Login(c.Context(), req.Email, req.Password, c.IP())
 	if err != nil {
-		if errors.Is(err, ErrInvalidCredentials) {
+		var throttled *LoginThrottledError
+		switch {
+		case errors.Is(err, ErrInvalidCredentials):
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid credentials"})
+		case errors.As(err, &throttled):
+			return tooManyRequests(c, err, throttled.RetryAfter)
 		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not login"})
 	}
-	return c.JSON(fiber.Map{"token": token})
+	return c.JSON(tokens)
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (r refreshRequest) Validate() error {
+	var v validation.Validator
+	v.Required("refresh_token", r.RefreshToken)
+	return v.Err()
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token.
+func (h *UserHandler) Refresh(c *fiber.Ctx) error {
+	var req refreshRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
+
+	tokens, err := h.usecase.Refresh(c.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, ErrRefreshTokenReused) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not refresh token"})
+	}
+	return c.JSON(tokens)
+}
+
+type logoutRequest struct {
+	// All logs out of every session, not just the one making the request.
+	All bool `json:"all"`
+}
+
+// Logout revokes the caller's session, or with "all" every session of the user.
+func (h *UserHandler) Logout(c *fiber.Ctx) error {
+	var req logoutRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+		}
+	}
+
+	if req.All {
+		userID, err := middlerware.UserID(c)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err := h.usecase.LogoutEverywhere(c.Context(), userID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not logout"})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+
+	// Tokens issued before sessions existed carry no sid and cannot be revoked one by one.
+	sessionID, err := middlerware.SessionID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := h.usecase.Logout(c.Context(), sessionID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not logout"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+type changeRoleRequest struct {
+	Role string `json:"role"`
+}
+
+func (r changeRoleRequest) Validate() error {
+	var v validation.Validator
+	_, err := middlerware.ParseRole(r.Role)
+	v.Check(err == nil, "role", fmt.Sprintf("must be %s, %s or %s", middlerware.RoleCustomer, middlerware.RoleMerchant, middlerware.RoleAdmin))
+	return v.Err()
+}
+
+// ChangeRole handles an admin changing a user's platform role.
+func (h *UserHandler) ChangeRole(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("userID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	var req changeRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
+	role, _ := middlerware.ParseRole(req.Role)
+
+	user, err := h.usecase.ChangeRole(c.Context(), userID, role)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not change role"})
+	}
+	return c.JSON(user)
+}
+
+// VerifyEmail handles the link in verification emails.
+func (h *UserHandler) VerifyEmail(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "token is required"})
+	}
+	user, err := h.usecase.VerifyEmail(c.Context(), token)
+	if err != nil {
+		if errors.Is(err, ErrInvalidVerificationToken) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not verify email"})
+	}
+	return c.JSON(fiber.Map{
+		"message":           "Email verified",
+		"email":             user.Email,
+		"email_verified_at": user.EmailVerifiedAt,
+	})
+}
+
+// ResendVerification sends the authenticated user a new verification email.
+func (h *UserHandler) ResendVerification(c *fiber.Ctx) error {
+	userID, err := middlerware.UserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := h.usecase.ResendVerification(c.Context(), userID); err != nil {
+		var limited *VerificationRateLimitError
+		switch {
+		case errors.As(err, &limited):
+			return tooManyRequests(c, err, limited.RetryAfter)
+		case errors.Is(err, ErrEmailAlreadyVerified):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, ErrUserNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not send verification email"})
+	}
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+// UnlockLogin handles an admin lifting a user's lockout after failed logins.
+func (h *UserHandler) UnlockLogin(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("userID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	if err := h.usecase.UnlockLogin(c.Context(), userID); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not unlock user"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// tooManyRequests tells the client to wait retryAfter before trying again.
+func tooManyRequests(c *fiber.Ctx, err error, retryAfter time.Duration) error {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error":               err.Error(),
+		"retry_after_seconds": seconds,
+	})
 }
@@ -8,6 +8,8 @@ import (
 	"io"
 	"log"
 	"minimart/internal/shared/eventbus"
+	middlerware "minimart/internal/shared/middleware"
+	"minimart/internal/shared/validation"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -18,7 +20,6 @@ import (
 	redisClient "github.com/go-redis/redis/v8"
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go"
@@ -91,18 +92,20 @@ func TestMain(m *testing.M) {
 	// Event bus
 	eventBus = eventbus.NewRedisEventBus(redisClient)
 
-	// Set up viper configuration for JWT
-	viper.Set("jwt.secret", "test-secret-key")
-
-	// Run the database migrations
-	migrationsPath, _ := filepath.Abs("../../migrations/001_create_users_table.sql")
-	migrationSQL, err := os.ReadFile(migrationsPath)
-	if err != nil {
-		log.Fatalf("could not read migration file: %s", err)
-	}
-	_, err = dbpool.Exec(ctx, string(migrationSQL))
-	if err != nil {
-		log.Fatalf("could not run migrations: %s", err)
+	// Run the database migrations. The merchant tables are needed by the
+	// role backfill in 019.
+	for _, migration := range []string{"001_create_users_table.sql", "004_create_merchants_table.sql", "009_add_merchant_owner.sql",
+		"012_create_merchant_memberships.sql", "018_create_refresh_tokens.sql", "019_add_user_roles.sql",
+		"020_create_password_resets.sql", "021_add_email_verification.sql", "022_add_user_deletion.sql", "023_create_user_identities.sql"} {
+		migrationsPath, _ := filepath.Abs("../../migrations/" + migration)
+		migrationSQL, err := os.ReadFile(migrationsPath)
+		if err != nil {
+			log.Fatalf("could not read migration file: %s", err)
+		}
+		_, err = dbpool.Exec(ctx, string(migrationSQL))
+		if err != nil {
+			log.Fatalf("could not run migrations: %s", err)
+		}
 	}
 
 	// 6. Run the actual tests
@@ -112,11 +115,18 @@ func TestMain(m *testing.M) {
 	os.Exit(exitCode)
 }
 
+var requireUserAdmin = middlerware.RequirePermission(middlerware.PermManageUsers)
+
+func newTestUserUsecase(userRepo UserRepository) UserUsecase {
+	return NewUserUsecase(userRepo, NewPostgresRefreshTokenRepository(dbpool), NewPostgresEmailVerificationRepository(dbpool), newTestLoginGuard(), middlerware.NewInMemoryTokenDenylist(), testKeys, eventBus,
+		TokenConfig{})
+}
+
 func TestUserHandler_RegisterUser_Integration(t *testing.T) {
 	// 1. Arrange: Set up our application and dependencies
 	userRepo := NewPostgresUserRepository(dbpool)
-	userUsecase := NewUserUsecase(userRepo, eventBus)
-	userHandler := NewUserHandler(userUsecase)
+	userUsecase := newTestUserUsecase(userRepo)
+	userHandler := NewUserHandler(userUsecase, middlerware.AuthRequire(testKeys, middlerware.NewInMemoryTokenDenylist()), requireUserAdmin, validation.DefaultPasswordPolicy)
 
 	// Create a new Fiber app for testing
 	app := fiber.New()
@@ -152,14 +162,29 @@ func TestUserHandler_RegisterUser_Integration(t *testing.T) {
 	assert.NotEmpty(t, createdUser.ID, "Expected user ID to be generated")
 	assert.Equal(t, "Test User", createdUser.Name, "Expected user name to match")
 	assert.Equal(t, "test@example.com", createdUser.Email, "Expected user email to match")
+
+	// Invalid fields are listed together
+	req = httptest.NewRequest(http.MethodPost, "/users/register", bytes.NewReader([]byte(`{"name": "Test User", "email": "", "password": "short"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	var invalid struct {
+		Fields validation.Errors `json:"fields"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&invalid))
+	assert.Equal(t, validation.Errors{
+		{Field: "email", Message: "is required"},
+		{Field: "password", Message: "must be at least 8 characters"},
+	}, invalid.Fields)
 }
 
 func TestUserHandler_Login_Integration(t *testing.T) {
 	// 1. Arrange: Set up our application and dependencies
 	// userRepo := NewInMemoryUserRepository()
 	userRepo := NewPostgresUserRepository(dbpool)
-	userUsecase := NewUserUsecase(userRepo, eventBus)
-	userHandler := NewUserHandler(userUsecase)
+	userUsecase := newTestUserUsecase(userRepo)
+	userHandler := NewUserHandler(userUsecase, middlerware.AuthRequire(testKeys, middlerware.NewInMemoryTokenDenylist()), requireUserAdmin, validation.DefaultPasswordPolicy)
 
 	// Create a new Fiber app for testing
 	app := fiber.New()
@@ -196,3 +221,359 @@ func TestUserHandler_Login_Integration(t *testing.T) {
 
 	assert.NotEmpty(t, respBody["token"], "Expected token in the response")
 }
+
+func TestUserHandler_RefreshAndLogout_Integration(t *testing.T) {
+	// Arrange: the usecase and AuthRequire share one denylist, as in main
+	denylist := middlerware.NewInMemoryTokenDenylist()
+	userRepo := NewPostgresUserRepository(dbpool)
+	userUsecase := NewUserUsecase(userRepo, NewPostgresRefreshTokenRepository(dbpool), NewPostgresEmailVerificationRepository(dbpool), newTestLoginGuard(), denylist, testKeys, eventBus,
+		TokenConfig{})
+	app := fiber.New()
+	NewUserHandler(userUsecase, middlerware.AuthRequire(testKeys, denylist), requireUserAdmin, validation.DefaultPasswordPolicy).RegisterRoutes(app)
+
+	_, err := userUsecase.RegisterUser(context.Background(), "Test Refresh", "testrefresh@example.com", "password")
+	require.NoError(t, err)
+	tokens, err := userUsecase.Login(context.Background(), "testrefresh@example.com", "password", testClientIP)
+	require.NoError(t, err)
+
+	post := func(path, token string, body any) *http.Response {
+		bodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// Act: refresh once, then replay the old refresh token
+	resp := post("/users/refresh", "", map[string]string{"refresh_token": tokens.RefreshToken})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var refreshed TokenPair
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&refreshed))
+	assert.NotEmpty(t, refreshed.Token)
+
+	resp = post("/users/refresh", "", map[string]string{"refresh_token": tokens.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Assert: the reuse revoked the session, so its access token no longer works
+	resp = post("/users/logout", refreshed.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// A fresh session logs out and its token stops working
+	tokens, err = userUsecase.Login(context.Background(), "testrefresh@example.com", "password", testClientIP)
+	require.NoError(t, err)
+	resp = post("/users/logout", tokens.Token, map[string]bool{"all": true})
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp = post("/users/logout", tokens.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestUserHandler_ChangeRole_Integration(t *testing.T) {
+	// Arrange: an admin and a customer, both logged in
+	ctx := context.Background()
+	userRepo := NewPostgresUserRepository(dbpool)
+	userUsecase := newTestUserUsecase(userRepo)
+	app := fiber.New()
+	NewUserHandler(userUsecase, middlerware.AuthRequire(testKeys, middlerware.NewInMemoryTokenDenylist()), requireUserAdmin, validation.DefaultPasswordPolicy).RegisterRoutes(app)
+
+	admin, err := userUsecase.RegisterUser(ctx, "Test Admin", "testadmin@example.com", "password")
+	require.NoError(t, err)
+	_, err = userUsecase.ChangeRole(ctx, admin.ID, middlerware.RoleAdmin)
+	require.NoError(t, err)
+	adminTokens, err := userUsecase.Login(ctx, "testadmin@example.com", "password", testClientIP)
+	require.NoError(t, err)
+	customer, err := userUsecase.RegisterUser(ctx, "Test Customer", "testcustomer@example.com", "password")
+	require.NoError(t, err)
+	customerTokens, err := userUsecase.Login(ctx, "testcustomer@example.com", "password", testClientIP)
+	require.NoError(t, err)
+
+	changeRole := func(token string, userID string, role string) *http.Response {
+		bodyBytes, _ := json.Marshal(map[string]string{"role": role})
+		req := httptest.NewRequest(http.MethodPatch, "/admin/users/"+userID+"/role", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// Act & Assert: customers cannot promote themselves
+	resp := changeRole(customerTokens.Token, customer.ID.String(), "admin")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = changeRole(adminTokens.Token, customer.ID.String(), "owner")
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+	resp = changeRole(adminTokens.Token, customer.ID.String(), "merchant")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var changed User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&changed))
+	assert.Equal(t, middlerware.RoleMerchant, changed.Role)
+
+	stored, err := userRepo.FindByID(ctx, customer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, middlerware.RoleMerchant, stored.Role)
+}
+
+func TestUserHandler_LoginLockout_Integration(t *testing.T) {
+	// Arrange: an admin and a customer whose password is being guessed
+	ctx := context.Background()
+	userUsecase := newTestUserUsecase(NewPostgresUserRepository(dbpool))
+	app := fiber.New()
+	NewUserHandler(userUsecase, middlerware.AuthRequire(testKeys, middlerware.NewInMemoryTokenDenylist()), requireUserAdmin, validation.DefaultPasswordPolicy).RegisterRoutes(app)
+
+	admin, err := userUsecase.RegisterUser(ctx, "Test Unlocker", "testunlocker@example.com", "password")
+	require.NoError(t, err)
+	_, err = userUsecase.ChangeRole(ctx, admin.ID, middlerware.RoleAdmin)
+	require.NoError(t, err)
+	adminTokens, err := userUsecase.Login(ctx, "testunlocker@example.com", "password", testClientIP)
+	require.NoError(t, err)
+	guessed, err := userUsecase.RegisterUser(ctx, "Test Guessed", "testguessed@example.com", "password")
+	require.NoError(t, err)
+
+	login := func(password string) *http.Response {
+		bodyBytes, _ := json.Marshal(map[string]string{"email": "testguessed@example.com", "password": password})
+		req := httptest.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// Act & Assert: after the free failures even the right password must wait
+	for range DefaultFreeLoginFailures {
+		assert.Equal(t, http.StatusUnauthorized, login("wrong").StatusCode)
+	}
+	resp := login("password")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get(fiber.HeaderRetryAfter))
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/users/"+guessed.ID.String()+"/unlock", nil)
+	req.Header.Set("Authorization", "Bearer "+adminTokens.Token)
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	assert.Equal(t, http.StatusOK, login("password").StatusCode)
+}
+
+func TestPasswordResetHandler_Integration(t *testing.T) {
+	// Arrange: an in-memory bus so the test can read the emailed token
+	ctx := context.Background()
+	bus := eventbus.NewInMemoryEventBus()
+	requested := make(chan PasswordResetRequestedEvent, 1)
+	require.NoError(t, bus.Subscribe(PasswordResetRequestedTopic, func(ctx context.Context, event eventbus.Event) error {
+		requested <- event.(PasswordResetRequestedEvent)
+		return nil
+	}))
+	userRepo := NewPostgresUserRepository(dbpool)
+	userUsecase := newTestUserUsecase(userRepo)
+	app := fiber.New()
+	NewPasswordResetHandler(NewPasswordResetUsecase(userRepo, NewPostgresPasswordResetRepository(dbpool), NewInMemoryLoginAttemptStore(), userUsecase, bus, 0, discardLogger), validation.DefaultPasswordPolicy).RegisterRoutes(app)
+
+	_, err := userUsecase.RegisterUser(ctx, "Test Reset", "testreset@example.com", "old-password")
+	require.NoError(t, err)
+
+	post := func(path string, body any) *http.Response {
+		bodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// Act & Assert: unknown and known emails get the same answer
+	unknown := post("/users/password/forgot", map[string]string{"email": "nobody@example.com"})
+	known := post("/users/password/forgot", map[string]string{"email": "testreset@example.com"})
+	assert.Equal(t, http.StatusAccepted, unknown.StatusCode)
+	assert.Equal(t, http.StatusAccepted, known.StatusCode)
+	unknownBody, _ := io.ReadAll(unknown.Body)
+	knownBody, _ := io.ReadAll(known.Body)
+	assert.Equal(t, string(unknownBody), string(knownBody))
+
+	var event PasswordResetRequestedEvent
+	select {
+	case event = <-requested:
+	case <-time.After(time.Second):
+		t.Fatal("no password reset event published")
+	}
+
+	resp := post("/users/password/reset", map[string]string{"token": event.Token, "password": "short"})
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode, "the password policy applies")
+	resp = post("/users/password/reset", map[string]string{"token": event.Token, "password": "new-password"})
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp = post("/users/password/reset", map[string]string{"token": event.Token, "password": "other-password"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	_, err = userUsecase.Login(ctx, "testreset@example.com", "new-password", testClientIP)
+	assert.NoError(t, err)
+}
+
+func TestUserHandler_VerifyEmail_Integration(t *testing.T) {
+	// Arrange: an in-memory bus so the test can read the emailed token
+	ctx := context.Background()
+	bus := eventbus.NewInMemoryEventBus()
+	created := make(chan UserCreatedEvent, 1)
+	require.NoError(t, bus.Subscribe(UserCreatedTopic, func(ctx context.Context, event eventbus.Event) error {
+		created <- event.(UserCreatedEvent)
+		return nil
+	}))
+	userRepo := NewPostgresUserRepository(dbpool)
+	denylist := middlerware.NewInMemoryTokenDenylist()
+	userUsecase := NewUserUsecase(userRepo, NewPostgresRefreshTokenRepository(dbpool), NewPostgresEmailVerificationRepository(dbpool), newTestLoginGuard(), denylist, testKeys, bus,
+		TokenConfig{})
+	app := fiber.New()
+	NewUserHandler(userUsecase, middlerware.AuthRequire(testKeys, denylist), requireUserAdmin, validation.DefaultPasswordPolicy).RegisterRoutes(app)
+
+	registered, err := userUsecase.RegisterUser(ctx, "Test Verify", "testverify@example.com", "password")
+	require.NoError(t, err)
+	var event UserCreatedEvent
+	select {
+	case event = <-created:
+	case <-time.After(time.Second):
+		t.Fatal("no user created event published")
+	}
+	tokens, err := userUsecase.Login(ctx, "testverify@example.com", "password", testClientIP)
+	require.NoError(t, err)
+	resend := func() *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/users/verify/resend", nil)
+		req.Header.Set("Authorization", "Bearer "+tokens.Token)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// Act & Assert: resending right after registering is rate limited
+	resp := resend()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get(fiber.HeaderRetryAfter))
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/users/verify?token="+event.VerificationToken, nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/users/verify?token="+event.VerificationToken, nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	stored, err := userRepo.FindByID(ctx, registered.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.EmailVerified())
+	assert.Equal(t, http.StatusConflict, resend().StatusCode)
+}
+
+func TestProfileHandler_Integration(t *testing.T) {
+	// Arrange: a logged-in user, and another whose email they cannot take
+	ctx := context.Background()
+	userRepo := NewPostgresUserRepository(dbpool)
+	userUsecase := newTestUserUsecase(userRepo)
+	profileUsecase := NewProfileUsecase(userRepo, NewPostgresEmailVerificationRepository(dbpool), newTestLoginGuard(), userUsecase, eventBus, 0)
+	app := fiber.New()
+	NewProfileHandler(profileUsecase, middlerware.AuthRequire(testKeys, middlerware.NewInMemoryTokenDenylist()), validation.DefaultPasswordPolicy).RegisterRoutes(app)
+
+	registered, err := userUsecase.RegisterUser(ctx, "Test Profile", "testprofile@example.com", "password")
+	require.NoError(t, err)
+	_, err = userUsecase.RegisterUser(ctx, "Test Taken", "testtaken@example.com", "password")
+	require.NoError(t, err)
+	tokens, err := userUsecase.Login(ctx, "testprofile@example.com", "password", testClientIP)
+	require.NoError(t, err)
+
+	send := func(method, path string, body any) *http.Response {
+		var reader io.Reader
+		if body != nil {
+			bodyBytes, _ := json.Marshal(body)
+			reader = bytes.NewReader(bodyBytes)
+		}
+		req := httptest.NewRequest(method, path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+tokens.Token)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// Act & Assert: the profile comes from the database
+	resp := send(http.MethodGet, "/api/profile", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var profile User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&profile))
+	assert.Equal(t, registered.ID, profile.ID)
+	assert.Equal(t, "Test Profile", profile.Name)
+
+	resp = send(http.MethodPatch, "/api/profile", map[string]string{"email": "testtaken@example.com"})
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	resp = send(http.MethodPatch, "/api/profile", map[string]string{"name": "Renamed", "email": "testrenamed@example.com"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	stored, err := userRepo.FindByID(ctx, registered.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", stored.Name)
+	assert.Equal(t, "testrenamed@example.com", stored.Email)
+	assert.False(t, stored.EmailVerified())
+
+	resp = send(http.MethodDelete, "/api/profile", map[string]string{"password": "wrong"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = send(http.MethodDelete, "/api/profile", map[string]string{"password": "password"})
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	_, err = userRepo.FindByID(ctx, registered.ID)
+	assert.Error(t, err)
+	var name, email string
+	require.NoError(t, dbpool.QueryRow(ctx, "SELECT name, email FROM users WHERE id = $1", registered.ID).Scan(&name, &email))
+	assert.Equal(t, DeletedUserName, name, "the row stays for the orders referring to it")
+	assert.NotContains(t, email, "testrenamed")
+}
+
+func TestOIDCHandler_Integration(t *testing.T) {
+	// Arrange: a mock provider whose user has an account with their email
+	ctx := context.Background()
+	mock := newMockOIDCProvider(t)
+	userRepo := NewPostgresUserRepository(dbpool)
+	userUsecase := newTestUserUsecase(userRepo)
+	oidcUsecase := NewOIDCUsecase([]OIDCProvider{NewOIDCProvider(mock.Config(), nil)}, NewInMemoryOIDCStateStore(),
+		NewPostgresExternalIdentityRepository(dbpool), userRepo, userUsecase, eventBus)
+	app := fiber.New()
+	NewOIDCHandler(oidcUsecase).RegisterRoutes(app)
+
+	registered, err := userUsecase.RegisterUser(ctx, "Test OIDC", "testoidc@example.com", "password")
+	require.NoError(t, err)
+	_, err = userRepo.MarkEmailVerified(ctx, registered.ID, registered.Email, time.Now())
+	require.NoError(t, err)
+	mock.SignInAs(OIDCIdentity{Subject: "test-oidc-1", Email: "testoidc@example.com", EmailVerified: true, Name: "Test OIDC"})
+
+	// Act: sign in as the browser would, through the provider and back
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/auth/oidc/mock/login", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	state, code := followOIDCLogin(t, resp.Header.Get(fiber.HeaderLocation))
+	callback := fmt.Sprintf("/auth/oidc/mock/callback?state=%s&code=%s", state, code)
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, callback, nil))
+	require.NoError(t, err)
+
+	// Assert: our own tokens for the existing user, and the identity linked
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var tokens TokenPair
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&tokens))
+	assert.NotEmpty(t, tokens.Token)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	identity, err := NewPostgresExternalIdentityRepository(dbpool).FindExternalIdentity(ctx, "mock", "test-oidc-1")
+	require.NoError(t, err)
+	assert.Equal(t, registered.ID, identity.UserID)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, callback, nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "each login completes once")
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/auth/oidc/mock/callback?error=access_denied", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/auth/oidc/unknown/login", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Deleting the user unlinks the identity
+	require.NoError(t, userRepo.Delete(ctx, registered.ID, time.Now()))
+	_, err = NewPostgresExternalIdentityRepository(dbpool).FindExternalIdentity(ctx, "mock", "test-oidc-1")
+	assert.ErrorIs(t, err, ErrExternalIdentityNotFound)
+}
@@ -0,0 +1,191 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// LoginAttemptStore keeps failed login attempts and lockouts by key, e.g. an
+// account or an IP address. Password reset requests are counted in it too,
+// under keys of their own.
+type LoginAttemptStore interface {
+	// RecordFailure records a failure at now and returns how many failures
+	// the key had within window before now, this one included.
+	RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (int, error)
+	// Failures returns how many failures the key had after since and when
+	// the last of them was.
+	Failures(ctx context.Context, key string, since time.Time) (int, time.Time, error)
+	// Lock locks the key out until the given time and forgets its failures,
+	// so that they are counted afresh once the lockout ends.
+	Lock(ctx context.Context, key string, until time.Time) error
+	// LockedUntil returns when the key's lockout ends and whether it is
+	// locked out at now.
+	LockedUntil(ctx context.Context, key string, now time.Time) (time.Time, bool, error)
+	// Clear forgets the key's failures and lifts its lockout.
+	Clear(ctx context.Context, key string) error
+}
+
+// RedisLoginAttemptStore is a LoginAttemptStore shared by every server
+// instance. Each key's failures are a sorted set scored by time, so the
+// window slides with every attempt, and its lockout is a key that expires
+// when the lockout ends.
+type RedisLoginAttemptStore struct {
+	client *redis.Client
+}
+
+// NewRedisLoginAttemptStore creates a new RedisLoginAttemptStore.
+func NewRedisLoginAttemptStore(client *redis.Client) LoginAttemptStore {
+	return &RedisLoginAttemptStore{client: client}
+}
+
+func (s *RedisLoginAttemptStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	failures := loginFailuresKey(key)
+	pipe := s.client.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, failures, "-inf", scoreOf(now.Add(-window)))
+	pipe.ZAdd(ctx, failures, &redis.Z{Score: float64(now.UnixMilli()), Member: uuid.NewString()})
+	count := pipe.ZCard(ctx, failures)
+	pipe.Expire(ctx, failures, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return int(count.Val()), nil
+}
+
+func (s *RedisLoginAttemptStore) Failures(ctx context.Context, key string, since time.Time) (int, time.Time, error) {
+	failures := loginFailuresKey(key)
+	pipe := s.client.Pipeline()
+	count := pipe.ZCount(ctx, failures, "("+scoreOf(since), "+inf")
+	last := pipe.ZRevRangeWithScores(ctx, failures, 0, 0)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, time.Time{}, err
+	}
+	if count.Val() == 0 || len(last.Val()) == 0 {
+		return 0, time.Time{}, nil
+	}
+	return int(count.Val()), time.UnixMilli(int64(last.Val()[0].Score)), nil
+}
+
+func (s *RedisLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, loginLockKey(key), until.UTC().Format(time.RFC3339Nano), ttl)
+	pipe.Del(ctx, loginFailuresKey(key))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisLoginAttemptStore) LockedUntil(ctx context.Context, key string, now time.Time) (time.Time, bool, error) {
+	value, err := s.client.Get(ctx, loginLockKey(key)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return time.Time{}, false, nil
+		}
+		return time.Time{}, false, err
+	}
+	until, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return until, now.Before(until), nil
+}
+
+func (s *RedisLoginAttemptStore) Clear(ctx context.Context, key string) error {
+	return s.client.Del(ctx, loginFailuresKey(key), loginLockKey(key)).Err()
+}
+
+func loginFailuresKey(key string) string {
+	return "login:failures:" + key
+}
+
+func loginLockKey(key string) string {
+	return "login:locked:" + key
+}
+
+func scoreOf(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+// InMemoryLoginAttemptStore is a LoginAttemptStore for tests and
+// single-instance setups.
+type InMemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+	locks    map[string]time.Time
+}
+
+// NewInMemoryLoginAttemptStore creates a new InMemoryLoginAttemptStore.
+func NewInMemoryLoginAttemptStore() *InMemoryLoginAttemptStore {
+	return &InMemoryLoginAttemptStore{
+		failures: make(map[string][]time.Time),
+		locks:    make(map[string]time.Time),
+	}
+}
+
+func (s *InMemoryLoginAttemptStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var recent []time.Time
+	for _, at := range s.failures[key] {
+		if at.After(now.Add(-window)) {
+			recent = append(recent, at)
+		}
+	}
+	s.failures[key] = append(recent, now)
+	return len(s.failures[key]), nil
+}
+
+func (s *InMemoryLoginAttemptStore) Failures(ctx context.Context, key string, since time.Time) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int
+	var last time.Time
+	for _, at := range s.failures[key] {
+		if at.After(since) {
+			count++
+			if at.After(last) {
+				last = at
+			}
+		}
+	}
+	return count, last, nil
+}
+
+func (s *InMemoryLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.locks[key] = until
+	delete(s.failures, key)
+	return nil
+}
+
+func (s *InMemoryLoginAttemptStore) LockedUntil(ctx context.Context, key string, now time.Time) (time.Time, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until, found := s.locks[key]
+	if !found {
+		return time.Time{}, false, nil
+	}
+	return until, now.Before(until), nil
+}
+
+func (s *InMemoryLoginAttemptStore) Clear(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failures, key)
+	delete(s.locks, key)
+	return nil
+}
@@ -0,0 +1,190 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrTooManyLoginAttempts is returned, wrapped in a *LoginThrottledError,
+	// when an account or IP address has failed to log in too often. It reads
+	// the same whether or not the account exists.
+	ErrTooManyLoginAttempts = errors.New("too many failed login attempts")
+)
+
+// LoginThrottledError tells the client how long to wait before trying to
+// log in again, either because of a progressive delay or a lockout.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("%s, try again in %s", ErrTooManyLoginAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return ErrTooManyLoginAttempts
+}
+
+// LoginGuardConfig configures the brute-force protection of Login. Zero
+// values use the defaults.
+type LoginGuardConfig struct {
+	// Window is how long a failed attempt counts towards the limits.
+	Window time.Duration
+	// MaxAccountFailures failures on one account within Window lock it for
+	// LockoutDuration.
+	MaxAccountFailures int
+	// MaxIPFailures failures from one IP address, on any accounts, within
+	// Window lock the address out for LockoutDuration. It is higher than
+	// MaxAccountFailures because many users can share an address.
+	MaxIPFailures   int
+	LockoutDuration time.Duration
+	// FreeFailures failures on an account are allowed in a row. After that,
+	// each attempt waits BaseDelay after the last failure, doubling with
+	// every failure up to MaxDelay.
+	FreeFailures int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+}
+
+const (
+	DefaultLoginWindow             = 15 * time.Minute
+	DefaultMaxAccountLoginFailures = 10
+	DefaultMaxIPLoginFailures      = 50
+	DefaultLoginLockoutDuration    = 15 * time.Minute
+	DefaultFreeLoginFailures       = 3
+	DefaultLoginBaseDelay          = time.Second
+	DefaultLoginMaxDelay           = 30 * time.Second
+)
+
+func (c LoginGuardConfig) withDefaults() LoginGuardConfig {
+	if c.Window <= 0 {
+		c.Window = DefaultLoginWindow
+	}
+	if c.MaxAccountFailures <= 0 {
+		c.MaxAccountFailures = DefaultMaxAccountLoginFailures
+	}
+	if c.MaxIPFailures <= 0 {
+		c.MaxIPFailures = DefaultMaxIPLoginFailures
+	}
+	if c.LockoutDuration <= 0 {
+		c.LockoutDuration = DefaultLoginLockoutDuration
+	}
+	if c.FreeFailures <= 0 {
+		c.FreeFailures = DefaultFreeLoginFailures
+	}
+	if c.BaseDelay <= 0 {
+		c.BaseDelay = DefaultLoginBaseDelay
+	}
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = DefaultLoginMaxDelay
+	}
+	return c
+}
+
+// LoginGuard decides whether a login attempt may be tried and keeps count of
+// the failed ones. Accounts are tracked by email, whether or not a user has
+// it, so that the guard does not tell which accounts exist.
+type LoginGuard interface {
+	// Check returns a *LoginThrottledError if the attempt must wait.
+	Check(ctx context.Context, email, ip string, now time.Time) error
+	// Fail records a failed attempt. It returns when the account is locked
+	// until if this failure locked it, and the zero time otherwise.
+	Fail(ctx context.Context, email, ip string, now time.Time) (time.Time, error)
+	// Succeed forgets the account's failures. Those of the IP address stay,
+	// so that one working login does not reset an attack from it.
+	Succeed(ctx context.Context, email string) error
+	// Unlock lifts the account's lockout and forgets its failures.
+	Unlock(ctx context.Context, email string) error
+}
+
+type loginGuard struct {
+	attempts LoginAttemptStore
+	config   LoginGuardConfig
+}
+
+// NewLoginGuard creates a LoginGuard that keeps its counters in attempts.
+func NewLoginGuard(attempts LoginAttemptStore, config LoginGuardConfig) LoginGuard {
+	return &loginGuard{attempts: attempts, config: config.withDefaults()}
+}
+
+func (g *loginGuard) Check(ctx context.Context, email, ip string, now time.Time) error {
+	for _, key := range loginAttemptKeys(email, ip) {
+		until, locked, err := g.attempts.LockedUntil(ctx, key, now)
+		if err != nil {
+			return err
+		}
+		if locked {
+			return &LoginThrottledError{RetryAfter: until.Sub(now)}
+		}
+	}
+
+	failures, last, err := g.attempts.Failures(ctx, accountAttemptKey(email), now.Add(-g.config.Window))
+	if err != nil {
+		return err
+	}
+	if wait := last.Add(g.delay(failures)).Sub(now); wait > 0 {
+		return &LoginThrottledError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// delay is how long to wait after the last of failures before trying again.
+func (g *loginGuard) delay(failures int) time.Duration {
+	if failures < g.config.FreeFailures {
+		return 0
+	}
+	delay := g.config.BaseDelay
+	for i := g.config.FreeFailures; i < failures && delay < g.config.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, g.config.MaxDelay)
+}
+
+func (g *loginGuard) Fail(ctx context.Context, email, ip string, now time.Time) (time.Time, error) {
+	var accountLockedUntil time.Time
+	for _, key := range loginAttemptKeys(email, ip) {
+		failures, err := g.attempts.RecordFailure(ctx, key, now, g.config.Window)
+		if err != nil {
+			return time.Time{}, err
+		}
+		limit := g.config.MaxAccountFailures
+		if key != accountAttemptKey(email) {
+			limit = g.config.MaxIPFailures
+		}
+		if failures < limit {
+			continue
+		}
+		until := now.Add(g.config.LockoutDuration)
+		if err := g.attempts.Lock(ctx, key, until); err != nil {
+			return time.Time{}, err
+		}
+		if key == accountAttemptKey(email) {
+			accountLockedUntil = until
+		}
+	}
+	return accountLockedUntil, nil
+}
+
+func (g *loginGuard) Succeed(ctx context.Context, email string) error {
+	return g.attempts.Clear(ctx, accountAttemptKey(email))
+}
+
+func (g *loginGuard) Unlock(ctx context.Context, email string) error {
+	return g.attempts.Clear(ctx, accountAttemptKey(email))
+}
+
+func loginAttemptKeys(email, ip string) []string {
+	keys := []string{accountAttemptKey(email)}
+	if ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+	return keys
+}
+
+func accountAttemptKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,361 @@
+package user
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrOIDCProviderUnavailable is returned, wrapped, when a provider cannot
+	// be reached or answers with something that is not OpenID Connect.
+	ErrOIDCProviderUnavailable = errors.New("identity provider is unavailable")
+	// ErrOIDCCodeRejected is returned when the provider does not accept an
+	// authorization code, e.g. because it was used already.
+	ErrOIDCCodeRejected = errors.New("identity provider rejected the authorization code")
+	// ErrInvalidIDToken is returned, wrapped, when an ID token is not signed by
+	// the provider, not meant for us, expired or from another login.
+	ErrInvalidIDToken = errors.New("ID token is invalid")
+)
+
+// DefaultOIDCScopes ask for the user's email and name.
+var DefaultOIDCScopes = []string{"openid", "email", "profile"}
+
+// oidcClockSkew is how far the provider's clock may be off from ours.
+const oidcClockSkew = time.Minute
+
+// OIDCProviderConfig configures an OpenID Connect provider, e.g. Google.
+type OIDCProviderConfig struct {
+	// Name identifies the provider in URLs and linked identities, e.g. "google".
+	Name string
+	// Issuer is the provider's issuer URL; its discovery document is read
+	// from Issuer + "/.well-known/openid-configuration".
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is our callback, registered with the provider.
+	RedirectURL string
+	// Scopes default to DefaultOIDCScopes.
+	Scopes []string
+	// ResponseMode is sent as response_mode if set. Apple needs "form_post"
+	// to release the user's email, and then POSTs to the callback.
+	ResponseMode string
+}
+
+// OIDCIdentity is who a provider says signed in.
+type OIDCIdentity struct {
+	// Subject is the user's ID at the provider; it never changes.
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// OIDCProvider signs users in with the authorization code flow and PKCE.
+type OIDCProvider interface {
+	Name() string
+	// AuthCodeURL returns where to send the user to sign in. The provider
+	// sends them back to the redirect URL with state and a code.
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	// Exchange redeems a code for an ID token and returns the identity in it,
+	// once the token is verified to be from this login.
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*OIDCIdentity, error)
+}
+
+type oidcProvider struct {
+	config OIDCProviderConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]crypto.PublicKey
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewOIDCProvider creates an OIDCProvider. Its discovery document and
+// signing keys are fetched on first use, so that a provider being down does
+// not stop the server from starting. client defaults to http.DefaultClient.
+func NewOIDCProvider(config OIDCProviderConfig, client *http.Client) OIDCProvider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = DefaultOIDCScopes
+	}
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &oidcProvider{config: config, client: client}
+}
+
+func (p *oidcProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	if p.config.ResponseMode != "" {
+		query.Set("response_mode", p.config.ResponseMode)
+	}
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*OIDCIdentity, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCProviderUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("%w: token response: %v", ErrOIDCProviderUnavailable, err)
+	}
+	switch {
+	case body.Error == "invalid_grant":
+		return nil, ErrOIDCCodeRejected
+	case resp.StatusCode != http.StatusOK || body.IDToken == "":
+		return nil, fmt.Errorf("%w: token endpoint answered %d %s", ErrOIDCProviderUnavailable, resp.StatusCode, body.Error)
+	}
+	return p.verifyIDToken(ctx, discovery, body.IDToken, nonce)
+}
+
+// idTokenClaims are the ID token claims we use. Apple sends email_verified
+// as a string.
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	AuthorizedParty string    `json:"azp"`
+	Nonce           string    `json:"nonce"`
+	Email           string    `json:"email"`
+	EmailVerified   claimBool `json:"email_verified"`
+	Name            string    `json:"name"`
+}
+
+type claimBool bool
+
+func (b *claimBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	case "false", "null":
+		*b = false
+	default:
+		return fmt.Errorf("invalid boolean claim %s", data)
+	}
+	return nil
+}
+
+func (p *oidcProvider) verifyIDToken(ctx context.Context, discovery *oidcDiscovery, idToken, nonce string) (*OIDCIdentity, error) {
+	var claims idTokenClaims
+	_, err := jwt.ParseWithClaims(idToken, &claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.signingKey(ctx, discovery, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(oidcClockSkew),
+	)
+	if err != nil {
+		if errors.Is(err, ErrOIDCProviderUnavailable) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	// A token for several clients must have been issued to us.
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID {
+		return nil, fmt.Errorf("%w: issued to %q", ErrInvalidIDToken, claims.AuthorizedParty)
+	}
+	// The nonce ties the token to the login that asked for it.
+	if claims.Nonce == "" || claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce does not match", ErrInvalidIDToken)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+	return &OIDCIdentity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+// discover fetches the provider's discovery document once.
+func (p *oidcProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := p.getJSON(ctx, p.config.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	if discovery.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("%w: discovery document is for issuer %q", ErrOIDCProviderUnavailable, discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: discovery document is incomplete", ErrOIDCProviderUnavailable)
+	}
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// signingKey returns the provider's key with the key ID. The keys are
+// fetched again when kid is unknown, since providers rotate them.
+func (p *oidcProvider) signingKey(ctx context.Context, discovery *oidcDiscovery, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, found := p.keys[kid]; found {
+		return key, nil
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Keys we cannot use are skipped; tokens signed with them fail below.
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.KeyID] = key
+		}
+	}
+	p.keys = keys
+	if key, found := keys[kid]; found {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (p *oidcProvider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrOIDCProviderUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: GET %s answered %d", ErrOIDCProviderUnavailable, url, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: GET %s: %v", ErrOIDCProviderUnavailable, url, err)
+	}
+	return nil
+}
+
+// jsonWebKey is a public key from a JWKS document (RFC 7517).
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Curve != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			return nil, errors.New("invalid EC key")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("EC key is not on its curve")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+}
+
+// pkceChallenge returns the S256 code challenge of a PKCE code verifier (RFC 7636).
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
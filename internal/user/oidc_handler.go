@@ -0,0 +1,96 @@
+package user
+
+import (
+	"errors"
+	"minimart/internal/shared/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type OIDCHandler struct {
+	usecase OIDCUsecase
+}
+
+func NewOIDCHandler(usecase OIDCUsecase) *OIDCHandler {
+	return &OIDCHandler{usecase: usecase}
+}
+
+func (h *OIDCHandler) RegisterRoutes(app *fiber.App) {
+	app.Get("/auth/oidc/providers", h.Providers)
+	app.Get("/auth/oidc/:provider/login", h.Login)
+	// Providers using response_mode=form_post, such as Apple, POST the callback
+	app.Get("/auth/oidc/:provider/callback", h.Callback)
+	app.Post("/auth/oidc/:provider/callback", h.Callback)
+}
+
+// Providers lists the providers users can sign in with.
+func (h *OIDCHandler) Providers(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"providers": h.usecase.Providers()})
+}
+
+// Login sends the user to the provider to sign in.
+func (h *OIDCHandler) Login(c *fiber.Ctx) error {
+	authURL, err := h.usecase.BeginLogin(c.Context(), c.Params("provider"))
+	if err != nil {
+		return oidcErrorResponse(c, err)
+	}
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+type oidcCallbackRequest struct {
+	Code  string
+	State string
+	// Error is set instead of Code when the user did not sign in, e.g.
+	// "access_denied".
+	Error string
+}
+
+func (r oidcCallbackRequest) Validate() error {
+	var v validation.Validator
+	v.Required("code", r.Code)
+	v.Required("state", r.State)
+	return v.Err()
+}
+
+// Callback is where the provider sends the user back to. It answers with the
+// same tokens as Login.
+func (h *OIDCHandler) Callback(c *fiber.Ctx) error {
+	// FormValue reads the query string as well as a POSTed form.
+	req := oidcCallbackRequest{
+		Code:  c.FormValue("code"),
+		State: c.FormValue("state"),
+		Error: c.FormValue("error"),
+	}
+	if req.Error != "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Sign-in failed at the identity provider: " + req.Error})
+	}
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
+
+	tokens, err := h.usecase.CompleteLogin(c.Context(), c.Params("provider"), req.State, req.Code)
+	if err != nil {
+		return oidcErrorResponse(c, err)
+	}
+	return c.JSON(tokens)
+}
+
+// oidcErrorResponse maps the errors of OIDC logins to HTTP responses.
+func oidcErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrUnknownOIDCProvider):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrInvalidOIDCState):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrOIDCCodeRejected), errors.Is(err, ErrInvalidIDToken):
+		// Why a token was refused is no business of the client
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Could not sign in with the identity provider"})
+	case errors.Is(err, ErrOIDCEmailNotVerified):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrEmailTaken):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrOIDCProviderUnavailable):
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": ErrOIDCProviderUnavailable.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not sign in"})
+}
@@ -0,0 +1,107 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// OIDCLogin is what we remember about a login between sending the user to
+// the provider and them coming back.
+type OIDCLogin struct {
+	Provider     string    `json:"provider"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// OIDCStateStore keeps pending logins by their state parameter.
+type OIDCStateStore interface {
+	SaveOIDCLogin(ctx context.Context, state string, login *OIDCLogin) error
+	// TakeOIDCLogin returns the login with the state and forgets it, so that
+	// each state is used once. It returns ErrInvalidOIDCState if there is no
+	// such login or it has expired.
+	TakeOIDCLogin(ctx context.Context, state string, now time.Time) (*OIDCLogin, error)
+}
+
+// RedisOIDCStateStore is an OIDCStateStore shared by every server instance,
+// so that the callback may reach another instance than the login did.
+type RedisOIDCStateStore struct {
+	client *redis.Client
+}
+
+// NewRedisOIDCStateStore creates a new RedisOIDCStateStore.
+func NewRedisOIDCStateStore(client *redis.Client) OIDCStateStore {
+	return &RedisOIDCStateStore{client: client}
+}
+
+func (s *RedisOIDCStateStore) SaveOIDCLogin(ctx context.Context, state string, login *OIDCLogin) error {
+	value, err := json.Marshal(login)
+	if err != nil {
+		return err
+	}
+	ttl := time.Until(login.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return s.client.Set(ctx, oidcStateKey(state), value, ttl).Err()
+}
+
+func (s *RedisOIDCStateStore) TakeOIDCLogin(ctx context.Context, state string, now time.Time) (*OIDCLogin, error) {
+	value, err := s.client.GetDel(ctx, oidcStateKey(state)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrInvalidOIDCState
+		}
+		return nil, err
+	}
+	var login OIDCLogin
+	if err := json.Unmarshal(value, &login); err != nil {
+		return nil, err
+	}
+	if !now.Before(login.ExpiresAt) {
+		return nil, ErrInvalidOIDCState
+	}
+	return &login, nil
+}
+
+// oidcStateKey keys a login by the hash of its state, which is as good as a
+// password until the login completes.
+func oidcStateKey(state string) string {
+	return "oidc:state:" + hashSecretToken(state)
+}
+
+// InMemoryOIDCStateStore is an OIDCStateStore for tests and single-instance
+// setups.
+type InMemoryOIDCStateStore struct {
+	mu     sync.Mutex
+	logins map[string]OIDCLogin
+}
+
+// NewInMemoryOIDCStateStore creates a new InMemoryOIDCStateStore.
+func NewInMemoryOIDCStateStore() *InMemoryOIDCStateStore {
+	return &InMemoryOIDCStateStore{logins: make(map[string]OIDCLogin)}
+}
+
+func (s *InMemoryOIDCStateStore) SaveOIDCLogin(ctx context.Context, state string, login *OIDCLogin) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logins[oidcStateKey(state)] = *login
+	return nil
+}
+
+func (s *InMemoryOIDCStateStore) TakeOIDCLogin(ctx context.Context, state string, now time.Time) (*OIDCLogin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := oidcStateKey(state)
+	login, found := s.logins[key]
+	delete(s.logins, key)
+	if !found || !now.Before(login.ExpiresAt) {
+		return nil, ErrInvalidOIDCState
+	}
+	return &login, nil
+}
@@ -0,0 +1,300 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"minimart/internal/shared/eventbus"
+	middlerware "minimart/internal/shared/middleware"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	mockOIDCClientID     = "minimart"
+	mockOIDCClientSecret = "mock-secret"
+	mockOIDCRedirectURL  = "http://minimart.test/auth/oidc/mock/callback"
+)
+
+// mockOIDCProvider is a local OpenID Connect provider. Its authorization
+// endpoint signs in whoever SignInAs set last, without asking.
+type mockOIDCProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu       sync.Mutex
+	identity OIDCIdentity
+	audience string
+	codes    map[string]mockAuthorization
+}
+
+type mockAuthorization struct {
+	challenge string
+	nonce     string
+	identity  OIDCIdentity
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	mock := &mockOIDCProvider{key: key, audience: mockOIDCClientID, codes: map[string]mockAuthorization{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 mock.server.URL,
+			"authorization_endpoint": mock.server.URL + "/authorize",
+			"token_endpoint":         mock.server.URL + "/token",
+			"jwks_uri":               mock.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "mock-key",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("GET /authorize", mock.authorize)
+	mux.HandleFunc("POST /token", mock.token)
+	mock.server = httptest.NewServer(mux)
+	t.Cleanup(mock.server.Close)
+	return mock
+}
+
+// SignInAs makes the identity the one that signs in next.
+func (m *mockOIDCProvider) SignInAs(identity OIDCIdentity) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.identity = identity
+}
+
+// IssueTo makes the provider issue ID tokens for another client.
+func (m *mockOIDCProvider) IssueTo(audience string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.audience = audience
+}
+
+func (m *mockOIDCProvider) Config() OIDCProviderConfig {
+	return OIDCProviderConfig{
+		Name:         "mock",
+		Issuer:       m.server.URL,
+		ClientID:     mockOIDCClientID,
+		ClientSecret: mockOIDCClientSecret,
+		RedirectURL:  mockOIDCRedirectURL,
+	}
+}
+
+func (m *mockOIDCProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != mockOIDCClientID || query.Get("redirect_uri") != mockOIDCRedirectURL ||
+		query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	code, err := newSecretToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	m.mu.Lock()
+	m.codes[code] = mockAuthorization{challenge: query.Get("code_challenge"), nonce: query.Get("nonce"), identity: m.identity}
+	m.mu.Unlock()
+
+	callback := url.Values{"code": {code}, "state": {query.Get("state")}}
+	http.Redirect(w, r, mockOIDCRedirectURL+"?"+callback.Encode(), http.StatusFound)
+}
+
+func (m *mockOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tokenError := func(code string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": code})
+	}
+	if r.PostFormValue("client_id") != mockOIDCClientID || r.PostFormValue("client_secret") != mockOIDCClientSecret {
+		tokenError("invalid_client")
+		return
+	}
+	code := r.PostFormValue("code")
+	authorization, found := m.codes[code]
+	delete(m.codes, code)
+	if !found || r.PostFormValue("redirect_uri") != mockOIDCRedirectURL ||
+		pkceChallenge(r.PostFormValue("code_verifier")) != authorization.challenge {
+		tokenError("invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            m.server.URL,
+		"aud":            m.audience,
+		"sub":            authorization.identity.Subject,
+		"email":          authorization.identity.Email,
+		"email_verified": authorization.identity.EmailVerified,
+		"name":           authorization.identity.Name,
+		"nonce":          authorization.nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	})
+	idToken.Header["kid"] = "mock-key"
+	signed, err := idToken.SignedString(m.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "mock-access-token", "token_type": "Bearer", "id_token": signed})
+}
+
+// followOIDCLogin signs in at the provider as its browser would and returns
+// the state and code it sends back.
+func followOIDCLogin(t *testing.T, authURL string) (state, code string) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return callback.Query().Get("state"), callback.Query().Get("code")
+}
+
+func TestOIDCUsecase(t *testing.T) {
+	ctx := context.Background()
+	mock := newMockOIDCProvider(t)
+	users := NewInMemoryUserRepository()
+	bus := eventbus.NewInMemoryEventBus()
+	userUsecase := NewUserUsecase(users, NewInMemoryRefreshTokenRepository(), NewInMemoryEmailVerificationRepository(), newTestLoginGuard(), middlerware.NewInMemoryTokenDenylist(), testKeys, bus, TokenConfig{})
+	usecase := NewOIDCUsecase([]OIDCProvider{NewOIDCProvider(mock.Config(), nil)}, NewInMemoryOIDCStateStore(), NewInMemoryExternalIdentityRepository(), users, userUsecase, bus)
+
+	created := make(chan UserCreatedEvent, 10)
+	require.NoError(t, bus.Subscribe(UserCreatedTopic, func(ctx context.Context, event eventbus.Event) error {
+		created <- event.(UserCreatedEvent)
+		return nil
+	}))
+	signIn := func(identity OIDCIdentity) (*TokenPair, error) {
+		mock.SignInAs(identity)
+		authURL, err := usecase.BeginLogin(ctx, "mock")
+		require.NoError(t, err)
+		state, code := followOIDCLogin(t, authURL)
+		return usecase.CompleteLogin(ctx, "mock", state, code)
+	}
+	userOf := func(tokens *TokenPair) *User {
+		claims := &middlerware.Claims{}
+		require.NoError(t, testKeys.Parse(tokens.Token, claims))
+		user, err := users.FindByEmail(ctx, claims.Email)
+		require.NoError(t, err)
+		return user
+	}
+
+	t.Run("should create a verified user on first sign-in", func(t *testing.T) {
+		tokens, err := signIn(OIDCIdentity{Subject: "jane-1", Email: "jane@example.com", EmailVerified: true, Name: "Jane"})
+		require.NoError(t, err)
+		jane := userOf(tokens)
+		assert.Equal(t, "Jane", jane.Name)
+		assert.True(t, jane.EmailVerified())
+		select {
+		case event := <-created:
+			assert.Equal(t, jane.ID.String(), event.UserID)
+			assert.Equal(t, "mock", event.IdentityProvider)
+			assert.Empty(t, event.VerificationToken)
+		case <-time.After(time.Second):
+			t.Fatal("no user created event published")
+		}
+
+		// The subject, not the email, identifies the user from then on
+		tokens, err = signIn(OIDCIdentity{Subject: "jane-1", Email: "jane@example.org", EmailVerified: true})
+		require.NoError(t, err)
+		assert.Equal(t, jane.ID, userOf(tokens).ID)
+
+		_, err = userUsecase.Login(ctx, "jane@example.com", "", testClientIP)
+		assert.ErrorIs(t, err, ErrInvalidCredentials, "users from a provider have no password")
+	})
+
+	t.Run("should link an existing user by verified email", func(t *testing.T) {
+		john, err := userUsecase.RegisterUser(ctx, "John", "john@example.com", "password")
+		require.NoError(t, err)
+		<-created
+		verifiedAt := time.Now()
+		john.EmailVerifiedAt = &verifiedAt
+
+		_, err = signIn(OIDCIdentity{Subject: "john-1", Email: "john@example.com", EmailVerified: false})
+		assert.ErrorIs(t, err, ErrOIDCEmailNotVerified)
+
+		tokens, err := signIn(OIDCIdentity{Subject: "john-1", Email: "john@example.com", EmailVerified: true})
+		require.NoError(t, err)
+		assert.Equal(t, john.ID, userOf(tokens).ID)
+		assert.Empty(t, created, "no user is created")
+		_, err = userUsecase.Login(ctx, "john@example.com", "password", testClientIP)
+		assert.NoError(t, err, "the password still works")
+	})
+
+	t.Run("should take an unverified user from whoever registered it", func(t *testing.T) {
+		_, err := userUsecase.RegisterUser(ctx, "Squatter", "mary@example.com", "password")
+		require.NoError(t, err)
+		<-created
+
+		tokens, err := signIn(OIDCIdentity{Subject: "mary-1", Email: "mary@example.com", EmailVerified: true})
+		require.NoError(t, err)
+		assert.True(t, userOf(tokens).EmailVerified())
+		_, err = userUsecase.Login(ctx, "mary@example.com", "password", testClientIP)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("should accept each login once and only from its provider", func(t *testing.T) {
+		mock.SignInAs(OIDCIdentity{Subject: "paul-1", Email: "paul@example.com", EmailVerified: true})
+		authURL, err := usecase.BeginLogin(ctx, "mock")
+		require.NoError(t, err)
+		state, code := followOIDCLogin(t, authURL)
+
+		_, err = usecase.CompleteLogin(ctx, "other", state, code)
+		assert.ErrorIs(t, err, ErrUnknownOIDCProvider)
+		_, err = usecase.CompleteLogin(ctx, "mock", "forged-state", code)
+		assert.ErrorIs(t, err, ErrInvalidOIDCState)
+		_, err = usecase.CompleteLogin(ctx, "mock", state, code)
+		require.NoError(t, err)
+		_, err = usecase.CompleteLogin(ctx, "mock", state, code)
+		assert.ErrorIs(t, err, ErrInvalidOIDCState)
+		<-created
+
+		_, err = usecase.BeginLogin(ctx, "other")
+		assert.ErrorIs(t, err, ErrUnknownOIDCProvider)
+	})
+
+	t.Run("should not create a user when looking up the email fails", func(t *testing.T) {
+		unreachable := NewOIDCUsecase([]OIDCProvider{NewOIDCProvider(mock.Config(), nil)}, NewInMemoryOIDCStateStore(), NewInMemoryExternalIdentityRepository(), unreachableUserRepository{users}, userUsecase, bus)
+		mock.SignInAs(OIDCIdentity{Subject: "lost-1", Email: "lost@example.com", EmailVerified: true})
+		authURL, err := unreachable.BeginLogin(ctx, "mock")
+		require.NoError(t, err)
+		state, code := followOIDCLogin(t, authURL)
+
+		_, err = unreachable.CompleteLogin(ctx, "mock", state, code)
+		require.Error(t, err)
+		_, err = users.FindByEmail(ctx, "lost@example.com")
+		assert.ErrorIs(t, err, ErrUserNotFound)
+		assert.Empty(t, created)
+	})
+
+	t.Run("should refuse ID tokens issued to another client", func(t *testing.T) {
+		mock.IssueTo("someone-else")
+		defer mock.IssueTo(mockOIDCClientID)
+
+		_, err := signIn(OIDCIdentity{Subject: "anna-1", Email: "anna@example.com", EmailVerified: true})
+		assert.ErrorIs(t, err, ErrInvalidIDToken)
+	})
+}
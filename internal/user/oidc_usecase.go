@@ -0,0 +1,240 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"minimart/internal/shared/eventbus"
+	middlerware "minimart/internal/shared/middleware"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OIDCLoginTTL is how long a user has to sign in at the provider.
+const OIDCLoginTTL = 10 * time.Minute
+
+var (
+	ErrUnknownOIDCProvider = errors.New("unknown identity provider")
+	// ErrInvalidOIDCState is returned when a callback does not belong to a
+	// login we started, or the login took too long.
+	ErrInvalidOIDCState = errors.New("login state is invalid or expired")
+	// ErrOIDCEmailNotVerified is returned when a provider has not verified the
+	// email of a user we do not know yet, so we cannot tell whose it is.
+	ErrOIDCEmailNotVerified = errors.New("identity provider has not verified the email address")
+)
+
+// OIDCUsecase signs users in with OpenID Connect providers.
+type OIDCUsecase interface {
+	// Providers returns the names of the configured providers.
+	Providers() []string
+	// BeginLogin starts a login with the provider and returns where to send
+	// the user.
+	BeginLogin(ctx context.Context, provider string) (string, error)
+	// CompleteLogin finishes a login with the state and code the provider
+	// sent the user back with, and starts a session. Users are found by the
+	// identity they signed in with, or else by its email if the provider
+	// verified it; new emails get a new user.
+	CompleteLogin(ctx context.Context, provider, state, code string) (*TokenPair, error)
+}
+
+// SessionStarter starts and ends sessions, normally UserUsecase.
+type SessionStarter interface {
+	SessionRevoker
+	StartSession(ctx context.Context, user *User) (*TokenPair, error)
+}
+
+type oidcUsecase struct {
+	providers  map[string]OIDCProvider
+	states     OIDCStateStore
+	identities ExternalIdentityRepository
+	users      UserRepository
+	sessions   SessionStarter
+	eventBus   eventbus.EventBus
+	now        func() time.Time
+}
+
+func NewOIDCUsecase(providers []OIDCProvider, states OIDCStateStore, identities ExternalIdentityRepository, users UserRepository, sessions SessionStarter, eventBus eventbus.EventBus) OIDCUsecase {
+	byName := make(map[string]OIDCProvider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+	}
+	return &oidcUsecase{
+		providers:  byName,
+		states:     states,
+		identities: identities,
+		users:      users,
+		sessions:   sessions,
+		eventBus:   eventBus,
+		now:        time.Now,
+	}
+}
+
+func (u *oidcUsecase) Providers() []string {
+	return slices.Sorted(maps.Keys(u.providers))
+}
+
+func (u *oidcUsecase) BeginLogin(ctx context.Context, providerName string) (string, error) {
+	provider, found := u.providers[providerName]
+	if !found {
+		return "", ErrUnknownOIDCProvider
+	}
+	state, err := newSecretToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := newSecretToken()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := newSecretToken()
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, pkceChallenge(verifier))
+	if err != nil {
+		return "", err
+	}
+	login := &OIDCLogin{
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    u.now().Add(OIDCLoginTTL),
+	}
+	if err := u.states.SaveOIDCLogin(ctx, state, login); err != nil {
+		return "", err
+	}
+	return authURL, nil
+}
+
+func (u *oidcUsecase) CompleteLogin(ctx context.Context, providerName, state, code string) (*TokenPair, error) {
+	provider, found := u.providers[providerName]
+	if !found {
+		return nil, ErrUnknownOIDCProvider
+	}
+	login, err := u.states.TakeOIDCLogin(ctx, state, u.now())
+	if err != nil {
+		return nil, err
+	}
+	if login.Provider != providerName {
+		return nil, ErrInvalidOIDCState
+	}
+	identity, err := provider.Exchange(ctx, code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := u.findUser(ctx, providerName, identity)
+	if err != nil {
+		return nil, err
+	}
+	return u.sessions.StartSession(ctx, user)
+}
+
+// findUser returns the user the identity belongs to, linking or creating
+// one the first time it signs in.
+func (u *oidcUsecase) findUser(ctx context.Context, providerName string, identity *OIDCIdentity) (*User, error) {
+	linked, err := u.identities.FindExternalIdentity(ctx, providerName, identity.Subject)
+	switch {
+	case err == nil:
+		user, err := u.users.FindByID(ctx, linked.UserID)
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, ErrUserNotFound) {
+			return nil, err
+		}
+		// The user was deleted; the identity is free to sign up again.
+	case !errors.Is(err, ErrExternalIdentityNotFound):
+		return nil, err
+	}
+
+	// Anyone can claim any email at some providers; only verified ones say
+	// whose account this is.
+	email := strings.TrimSpace(identity.Email)
+	if email == "" || !identity.EmailVerified {
+		return nil, ErrOIDCEmailNotVerified
+	}
+	now := u.now()
+	user, err := u.users.FindByEmail(ctx, email)
+	switch {
+	case err == nil:
+		if err := u.claimUnverifiedUser(ctx, user, now); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, ErrUserNotFound):
+		if user, err = u.createUser(ctx, providerName, identity, email, now); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	err = u.identities.LinkExternalIdentity(ctx, &ExternalIdentity{
+		Provider:  providerName,
+		Subject:   identity.Subject,
+		UserID:    user.ID,
+		Email:     email,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// claimUnverifiedUser hands a user who never verified their email to the
+// provider's user, who has. Whoever registered it may not own the email, so
+// their password stops working and their sessions end.
+func (u *oidcUsecase) claimUnverifiedUser(ctx context.Context, user *User, now time.Time) error {
+	if user.EmailVerified() {
+		return nil
+	}
+	if err := u.users.UpdatePassword(ctx, user.ID, ""); err != nil {
+		return err
+	}
+	if err := u.sessions.LogoutEverywhere(ctx, user.ID); err != nil {
+		return err
+	}
+	if _, err := u.users.MarkEmailVerified(ctx, user.ID, user.Email, now); err != nil {
+		return err
+	}
+	user.Password = ""
+	user.EmailVerifiedAt = &now
+	return nil
+}
+
+// createUser signs up the provider's user. They have no password until they
+// reset it, and their email is verified already.
+func (u *oidcUsecase) createUser(ctx context.Context, providerName string, identity *OIDCIdentity, email string, now time.Time) (*User, error) {
+	name := strings.TrimSpace(identity.Name)
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+	user := &User{
+		ID:              uuid.New(),
+		Name:            name,
+		Email:           email,
+		Role:            middlerware.RoleCustomer,
+		EmailVerifiedAt: &now,
+		CreatedAt:       now,
+	}
+	if err := u.users.Save(ctx, user); err != nil {
+		return nil, err
+	}
+
+	event := UserCreatedEvent{
+		UserID:           user.ID.String(),
+		Name:             user.Name,
+		Email:            user.Email,
+		IdentityProvider: providerName,
+		CreatedAt:        now,
+	}
+	if err := u.eventBus.Publish(ctx, event); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
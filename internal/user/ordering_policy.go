@@ -0,0 +1,42 @@
+package user
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// OrderingPolicy decides whether a customer may place orders. It is
+// configured once for the whole platform.
+type OrderingPolicy struct {
+	users                UserRepository
+	requireVerifiedEmail bool
+}
+
+// NewOrderingPolicy creates an OrderingPolicy. With requireVerifiedEmail,
+// only customers who verified their email may place orders; otherwise anyone may.
+func NewOrderingPolicy(users UserRepository, requireVerifiedEmail bool) *OrderingPolicy {
+	return &OrderingPolicy{users: users, requireVerifiedEmail: requireVerifiedEmail}
+}
+
+// CanPlaceOrders returns ErrEmailNotVerified if the policy requires a verified
+// email the customer does not have. Failing to look the customer up is
+// returned as is.
+func (p *OrderingPolicy) CanPlaceOrders(ctx context.Context, customerID uuid.UUID) error {
+	if !p.requireVerifiedEmail {
+		return nil
+	}
+	customer, err := p.users.FindByID(ctx, customerID)
+	if errors.Is(err, ErrUserNotFound) {
+		// Unknown customers cannot have verified anything.
+		return ErrEmailNotVerified
+	}
+	if err != nil {
+		return err
+	}
+	if !customer.EmailVerified() {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultPasswordResetTTL = time.Hour
+
+	// PasswordResetWindow is how long a password reset request counts towards
+	// the limits below. Reaching a limit blocks further requests for as long.
+	PasswordResetWindow = time.Hour
+	// MaxPasswordResetsPerEmail is how many resets one email may be sent
+	// within PasswordResetWindow, whether or not an account has it.
+	MaxPasswordResetsPerEmail = 5
+	// MaxPasswordResetsPerIP is how many resets one IP address may request
+	// within PasswordResetWindow, for any emails.
+	MaxPasswordResetsPerIP = 20
+)
+
+var (
+	ErrInvalidResetToken = errors.New("password reset token is invalid or expired")
+	// ErrTooManyPasswordResets is returned, wrapped in a
+	// *PasswordResetThrottledError, when an email or IP address has requested
+	// too many password resets.
+	ErrTooManyPasswordResets = errors.New("too many password reset requests")
+)
+
+// PasswordResetThrottledError tells the client how long to wait before
+// requesting another password reset.
+type PasswordResetThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *PasswordResetThrottledError) Error() string {
+	return fmt.Sprintf("%s, try again in %s", ErrTooManyPasswordResets, e.RetryAfter.Round(time.Second))
+}
+
+func (e *PasswordResetThrottledError) Unwrap() error {
+	return ErrTooManyPasswordResets
+}
+
+// PasswordReset lets the holder of its token choose a new password once.
+// Only a hash of the token is stored.
+type PasswordReset struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	// UsedAt is set once the token was used, or another of the user's tokens was.
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// newPasswordReset creates a password reset and returns it with the token to
+// email the user.
+func newPasswordReset(userID uuid.UUID, now time.Time, ttl time.Duration) (*PasswordReset, string, error) {
+	token, err := newSecretToken()
+	if err != nil {
+		return nil, "", err
+	}
+	return &PasswordReset{
+		ID:        uuid.New(),
+		UserID:    userID,
+		TokenHash: HashPasswordResetToken(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}, token, nil
+}
+
+// HashPasswordResetToken returns the hash password reset tokens are stored and looked up by.
+func HashPasswordResetToken(token string) string {
+	return hashSecretToken(token)
+}
+
+// Usable reports whether the token may be used at now.
+func (r *PasswordReset) Usable(now time.Time) bool {
+	return r.UsedAt == nil && now.Before(r.ExpiresAt)
+}
@@ -0,0 +1,87 @@
+package user
+
+import (
+	"errors"
+	"minimart/internal/shared/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PasswordResetHandler struct {
+	usecase   PasswordResetUsecase
+	passwords validation.PasswordPolicy
+}
+
+// NewPasswordResetHandler creates a new PasswordResetHandler. New passwords
+// must meet passwords.
+func NewPasswordResetHandler(usecase PasswordResetUsecase, passwords validation.PasswordPolicy) *PasswordResetHandler {
+	return &PasswordResetHandler{usecase: usecase, passwords: passwords}
+}
+
+func (h *PasswordResetHandler) RegisterRoutes(app *fiber.App) {
+	app.Post("users/password/forgot", h.ForgotPassword)
+	app.Post("users/password/reset", h.ResetPassword)
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+func (r forgotPasswordRequest) Validate() error {
+	var v validation.Validator
+	v.Required("email", r.Email)
+	v.Email("email", r.Email)
+	return v.Err()
+}
+
+// ForgotPassword emails a reset link. The response is the same whether or not
+// the email belongs to an account.
+func (h *PasswordResetHandler) ForgotPassword(c *fiber.Ctx) error {
+	var req forgotPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
+	if err := h.usecase.ForgotPassword(c.Context(), req.Email, c.IP()); err != nil {
+		var throttled *PasswordResetThrottledError
+		if errors.As(err, &throttled) {
+			return tooManyRequests(c, err, throttled.RetryAfter)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not start password reset"})
+	}
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "If an account uses this email, a password reset link has been sent to it",
+	})
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (r resetPasswordRequest) Validate(passwords validation.PasswordPolicy) error {
+	var v validation.Validator
+	v.Required("token", r.Token)
+	v.Password("password", r.Password, passwords)
+	return v.Err()
+}
+
+// ResetPassword sets a new password with the token from the reset email.
+func (h *PasswordResetHandler) ResetPassword(c *fiber.Ctx) error {
+	var req resetPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if err := req.Validate(h.passwords); err != nil {
+		return validation.Respond(c, err)
+	}
+	if err := h.usecase.ResetPassword(c.Context(), req.Token, req.Password); err != nil {
+		if errors.Is(err, ErrInvalidResetToken) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not reset password"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
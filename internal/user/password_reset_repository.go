@@ -0,0 +1,64 @@
+package user
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PasswordResetRepository interface {
+	SavePasswordReset(ctx context.Context, reset *PasswordReset) error
+	// FindPasswordReset returns the reset with the token hash, or ErrInvalidResetToken.
+	FindPasswordReset(ctx context.Context, tokenHash string) (*PasswordReset, error)
+	// UsePasswordResets marks every unused reset of the user as used, so a
+	// reset link cannot be used once another one has been. It returns false if
+	// the reset with id was used in the meantime.
+	UsePasswordResets(ctx context.Context, userID, id uuid.UUID, usedAt time.Time) (bool, error)
+}
+
+type InMemoryPasswordResetRepository struct {
+	mu     sync.Mutex
+	resets map[uuid.UUID]*PasswordReset
+}
+
+func NewInMemoryPasswordResetRepository() *InMemoryPasswordResetRepository {
+	return &InMemoryPasswordResetRepository{resets: map[uuid.UUID]*PasswordReset{}}
+}
+
+func (r *InMemoryPasswordResetRepository) SavePasswordReset(ctx context.Context, reset *PasswordReset) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *reset
+	r.resets[reset.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryPasswordResetRepository) FindPasswordReset(ctx context.Context, tokenHash string) (*PasswordReset, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, reset := range r.resets {
+		if reset.TokenHash == tokenHash {
+			found := *reset
+			return &found, nil
+		}
+	}
+	return nil, ErrInvalidResetToken
+}
+
+func (r *InMemoryPasswordResetRepository) UsePasswordResets(ctx context.Context, userID, id uuid.UUID, usedAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	used := false
+	for _, reset := range r.resets {
+		if reset.UserID != userID || reset.UsedAt != nil {
+			continue
+		}
+		reset.UsedAt = &usedAt
+		if reset.ID == id {
+			used = true
+		}
+	}
+	return used, nil
+}
@@ -0,0 +1,164 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"minimart/internal/shared/eventbus"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type PasswordResetUsecase interface {
+	// ForgotPassword emails the user a link to reset their password. It
+	// succeeds whether or not an account has the email, so callers cannot
+	// find out which emails are registered. It returns a
+	// *PasswordResetThrottledError once the email or the IP address the
+	// request comes from has asked for too many resets.
+	ForgotPassword(ctx context.Context, email, ip string) error
+	// ResetPassword sets a new password with a token from ForgotPassword and
+	// logs the user out everywhere. The handler checks the password against
+	// the password policy.
+	ResetPassword(ctx context.Context, token, password string) error
+}
+
+// SessionRevoker ends a user's sessions, normally UserUsecase.
+type SessionRevoker interface {
+	LogoutEverywhere(ctx context.Context, userID uuid.UUID) error
+}
+
+type passwordResetUsecase struct {
+	users    UserRepository
+	resets   PasswordResetRepository
+	attempts LoginAttemptStore
+	sessions SessionRevoker
+	eventBus eventbus.EventBus
+	ttl      time.Duration
+	logger   *slog.Logger
+	now      func() time.Time
+}
+
+// NewPasswordResetUsecase creates a PasswordResetUsecase whose tokens last
+// ttl, or DefaultPasswordResetTTL if it is zero. Reset requests are counted in
+// attempts, under keys of their own.
+func NewPasswordResetUsecase(users UserRepository, resets PasswordResetRepository, attempts LoginAttemptStore, sessions SessionRevoker, eventBus eventbus.EventBus, ttl time.Duration, logger *slog.Logger) PasswordResetUsecase {
+	if ttl <= 0 {
+		ttl = DefaultPasswordResetTTL
+	}
+	return &passwordResetUsecase{
+		users:    users,
+		resets:   resets,
+		attempts: attempts,
+		sessions: sessions,
+		eventBus: eventBus,
+		ttl:      ttl,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+func (u *passwordResetUsecase) ForgotPassword(ctx context.Context, email, ip string) error {
+	if err := u.throttle(ctx, email, ip, u.now()); err != nil {
+		return err
+	}
+
+	user, err := u.users.FindByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		// Unknown emails get the same answer as known ones.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// The token is stored and sent after answering, so that known emails are
+	// answered as quickly as unknown ones. The request's context ends with the
+	// response, so the work gets one of its own.
+	go u.sendPasswordReset(context.Background(), user)
+	return nil
+}
+
+// sendPasswordReset stores a new reset token for the user and has it emailed.
+// Nobody waits for it, so failures are logged.
+func (u *passwordResetUsecase) sendPasswordReset(ctx context.Context, user *User) {
+	reset, token, err := newPasswordReset(user.ID, u.now(), u.ttl)
+	if err == nil {
+		err = u.resets.SavePasswordReset(ctx, reset)
+	}
+	if err == nil {
+		// The notifications module emails the link.
+		err = u.eventBus.Publish(ctx, PasswordResetRequestedEvent{
+			UserID:    user.ID.String(),
+			Name:      user.Name,
+			Email:     user.Email,
+			Token:     token,
+			ExpiresAt: reset.ExpiresAt,
+		})
+	}
+	if err != nil {
+		u.logger.Error("Failed to send password reset", "module", "user", "user_id", user.ID, "error", err)
+	}
+}
+
+// throttle counts a reset request against the email and the IP address and
+// returns a *PasswordResetThrottledError if either is blocked. Like the login
+// guard, it tracks emails whether or not an account has them.
+func (u *passwordResetUsecase) throttle(ctx context.Context, email, ip string, now time.Time) error {
+	limits := map[string]int{"password-reset:" + accountAttemptKey(email): MaxPasswordResetsPerEmail}
+	if ip != "" {
+		limits["password-reset:ip:"+ip] = MaxPasswordResetsPerIP
+	}
+
+	for key := range limits {
+		until, locked, err := u.attempts.LockedUntil(ctx, key, now)
+		if err != nil {
+			return err
+		}
+		if locked {
+			return &PasswordResetThrottledError{RetryAfter: until.Sub(now)}
+		}
+	}
+	for key, limit := range limits {
+		requests, err := u.attempts.RecordFailure(ctx, key, now, PasswordResetWindow)
+		if err != nil {
+			return err
+		}
+		if requests >= limit {
+			if err := u.attempts.Lock(ctx, key, now.Add(PasswordResetWindow)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (u *passwordResetUsecase) ResetPassword(ctx context.Context, token, password string) error {
+	reset, err := u.resets.FindPasswordReset(ctx, HashPasswordResetToken(token))
+	if err != nil {
+		return err
+	}
+	now := u.now()
+	if !reset.Usable(now) {
+		return ErrInvalidResetToken
+	}
+	// Use the token before changing the password so it cannot be used twice.
+	used, err := u.resets.UsePasswordResets(ctx, reset.UserID, reset.ID, now)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidResetToken
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := u.users.UpdatePassword(ctx, reset.UserID, string(hashedPassword)); err != nil {
+		return err
+	}
+	// Whoever knew the old password may be logged in.
+	return u.sessions.LogoutEverywhere(ctx, reset.UserID)
+}
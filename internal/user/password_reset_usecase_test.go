@@ -0,0 +1,135 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"minimart/internal/shared/eventbus"
+	middlerware "minimart/internal/shared/middleware"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestPasswordResetUsecase(t *testing.T) {
+	ctx := context.Background()
+	users := NewInMemoryUserRepository()
+	bus := eventbus.NewInMemoryEventBus()
+	userUsecase := NewUserUsecase(users, NewInMemoryRefreshTokenRepository(), NewInMemoryEmailVerificationRepository(), newTestLoginGuard(), middlerware.NewInMemoryTokenDenylist(), testKeys, bus, TokenConfig{})
+	attempts := NewInMemoryLoginAttemptStore()
+	usecase := NewPasswordResetUsecase(users, NewInMemoryPasswordResetRepository(), attempts, userUsecase, bus, 0, discardLogger).(*passwordResetUsecase)
+
+	requested := make(chan PasswordResetRequestedEvent, 10)
+	require.NoError(t, bus.Subscribe(PasswordResetRequestedTopic, func(ctx context.Context, event eventbus.Event) error {
+		requested <- event.(PasswordResetRequestedEvent)
+		return nil
+	}))
+	// forgot requests a reset and returns the token the user would be emailed.
+	forgot := func(email string) string {
+		require.NoError(t, usecase.ForgotPassword(ctx, email, testClientIP))
+		select {
+		case event := <-requested:
+			assert.Equal(t, email, event.Email)
+			return event.Token
+		case <-time.After(time.Second):
+			t.Fatal("no password reset event published")
+			return ""
+		}
+	}
+
+	_, err := userUsecase.RegisterUser(ctx, "Jane", "jane@example.com", "old-password")
+	require.NoError(t, err)
+
+	t.Run("should answer unknown emails without sending anything", func(t *testing.T) {
+		require.NoError(t, usecase.ForgotPassword(ctx, "nobody@example.com", testClientIP))
+		select {
+		case <-requested:
+			t.Fatal("a reset was sent for an unknown email")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("should reset the password once and end every session", func(t *testing.T) {
+		session, err := userUsecase.Login(ctx, "jane@example.com", "old-password", testClientIP)
+		require.NoError(t, err)
+		earlier := forgot("jane@example.com")
+		token := forgot("jane@example.com")
+
+		require.NoError(t, usecase.ResetPassword(ctx, token, "new-password"))
+
+		_, err = userUsecase.Login(ctx, "jane@example.com", "old-password", testClientIP)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		_, err = userUsecase.Login(ctx, "jane@example.com", "new-password", testClientIP)
+		assert.NoError(t, err)
+		_, err = userUsecase.Refresh(ctx, session.RefreshToken)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+		assert.ErrorIs(t, usecase.ResetPassword(ctx, token, "another-password"), ErrInvalidResetToken)
+		assert.ErrorIs(t, usecase.ResetPassword(ctx, earlier, "another-password"), ErrInvalidResetToken, "older links die with the one used")
+		assert.ErrorIs(t, usecase.ResetPassword(ctx, "not-a-token", "another-password"), ErrInvalidResetToken)
+	})
+
+	t.Run("should reject expired tokens", func(t *testing.T) {
+		token := forgot("jane@example.com")
+
+		usecase.now = func() time.Time { return time.Now().Add(DefaultPasswordResetTTL) }
+		defer func() { usecase.now = time.Now }()
+		assert.ErrorIs(t, usecase.ResetPassword(ctx, token, "another-password"), ErrInvalidResetToken)
+	})
+
+	t.Run("should leave password rules to the configured policy", func(t *testing.T) {
+		// Handlers configured with a shorter minimum accept this, so the usecase must too
+		token := forgot("jane@example.com")
+		require.NoError(t, usecase.ResetPassword(ctx, token, "pin123"))
+	})
+
+	t.Run("should throttle requests per email whether or not an account has it", func(t *testing.T) {
+		for _, email := range []string{"jane@example.com", "nobody@example.com"} {
+			require.NoError(t, attempts.Clear(ctx, "password-reset:"+accountAttemptKey(email)))
+			for range MaxPasswordResetsPerEmail {
+				require.NoError(t, usecase.ForgotPassword(ctx, email, ""))
+			}
+			err := usecase.ForgotPassword(ctx, email, "")
+			var throttled *PasswordResetThrottledError
+			require.ErrorAs(t, err, &throttled, email)
+			assert.InDelta(t, PasswordResetWindow, throttled.RetryAfter, float64(time.Second))
+		}
+		for len(requested) > 0 {
+			<-requested
+		}
+	})
+
+	t.Run("should throttle requests per IP address", func(t *testing.T) {
+		ip := "203.0.113.7"
+		for i := range MaxPasswordResetsPerIP {
+			require.NoError(t, usecase.ForgotPassword(ctx, fmt.Sprintf("someone-%d@example.com", i), ip))
+		}
+		err := usecase.ForgotPassword(ctx, "someone-else@example.com", ip)
+		assert.ErrorIs(t, err, ErrTooManyPasswordResets)
+	})
+}
+
+// unreachableUserRepository fails every lookup, like a database outage.
+type unreachableUserRepository struct {
+	UserRepository
+}
+
+func (unreachableUserRepository) FindByEmail(ctx context.Context, email string) (*User, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestPasswordResetUsecase_LookupFailure(t *testing.T) {
+	usecase := NewPasswordResetUsecase(unreachableUserRepository{}, NewInMemoryPasswordResetRepository(), NewInMemoryLoginAttemptStore(), nil, eventbus.NewInMemoryEventBus(), 0, discardLogger)
+
+	t.Run("should report lookup failures instead of answering as for unknown emails", func(t *testing.T) {
+		err := usecase.ForgotPassword(context.Background(), "jane@example.com", testClientIP)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrTooManyPasswordResets)
+	})
+}
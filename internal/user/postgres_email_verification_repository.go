@@ -0,0 +1,113 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresEmailVerificationRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresEmailVerificationRepository(db *pgxpool.Pool) EmailVerificationRepository {
+	return &PostgresEmailVerificationRepository{db: db}
+}
+
+func (r *PostgresEmailVerificationRepository) SaveEmailVerification(ctx context.Context, verification *EmailVerification) error {
+	query := `
+		INSERT INTO email_verifications (id, user_id, email, token_hash, expires_at, used_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7);
+	`
+	_, err := r.db.Exec(ctx, query, verification.ID, verification.UserID, verification.Email, verification.TokenHash,
+		verification.ExpiresAt, verification.UsedAt, verification.CreatedAt)
+	return err
+}
+
+func (r *PostgresEmailVerificationRepository) FindEmailVerification(ctx context.Context, tokenHash string) (*EmailVerification, error) {
+	query := `
+		SELECT ` + emailVerificationColumns + `
+		FROM email_verifications
+		WHERE token_hash = $1;
+	`
+	verification, err := scanEmailVerification(r.db.QueryRow(ctx, query, tokenHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidVerificationToken
+		}
+		return nil, err
+	}
+	return verification, nil
+}
+
+func (r *PostgresEmailVerificationRepository) ListEmailVerificationsSince(ctx context.Context, userID uuid.UUID, since time.Time) ([]*EmailVerification, error) {
+	query := `
+		SELECT ` + emailVerificationColumns + `
+		FROM email_verifications
+		WHERE user_id = $1 AND created_at >= $2
+		ORDER BY created_at;
+	`
+	rows, err := r.db.Query(ctx, query, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recent := []*EmailVerification{}
+	for rows.Next() {
+		verification, err := scanEmailVerification(rows)
+		if err != nil {
+			return nil, err
+		}
+		recent = append(recent, verification)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return recent, nil
+}
+
+func (r *PostgresEmailVerificationRepository) UseEmailVerifications(ctx context.Context, userID, id uuid.UUID, usedAt time.Time) (bool, error) {
+	query := `
+		UPDATE email_verifications
+		SET used_at = $3
+		WHERE user_id = $1 AND used_at IS NULL
+		RETURNING id;
+	`
+	rows, err := r.db.Query(ctx, query, userID, usedAt)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	found := false
+	for rows.Next() {
+		var used uuid.UUID
+		if err := rows.Scan(&used); err != nil {
+			return false, err
+		}
+		if used == id {
+			found = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	return found, nil
+}
+
+const emailVerificationColumns = "id, user_id, email, token_hash, expires_at, used_at, created_at"
+
+func scanEmailVerification(row pgx.Row) (*EmailVerification, error) {
+	verification := &EmailVerification{}
+	err := row.Scan(&verification.ID, &verification.UserID, &verification.Email, &verification.TokenHash,
+		&verification.ExpiresAt, &verification.UsedAt, &verification.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return verification, nil
+}
@@ -0,0 +1,46 @@
+package user
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresExternalIdentityRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresExternalIdentityRepository(db *pgxpool.Pool) ExternalIdentityRepository {
+	return &PostgresExternalIdentityRepository{db: db}
+}
+
+func (r *PostgresExternalIdentityRepository) FindExternalIdentity(ctx context.Context, provider, subject string) (*ExternalIdentity, error) {
+	query := `
+		SELECT provider, subject, user_id, email, created_at
+		FROM user_identities
+		WHERE provider = $1 AND subject = $2;
+	`
+	var identity ExternalIdentity
+	err := r.db.QueryRow(ctx, query, provider, subject).Scan(
+		&identity.Provider, &identity.Subject, &identity.UserID, &identity.Email, &identity.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrExternalIdentityNotFound
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *PostgresExternalIdentityRepository) LinkExternalIdentity(ctx context.Context, identity *ExternalIdentity) error {
+	query := `
+		INSERT INTO user_identities (provider, subject, user_id, email, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (provider, subject) DO UPDATE
+		SET user_id = EXCLUDED.user_id, email = EXCLUDED.email, created_at = EXCLUDED.created_at;
+	`
+	_, err := r.db.Exec(ctx, query, identity.Provider, identity.Subject, identity.UserID, identity.Email, identity.CreatedAt)
+	return err
+}
@@ -0,0 +1,74 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresPasswordResetRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresPasswordResetRepository(db *pgxpool.Pool) PasswordResetRepository {
+	return &PostgresPasswordResetRepository{db: db}
+}
+
+func (r *PostgresPasswordResetRepository) SavePasswordReset(ctx context.Context, reset *PasswordReset) error {
+	query := `
+		INSERT INTO password_resets (id, user_id, token_hash, expires_at, used_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6);
+	`
+	_, err := r.db.Exec(ctx, query, reset.ID, reset.UserID, reset.TokenHash, reset.ExpiresAt, reset.UsedAt, reset.CreatedAt)
+	return err
+}
+
+func (r *PostgresPasswordResetRepository) FindPasswordReset(ctx context.Context, tokenHash string) (*PasswordReset, error) {
+	query := `
+		SELECT id, user_id, token_hash, expires_at, used_at, created_at
+		FROM password_resets
+		WHERE token_hash = $1;
+	`
+	reset := &PasswordReset{}
+	err := r.db.QueryRow(ctx, query, tokenHash).Scan(&reset.ID, &reset.UserID, &reset.TokenHash, &reset.ExpiresAt, &reset.UsedAt, &reset.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidResetToken
+		}
+		return nil, err
+	}
+	return reset, nil
+}
+
+func (r *PostgresPasswordResetRepository) UsePasswordResets(ctx context.Context, userID, id uuid.UUID, usedAt time.Time) (bool, error) {
+	query := `
+		UPDATE password_resets
+		SET used_at = $3
+		WHERE user_id = $1 AND used_at IS NULL
+		RETURNING id;
+	`
+	rows, err := r.db.Query(ctx, query, userID, usedAt)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	found := false
+	for rows.Next() {
+		var used uuid.UUID
+		if err := rows.Scan(&used); err != nil {
+			return false, err
+		}
+		if used == id {
+			found = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	return found, nil
+}
@@ -3,6 +3,9 @@ package user
 import (
 	"context"
 	"errors"
+	"time"
+
+	middlerware "minimart/internal/shared/middleware"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -19,14 +22,14 @@ func NewPostgresUserRepository(db *pgxpool.Pool) UserRepository {
 }
 
 func (r *PostgresUserRepository) Save(ctx context.Context, user *User) error {
-	query := `INSERT INTO users (id, name, email, password, created_at) VALUES ($1, $2, $3, $4, $5)`
-	_, err := r.db.Exec(ctx, query, user.ID, user.Name, user.Email, user.Password, user.CreatedAt)
+	query := `INSERT INTO users (id, name, email, password, role, email_verified_at, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := r.db.Exec(ctx, query, user.ID, user.Name, user.Email, user.Password, user.Role, user.EmailVerifiedAt, user.CreatedAt)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			if pgErr.Code == "23505" { // Unique violation
-				return errors.New("User with this email already exists")
+				return ErrEmailTaken
 			}
 		}
 		return err
@@ -36,14 +39,14 @@ func (r *PostgresUserRepository) Save(ctx context.Context, user *User) error {
 
 // FindByID retrives a user from the database by their ID.
 func (r *PostgresUserRepository) FindByID(ctx context.Context, id uuid.UUID) (*User, error) {
-	query := `SELECT id, name, email, password, created_at FROM users WHERE id = $1`
+	query := `SELECT id, name, email, password, role, email_verified_at, created_at FROM users WHERE id = $1 AND deleted_at IS NULL`
 	row := r.db.QueryRow(ctx, query, id)
 
 	var user User
-	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.CreatedAt)
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.EmailVerifiedAt, &user.CreatedAt)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, errors.New("User not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, err
 	}
@@ -52,16 +55,97 @@ func (r *PostgresUserRepository) FindByID(ctx context.Context, id uuid.UUID) (*U
 
 // FindByEmail retrives a user from the database by their email.
 func (r *PostgresUserRepository) FindByEmail(ctx context.Context, email string) (*User, error) {
-	query := `SELECT id, name, email, password, created_at FROM users WHERE email = $1`
+	query := `SELECT id, name, email, password, role, email_verified_at, created_at FROM users WHERE email = $1 AND deleted_at IS NULL`
 	row := r.db.QueryRow(ctx, query, email)
 
 	var user User
-	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.CreatedAt)
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.EmailVerifiedAt, &user.CreatedAt)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, errors.New("User not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, err
 	}
 	return &user, nil
 }
+
+// UpdateRole changes the platform role of a user.
+func (r *PostgresUserRepository) UpdateRole(ctx context.Context, id uuid.UUID, role middlerware.Role) error {
+	tag, err := r.db.Exec(ctx, `UPDATE users SET role = $2 WHERE id = $1 AND deleted_at IS NULL`, id, role)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// UpdatePassword replaces the password hash of a user.
+func (r *PostgresUserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	tag, err := r.db.Exec(ctx, `UPDATE users SET password = $2 WHERE id = $1 AND deleted_at IS NULL`, id, passwordHash)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// MarkEmailVerified records when the user verified their email, provided it
+// is still their email.
+func (r *PostgresUserRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID, email string, at time.Time) (bool, error) {
+	tag, err := r.db.Exec(ctx, `UPDATE users SET email_verified_at = $3 WHERE id = $1 AND email = $2`, id, email, at)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Update saves the name, email and email verification of a user.
+func (r *PostgresUserRepository) Update(ctx context.Context, user *User) error {
+	query := `UPDATE users SET name = $2, email = $3, email_verified_at = $4 WHERE id = $1 AND deleted_at IS NULL`
+	tag, err := r.db.Exec(ctx, query, user.ID, user.Name, user.Email, user.EmailVerifiedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrEmailTaken
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// Delete anonymises a user in place, so that merchants they own and orders
+// they placed keep referring to a row, and removes their pending email tokens.
+func (r *PostgresUserRepository) Delete(ctx context.Context, id uuid.UUID, at time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `UPDATE users SET name = $2, email = $3, password = '', role = $4, email_verified_at = NULL, deleted_at = $5
+		WHERE id = $1 AND deleted_at IS NULL`
+	tag, err := tx.Exec(ctx, query, id, DeletedUserName, deletedUserEmail(id), middlerware.RoleCustomer, at)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM email_verifications WHERE user_id = $1`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM password_resets WHERE user_id = $1`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM user_identities WHERE user_id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
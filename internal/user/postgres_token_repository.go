@@ -0,0 +1,111 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresRefreshTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRefreshTokenRepository(db *pgxpool.Pool) RefreshTokenRepository {
+	return &PostgresRefreshTokenRepository{db: db}
+}
+
+func (r *PostgresRefreshTokenRepository) SaveRefreshToken(ctx context.Context, token *RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, access_token_id, access_token_expires_at,
+			expires_at, used_at, revoked_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
+	`
+	_, err := r.db.Exec(ctx, query, token.ID, token.UserID, token.FamilyID, token.TokenHash, token.AccessTokenID,
+		token.AccessTokenExpiresAt, token.ExpiresAt, token.UsedAt, token.RevokedAt, token.CreatedAt)
+	return err
+}
+
+func (r *PostgresRefreshTokenRepository) FindRefreshToken(ctx context.Context, tokenHash string) (*RefreshToken, error) {
+	query := `
+		SELECT ` + refreshTokenColumns + `
+		FROM refresh_tokens
+		WHERE token_hash = $1;
+	`
+	token, err := scanRefreshToken(r.db.QueryRow(ctx, query, tokenHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+func (r *PostgresRefreshTokenRepository) MarkRefreshTokenUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) (bool, error) {
+	query := `
+		UPDATE refresh_tokens
+		SET used_at = $2
+		WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL;
+	`
+	tag, err := r.db.Exec(ctx, query, id, usedAt)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *PostgresRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID, at time.Time) ([]*RefreshToken, error) {
+	return r.revoke(ctx, "family_id = $1", familyID, at)
+}
+
+func (r *PostgresRefreshTokenRepository) RevokeUserTokens(ctx context.Context, userID uuid.UUID, at time.Time) ([]*RefreshToken, error) {
+	return r.revoke(ctx, "user_id = $1", userID, at)
+}
+
+// revoke revokes the tokens matching filter, which compares $1 to id. Tokens
+// that are expired and revoked already are not touched.
+func (r *PostgresRefreshTokenRepository) revoke(ctx context.Context, filter string, id uuid.UUID, at time.Time) ([]*RefreshToken, error) {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = COALESCE(revoked_at, $2)
+		WHERE ` + filter + ` AND (revoked_at IS NULL OR access_token_expires_at > $2)
+		RETURNING ` + refreshTokenColumns + `;
+	`
+	rows, err := r.db.Query(ctx, query, id, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	live := []*RefreshToken{}
+	for rows.Next() {
+		token, err := scanRefreshToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		if token.AccessTokenExpiresAt.After(at) {
+			live = append(live, token)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return live, nil
+}
+
+const refreshTokenColumns = "id, user_id, family_id, token_hash, access_token_id, access_token_expires_at, " +
+	"expires_at, used_at, revoked_at, created_at"
+
+func scanRefreshToken(row pgx.Row) (*RefreshToken, error) {
+	token := &RefreshToken{}
+	err := row.Scan(&token.ID, &token.UserID, &token.FamilyID, &token.TokenHash, &token.AccessTokenID, &token.AccessTokenExpiresAt,
+		&token.ExpiresAt, &token.UsedAt, &token.RevokedAt, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
@@ -0,0 +1,171 @@
+package user
+
+import (
+	"errors"
+	middlerware "minimart/internal/shared/middleware"
+	"minimart/internal/shared/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ProfileHandler struct {
+	usecase   ProfileUsecase
+	auth      fiber.Handler
+	passwords validation.PasswordPolicy
+}
+
+// NewProfileHandler creates a new ProfileHandler. auth guards every route,
+// normally middlerware.AuthRequire. New passwords must meet passwords.
+func NewProfileHandler(usecase ProfileUsecase, auth fiber.Handler, passwords validation.PasswordPolicy) *ProfileHandler {
+	return &ProfileHandler{usecase: usecase, auth: auth, passwords: passwords}
+}
+
+func (h *ProfileHandler) RegisterRoutes(app *fiber.App) {
+	app.Get("/api/profile", h.auth, h.Profile)
+	app.Patch("/api/profile", h.auth, h.UpdateProfile)
+	app.Post("/api/profile/password", h.auth, h.ChangePassword)
+	app.Delete("/api/profile", h.auth, h.DeleteAccount)
+}
+
+// Profile returns the authenticated user as stored, not as their token has it.
+func (h *ProfileHandler) Profile(c *fiber.Ctx) error {
+	userID, err := middlerware.UserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	user, err := h.usecase.Profile(c.Context(), userID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load profile"})
+	}
+	return c.JSON(user)
+}
+
+type updateProfileRequest struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
+}
+
+func (r updateProfileRequest) Validate() error {
+	var v validation.Validator
+	if r.Name != nil {
+		v.Required("name", *r.Name)
+		v.MaxLength("name", *r.Name, validation.MaxNameLength)
+	}
+	if r.Email != nil {
+		v.Required("email", *r.Email)
+		v.Email("email", *r.Email)
+		v.MaxLength("email", *r.Email, validation.MaxNameLength)
+	}
+	return v.Err()
+}
+
+// UpdateProfile changes the authenticated user's name and email.
+func (h *ProfileHandler) UpdateProfile(c *fiber.Ctx) error {
+	userID, err := middlerware.UserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	var req updateProfileRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
+
+	user, err := h.usecase.UpdateProfile(c.Context(), userID, ProfileUpdate{Name: req.Name, Email: req.Email})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNameRequired), errors.Is(err, ErrInvalidEmail):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, ErrEmailTaken):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, ErrUserNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update profile"})
+	}
+	return c.JSON(user)
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+func (r changePasswordRequest) Validate(passwords validation.PasswordPolicy) error {
+	var v validation.Validator
+	v.Check(r.CurrentPassword != "", "current_password", "is required")
+	v.Password("new_password", r.NewPassword, passwords)
+	return v.Err()
+}
+
+// ChangePassword sets a new password and logs the user out, this session included.
+func (h *ProfileHandler) ChangePassword(c *fiber.Ctx) error {
+	userID, err := middlerware.UserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	var req changePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if err := req.Validate(h.passwords); err != nil {
+		return validation.Respond(c, err)
+	}
+
+	err = h.usecase.ChangePassword(c.Context(), userID, req.CurrentPassword, req.NewPassword, c.IP())
+	if err != nil {
+		return h.passwordConfirmationError(c, err, "Could not change password")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+type deleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+func (r deleteAccountRequest) Validate() error {
+	var v validation.Validator
+	v.Check(r.Password != "", "password", "is required")
+	return v.Err()
+}
+
+// DeleteAccount anonymises the authenticated user.
+func (h *ProfileHandler) DeleteAccount(c *fiber.Ctx) error {
+	userID, err := middlerware.UserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	var req deleteAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if err := req.Validate(); err != nil {
+		return validation.Respond(c, err)
+	}
+
+	if err := h.usecase.DeleteAccount(c.Context(), userID, req.Password, c.IP()); err != nil {
+		return h.passwordConfirmationError(c, err, "Could not delete account")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// passwordConfirmationError answers a request that confirmed the password and failed.
+func (h *ProfileHandler) passwordConfirmationError(c *fiber.Ctx, err error, message string) error {
+	var throttled *LoginThrottledError
+	switch {
+	case errors.Is(err, ErrWrongPassword):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrPasswordNotSet):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.As(err, &throttled):
+		return tooManyRequests(c, err, throttled.RetryAfter)
+	case errors.Is(err, ErrUserNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+}
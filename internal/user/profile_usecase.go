@@ -0,0 +1,179 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"minimart/internal/shared/eventbus"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrWrongPassword is returned when a user confirming a change gives the
+	// wrong current password.
+	ErrWrongPassword = errors.New("current password is incorrect")
+	// ErrPasswordNotSet is returned when a user without a password, such as
+	// one who signed up with an identity provider, confirms a change. They
+	// set a password with a password reset first.
+	ErrPasswordNotSet = errors.New("account has no password; set one with a password reset first")
+	ErrNameRequired   = errors.New("name must not be empty")
+	ErrInvalidEmail   = errors.New("email address is invalid")
+)
+
+// ProfileUpdate holds the profile fields a user changes; nil fields stay as
+// they are.
+type ProfileUpdate struct {
+	Name  *string
+	Email *string
+}
+
+// ProfileUsecase lets users manage their own account.
+type ProfileUsecase interface {
+	Profile(ctx context.Context, userID uuid.UUID) (*User, error)
+	// UpdateProfile changes the user's name and email. A new email must be
+	// verified again; the verification email is sent to it. Access tokens
+	// show the old values until they are refreshed.
+	UpdateProfile(ctx context.Context, userID uuid.UUID, update ProfileUpdate) (*User, error)
+	// ChangePassword sets a new password once the current one is confirmed,
+	// and logs the user out everywhere. Wrong passwords count towards the
+	// same limits as failed logins from ip. The handler checks the new
+	// password against the password policy.
+	ChangePassword(ctx context.Context, userID uuid.UUID, currentPassword, newPassword, ip string) error
+	// DeleteAccount anonymises the user once their password is confirmed and
+	// logs them out everywhere. Their orders are kept for accounting.
+	DeleteAccount(ctx context.Context, userID uuid.UUID, password, ip string) error
+}
+
+type profileUsecase struct {
+	users           UserRepository
+	verifications   EmailVerificationRepository
+	guard           LoginGuard
+	sessions        SessionRevoker
+	eventBus        eventbus.EventBus
+	verificationTTL time.Duration
+	now             func() time.Time
+}
+
+// NewProfileUsecase creates a ProfileUsecase whose verification emails last
+// verificationTTL, or DefaultEmailVerificationTTL if it is zero.
+func NewProfileUsecase(users UserRepository, verifications EmailVerificationRepository, guard LoginGuard, sessions SessionRevoker, eventBus eventbus.EventBus, verificationTTL time.Duration) ProfileUsecase {
+	if verificationTTL <= 0 {
+		verificationTTL = DefaultEmailVerificationTTL
+	}
+	return &profileUsecase{
+		users:           users,
+		verifications:   verifications,
+		guard:           guard,
+		sessions:        sessions,
+		eventBus:        eventBus,
+		verificationTTL: verificationTTL,
+		now:             time.Now,
+	}
+}
+
+func (u *profileUsecase) Profile(ctx context.Context, userID uuid.UUID) (*User, error) {
+	user, err := u.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (u *profileUsecase) UpdateProfile(ctx context.Context, userID uuid.UUID, update ProfileUpdate) (*User, error) {
+	user, err := u.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	// Work on a copy so a failed update leaves the stored user alone.
+	updated := *user
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" {
+			return nil, ErrNameRequired
+		}
+		updated.Name = name
+	}
+	emailChanged := false
+	if update.Email != nil {
+		email := strings.TrimSpace(*update.Email)
+		if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+			return nil, ErrInvalidEmail
+		}
+		if email != user.Email {
+			updated.Email = email
+			updated.EmailVerifiedAt = nil
+			emailChanged = true
+		}
+	}
+
+	if err := u.users.Update(ctx, &updated); err != nil {
+		return nil, err
+	}
+	if emailChanged {
+		// Tokens sent to the old email stop working by themselves, since they
+		// only verify the address they were sent to.
+		if err := sendVerification(ctx, u.verifications, u.eventBus, &updated, u.now(), u.verificationTTL); err != nil {
+			return nil, err
+		}
+	}
+	return &updated, nil
+}
+
+func (u *profileUsecase) ChangePassword(ctx context.Context, userID uuid.UUID, currentPassword, newPassword, ip string) error {
+	user, err := u.users.FindByID(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if err := u.confirmPassword(ctx, user, currentPassword, ip); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := u.users.UpdatePassword(ctx, userID, string(hashedPassword)); err != nil {
+		return err
+	}
+	// Whoever knew the old password may be logged in.
+	return u.sessions.LogoutEverywhere(ctx, userID)
+}
+
+func (u *profileUsecase) DeleteAccount(ctx context.Context, userID uuid.UUID, password, ip string) error {
+	user, err := u.users.FindByID(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if err := u.confirmPassword(ctx, user, password, ip); err != nil {
+		return err
+	}
+	if err := u.sessions.LogoutEverywhere(ctx, userID); err != nil {
+		return err
+	}
+	return u.users.Delete(ctx, userID, u.now())
+}
+
+// confirmPassword checks the password of a signed-in user. Someone holding a
+// stolen access token could otherwise guess it here without the limits of Login.
+// Users without a password have nothing to guess, so they are turned away
+// without counting a failure; a password reset proves they own the email.
+func (u *profileUsecase) confirmPassword(ctx context.Context, user *User, password, ip string) error {
+	if user.Password == "" {
+		return ErrPasswordNotSet
+	}
+	now := u.now()
+	if err := u.guard.Check(ctx, user.Email, ip, now); err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		if _, err := u.guard.Fail(ctx, user.Email, ip, now); err != nil {
+			return err
+		}
+		return ErrWrongPassword
+	}
+	return nil
+}
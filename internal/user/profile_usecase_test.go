@@ -0,0 +1,135 @@
+package user
+
+import (
+	"context"
+	"minimart/internal/shared/eventbus"
+	middlerware "minimart/internal/shared/middleware"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestProfileUsecase(t *testing.T) {
+	ctx := context.Background()
+	users := NewInMemoryUserRepository()
+	verifications := NewInMemoryEmailVerificationRepository()
+	guard := newTestLoginGuard()
+	bus := eventbus.NewInMemoryEventBus()
+	userUsecase := NewUserUsecase(users, NewInMemoryRefreshTokenRepository(), verifications, guard, middlerware.NewInMemoryTokenDenylist(), testKeys, bus, TokenConfig{})
+	usecase := NewProfileUsecase(users, verifications, guard, userUsecase, bus, 0)
+
+	resent := make(chan EmailVerificationRequestedEvent, 10)
+	require.NoError(t, bus.Subscribe(EmailVerificationRequestedTopic, func(ctx context.Context, event eventbus.Event) error {
+		resent <- event.(EmailVerificationRequestedEvent)
+		return nil
+	}))
+	register := func(name, email string) *User {
+		user, err := userUsecase.RegisterUser(ctx, name, email, "password")
+		require.NoError(t, err)
+		return user
+	}
+	stringPtr := func(s string) *string { return &s }
+
+	t.Run("should load the stored profile", func(t *testing.T) {
+		jane := register("Jane", "jane@example.com")
+
+		profile, err := usecase.Profile(ctx, jane.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "jane@example.com", profile.Email)
+
+		_, err = usecase.Profile(ctx, uuid.New())
+		assert.ErrorIs(t, err, ErrUserNotFound)
+	})
+
+	t.Run("should ask for a new email to be verified again", func(t *testing.T) {
+		john := register("John", "john@example.com")
+		verifiedAt := time.Now()
+		// The in-memory repository hands out the stored user
+		john.EmailVerifiedAt = &verifiedAt
+		register("Taken", "taken@example.com")
+
+		_, err := usecase.UpdateProfile(ctx, john.ID, ProfileUpdate{Name: stringPtr("  ")})
+		assert.ErrorIs(t, err, ErrNameRequired)
+		_, err = usecase.UpdateProfile(ctx, john.ID, ProfileUpdate{Email: stringPtr("John <john@example.org>")})
+		assert.ErrorIs(t, err, ErrInvalidEmail)
+		_, err = usecase.UpdateProfile(ctx, john.ID, ProfileUpdate{Email: stringPtr("taken@example.com")})
+		assert.ErrorIs(t, err, ErrEmailTaken)
+
+		updated, err := usecase.UpdateProfile(ctx, john.ID, ProfileUpdate{Name: stringPtr("Johnny")})
+		require.NoError(t, err)
+		assert.Equal(t, "Johnny", updated.Name)
+		assert.True(t, updated.EmailVerified(), "keeping the email keeps it verified")
+
+		updated, err = usecase.UpdateProfile(ctx, john.ID, ProfileUpdate{Email: stringPtr("johnny@example.com")})
+		require.NoError(t, err)
+		assert.False(t, updated.EmailVerified())
+		select {
+		case event := <-resent:
+			assert.Equal(t, "johnny@example.com", event.Email)
+			_, err = userUsecase.VerifyEmail(ctx, event.Token)
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("no verification email requested for the new email")
+		}
+		stored, err := users.FindByID(ctx, john.ID)
+		require.NoError(t, err)
+		assert.True(t, stored.EmailVerified())
+	})
+
+	t.Run("should change the password only with the current one", func(t *testing.T) {
+		mary := register("Mary", "mary@example.com")
+		session, err := userUsecase.Login(ctx, "mary@example.com", "password", testClientIP)
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, usecase.ChangePassword(ctx, mary.ID, "wrong", "new-password", testClientIP), ErrWrongPassword)
+		require.NoError(t, usecase.ChangePassword(ctx, mary.ID, "password", "new-password", testClientIP))
+
+		_, err = userUsecase.Refresh(ctx, session.RefreshToken)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken, "changing the password ends every session")
+		_, err = userUsecase.Login(ctx, "mary@example.com", "new-password", testClientIP)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should throttle guessing the current password", func(t *testing.T) {
+		paul := register("Paul", "paul@example.com")
+		for range DefaultFreeLoginFailures {
+			assert.ErrorIs(t, usecase.DeleteAccount(ctx, paul.ID, "wrong", testClientIP), ErrWrongPassword)
+		}
+		var throttled *LoginThrottledError
+		assert.ErrorAs(t, usecase.DeleteAccount(ctx, paul.ID, "password", testClientIP), &throttled)
+	})
+
+	t.Run("should ask users without a password to reset it first", func(t *testing.T) {
+		// Like a user who signed up with an identity provider
+		olga := &User{ID: uuid.New(), Name: "Olga", Email: "olga@example.com", Role: middlerware.RoleCustomer}
+		require.NoError(t, users.Save(ctx, olga))
+
+		for range DefaultFreeLoginFailures + 1 {
+			assert.ErrorIs(t, usecase.DeleteAccount(ctx, olga.ID, "", testClientIP), ErrPasswordNotSet)
+		}
+		assert.ErrorIs(t, usecase.ChangePassword(ctx, olga.ID, "", "new-password", testClientIP), ErrPasswordNotSet)
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+		require.NoError(t, err)
+		require.NoError(t, users.UpdatePassword(ctx, olga.ID, string(hashedPassword)))
+		assert.NoError(t, usecase.ChangePassword(ctx, olga.ID, "password", "new-password", testClientIP), "no failures were counted")
+	})
+
+	t.Run("should delete the account once the password is confirmed", func(t *testing.T) {
+		anna := register("Anna", "anna@example.com")
+		session, err := userUsecase.Login(ctx, "anna@example.com", "password", testClientIP)
+		require.NoError(t, err)
+
+		require.NoError(t, usecase.DeleteAccount(ctx, anna.ID, "password", testClientIP))
+		_, err = usecase.Profile(ctx, anna.ID)
+		assert.ErrorIs(t, err, ErrUserNotFound)
+		_, err = userUsecase.Refresh(ctx, session.RefreshToken)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+		_, err = userUsecase.Login(ctx, "anna@example.com", "password", testClientIP)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+}
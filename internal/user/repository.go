@@ -2,9 +2,9 @@ package user
 
 import (
 	"context"
-	"errors"
-	"fmt"
+	middlerware "minimart/internal/shared/middleware"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -13,6 +13,19 @@ type UserRepository interface {
 	Save(ctx context.Context, user *User) error
 	FindByID(ctx context.Context, id uuid.UUID) (*User, error)
 	FindByEmail(ctx context.Context, email string) (*User, error)
+	UpdateRole(ctx context.Context, id uuid.UUID, role middlerware.Role) error
+	// UpdatePassword replaces the user's bcrypt password hash.
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+	// MarkEmailVerified records that the user verified email. It returns
+	// false if the user no longer has that email.
+	MarkEmailVerified(ctx context.Context, id uuid.UUID, email string, at time.Time) (bool, error)
+	// Update saves the user's name, email and email verification. It returns
+	// ErrEmailTaken if another user has the email.
+	Update(ctx context.Context, user *User) error
+	// Delete anonymises the user and forgets their pending email tokens and
+	// linked identities. The user is no longer found afterwards, but what
+	// refers to them, such as their orders, stays valid.
+	Delete(ctx context.Context, id uuid.UUID, at time.Time) error
 }
 
 type InMemoryUserRepository struct {
@@ -31,7 +44,7 @@ func (r *InMemoryUserRepository) Save(ctx context.Context, user *User) error {
 	defer r.mu.Unlock()
 	for _, u := range r.users {
 		if u.Email == user.Email {
-			return errors.New("User with this email already exists")
+			return ErrEmailTaken
 		}
 	}
 	r.users[user.ID] = user
@@ -43,7 +56,7 @@ func (r *InMemoryUserRepository) FindByID(ctx context.Context, id uuid.UUID) (*U
 	defer r.mu.RUnlock()
 	user, ok := r.users[id]
 	if !ok {
-		return nil, fmt.Errorf("User not found")
+		return nil, ErrUserNotFound
 	}
 	return user, nil
 }
@@ -56,5 +69,67 @@ func (r *InMemoryUserRepository) FindByEmail(ctx context.Context, email string)
 			return user, nil
 		}
 	}
-	return nil, fmt.Errorf("User not found")
+	return nil, ErrUserNotFound
+}
+
+func (r *InMemoryUserRepository) UpdateRole(ctx context.Context, id uuid.UUID, role middlerware.Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.Role = role
+	return nil
+}
+
+func (r *InMemoryUserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.Password = passwordHash
+	return nil
+}
+
+func (r *InMemoryUserRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID, email string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok || user.Email != email {
+		return false, nil
+	}
+	user.EmailVerifiedAt = &at
+	return true, nil
+}
+
+func (r *InMemoryUserRepository) Update(ctx context.Context, user *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.users[user.ID]
+	if !ok {
+		return ErrUserNotFound
+	}
+	for _, u := range r.users {
+		if u.ID != user.ID && u.Email == user.Email {
+			return ErrEmailTaken
+		}
+	}
+	stored.Name = user.Name
+	stored.Email = user.Email
+	stored.EmailVerifiedAt = user.EmailVerifiedAt
+	return nil
+}
+
+// Delete forgets the user; nothing else refers to users kept in memory.
+func (r *InMemoryUserRepository) Delete(ctx context.Context, id uuid.UUID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[id]; !ok {
+		return ErrUserNotFound
+	}
+	delete(r.users, id)
+	return nil
 }
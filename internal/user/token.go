@@ -0,0 +1,96 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid or expired")
+	// ErrRefreshTokenReused is returned when a refresh token that was already
+	// exchanged is presented again, which means it was copied. The whole
+	// session is revoked.
+	ErrRefreshTokenReused = errors.New("refresh token was already used; the session has been revoked")
+)
+
+// TokenPair is what a login or a refresh hands the client.
+type TokenPair struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// RefreshToken is one link in a session's chain of refresh tokens. Each is
+// exchanged once for a new pair; only a hash of it is stored.
+type RefreshToken struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	// FamilyID is the session: every token rotated from one login shares it,
+	// and so does the sid claim of their access tokens.
+	FamilyID  uuid.UUID
+	TokenHash string
+	// AccessTokenID is the jti of the access token issued alongside, so it can
+	// be denied when the session is revoked.
+	AccessTokenID        string
+	AccessTokenExpiresAt time.Time
+	ExpiresAt            time.Time
+	// UsedAt is set once the token has been exchanged.
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// newRefreshToken creates a refresh token and returns it with the token to
+// give the client.
+func newRefreshToken(userID, familyID uuid.UUID, now time.Time, ttl time.Duration) (*RefreshToken, string, error) {
+	token, err := newSecretToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &RefreshToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: HashRefreshToken(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}, token, nil
+}
+
+// HashRefreshToken returns the hash refresh tokens are stored and looked up by.
+func HashRefreshToken(token string) string {
+	return hashSecretToken(token)
+}
+
+// newSecretToken returns a random token to hand a client, of which only the
+// hash is stored.
+func newSecretToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func hashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Usable reports whether the token may be exchanged at now.
+func (t *RefreshToken) Usable(now time.Time) bool {
+	return t.UsedAt == nil && t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
@@ -0,0 +1,90 @@
+package user
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RefreshTokenRepository interface {
+	SaveRefreshToken(ctx context.Context, token *RefreshToken) error
+	// FindRefreshToken returns the token with the hash, or ErrInvalidRefreshToken.
+	FindRefreshToken(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	// MarkRefreshTokenUsed marks a usable token as exchanged. It returns false
+	// if the token was used or revoked in the meantime.
+	MarkRefreshTokenUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) (bool, error)
+	// RevokeFamily revokes every token of the session and returns those whose
+	// access token has not expired by at.
+	RevokeFamily(ctx context.Context, familyID uuid.UUID, at time.Time) ([]*RefreshToken, error)
+	// RevokeUserTokens does the same for every session of the user.
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID, at time.Time) ([]*RefreshToken, error)
+}
+
+type InMemoryRefreshTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[uuid.UUID]*RefreshToken
+}
+
+func NewInMemoryRefreshTokenRepository() *InMemoryRefreshTokenRepository {
+	return &InMemoryRefreshTokenRepository{tokens: map[uuid.UUID]*RefreshToken{}}
+}
+
+func (r *InMemoryRefreshTokenRepository) SaveRefreshToken(ctx context.Context, token *RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *token
+	r.tokens[token.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryRefreshTokenRepository) FindRefreshToken(ctx context.Context, tokenHash string) (*RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			found := *token
+			return &found, nil
+		}
+	}
+	return nil, ErrInvalidRefreshToken
+}
+
+func (r *InMemoryRefreshTokenRepository) MarkRefreshTokenUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, found := r.tokens[id]
+	if !found || token.UsedAt != nil || token.RevokedAt != nil {
+		return false, nil
+	}
+	token.UsedAt = &usedAt
+	return true, nil
+}
+
+func (r *InMemoryRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID, at time.Time) ([]*RefreshToken, error) {
+	return r.revoke(func(token *RefreshToken) bool { return token.FamilyID == familyID }, at), nil
+}
+
+func (r *InMemoryRefreshTokenRepository) RevokeUserTokens(ctx context.Context, userID uuid.UUID, at time.Time) ([]*RefreshToken, error) {
+	return r.revoke(func(token *RefreshToken) bool { return token.UserID == userID }, at), nil
+}
+
+func (r *InMemoryRefreshTokenRepository) revoke(match func(*RefreshToken) bool, at time.Time) []*RefreshToken {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	live := []*RefreshToken{}
+	for _, token := range r.tokens {
+		if !match(token) {
+			continue
+		}
+		if token.RevokedAt == nil {
+			token.RevokedAt = &at
+		}
+		if token.AccessTokenExpiresAt.After(at) {
+			revoked := *token
+			live = append(live, &revoked)
+		}
+	}
+	return live
+}
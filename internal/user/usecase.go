@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"minimart/internal/shared/eventbus"
+	"minimart/internal/shared/jwtkeys"
+	middlerware "minimart/internal/shared/middleware"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -11,25 +13,88 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-// ErrInvalidCredentials is a specific error for login failures.
-var ErrInvalidCredentials = errors.New("Invalid email or password")
+var (
+	// ErrInvalidCredentials is a specific error for login failures.
+	ErrInvalidCredentials = errors.New("Invalid email or password")
+	ErrUserNotFound       = errors.New("User not found")
+	ErrEmailTaken         = errors.New("User with this email already exists")
+)
 
 type UserUsecase interface {
 	RegisterUser(ctx context.Context, name, email string, password string) (*User, error)
-	Login(ctx context.Context, email, password string) (string, error)
+	// Login starts a session and returns its first access and refresh tokens.
+	// ip is the client's address; repeated failures from it or on the account
+	// are throttled with a *LoginThrottledError.
+	Login(ctx context.Context, email, password, ip string) (*TokenPair, error)
+	// StartSession starts a session for a user who proved who they are some
+	// other way than with their password, e.g. with an OIDC provider.
+	StartSession(ctx context.Context, user *User) (*TokenPair, error)
+	// Refresh exchanges a refresh token for a new pair. Each refresh token can
+	// be exchanged once; presenting it again revokes the session.
+	Refresh(ctx context.Context, refreshToken string) (*TokenPair, error)
+	// Logout revokes a session, taken from the sid claim of the user's access
+	// token, including its live access tokens.
+	Logout(ctx context.Context, sessionID uuid.UUID) error
+	// LogoutEverywhere revokes every session of the user, e.g. after a lost phone.
+	LogoutEverywhere(ctx context.Context, userID uuid.UUID) error
+	// GrantRole raises the user to role, e.g. to merchant when they open or
+	// join a merchant. Users whose role already includes it are left as they are.
+	GrantRole(ctx context.Context, userID uuid.UUID, role middlerware.Role) error
+	// ChangeRole sets the user's role. Taking permissions away also ends the
+	// user's sessions, so their access tokens stop carrying the old role.
+	ChangeRole(ctx context.Context, userID uuid.UUID, role middlerware.Role) (*User, error)
+	// VerifyEmail verifies the email a token from a verification email was
+	// sent to. Using one token invalidates the user's other tokens.
+	VerifyEmail(ctx context.Context, token string) (*User, error)
+	// ResendVerification sends the user a new verification email, at most
+	// once every VerificationResendInterval and MaxVerificationsPerDay times a day.
+	ResendVerification(ctx context.Context, userID uuid.UUID) error
+	// UnlockLogin lifts a lockout caused by failed logins to the user's account.
+	UnlockLogin(ctx context.Context, userID uuid.UUID) error
+}
+
+// TokenConfig configures the tokens the usecase issues: the access and
+// refresh tokens of Login and Refresh, and email verification tokens. Zero
+// TTLs use the defaults.
+type TokenConfig struct {
+	AccessTokenTTL       time.Duration
+	RefreshTokenTTL      time.Duration
+	EmailVerificationTTL time.Duration
 }
 
 type userUsecase struct {
-	repo      UserRepository
-	eventBus  eventbus.EventBus
-	jwtSecret string
+	repo          UserRepository
+	tokens        RefreshTokenRepository
+	verifications EmailVerificationRepository
+	guard         LoginGuard
+	denylist      middlerware.TokenDenylist
+	keys          jwtkeys.KeyManager
+	eventBus      eventbus.EventBus
+	config        TokenConfig
+	now           func() time.Time
 }
 
-func NewUserUsecase(repo UserRepository, eventBus eventbus.EventBus, jwtSecret string) UserUsecase {
+// NewUserUsecase creates a UserUsecase that signs access tokens with keys.
+func NewUserUsecase(repo UserRepository, tokens RefreshTokenRepository, verifications EmailVerificationRepository, guard LoginGuard, denylist middlerware.TokenDenylist, keys jwtkeys.KeyManager, eventBus eventbus.EventBus, config TokenConfig) UserUsecase {
+	if config.AccessTokenTTL <= 0 {
+		config.AccessTokenTTL = DefaultAccessTokenTTL
+	}
+	if config.RefreshTokenTTL <= 0 {
+		config.RefreshTokenTTL = DefaultRefreshTokenTTL
+	}
+	if config.EmailVerificationTTL <= 0 {
+		config.EmailVerificationTTL = DefaultEmailVerificationTTL
+	}
 	return &userUsecase{
-		repo:      repo,
-		eventBus:  eventBus,
-		jwtSecret: jwtSecret,
+		repo:          repo,
+		tokens:        tokens,
+		verifications: verifications,
+		guard:         guard,
+		denylist:      denylist,
+		keys:          keys,
+		eventBus:      eventBus,
+		config:        config,
+		now:           time.Now,
 	}
 }
 
@@ -39,22 +104,34 @@ func (u *userUsecase) RegisterUser(ctx context.Context, name, email, password st
 		return nil, err
 	}
 
+	now := u.now()
 	user := &User{
 		ID:        uuid.New(),
 		Name:      name,
 		Email:     email,
 		Password:  string(hasedPassword),
-		CreatedAt: time.Now(),
+		Role:      middlerware.RoleCustomer,
+		CreatedAt: now,
 	}
 	if err := u.repo.Save(ctx, user); err != nil {
 		return nil, err
 	}
+	verification, token, err := newEmailVerification(user, now, u.config.EmailVerificationTTL)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.verifications.SaveEmailVerification(ctx, verification); err != nil {
+		return nil, err
+	}
 
+	// The notifications module emails the verification link.
 	event := UserCreatedEvent{
-		UserID:    user.ID.String(),
-		Name:      user.Name,
-		Email:     user.Email,
-		CreatedAt: time.Now(),
+		UserID:                user.ID.String(),
+		Name:                  user.Name,
+		Email:                 user.Email,
+		VerificationToken:     token,
+		VerificationExpiresAt: verification.ExpiresAt,
+		CreatedAt:             now,
 	}
 
 	if err := u.eventBus.Publish(ctx, event); err != nil {
@@ -65,32 +142,279 @@ func (u *userUsecase) RegisterUser(ctx context.Context, name, email, password st
 }
 
 // Login handles the user authentication and JWT generation.
-func (u *userUsecase) Login(ctx context.Context, email, password string) (string, error) {
+func (u *userUsecase) Login(ctx context.Context, email, password, ip string) (*TokenPair, error) {
+	now := u.now()
+	if err := u.guard.Check(ctx, email, ip, now); err != nil {
+		return nil, err
+	}
+
 	user, err := u.repo.FindByEmail(ctx, email)
 	if err != nil {
-		// Use a generic error to avoid revealing if the user exists.
-		return "", ErrInvalidCredentials
+		// Compare anyway so that unknown emails take as long as wrong
+		// passwords, and use a generic error to avoid revealing if the user exists.
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, u.loginFailed(ctx, nil, email, ip, now)
 	}
 
+	if user.Password == "" {
+		// Users who only sign in with a provider have no password to match.
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, u.loginFailed(ctx, user, email, ip, now)
+	}
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
-		return "", ErrInvalidCredentials
+		return nil, u.loginFailed(ctx, user, email, ip, now)
+	}
+	if err := u.guard.Succeed(ctx, email); err != nil {
+		return nil, err
+	}
+
+	// Each login is a new session, i.e. a new refresh token family.
+	return u.issueTokens(ctx, user, uuid.New())
+}
+
+func (u *userUsecase) StartSession(ctx context.Context, user *User) (*TokenPair, error) {
+	return u.issueTokens(ctx, user, uuid.New())
+}
+
+// dummyPasswordHash is compared against when logging in to an unknown email.
+var dummyPasswordHash = []byte("$2a$10$7R4v.7oDf/x90VTnK2zdWe8dj7XYac/bbHw.9saMLYIlw23OHvO3q")
+
+// loginFailed counts a failed login and tells the user, if there is one,
+// when it locks their account. It returns ErrInvalidCredentials either way.
+func (u *userUsecase) loginFailed(ctx context.Context, user *User, email, ip string, now time.Time) error {
+	lockedUntil, err := u.guard.Fail(ctx, email, ip, now)
+	if err != nil {
+		return err
+	}
+	if user != nil && !lockedUntil.IsZero() {
+		// The notifications module emails the owner.
+		event := AccountLockedEvent{
+			UserID:      user.ID.String(),
+			Name:        user.Name,
+			Email:       user.Email,
+			LockedUntil: lockedUntil,
+		}
+		if err := u.eventBus.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return ErrInvalidCredentials
+}
+
+func (u *userUsecase) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	token, err := u.tokens.FindRefreshToken(ctx, HashRefreshToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	now := u.now()
+	if token.RevokedAt != nil || !now.Before(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	// Only a copy of the token can be presented after it was exchanged, so
+	// whoever holds the session now is not to be trusted.
+	if token.UsedAt != nil {
+		return nil, u.revokeReusedFamily(ctx, token.FamilyID, now)
+	}
+	marked, err := u.tokens.MarkRefreshTokenUsed(ctx, token.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !marked {
+		// Someone else exchanged it at the same moment.
+		return nil, u.revokeReusedFamily(ctx, token.FamilyID, now)
+	}
+
+	user, err := u.repo.FindByID(ctx, token.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	return u.issueTokens(ctx, user, token.FamilyID)
+}
+
+func (u *userUsecase) Logout(ctx context.Context, sessionID uuid.UUID) error {
+	revoked, err := u.tokens.RevokeFamily(ctx, sessionID, u.now())
+	if err != nil {
+		return err
+	}
+	return u.denyAccessTokens(ctx, revoked)
+}
+
+func (u *userUsecase) LogoutEverywhere(ctx context.Context, userID uuid.UUID) error {
+	revoked, err := u.tokens.RevokeUserTokens(ctx, userID, u.now())
+	if err != nil {
+		return err
+	}
+	return u.denyAccessTokens(ctx, revoked)
+}
+
+func (u *userUsecase) GrantRole(ctx context.Context, userID uuid.UUID, role middlerware.Role) error {
+	user, err := u.repo.FindByID(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if user.Role.Includes(role) {
+		return nil
+	}
+	return u.repo.UpdateRole(ctx, userID, role)
+}
+
+func (u *userUsecase) ChangeRole(ctx context.Context, userID uuid.UUID, role middlerware.Role) (*User, error) {
+	user, err := u.repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.Role == role {
+		return user, nil
+	}
+	demoted := !role.Includes(user.Role)
+	if err := u.repo.UpdateRole(ctx, userID, role); err != nil {
+		return nil, err
 	}
+	user.Role = role
+	if demoted {
+		if err := u.LogoutEverywhere(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
 
-	claims := jwt.MapClaims{
-		"sub":   user.ID,
-		"name":  user.Name,
-		"email": user.Email,
-		"exp":   time.Now().Add(time.Hour * 72).Unix(),
+func (u *userUsecase) UnlockLogin(ctx context.Context, userID uuid.UUID) error {
+	user, err := u.repo.FindByID(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	return u.guard.Unlock(ctx, user.Email)
+}
+
+func (u *userUsecase) VerifyEmail(ctx context.Context, token string) (*User, error) {
+	verification, err := u.verifications.FindEmailVerification(ctx, HashVerificationToken(token))
+	if err != nil {
+		return nil, err
+	}
+	now := u.now()
+	if !verification.Usable(now) {
+		return nil, ErrInvalidVerificationToken
+	}
+	used, err := u.verifications.UseEmailVerifications(ctx, verification.UserID, verification.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, ErrInvalidVerificationToken
+	}
+	// The user may have changed their email since the token was sent.
+	verified, err := u.repo.MarkEmailVerified(ctx, verification.UserID, verification.Email, now)
+	if err != nil {
+		return nil, err
 	}
+	if !verified {
+		return nil, ErrInvalidVerificationToken
+	}
+	return u.repo.FindByID(ctx, verification.UserID)
+}
 
-	// Create the token object
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+func (u *userUsecase) ResendVerification(ctx context.Context, userID uuid.UUID) error {
+	user, err := u.repo.FindByID(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if user.EmailVerified() {
+		return ErrEmailAlreadyVerified
+	}
 
-	// Sign the token with our secret key
-	tokenString, err := token.SignedString([]byte(u.jwtSecret))
+	now := u.now()
+	recent, err := u.verifications.ListEmailVerificationsSince(ctx, userID, now.Add(-24*time.Hour))
 	if err != nil {
-		return "", err
+		return err
+	}
+	if wait := resendWait(recent, now); wait > 0 {
+		return &VerificationRateLimitError{RetryAfter: wait}
+	}
+	return sendVerification(ctx, u.verifications, u.eventBus, user, now, u.config.EmailVerificationTTL)
+}
+
+// sendVerification saves a verification of the user's current email and
+// publishes the event whose subscriber emails them its token.
+func sendVerification(ctx context.Context, verifications EmailVerificationRepository, eventBus eventbus.EventBus, user *User, now time.Time, ttl time.Duration) error {
+	verification, token, err := newEmailVerification(user, now, ttl)
+	if err != nil {
+		return err
+	}
+	if err := verifications.SaveEmailVerification(ctx, verification); err != nil {
+		return err
+	}
+	return eventBus.Publish(ctx, EmailVerificationRequestedEvent{
+		UserID:    user.ID.String(),
+		Name:      user.Name,
+		Email:     user.Email,
+		Token:     token,
+		ExpiresAt: verification.ExpiresAt,
+	})
+}
+
+// issueTokens signs an access token and creates the refresh token that
+// replaces it, both in the session familyID.
+func (u *userUsecase) issueTokens(ctx context.Context, user *User, familyID uuid.UUID) (*TokenPair, error) {
+	now := u.now()
+	tokenID := uuid.NewString()
+	expiresAt := now.Add(u.config.AccessTokenTTL)
+	claims := &middlerware.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID.String(),
+			ID:        tokenID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		Name:      user.Name,
+		Email:     user.Email,
+		Role:      user.Role,
+		SessionID: familyID.String(),
+	}
+
+	tokenString, err := u.keys.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, refreshToken, err := newRefreshToken(user.ID, familyID, now, u.config.RefreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh.AccessTokenID = tokenID
+	refresh.AccessTokenExpiresAt = expiresAt
+	if err := u.tokens.SaveRefreshToken(ctx, refresh); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		Token:        tokenString,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+// revokeReusedFamily revokes a session whose refresh token was reused and
+// returns ErrRefreshTokenReused.
+func (u *userUsecase) revokeReusedFamily(ctx context.Context, familyID uuid.UUID, now time.Time) error {
+	revoked, err := u.tokens.RevokeFamily(ctx, familyID, now)
+	if err != nil {
+		return err
+	}
+	if err := u.denyAccessTokens(ctx, revoked); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// denyAccessTokens denies the access tokens issued with the revoked refresh
+// tokens until they expire.
+func (u *userUsecase) denyAccessTokens(ctx context.Context, revoked []*RefreshToken) error {
+	var errs []error
+	for _, token := range revoked {
+		errs = append(errs, u.denylist.Deny(ctx, token.AccessTokenID, token.AccessTokenExpiresAt))
 	}
-	return tokenString, nil
+	return errors.Join(errs...)
 }
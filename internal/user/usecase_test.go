@@ -2,8 +2,17 @@ package user
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"minimart/internal/shared/eventbus"
+	"minimart/internal/shared/jwtkeys"
+	middlerware "minimart/internal/shared/middleware"
 	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestUserUseCase_RegisterUser(t *testing.T) {
@@ -13,7 +22,7 @@ func TestUserUseCase_RegisterUser(t *testing.T) {
 	t.Run("should register a user succsessfully", func(t *testing.T) {
 		// Arrange
 		ctx := context.Background()
-		userUsecase := NewUserUsecase(userRepo, eventBus, "test-secret")
+		userUsecase := NewUserUsecase(userRepo, NewInMemoryRefreshTokenRepository(), NewInMemoryEmailVerificationRepository(), newTestLoginGuard(), middlerware.NewInMemoryTokenDenylist(), testKeys, eventBus, TokenConfig{})
 
 		// Act
 		userName := "John Wick"
@@ -47,3 +56,367 @@ func TestUserUseCase_RegisterUser(t *testing.T) {
 		}
 	})
 }
+
+func TestUserUsecase_Tokens(t *testing.T) {
+	ctx := context.Background()
+	denylist := middlerware.NewInMemoryTokenDenylist()
+	usecase := NewUserUsecase(NewInMemoryUserRepository(), NewInMemoryRefreshTokenRepository(), NewInMemoryEmailVerificationRepository(), newTestLoginGuard(), denylist, testKeys,
+		eventbus.NewInMemoryEventBus(), TokenConfig{}).(*userUsecase)
+	_, err := usecase.RegisterUser(ctx, "Jane", "jane@example.com", "password")
+	require.NoError(t, err)
+
+	claimsOf := func(token string) *middlerware.Claims {
+		claims := &middlerware.Claims{}
+		require.NoError(t, testKeys.Parse(token, claims))
+		return claims
+	}
+	denied := func(token string) bool {
+		denied, err := denylist.IsDenied(ctx, claimsOf(token).ID)
+		require.NoError(t, err)
+		return denied
+	}
+
+	t.Run("should issue short-lived access tokens with a session", func(t *testing.T) {
+		tokens, err := usecase.Login(ctx, "jane@example.com", "password", testClientIP)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(DefaultAccessTokenTTL), tokens.ExpiresAt, time.Minute)
+
+		claims := claimsOf(tokens.Token)
+		assert.NotEmpty(t, claims.ID)
+		assert.NotEmpty(t, claims.SessionID)
+		assert.Equal(t, middlerware.RoleCustomer, claims.Role)
+		assert.NotEmpty(t, tokens.RefreshToken)
+	})
+
+	t.Run("should rotate refresh tokens within the session", func(t *testing.T) {
+		first, err := usecase.Login(ctx, "jane@example.com", "password", testClientIP)
+		require.NoError(t, err)
+		second, err := usecase.Refresh(ctx, first.RefreshToken)
+		require.NoError(t, err)
+
+		assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+		assert.Equal(t, claimsOf(first.Token).SessionID, claimsOf(second.Token).SessionID)
+		assert.NotEqual(t, claimsOf(first.Token).ID, claimsOf(second.Token).ID)
+
+		_, err = usecase.Refresh(ctx, "not-a-token")
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	})
+
+	t.Run("should revoke the session when a refresh token is reused", func(t *testing.T) {
+		first, err := usecase.Login(ctx, "jane@example.com", "password", testClientIP)
+		require.NoError(t, err)
+		second, err := usecase.Refresh(ctx, first.RefreshToken)
+		require.NoError(t, err)
+
+		// An attacker replays the stolen first token
+		_, err = usecase.Refresh(ctx, first.RefreshToken)
+		assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+		_, err = usecase.Refresh(ctx, second.RefreshToken)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+		assert.True(t, denied(first.Token))
+		assert.True(t, denied(second.Token))
+	})
+
+	t.Run("should reject expired refresh tokens", func(t *testing.T) {
+		tokens, err := usecase.Login(ctx, "jane@example.com", "password", testClientIP)
+		require.NoError(t, err)
+
+		usecase.now = func() time.Time { return time.Now().Add(DefaultRefreshTokenTTL) }
+		defer func() { usecase.now = time.Now }()
+		_, err = usecase.Refresh(ctx, tokens.RefreshToken)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	})
+
+	t.Run("should log out one session or all of them", func(t *testing.T) {
+		phone, err := usecase.Login(ctx, "jane@example.com", "password", testClientIP)
+		require.NoError(t, err)
+		laptop, err := usecase.Login(ctx, "jane@example.com", "password", testClientIP)
+		require.NoError(t, err)
+
+		sessionID, err := uuid.Parse(claimsOf(phone.Token).SessionID)
+		require.NoError(t, err)
+		require.NoError(t, usecase.Logout(ctx, sessionID))
+		assert.True(t, denied(phone.Token))
+		assert.False(t, denied(laptop.Token))
+		_, err = usecase.Refresh(ctx, phone.RefreshToken)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+		userID, err := uuid.Parse(claimsOf(laptop.Token).Subject)
+		require.NoError(t, err)
+		require.NoError(t, usecase.LogoutEverywhere(ctx, userID))
+		assert.True(t, denied(laptop.Token))
+		_, err = usecase.Refresh(ctx, laptop.RefreshToken)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	})
+}
+
+func TestUserUsecase_Roles(t *testing.T) {
+	ctx := context.Background()
+	denylist := middlerware.NewInMemoryTokenDenylist()
+	usecase := NewUserUsecase(NewInMemoryUserRepository(), NewInMemoryRefreshTokenRepository(), NewInMemoryEmailVerificationRepository(), newTestLoginGuard(), denylist, testKeys,
+		eventbus.NewInMemoryEventBus(), TokenConfig{})
+	user, err := usecase.RegisterUser(ctx, "Jane", "jane@example.com", "password")
+	require.NoError(t, err)
+	assert.Equal(t, middlerware.RoleCustomer, user.Role)
+
+	claimsOf := func(token string) *middlerware.Claims {
+		claims := &middlerware.Claims{}
+		require.NoError(t, testKeys.Parse(token, claims))
+		return claims
+	}
+
+	t.Run("should only ever raise a role when granting it", func(t *testing.T) {
+		require.NoError(t, usecase.GrantRole(ctx, user.ID, middlerware.RoleMerchant))
+		tokens, err := usecase.Login(ctx, "jane@example.com", "password", testClientIP)
+		require.NoError(t, err)
+		assert.Equal(t, middlerware.RoleMerchant, claimsOf(tokens.Token).Role)
+
+		_, err = usecase.ChangeRole(ctx, user.ID, middlerware.RoleAdmin)
+		require.NoError(t, err)
+		require.NoError(t, usecase.GrantRole(ctx, user.ID, middlerware.RoleMerchant))
+		tokens, err = usecase.Login(ctx, "jane@example.com", "password", testClientIP)
+		require.NoError(t, err)
+		assert.Equal(t, middlerware.RoleAdmin, claimsOf(tokens.Token).Role)
+
+		assert.ErrorIs(t, usecase.GrantRole(ctx, uuid.New(), middlerware.RoleMerchant), ErrUserNotFound)
+	})
+
+	t.Run("should end sessions when a role is taken away", func(t *testing.T) {
+		tokens, err := usecase.Login(ctx, "jane@example.com", "password", testClientIP)
+		require.NoError(t, err)
+
+		changed, err := usecase.ChangeRole(ctx, user.ID, middlerware.RoleCustomer)
+		require.NoError(t, err)
+		assert.Equal(t, middlerware.RoleCustomer, changed.Role)
+
+		denied, err := denylist.IsDenied(ctx, claimsOf(tokens.Token).ID)
+		require.NoError(t, err)
+		assert.True(t, denied)
+		_, err = usecase.Refresh(ctx, tokens.RefreshToken)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	})
+}
+
+func TestUserUsecase_EmailVerification(t *testing.T) {
+	ctx := context.Background()
+	users := NewInMemoryUserRepository()
+	bus := eventbus.NewInMemoryEventBus()
+	usecase := NewUserUsecase(users, NewInMemoryRefreshTokenRepository(), NewInMemoryEmailVerificationRepository(), newTestLoginGuard(), middlerware.NewInMemoryTokenDenylist(), testKeys,
+		bus, TokenConfig{}).(*userUsecase)
+
+	created := make(chan UserCreatedEvent, 10)
+	resent := make(chan EmailVerificationRequestedEvent, 10)
+	require.NoError(t, bus.Subscribe(UserCreatedTopic, func(ctx context.Context, event eventbus.Event) error {
+		created <- event.(UserCreatedEvent)
+		return nil
+	}))
+	require.NoError(t, bus.Subscribe(EmailVerificationRequestedTopic, func(ctx context.Context, event eventbus.Event) error {
+		resent <- event.(EmailVerificationRequestedEvent)
+		return nil
+	}))
+	register := func(email string) (*User, string) {
+		user, err := usecase.RegisterUser(ctx, "Jane", email, "password")
+		require.NoError(t, err)
+		select {
+		case event := <-created:
+			require.NotEmpty(t, event.VerificationToken)
+			return user, event.VerificationToken
+		case <-time.After(time.Second):
+			t.Fatal("no user created event published")
+			return nil, ""
+		}
+	}
+
+	t.Run("should stamp the user and the event with the usecase's clock", func(t *testing.T) {
+		registeredAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		usecase.now = func() time.Time { return registeredAt }
+		defer func() { usecase.now = time.Now }()
+
+		user, err := usecase.RegisterUser(ctx, "Jane", "clock@example.com", "password")
+		require.NoError(t, err)
+		assert.Equal(t, registeredAt, user.CreatedAt)
+		event := <-created
+		assert.Equal(t, registeredAt, event.CreatedAt)
+		assert.Equal(t, registeredAt.Add(DefaultEmailVerificationTTL), event.VerificationExpiresAt)
+	})
+
+	t.Run("should verify the email once", func(t *testing.T) {
+		user, token := register("jane@example.com")
+		assert.False(t, user.EmailVerified())
+
+		verified, err := usecase.VerifyEmail(ctx, token)
+		require.NoError(t, err)
+		assert.True(t, verified.EmailVerified())
+
+		_, err = usecase.VerifyEmail(ctx, token)
+		assert.ErrorIs(t, err, ErrInvalidVerificationToken)
+		assert.ErrorIs(t, usecase.ResendVerification(ctx, user.ID), ErrEmailAlreadyVerified)
+	})
+
+	t.Run("should not verify an email the user no longer has", func(t *testing.T) {
+		user, token := register("old@example.com")
+		// The in-memory repository hands out the stored user
+		user.Email = "new@example.com"
+
+		_, err := usecase.VerifyEmail(ctx, token)
+		assert.ErrorIs(t, err, ErrInvalidVerificationToken)
+	})
+
+	t.Run("should rate limit resending", func(t *testing.T) {
+		user, first := register("slow@example.com")
+
+		var limited *VerificationRateLimitError
+		require.ErrorAs(t, usecase.ResendVerification(ctx, user.ID), &limited)
+		assert.LessOrEqual(t, limited.RetryAfter, VerificationResendInterval)
+
+		start := time.Now()
+		defer func() { usecase.now = time.Now }()
+		for i := 1; i < MaxVerificationsPerDay; i++ {
+			usecase.now = func() time.Time { return start.Add(time.Duration(i) * 2 * VerificationResendInterval) }
+			require.NoError(t, usecase.ResendVerification(ctx, user.ID))
+			<-resent
+		}
+		usecase.now = func() time.Time { return start.Add(time.Hour) }
+		require.ErrorAs(t, usecase.ResendVerification(ctx, user.ID), &limited)
+		assert.Greater(t, limited.RetryAfter, 22*time.Hour, "the daily cap applies")
+
+		// Any of the tokens sent verifies the email, but only once
+		usecase.now = time.Now
+		_, err := usecase.VerifyEmail(ctx, first)
+		require.NoError(t, err)
+	})
+}
+
+func TestUserUsecase_LoginGuard(t *testing.T) {
+	ctx := context.Background()
+	bus := eventbus.NewInMemoryEventBus()
+	guard := NewLoginGuard(NewInMemoryLoginAttemptStore(), LoginGuardConfig{
+		MaxAccountFailures: 4,
+		MaxIPFailures:      6,
+		FreeFailures:       2,
+		BaseDelay:          time.Second,
+		MaxDelay:           4 * time.Second,
+	})
+	usecase := NewUserUsecase(NewInMemoryUserRepository(), NewInMemoryRefreshTokenRepository(), NewInMemoryEmailVerificationRepository(), guard,
+		middlerware.NewInMemoryTokenDenylist(), testKeys, bus, TokenConfig{}).(*userUsecase)
+	locked := make(chan AccountLockedEvent, 10)
+	require.NoError(t, bus.Subscribe(AccountLockedTopic, func(ctx context.Context, event eventbus.Event) error {
+		locked <- event.(AccountLockedEvent)
+		return nil
+	}))
+	jane, err := usecase.RegisterUser(ctx, "Jane", "jane@example.com", "password")
+	require.NoError(t, err)
+
+	now := time.Now()
+	usecase.now = func() time.Time { return now }
+	login := func(email, password, ip string) error {
+		_, err := usecase.Login(ctx, email, password, ip)
+		return err
+	}
+
+	t.Run("should slow down and then lock out failing accounts, known or not", func(t *testing.T) {
+		for _, email := range []string{"jane@example.com", "nobody@example.com"} {
+			var throttled *LoginThrottledError
+			require.ErrorIs(t, login(email, "wrong", "192.0.2.1"), ErrInvalidCredentials)
+			require.ErrorIs(t, login(email, "wrong", "192.0.2.2"), ErrInvalidCredentials)
+
+			// Past the free failures each attempt waits, doubling the delay
+			require.ErrorAs(t, login(email, "wrong", "192.0.2.3"), &throttled)
+			assert.Equal(t, time.Second, throttled.RetryAfter)
+			now = now.Add(time.Second)
+			require.ErrorIs(t, login(email, "wrong", "192.0.2.4"), ErrInvalidCredentials)
+			require.ErrorAs(t, login(email, "password", "192.0.2.5"), &throttled)
+			assert.Equal(t, 2*time.Second, throttled.RetryAfter)
+
+			now = now.Add(2 * time.Second)
+			require.ErrorIs(t, login(email, "wrong", "192.0.2.6"), ErrInvalidCredentials)
+			require.ErrorAs(t, login(email, "password", "192.0.2.7"), &throttled)
+			assert.Equal(t, DefaultLoginLockoutDuration, throttled.RetryAfter)
+
+			if email == jane.Email {
+				select {
+				case event := <-locked:
+					assert.Equal(t, jane.ID.String(), event.UserID)
+					assert.Equal(t, now.Add(DefaultLoginLockoutDuration), event.LockedUntil)
+				case <-time.After(time.Second):
+					t.Fatal("no account locked event published")
+				}
+			}
+		}
+		assert.Empty(t, locked, "unknown emails have nobody to tell")
+
+		var throttled *LoginThrottledError
+		assert.ErrorAs(t, login("Jane@Example.com", "password", "192.0.2.8"), &throttled, "emails are counted case-insensitively")
+	})
+
+	t.Run("should let an admin unlock an account", func(t *testing.T) {
+		require.ErrorIs(t, usecase.UnlockLogin(ctx, uuid.New()), ErrUserNotFound)
+		require.NoError(t, usecase.UnlockLogin(ctx, jane.ID))
+		assert.NoError(t, login("jane@example.com", "password", "192.0.2.8"))
+	})
+
+	t.Run("should lock out an IP address failing on many accounts", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		for i := range 6 {
+			require.ErrorIs(t, login(fmt.Sprintf("user%d@example.com", i), "wrong", "198.51.100.7"), ErrInvalidCredentials)
+		}
+		var throttled *LoginThrottledError
+		require.ErrorAs(t, login("jane@example.com", "password", "198.51.100.7"), &throttled)
+		assert.NoError(t, login("jane@example.com", "password", "198.51.100.8"))
+	})
+}
+
+func TestOrderingPolicy(t *testing.T) {
+	ctx := context.Background()
+	users := NewInMemoryUserRepository()
+	verifiedAt := time.Now()
+	verified := &User{ID: uuid.New(), Email: "verified@example.com", EmailVerifiedAt: &verifiedAt}
+	unverified := &User{ID: uuid.New(), Email: "unverified@example.com"}
+	require.NoError(t, users.Save(ctx, verified))
+	require.NoError(t, users.Save(ctx, unverified))
+
+	strict := NewOrderingPolicy(users, true)
+	assert.NoError(t, strict.CanPlaceOrders(ctx, verified.ID))
+	assert.ErrorIs(t, strict.CanPlaceOrders(ctx, unverified.ID), ErrEmailNotVerified)
+	assert.ErrorIs(t, strict.CanPlaceOrders(ctx, uuid.New()), ErrEmailNotVerified)
+
+	lenient := NewOrderingPolicy(users, false)
+	assert.NoError(t, lenient.CanPlaceOrders(ctx, unverified.ID))
+
+	outage := errors.New("connection refused")
+	broken := NewOrderingPolicy(unreachableUsers{UserRepository: users, err: outage}, true)
+	assert.ErrorIs(t, broken.CanPlaceOrders(ctx, verified.ID), outage, "outages are not unverified emails")
+}
+
+// unreachableUsers fails to find any user, as when the database is down.
+type unreachableUsers struct {
+	UserRepository
+	err error
+}
+
+func (r unreachableUsers) FindByID(context.Context, uuid.UUID) (*User, error) {
+	return nil, r.err
+}
+
+// testClientIP is the address test logins come from.
+const testClientIP = "192.0.2.1"
+
+// newTestLoginGuard returns a LoginGuard with the default limits, which the
+// tests outside TestUserUsecase_LoginGuard stay well below.
+func newTestLoginGuard() LoginGuard {
+	return NewLoginGuard(NewInMemoryLoginAttemptStore(), LoginGuardConfig{})
+}
+
+// testKeys signs and verifies the access tokens of the tests.
+var testKeys = func() jwtkeys.KeyManager {
+	key, err := jwtkeys.GenerateKey(jwtkeys.EdDSA)
+	if err != nil {
+		panic(err)
+	}
+	keys, err := jwtkeys.NewKeyManager(key)
+	if err != nil {
+		panic(err)
+	}
+	return keys
+}()
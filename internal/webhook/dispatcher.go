@@ -0,0 +1,42 @@
+package webhook
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Dispatcher sends due webhook deliveries in the background. Every server
+// instance can run one: deliveries are claimed, so each is sent by one of them.
+type Dispatcher struct {
+	usecase  WebhookUsecase
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+func NewDispatcher(usecase WebhookUsecase, interval time.Duration, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{usecase: usecase, interval: interval, logger: logger}
+}
+
+// Run sends due deliveries every interval until ctx is cancelled. Full
+// batches are followed straight away by the next one.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				sent, err := d.usecase.DeliverDue(ctx)
+				if err != nil {
+					d.logger.Error("Failed to send webhook deliveries", "module", "webhook", "error", err)
+				}
+				if sent < DeliveryBatchSize || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
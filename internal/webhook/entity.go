@@ -0,0 +1,229 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"minimart/internal/order"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// SignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256>", signed
+	// over "<unix seconds>.<body>" with the endpoint's secret.
+	SignatureHeader = "X-Minimart-Signature"
+	EventTypeHeader = "X-Minimart-Event"
+	DeliveryHeader  = "X-Minimart-Delivery"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is tried before it is given up on.
+	MaxAttempts = 8
+	// RetryBaseDelay is the wait after the first failed attempt; it doubles
+	// after every further failure.
+	RetryBaseDelay = 30 * time.Second
+)
+
+var (
+	ErrEndpointNotFound = errors.New("webhook endpoint not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrInvalidURL       = errors.New("webhook URL must be an absolute http or https URL")
+	ErrUnknownEventType = errors.New("unknown webhook event type")
+	ErrNoEventTypes     = errors.New("webhook endpoint must subscribe to at least one event type")
+	// ErrForbiddenTarget is returned when an endpoint resolves to a loopback,
+	// private, link-local or other reserved address.
+	ErrForbiddenTarget = errors.New("webhook URL resolves to a forbidden address")
+)
+
+// EventTypes lists the events merchants can receive webhooks for. Each is the
+// event's topic on the event bus.
+var EventTypes = []string{order.OrderPlacedTopic, order.OrderStatusChangedTopic}
+
+// ParseEventTypes validates and de-duplicates the event types an endpoint subscribes to.
+func ParseEventTypes(names []string) ([]string, error) {
+	var eventTypes []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		known := false
+		for _, eventType := range EventTypes {
+			known = known || eventType == name
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownEventType, name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			eventTypes = append(eventTypes, name)
+		}
+	}
+	if len(eventTypes) == 0 {
+		return nil, ErrNoEventTypes
+	}
+	return eventTypes, nil
+}
+
+// Endpoint is a URL a merchant wants events pushed to.
+type Endpoint struct {
+	ID         uuid.UUID `json:"id"`
+	MerchantID uuid.UUID `json:"merchant_id"`
+	URL        string    `json:"url"`
+	// Secret signs every delivery. It is only shown once, when the endpoint is created.
+	Secret     string    `json:"-"`
+	EventTypes []string  `json:"event_types"`
+	IsActive   bool      `json:"is_active"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// NewEndpoint creates an active endpoint with a fresh signing secret.
+func NewEndpoint(merchantID uuid.UUID, url string, eventTypes []string) (*Endpoint, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	return &Endpoint{
+		ID:         uuid.New(),
+		MerchantID: merchantID,
+		URL:        url,
+		Secret:     "whsec_" + base64.RawURLEncoding.EncodeToString(raw),
+		EventTypes: eventTypes,
+		IsActive:   true,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// Subscribes reports whether the endpoint wants events of the given type.
+func (e *Endpoint) Subscribes(eventType string) bool {
+	for _, subscribed := range e.EventTypes {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryStatus is where a delivery is in its retries.
+type DeliveryStatus string
+
+const (
+	// DeliveryPending deliveries are waiting for their next attempt.
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryFailed deliveries ran out of attempts; they can still be redelivered by hand.
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// Delivery is one event on its way to one endpoint.
+type Delivery struct {
+	ID         uuid.UUID `json:"id"`
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	MerchantID uuid.UUID `json:"merchant_id"`
+	EventID    uuid.UUID `json:"event_id"`
+	EventType  string    `json:"event_type"`
+	// Payload is the body sent to the endpoint.
+	Payload  json.RawMessage `json:"payload"`
+	Status   DeliveryStatus  `json:"status"`
+	Attempts int             `json:"attempts"`
+	// NextAttemptAt is nil once the delivery has succeeded or failed.
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	// LastResponseCode is nil until the endpoint has answered.
+	LastResponseCode *int      `json:"last_response_code"`
+	LastError        string    `json:"last_error"`
+	CreatedAt        time.Time `json:"created_at"`
+	// AttemptLog is only filled in when a single delivery is fetched.
+	AttemptLog []Attempt `json:"attempt_log,omitempty"`
+}
+
+// Attempt is the outcome of one try at a delivery.
+type Attempt struct {
+	Attempt int `json:"attempt"`
+	// ResponseCode is nil when the endpoint could not be reached.
+	ResponseCode *int      `json:"response_code"`
+	Error        string    `json:"error"`
+	DurationMs   int64     `json:"duration_ms"`
+	AttemptedAt  time.Time `json:"attempted_at"`
+}
+
+// Succeeded reports whether the endpoint accepted the delivery with a 2xx response.
+func (a Attempt) Succeeded() bool {
+	return a.ResponseCode != nil && *a.ResponseCode >= 200 && *a.ResponseCode < 300
+}
+
+// Record applies the outcome of an attempt made at a.AttemptedAt. Failed
+// attempts are retried with exponential backoff until MaxAttempts, unless
+// retry is false.
+func (d *Delivery) Record(a Attempt, retry bool) {
+	d.Attempts = a.Attempt
+	d.LastResponseCode = a.ResponseCode
+	d.LastError = a.Error
+	d.NextAttemptAt = nil
+	switch {
+	case a.Succeeded():
+		d.Status = DeliverySucceeded
+	case retry && d.Attempts < MaxAttempts:
+		next := a.AttemptedAt.Add(RetryDelay(d.Attempts))
+		d.Status = DeliveryPending
+		d.NextAttemptAt = &next
+	default:
+		d.Status = DeliveryFailed
+	}
+}
+
+// RetryDelay returns how long to wait after the given number of failed attempts.
+func RetryDelay(failedAttempts int) time.Duration {
+	return RetryBaseDelay << (failedAttempts - 1)
+}
+
+// Envelope is the JSON body of every delivery.
+type Envelope struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Sign returns the SignatureHeader value for body sent at the given time.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + unix + ",v1=" + signature(secret, unix, body)
+}
+
+// VerifySignature checks a SignatureHeader value against body, rejecting
+// signatures made more than tolerance away from now. Receivers can use it as is.
+func VerifySignature(secret, header string, body []byte, now time.Time, tolerance time.Duration) bool {
+	var unix, signed string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			unix = value
+		case "v1":
+			signed = value
+		}
+	}
+	seconds, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil || signed == "" {
+		return false
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return false
+	}
+	return hmac.Equal([]byte(signed), []byte(signature(secret, unix, body)))
+}
+
+func signature(secret, unix string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,195 @@
+package webhook
+
+import (
+	"errors"
+	"minimart/internal/merchant"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type WebhookHandler struct {
+	usecase WebhookUsecase
+	auth    fiber.Handler
+	access  merchant.AccessGuard
+}
+
+// NewWebhookHandler creates a new WebhookHandler. Every route runs auth and
+// then access, normally middlerware.AuthRequire and merchant.RequireAccess.
+func NewWebhookHandler(usecase WebhookUsecase, auth fiber.Handler, access merchant.AccessGuard) *WebhookHandler {
+	return &WebhookHandler{usecase: usecase, auth: auth, access: access}
+}
+
+func (h *WebhookHandler) RegisterRoutes(app *fiber.App) {
+	// Not a group: group middleware would also guard the public /merchants/:merchantID routes
+	manage := h.access(merchant.PermManageWebhooks)
+	app.Post("/merchants/:merchantID/webhooks", h.auth, manage, h.CreateEndpoint)
+	app.Get("/merchants/:merchantID/webhooks", h.auth, manage, h.ListEndpoints)
+	app.Patch("/merchants/:merchantID/webhooks/:endpointID", h.auth, manage, h.UpdateEndpoint)
+	app.Delete("/merchants/:merchantID/webhooks/:endpointID", h.auth, manage, h.DeleteEndpoint)
+	app.Get("/merchants/:merchantID/webhooks/:endpointID/deliveries", h.auth, manage, h.ListDeliveries)
+	app.Get("/merchants/:merchantID/webhooks/:endpointID/deliveries/:deliveryID", h.auth, manage, h.GetDelivery)
+	app.Post("/merchants/:merchantID/webhooks/:endpointID/deliveries/:deliveryID/redeliver", h.auth, manage, h.Redeliver)
+}
+
+// CreateEndpointRequest defines the JSON request body for registering a webhook endpoint.
+type CreateEndpointRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+}
+
+// CreateEndpointResponse is the only response that includes the signing secret.
+type CreateEndpointResponse struct {
+	*Endpoint
+	Secret string `json:"secret"`
+}
+
+// CreateEndpoint handles a merchant registering a URL for its events.
+func (h *WebhookHandler) CreateEndpoint(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	var req CreateEndpointRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	endpoint, err := h.usecase.CreateEndpoint(c.Context(), merchantID, req.URL, req.EventTypes)
+	if err != nil {
+		return webhookErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(CreateEndpointResponse{Endpoint: endpoint, Secret: endpoint.Secret})
+}
+
+// ListEndpoints handles listing a merchant's webhook endpoints, without their secrets.
+func (h *WebhookHandler) ListEndpoints(c *fiber.Ctx) error {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merchant ID"})
+	}
+
+	endpoints, err := h.usecase.ListEndpoints(c.Context(), merchantID)
+	if err != nil {
+		return webhookErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(endpoints)
+}
+
+// UpdateEndpointRequest defines the JSON request body for a partial endpoint update.
+type UpdateEndpointRequest struct {
+	URL        *string  `json:"url"`
+	EventTypes []string `json:"event_types"`
+	IsActive   *bool    `json:"is_active"`
+}
+
+// UpdateEndpoint handles changing an endpoint's URL, event types or whether it is active.
+func (h *WebhookHandler) UpdateEndpoint(c *fiber.Ctx) error {
+	merchantID, endpointID, err := endpointParams(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var req UpdateEndpointRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	endpoint, err := h.usecase.UpdateEndpoint(c.Context(), merchantID, endpointID, EndpointChanges{
+		URL:        req.URL,
+		EventTypes: req.EventTypes,
+		IsActive:   req.IsActive,
+	})
+	if err != nil {
+		return webhookErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(endpoint)
+}
+
+// DeleteEndpoint handles removing an endpoint and its delivery log.
+func (h *WebhookHandler) DeleteEndpoint(c *fiber.Ctx) error {
+	merchantID, endpointID, err := endpointParams(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := h.usecase.DeleteEndpoint(c.Context(), merchantID, endpointID); err != nil {
+		return webhookErrorResponse(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListDeliveries handles listing an endpoint's latest deliveries.
+func (h *WebhookHandler) ListDeliveries(c *fiber.Ctx) error {
+	merchantID, endpointID, err := endpointParams(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	deliveries, err := h.usecase.ListDeliveries(c.Context(), merchantID, endpointID)
+	if err != nil {
+		return webhookErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(deliveries)
+}
+
+// GetDelivery handles fetching a delivery with the response code of each attempt.
+func (h *WebhookHandler) GetDelivery(c *fiber.Ctx) error {
+	merchantID, endpointID, err := endpointParams(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	deliveryID, err := uuid.Parse(c.Params("deliveryID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid delivery ID"})
+	}
+
+	delivery, err := h.usecase.GetDelivery(c.Context(), merchantID, endpointID, deliveryID)
+	if err != nil {
+		return webhookErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(delivery)
+}
+
+// Redeliver handles a merchant sending a delivery again, e.g. after fixing their receiver.
+func (h *WebhookHandler) Redeliver(c *fiber.Ctx) error {
+	merchantID, endpointID, err := endpointParams(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	deliveryID, err := uuid.Parse(c.Params("deliveryID"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid delivery ID"})
+	}
+
+	delivery, err := h.usecase.Redeliver(c.Context(), merchantID, endpointID, deliveryID)
+	if err != nil {
+		return webhookErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(delivery)
+}
+
+// endpointParams parses the merchant and endpoint IDs in the route.
+func endpointParams(c *fiber.Ctx) (uuid.UUID, uuid.UUID, error) {
+	merchantID, err := uuid.Parse(c.Params("merchantID"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, errors.New("Invalid merchant ID")
+	}
+	endpointID, err := uuid.Parse(c.Params("endpointID"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, errors.New("Invalid endpoint ID")
+	}
+	return merchantID, endpointID, nil
+}
+
+// webhookErrorResponse maps the errors shared by the webhook endpoints to HTTP responses.
+func webhookErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrEndpointNotFound), errors.Is(err, ErrDeliveryNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrInvalidURL), errors.Is(err, ErrUnknownEventType), errors.Is(err, ErrNoEventTypes):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
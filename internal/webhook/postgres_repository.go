@@ -0,0 +1,232 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresWebhookRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresWebhookRepository(db *pgxpool.Pool) WebhookRepository {
+	return &PostgresWebhookRepository{db: db}
+}
+
+func (r *PostgresWebhookRepository) SaveEndpoint(ctx context.Context, endpoint *Endpoint) error {
+	query := `
+		INSERT INTO webhook_endpoints (id, merchant_id, url, secret, event_types, is_active, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7);
+	`
+	_, err := r.db.Exec(ctx, query, endpoint.ID, endpoint.MerchantID, endpoint.URL, endpoint.Secret, endpoint.EventTypes,
+		endpoint.IsActive, endpoint.CreatedAt)
+	return err
+}
+
+func (r *PostgresWebhookRepository) UpdateEndpoint(ctx context.Context, endpoint *Endpoint) error {
+	query := "UPDATE webhook_endpoints SET url = $2, event_types = $3, is_active = $4 WHERE id = $1"
+	tag, err := r.db.Exec(ctx, query, endpoint.ID, endpoint.URL, endpoint.EventTypes, endpoint.IsActive)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrEndpointNotFound
+	}
+	return nil
+}
+
+func (r *PostgresWebhookRepository) DeleteEndpoint(ctx context.Context, id uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM webhook_endpoints WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrEndpointNotFound
+	}
+	return nil
+}
+
+func (r *PostgresWebhookRepository) GetEndpoint(ctx context.Context, id uuid.UUID) (*Endpoint, error) {
+	query := "SELECT " + endpointColumns + " FROM webhook_endpoints WHERE id = $1"
+	endpoint, err := scanEndpoint(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrEndpointNotFound
+	}
+	return endpoint, err
+}
+
+func (r *PostgresWebhookRepository) ListEndpoints(ctx context.Context, merchantID uuid.UUID) ([]*Endpoint, error) {
+	query := "SELECT " + endpointColumns + " FROM webhook_endpoints WHERE merchant_id = $1 ORDER BY created_at"
+	rows, err := r.db.Query(ctx, query, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	endpoints := []*Endpoint{}
+	for rows.Next() {
+		endpoint, err := scanEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, rows.Err()
+}
+
+func (r *PostgresWebhookRepository) EnqueueDelivery(ctx context.Context, delivery *Delivery) (bool, error) {
+	query := `
+		INSERT INTO webhook_deliveries (id, endpoint_id, merchant_id, event_id, event_type, payload, status, attempts,
+			next_attempt_at, last_response_code, last_error, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (endpoint_id, event_id) DO NOTHING;
+	`
+	tag, err := r.db.Exec(ctx, query, delivery.ID, delivery.EndpointID, delivery.MerchantID, delivery.EventID, delivery.EventType,
+		[]byte(delivery.Payload), delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastResponseCode,
+		delivery.LastError, delivery.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ClaimDueDeliveries skips rows other workers have locked, so concurrent
+// instances never claim the same delivery.
+func (r *PostgresWebhookRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*Delivery, error) {
+	query := `
+		UPDATE webhook_deliveries
+		SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = $3 AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + deliveryColumns + `;
+	`
+	rows, err := r.db.Query(ctx, query, now, now.Add(lease), DeliveryPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows)
+}
+
+func (r *PostgresWebhookRepository) RecordAttempt(ctx context.Context, delivery *Delivery, attempt Attempt) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, next_attempt_at = $4, last_response_code = $5, last_error = $6
+		WHERE id = $1;
+	`
+	tag, err := tx.Exec(ctx, query, delivery.ID, delivery.Status, delivery.Attempts, delivery.NextAttemptAt,
+		delivery.LastResponseCode, delivery.LastError)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDeliveryNotFound
+	}
+
+	attemptQuery := `
+		INSERT INTO webhook_delivery_attempts (delivery_id, attempt, response_code, error, duration_ms, attempted_at)
+		VALUES ($1, $2, $3, $4, $5, $6);
+	`
+	if _, err := tx.Exec(ctx, attemptQuery, delivery.ID, attempt.Attempt, attempt.ResponseCode, attempt.Error,
+		attempt.DurationMs, attempt.AttemptedAt); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresWebhookRepository) GetDelivery(ctx context.Context, id uuid.UUID) (*Delivery, error) {
+	query := "SELECT " + deliveryColumns + " FROM webhook_deliveries WHERE id = $1"
+	delivery, err := scanDelivery(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrDeliveryNotFound
+		}
+		return nil, err
+	}
+
+	attemptsQuery := `
+		SELECT attempt, response_code, error, duration_ms, attempted_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = $1
+		ORDER BY attempt, id;
+	`
+	rows, err := r.db.Query(ctx, attemptsQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	delivery.AttemptLog = []Attempt{}
+	for rows.Next() {
+		var attempt Attempt
+		if err := rows.Scan(&attempt.Attempt, &attempt.ResponseCode, &attempt.Error, &attempt.DurationMs, &attempt.AttemptedAt); err != nil {
+			return nil, err
+		}
+		delivery.AttemptLog = append(delivery.AttemptLog, attempt)
+	}
+	return delivery, rows.Err()
+}
+
+func (r *PostgresWebhookRepository) ListDeliveries(ctx context.Context, endpointID uuid.UUID, limit int) ([]*Delivery, error) {
+	query := "SELECT " + deliveryColumns + " FROM webhook_deliveries WHERE endpoint_id = $1 ORDER BY created_at DESC LIMIT $2"
+	rows, err := r.db.Query(ctx, query, endpointID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows)
+}
+
+const endpointColumns = "id, merchant_id, url, secret, event_types, is_active, created_at"
+
+func scanEndpoint(row pgx.Row) (*Endpoint, error) {
+	var endpoint Endpoint
+	if err := row.Scan(&endpoint.ID, &endpoint.MerchantID, &endpoint.URL, &endpoint.Secret, &endpoint.EventTypes,
+		&endpoint.IsActive, &endpoint.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+const deliveryColumns = "id, endpoint_id, merchant_id, event_id, event_type, payload, status, attempts, next_attempt_at, " +
+	"last_response_code, last_error, created_at"
+
+func scanDelivery(row pgx.Row) (*Delivery, error) {
+	var delivery Delivery
+	var payload []byte
+	if err := row.Scan(&delivery.ID, &delivery.EndpointID, &delivery.MerchantID, &delivery.EventID, &delivery.EventType,
+		&payload, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastResponseCode,
+		&delivery.LastError, &delivery.CreatedAt); err != nil {
+		return nil, err
+	}
+	delivery.Payload = payload
+	return &delivery, nil
+}
+
+func scanDeliveries(rows pgx.Rows) ([]*Delivery, error) {
+	defer rows.Close()
+
+	deliveries := []*Delivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
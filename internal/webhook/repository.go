@@ -0,0 +1,197 @@
+package webhook
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookRepository interface {
+	SaveEndpoint(ctx context.Context, endpoint *Endpoint) error
+	UpdateEndpoint(ctx context.Context, endpoint *Endpoint) error
+	// DeleteEndpoint removes the endpoint along with its deliveries.
+	DeleteEndpoint(ctx context.Context, id uuid.UUID) error
+	GetEndpoint(ctx context.Context, id uuid.UUID) (*Endpoint, error)
+	// ListEndpoints returns the merchant's endpoints, oldest first.
+	ListEndpoints(ctx context.Context, merchantID uuid.UUID) ([]*Endpoint, error)
+
+	// EnqueueDelivery stores a new delivery. It reports false, without an
+	// error, if the endpoint already has a delivery for the event.
+	EnqueueDelivery(ctx context.Context, delivery *Delivery) (bool, error)
+	// ClaimDueDeliveries returns up to limit pending deliveries due at now, and
+	// moves their next attempt lease later so that no other worker claims them meanwhile.
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*Delivery, error)
+	// RecordAttempt stores the delivery's new state along with the attempt that led to it.
+	RecordAttempt(ctx context.Context, delivery *Delivery, attempt Attempt) error
+	// GetDelivery returns the delivery with its attempt log.
+	GetDelivery(ctx context.Context, id uuid.UUID) (*Delivery, error)
+	// ListDeliveries returns the endpoint's latest deliveries, newest first.
+	ListDeliveries(ctx context.Context, endpointID uuid.UUID, limit int) ([]*Delivery, error)
+}
+
+type InMemoryWebhookRepository struct {
+	mu         sync.Mutex
+	endpoints  map[uuid.UUID]*Endpoint
+	deliveries map[uuid.UUID]*Delivery
+	attempts   map[uuid.UUID][]Attempt
+}
+
+func NewInMemoryWebhookRepository() *InMemoryWebhookRepository {
+	return &InMemoryWebhookRepository{
+		endpoints:  map[uuid.UUID]*Endpoint{},
+		deliveries: map[uuid.UUID]*Delivery{},
+		attempts:   map[uuid.UUID][]Attempt{},
+	}
+}
+
+func (r *InMemoryWebhookRepository) SaveEndpoint(ctx context.Context, endpoint *Endpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *endpoint
+	r.endpoints[endpoint.ID] = &copied
+	return nil
+}
+
+func (r *InMemoryWebhookRepository) UpdateEndpoint(ctx context.Context, endpoint *Endpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.endpoints[endpoint.ID]; !exists {
+		return ErrEndpointNotFound
+	}
+	copied := *endpoint
+	r.endpoints[endpoint.ID] = &copied
+	return nil
+}
+
+func (r *InMemoryWebhookRepository) DeleteEndpoint(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.endpoints[id]; !exists {
+		return ErrEndpointNotFound
+	}
+	delete(r.endpoints, id)
+	for deliveryID, delivery := range r.deliveries {
+		if delivery.EndpointID == id {
+			delete(r.deliveries, deliveryID)
+			delete(r.attempts, deliveryID)
+		}
+	}
+	return nil
+}
+
+func (r *InMemoryWebhookRepository) GetEndpoint(ctx context.Context, id uuid.UUID) (*Endpoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	endpoint, exists := r.endpoints[id]
+	if !exists {
+		return nil, ErrEndpointNotFound
+	}
+	copied := *endpoint
+	return &copied, nil
+}
+
+func (r *InMemoryWebhookRepository) ListEndpoints(ctx context.Context, merchantID uuid.UUID) ([]*Endpoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	endpoints := []*Endpoint{}
+	for _, endpoint := range r.endpoints {
+		if endpoint.MerchantID == merchantID {
+			copied := *endpoint
+			endpoints = append(endpoints, &copied)
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].CreatedAt.Before(endpoints[j].CreatedAt) })
+	return endpoints, nil
+}
+
+func (r *InMemoryWebhookRepository) EnqueueDelivery(ctx context.Context, delivery *Delivery) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.deliveries {
+		if existing.EndpointID == delivery.EndpointID && existing.EventID == delivery.EventID {
+			return false, nil
+		}
+	}
+	copied := *delivery
+	r.deliveries[delivery.ID] = &copied
+	return true, nil
+}
+
+func (r *InMemoryWebhookRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []*Delivery
+	for _, delivery := range r.deliveries {
+		if delivery.Status == DeliveryPending && delivery.NextAttemptAt != nil && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(*due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*Delivery, 0, len(due))
+	for _, delivery := range due {
+		leased := now.Add(lease)
+		delivery.NextAttemptAt = &leased
+		copied := *delivery
+		claimed = append(claimed, &copied)
+	}
+	return claimed, nil
+}
+
+func (r *InMemoryWebhookRepository) RecordAttempt(ctx context.Context, delivery *Delivery, attempt Attempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.deliveries[delivery.ID]; !exists {
+		return ErrDeliveryNotFound
+	}
+	copied := *delivery
+	copied.AttemptLog = nil
+	r.deliveries[delivery.ID] = &copied
+	r.attempts[delivery.ID] = append(r.attempts[delivery.ID], attempt)
+	return nil
+}
+
+func (r *InMemoryWebhookRepository) GetDelivery(ctx context.Context, id uuid.UUID) (*Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delivery, exists := r.deliveries[id]
+	if !exists {
+		return nil, ErrDeliveryNotFound
+	}
+	copied := *delivery
+	copied.AttemptLog = append([]Attempt{}, r.attempts[id]...)
+	return &copied, nil
+}
+
+func (r *InMemoryWebhookRepository) ListDeliveries(ctx context.Context, endpointID uuid.UUID, limit int) ([]*Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deliveries := []*Delivery{}
+	for _, delivery := range r.deliveries {
+		if delivery.EndpointID == endpointID {
+			copied := *delivery
+			deliveries = append(deliveries, &copied)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt) })
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
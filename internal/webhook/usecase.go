@@ -0,0 +1,317 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"minimart/internal/order"
+	"minimart/internal/shared/eventbus"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DeliveryBatchSize is how many due deliveries DeliverDue sends at most.
+	DeliveryBatchSize = 10
+	// DeliveryLease is how long claimed deliveries are hidden from other
+	// workers while they are sent. It must outlast sending a whole batch at
+	// the HTTP client's timeout.
+	DeliveryLease = 5 * time.Minute
+	// DeliveryListLimit is how many of an endpoint's latest deliveries are listed.
+	DeliveryListLimit = 100
+)
+
+// maxResponseBytes is how much of a receiver's response is read before the
+// connection is let go.
+const maxResponseBytes = 64 << 10
+
+type WebhookUsecase interface {
+	// CreateEndpoint registers a URL for the merchant's events. The returned
+	// endpoint carries the signing secret, which is not shown again.
+	CreateEndpoint(ctx context.Context, merchantID uuid.UUID, rawURL string, eventTypes []string) (*Endpoint, error)
+	ListEndpoints(ctx context.Context, merchantID uuid.UUID) ([]*Endpoint, error)
+	UpdateEndpoint(ctx context.Context, merchantID, endpointID uuid.UUID, changes EndpointChanges) (*Endpoint, error)
+	DeleteEndpoint(ctx context.Context, merchantID, endpointID uuid.UUID) error
+
+	// ListDeliveries returns the endpoint's latest deliveries, newest first.
+	ListDeliveries(ctx context.Context, merchantID, endpointID uuid.UUID) ([]*Delivery, error)
+	// GetDelivery returns a delivery with its attempt log.
+	GetDelivery(ctx context.Context, merchantID, endpointID, deliveryID uuid.UUID) (*Delivery, error)
+	// Redeliver sends a delivery again right away, whatever its status, and
+	// returns it with its attempt log. Failed deliveries stay failed if this
+	// attempt fails too; pending ones keep retrying.
+	Redeliver(ctx context.Context, merchantID, endpointID, deliveryID uuid.UUID) (*Delivery, error)
+
+	// HandleOrderEvent queues a delivery of an order event to each of the
+	// merchant's active endpoints that subscribe to it. Hearing the same event
+	// again is a no-op.
+	HandleOrderEvent(ctx context.Context, event eventbus.Event) error
+	// DeliverDue sends the deliveries whose next attempt is due and returns how many it sent.
+	DeliverDue(ctx context.Context) (int, error)
+}
+
+// EndpointChanges holds the fields of a partial endpoint update.
+// Nil fields are left untouched.
+type EndpointChanges struct {
+	URL        *string
+	EventTypes []string
+	IsActive   *bool
+}
+
+type webhookUsecase struct {
+	repo   WebhookRepository
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewWebhookUsecase creates a WebhookUsecase that sends deliveries with
+// client, normally one from NewHTTPClient.
+func NewWebhookUsecase(repo WebhookRepository, client *http.Client) WebhookUsecase {
+	return &webhookUsecase{repo: repo, client: client, now: time.Now}
+}
+
+func (u *webhookUsecase) CreateEndpoint(ctx context.Context, merchantID uuid.UUID, rawURL string, eventTypes []string) (*Endpoint, error) {
+	endpointURL, err := parseEndpointURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	parsedTypes, err := ParseEventTypes(eventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := NewEndpoint(merchantID, endpointURL, parsedTypes)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.repo.SaveEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (u *webhookUsecase) ListEndpoints(ctx context.Context, merchantID uuid.UUID) ([]*Endpoint, error) {
+	return u.repo.ListEndpoints(ctx, merchantID)
+}
+
+func (u *webhookUsecase) UpdateEndpoint(ctx context.Context, merchantID, endpointID uuid.UUID, changes EndpointChanges) (*Endpoint, error) {
+	endpoint, err := u.merchantEndpoint(ctx, merchantID, endpointID)
+	if err != nil {
+		return nil, err
+	}
+
+	if changes.URL != nil {
+		if endpoint.URL, err = parseEndpointURL(*changes.URL); err != nil {
+			return nil, err
+		}
+	}
+	if changes.EventTypes != nil {
+		if endpoint.EventTypes, err = ParseEventTypes(changes.EventTypes); err != nil {
+			return nil, err
+		}
+	}
+	if changes.IsActive != nil {
+		endpoint.IsActive = *changes.IsActive
+	}
+	if err := u.repo.UpdateEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (u *webhookUsecase) DeleteEndpoint(ctx context.Context, merchantID, endpointID uuid.UUID) error {
+	if _, err := u.merchantEndpoint(ctx, merchantID, endpointID); err != nil {
+		return err
+	}
+	return u.repo.DeleteEndpoint(ctx, endpointID)
+}
+
+func (u *webhookUsecase) ListDeliveries(ctx context.Context, merchantID, endpointID uuid.UUID) ([]*Delivery, error) {
+	if _, err := u.merchantEndpoint(ctx, merchantID, endpointID); err != nil {
+		return nil, err
+	}
+	return u.repo.ListDeliveries(ctx, endpointID, DeliveryListLimit)
+}
+
+func (u *webhookUsecase) GetDelivery(ctx context.Context, merchantID, endpointID, deliveryID uuid.UUID) (*Delivery, error) {
+	if _, err := u.merchantEndpoint(ctx, merchantID, endpointID); err != nil {
+		return nil, err
+	}
+	delivery, err := u.repo.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.EndpointID != endpointID {
+		return nil, ErrDeliveryNotFound
+	}
+	return delivery, nil
+}
+
+func (u *webhookUsecase) Redeliver(ctx context.Context, merchantID, endpointID, deliveryID uuid.UUID) (*Delivery, error) {
+	endpoint, err := u.merchantEndpoint(ctx, merchantID, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := u.GetDelivery(ctx, merchantID, endpointID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.send(ctx, endpoint, delivery, delivery.Status == DeliveryPending); err != nil {
+		return nil, err
+	}
+	return u.repo.GetDelivery(ctx, deliveryID)
+}
+
+func (u *webhookUsecase) HandleOrderEvent(ctx context.Context, event eventbus.Event) error {
+	var eventID, merchantID string
+	var occurredAt time.Time
+	switch orderEvent := event.(type) {
+	case order.OrderPlacedEvent:
+		eventID, merchantID, occurredAt = orderEvent.EventID, orderEvent.MerchantID, orderEvent.CreatedAt
+	case order.OrderStatusChangedEvent:
+		eventID, merchantID, occurredAt = orderEvent.EventID, orderEvent.MerchantID, orderEvent.ChangedAt
+	default:
+		return fmt.Errorf("%w: %T", ErrUnknownEventType, event)
+	}
+	parsedEventID, err := uuid.Parse(eventID)
+	if err != nil {
+		return fmt.Errorf("event %s has an invalid ID: %w", event.Topic(), err)
+	}
+	parsedMerchantID, err := uuid.Parse(merchantID)
+	if err != nil {
+		return fmt.Errorf("event %s has an invalid merchant ID: %w", event.Topic(), err)
+	}
+
+	endpoints, err := u.repo.ListEndpoints(ctx, parsedMerchantID)
+	if err != nil {
+		return err
+	}
+	var payload []byte
+	for _, endpoint := range endpoints {
+		if !endpoint.IsActive || !endpoint.Subscribes(event.Topic()) {
+			continue
+		}
+		if payload == nil {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			if payload, err = json.Marshal(Envelope{ID: parsedEventID, Type: event.Topic(), CreatedAt: occurredAt, Data: data}); err != nil {
+				return err
+			}
+		}
+
+		now := u.now()
+		delivery := &Delivery{
+			ID:            uuid.New(),
+			EndpointID:    endpoint.ID,
+			MerchantID:    endpoint.MerchantID,
+			EventID:       parsedEventID,
+			EventType:     event.Topic(),
+			Payload:       payload,
+			Status:        DeliveryPending,
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+		}
+		if _, err := u.repo.EnqueueDelivery(ctx, delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *webhookUsecase) DeliverDue(ctx context.Context) (int, error) {
+	deliveries, err := u.repo.ClaimDueDeliveries(ctx, u.now(), DeliveryLease, DeliveryBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	endpoints := map[uuid.UUID]*Endpoint{}
+	for _, delivery := range deliveries {
+		endpoint, found := endpoints[delivery.EndpointID]
+		if !found {
+			if endpoint, err = u.repo.GetEndpoint(ctx, delivery.EndpointID); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			endpoints[endpoint.ID] = endpoint
+		}
+
+		if !endpoint.IsActive {
+			// Disabled endpoints get nothing; their deliveries can be redelivered once they are back.
+			attempt := Attempt{Attempt: delivery.Attempts + 1, Error: "endpoint is disabled", AttemptedAt: u.now()}
+			delivery.Record(attempt, false)
+			errs = append(errs, u.repo.RecordAttempt(ctx, delivery, attempt))
+			continue
+		}
+		errs = append(errs, u.send(ctx, endpoint, delivery, true))
+	}
+	return len(deliveries), errors.Join(errs...)
+}
+
+// send makes one attempt at the delivery and records its outcome. Errors
+// reaching the endpoint are part of the outcome; only failing to record it is
+// returned.
+func (u *webhookUsecase) send(ctx context.Context, endpoint *Endpoint, delivery *Delivery, retry bool) error {
+	attempt := Attempt{Attempt: delivery.Attempts + 1, AttemptedAt: u.now()}
+	started := time.Now()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "Minimart-Webhooks/1.0")
+	request.Header.Set(EventTypeHeader, delivery.EventType)
+	request.Header.Set(DeliveryHeader, delivery.ID.String())
+	request.Header.Set(SignatureHeader, Sign(endpoint.Secret, attempt.AttemptedAt, delivery.Payload))
+
+	response, err := u.client.Do(request)
+	attempt.DurationMs = time.Since(started).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+	} else {
+		io.Copy(io.Discard, io.LimitReader(response.Body, maxResponseBytes))
+		response.Body.Close()
+		code := response.StatusCode
+		attempt.ResponseCode = &code
+		if !attempt.Succeeded() {
+			attempt.Error = response.Status
+		}
+	}
+
+	delivery.Record(attempt, retry)
+	return u.repo.RecordAttempt(ctx, delivery, attempt)
+}
+
+// merchantEndpoint returns the endpoint if it belongs to the merchant.
+// Endpoints of other merchants are reported as missing rather than forbidden.
+func (u *webhookUsecase) merchantEndpoint(ctx context.Context, merchantID, endpointID uuid.UUID) (*Endpoint, error) {
+	endpoint, err := u.repo.GetEndpoint(ctx, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.MerchantID != merchantID {
+		return nil, ErrEndpointNotFound
+	}
+	return endpoint, nil
+}
+
+// parseEndpointURL checks rawURL is an absolute http or https URL.
+func parseEndpointURL(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || parsed.User != nil {
+		return "", ErrInvalidURL
+	}
+	return rawURL, nil
+}
@@ -0,0 +1,246 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"minimart/internal/order"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver is a local webhook endpoint that checks signatures and answers
+// with whatever status the test sets.
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	secret   string
+	now      func() time.Time
+	status   int
+	received []Envelope
+	headers  []http.Header
+}
+
+func newReceiver(t *testing.T) *receiver {
+	r := &receiver{status: http.StatusOK, now: time.Now}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if !VerifySignature(r.secret, req.Header.Get(SignatureHeader), body, r.now(), 5*time.Minute) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var envelope Envelope
+		require.NoError(t, json.Unmarshal(body, &envelope))
+		r.received = append(r.received, envelope)
+		r.headers = append(r.headers, req.Header.Clone())
+		w.WriteHeader(r.status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) respondWith(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.received)
+}
+
+func placedEvent(merchantID uuid.UUID) order.OrderPlacedEvent {
+	return order.OrderPlacedEvent{
+		EventID:     uuid.NewString(),
+		OrderID:     uuid.NewString(),
+		MerchantID:  merchantID.String(),
+		CustomerID:  uuid.NewString(),
+		Status:      "NEW",
+		TotalAmount: 1250,
+		Currency:    "USD",
+		CreatedAt:   time.Now(),
+	}
+}
+
+func TestWebhookUsecase(t *testing.T) {
+	ctx := context.Background()
+	merchantID := uuid.New()
+	server := newReceiver(t)
+
+	repo := NewInMemoryWebhookRepository()
+	usecase := NewWebhookUsecase(repo, server.Client()).(*webhookUsecase)
+	clock := time.Now()
+	usecase.now = func() time.Time { return clock }
+	server.now = usecase.now
+
+	endpoint, err := usecase.CreateEndpoint(ctx, merchantID, server.URL+"/hooks", []string{order.OrderPlacedTopic})
+	require.NoError(t, err)
+	server.secret = endpoint.Secret
+
+	t.Run("should validate endpoints", func(t *testing.T) {
+		_, err := usecase.CreateEndpoint(ctx, merchantID, "ftp://example.com", []string{order.OrderPlacedTopic})
+		assert.ErrorIs(t, err, ErrInvalidURL)
+
+		_, err = usecase.CreateEndpoint(ctx, merchantID, "https://example.com", []string{"order.eaten"})
+		assert.ErrorIs(t, err, ErrUnknownEventType)
+
+		_, err = usecase.CreateEndpoint(ctx, merchantID, "https://example.com", nil)
+		assert.ErrorIs(t, err, ErrNoEventTypes)
+
+		_, err = usecase.ListDeliveries(ctx, uuid.New(), endpoint.ID)
+		assert.ErrorIs(t, err, ErrEndpointNotFound)
+	})
+
+	t.Run("should deliver signed events the endpoint subscribes to once", func(t *testing.T) {
+		event := placedEvent(merchantID)
+		require.NoError(t, usecase.HandleOrderEvent(ctx, event))
+		// Every server instance hears the event
+		require.NoError(t, usecase.HandleOrderEvent(ctx, event))
+		require.NoError(t, usecase.HandleOrderEvent(ctx, order.OrderStatusChangedEvent{
+			EventID: uuid.NewString(), MerchantID: merchantID.String(), From: "NEW", To: "PENDING",
+		}))
+		require.NoError(t, usecase.HandleOrderEvent(ctx, placedEvent(uuid.New())))
+
+		sent, err := usecase.DeliverDue(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		require.Equal(t, 1, server.count())
+
+		assert.Equal(t, event.EventID, server.received[0].ID.String())
+		assert.Equal(t, order.OrderPlacedTopic, server.received[0].Type)
+		var data order.OrderPlacedEvent
+		require.NoError(t, json.Unmarshal(server.received[0].Data, &data))
+		assert.Equal(t, event.OrderID, data.OrderID)
+		assert.Equal(t, order.OrderPlacedTopic, server.headers[0].Get(EventTypeHeader))
+
+		deliveries, err := usecase.ListDeliveries(ctx, merchantID, endpoint.ID)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, DeliverySucceeded, deliveries[0].Status)
+		assert.Equal(t, http.StatusOK, *deliveries[0].LastResponseCode)
+		assert.Equal(t, deliveries[0].ID.String(), server.headers[0].Get(DeliveryHeader))
+	})
+
+	t.Run("should retry failures with exponential backoff until giving up", func(t *testing.T) {
+		server.respondWith(http.StatusInternalServerError)
+		clock = clock.Add(time.Minute)
+		require.NoError(t, usecase.HandleOrderEvent(ctx, placedEvent(merchantID)))
+
+		var deliveryID uuid.UUID
+		for attempt := 1; attempt <= MaxAttempts; attempt++ {
+			sent, err := usecase.DeliverDue(ctx)
+			require.NoError(t, err)
+			require.Equal(t, 1, sent, attempt)
+
+			deliveries, err := usecase.ListDeliveries(ctx, merchantID, endpoint.ID)
+			require.NoError(t, err)
+			delivery := deliveries[0]
+			deliveryID = delivery.ID
+			assert.Equal(t, attempt, delivery.Attempts)
+			assert.Equal(t, http.StatusInternalServerError, *delivery.LastResponseCode)
+			if attempt < MaxAttempts {
+				assert.Equal(t, DeliveryPending, delivery.Status)
+				assert.Equal(t, clock.Add(RetryBaseDelay<<(attempt-1)), *delivery.NextAttemptAt)
+
+				// Nothing is sent before the backoff has passed
+				sent, err = usecase.DeliverDue(ctx)
+				require.NoError(t, err)
+				assert.Zero(t, sent)
+				clock = *delivery.NextAttemptAt
+			} else {
+				assert.Equal(t, DeliveryFailed, delivery.Status)
+				assert.Nil(t, delivery.NextAttemptAt)
+			}
+		}
+
+		t.Run("and redeliver by hand", func(t *testing.T) {
+			server.respondWith(http.StatusAccepted)
+			delivery, err := usecase.Redeliver(ctx, merchantID, endpoint.ID, deliveryID)
+			require.NoError(t, err)
+			assert.Equal(t, DeliverySucceeded, delivery.Status)
+			require.Len(t, delivery.AttemptLog, MaxAttempts+1)
+			assert.Equal(t, http.StatusInternalServerError, *delivery.AttemptLog[0].ResponseCode)
+			assert.Equal(t, http.StatusAccepted, *delivery.AttemptLog[MaxAttempts].ResponseCode)
+
+			_, err = usecase.Redeliver(ctx, merchantID, uuid.New(), deliveryID)
+			assert.ErrorIs(t, err, ErrEndpointNotFound)
+		})
+	})
+
+	t.Run("should not deliver to disabled endpoints", func(t *testing.T) {
+		before := server.count()
+		inactive := false
+		_, err := usecase.UpdateEndpoint(ctx, merchantID, endpoint.ID, EndpointChanges{IsActive: &inactive})
+		require.NoError(t, err)
+
+		require.NoError(t, usecase.HandleOrderEvent(ctx, placedEvent(merchantID)))
+		sent, err := usecase.DeliverDue(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, sent)
+		assert.Equal(t, before, server.count())
+	})
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, err := NewHTTPClient(time.Second, false).Post(server.URL, "application/json", nil)
+	assert.ErrorIs(t, err, ErrForbiddenTarget)
+
+	response, err := NewHTTPClient(time.Second, true).Post(server.URL, "application/json", nil)
+	require.NoError(t, err)
+	response.Body.Close()
+}
+
+func TestIsForbiddenTarget(t *testing.T) {
+	forbidden := map[string]string{
+		"loopback":                 "127.0.0.1",
+		"private":                  "10.1.2.3",
+		"link-local":               "169.254.169.254",
+		"unspecified":              "::",
+		"this network":             "0.1.2.3",
+		"shared address space":     "100.64.0.1",
+		"shared address space end": "100.127.255.254",
+		"IETF protocol assignment": "192.0.0.8",
+		"benchmarking":             "198.18.0.1",
+		"benchmarking end":         "198.19.255.254",
+		"IPv4-mapped CGNAT":        "::ffff:100.64.0.1",
+		"NAT64 private":            "64:ff9b::a00:1",
+		"NAT64 loopback":           "64:ff9b::7f00:1",
+		"NAT64 local-use":          "64:ff9b:1::1",
+	}
+	for name, address := range forbidden {
+		assert.True(t, isForbiddenTarget(net.ParseIP(address)), name)
+	}
+
+	for _, address := range []string{"93.184.216.34", "100.63.255.255", "100.128.0.1", "198.20.0.1", "192.0.1.1", "2606:4700::1111", "64:ff9b:2::1"} {
+		assert.False(t, isForbiddenTarget(net.ParseIP(address)), address)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	signedAt := time.Now()
+	header := Sign("whsec_test", signedAt, body)
+
+	assert.True(t, VerifySignature("whsec_test", header, body, signedAt, time.Minute))
+	assert.False(t, VerifySignature("whsec_other", header, body, signedAt, time.Minute))
+	assert.False(t, VerifySignature("whsec_test", header, []byte(`{"id":"2"}`), signedAt, time.Minute))
+	assert.False(t, VerifySignature("whsec_test", header, body, signedAt.Add(2*time.Minute), time.Minute))
+	assert.False(t, VerifySignature("whsec_test", "v1=abc", body, signedAt, time.Minute))
+}
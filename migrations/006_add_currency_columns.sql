@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Every merchant prices its menu in a single ISO 4217 currency.
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Prices are stored in minor units (e.g. cents) of the given currency.
+ALTER TABLE menu_items
+    ALTER COLUMN price TYPE BIGINT,
+    ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+UPDATE menu_items mi
+SET currency = m.currency
+FROM merchants m
+WHERE m.id = mi.merchant_id;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Orders are placed with a single merchant and priced in its currency.
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS merchant_id UUID,
+    ADD COLUMN IF NOT EXISTS total_amount BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE order_items
+    ADD COLUMN IF NOT EXISTS unit_price BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+CREATE INDEX IF NOT EXISTS idx_orders_merchant_id ON orders(merchant_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_orders_merchant_id;
+ALTER TABLE order_items DROP COLUMN IF EXISTS currency, DROP COLUMN IF EXISTS unit_price;
+ALTER TABLE orders DROP COLUMN IF EXISTS currency, DROP COLUMN IF EXISTS total_amount, DROP COLUMN IF EXISTS merchant_id;
+ALTER TABLE menu_items DROP COLUMN IF EXISTS currency, ALTER COLUMN price TYPE INT;
+ALTER TABLE merchants DROP COLUMN IF EXISTS currency;
+-- +goose StatementEnd
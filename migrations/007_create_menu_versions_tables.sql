@@ -0,0 +1,57 @@
+-- +goose Up
+-- +goose StatementBegin
+-- menu_items holds each merchant's draft menu. Publishing copies the draft
+-- into an immutable version; customers only ever see the live version.
+CREATE TABLE IF NOT EXISTS menu_versions (
+    id UUID PRIMARY KEY,
+    merchant_id UUID NOT NULL,
+    number INT NOT NULL,
+    is_live BOOLEAN NOT NULL DEFAULT FALSE,
+    published_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (merchant_id, number)
+);
+
+CREATE TABLE IF NOT EXISTS menu_version_items (
+    version_id UUID NOT NULL REFERENCES menu_versions(id) ON DELETE CASCADE,
+    item_id UUID NOT NULL,
+    merchant_id UUID NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    price BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    in_stock BOOLEAN NOT NULL,
+    image_url TEXT NOT NULL DEFAULT '',
+    thumbnail_url TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (version_id, item_id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS idx_menu_versions_live ON menu_versions(merchant_id) WHERE is_live;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Publish today's menus as version 1 so customers keep seeing them.
+INSERT INTO menu_versions (id, merchant_id, number, is_live)
+SELECT gen_random_uuid(), merchant_id, 1, TRUE
+FROM menu_items
+GROUP BY merchant_id;
+
+INSERT INTO menu_version_items (version_id, item_id, merchant_id, name, description, price, currency, in_stock, image_url, thumbnail_url)
+SELECT v.id, mi.id, mi.merchant_id, mi.name, mi.description, mi.price, mi.currency, mi.in_stock, mi.image_url, mi.thumbnail_url
+FROM menu_items mi
+JOIN menu_versions v ON v.merchant_id = mi.merchant_id AND v.is_live;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Orders remember the menu version they were priced against.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS menu_version_id UUID;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE orders DROP COLUMN IF EXISTS menu_version_id;
+DROP INDEX IF EXISTS idx_menu_versions_live;
+DROP TABLE IF EXISTS menu_version_items;
+DROP TABLE IF EXISTS menu_versions;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE menu_items
+    ADD COLUMN IF NOT EXISTS dietary_tags TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS allergens TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS nutrition JSONB;
+
+ALTER TABLE menu_version_items
+    ADD COLUMN IF NOT EXISTS dietary_tags TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS allergens TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS nutrition JSONB;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Customers filter live menus with @> (tags) and && (allergens).
+CREATE INDEX IF NOT EXISTS idx_menu_version_items_dietary_tags ON menu_version_items USING GIN (dietary_tags);
+CREATE INDEX IF NOT EXISTS idx_menu_version_items_allergens ON menu_version_items USING GIN (allergens);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_menu_version_items_allergens;
+DROP INDEX IF EXISTS idx_menu_version_items_dietary_tags;
+ALTER TABLE menu_version_items DROP COLUMN IF EXISTS nutrition, DROP COLUMN IF EXISTS allergens, DROP COLUMN IF EXISTS dietary_tags;
+ALTER TABLE menu_items DROP COLUMN IF EXISTS nutrition, DROP COLUMN IF EXISTS allergens, DROP COLUMN IF EXISTS dietary_tags;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Merchants registered before ownership existed keep a NULL owner and can
+-- only be managed once an owner has been assigned.
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS owner_id UUID REFERENCES users(id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_merchants_owner_id ON merchants(owner_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_merchants_owner_id;
+ALTER TABLE merchants DROP COLUMN IF EXISTS owner_id;
+-- +goose StatementEnd
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Merchants that already exist were live before onboarding, so they start out approved.
+ALTER TABLE merchants
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'approved',
+    ADD COLUMN IF NOT EXISTS status_reason TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS contact_email VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS contact_phone VARCHAR(50) NOT NULL DEFAULT '';
+
+ALTER TABLE merchants ALTER COLUMN status SET DEFAULT 'submitted';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_merchants_status ON merchants(status);
+
+CREATE TABLE IF NOT EXISTS merchant_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    merchant_id UUID NOT NULL REFERENCES merchants(id) ON DELETE CASCADE,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    changed_by UUID,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_merchant_status_history_merchant_id ON merchant_status_history(merchant_id, changed_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS merchant_status_history;
+DROP INDEX IF EXISTS idx_merchants_status;
+ALTER TABLE merchants
+    DROP COLUMN IF EXISTS contact_phone,
+    DROP COLUMN IF EXISTS contact_email,
+    DROP COLUMN IF EXISTS status_reason,
+    DROP COLUMN IF EXISTS status;
+-- +goose StatementEnd
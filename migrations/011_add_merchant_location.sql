@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Coordinates stay NULL until the merchant sets them; such merchants never
+-- show up in nearby searches.
+ALTER TABLE merchants
+    ADD COLUMN IF NOT EXISTS address TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION CHECK (latitude BETWEEN -90 AND 90),
+    ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION CHECK (longitude BETWEEN -180 AND 180),
+    ADD COLUMN IF NOT EXISTS delivery_radius_km DOUBLE PRECISION NOT NULL DEFAULT 5 CHECK (delivery_radius_km > 0);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Nearby searches narrow to a bounding box on these columns before computing distances.
+CREATE INDEX IF NOT EXISTS idx_merchants_location ON merchants(latitude, longitude) WHERE latitude IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_merchants_location;
+ALTER TABLE merchants
+    DROP COLUMN IF EXISTS delivery_radius_km,
+    DROP COLUMN IF EXISTS longitude,
+    DROP COLUMN IF EXISTS latitude,
+    DROP COLUMN IF EXISTS address;
+-- +goose StatementEnd
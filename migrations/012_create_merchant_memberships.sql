@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The user in merchants.owner_id is always an owner; memberships hold
+-- everyone else who works for the merchant, including co-owners.
+CREATE TABLE IF NOT EXISTS merchant_memberships (
+    merchant_id UUID NOT NULL REFERENCES merchants(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (merchant_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_merchant_memberships_user_id ON merchant_memberships(user_id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS merchant_invites (
+    id UUID PRIMARY KEY,
+    merchant_id UUID NOT NULL REFERENCES merchants(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_merchant_invites_merchant_id ON merchant_invites(merchant_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS merchant_invites;
+DROP TABLE IF EXISTS merchant_memberships;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Sales reports bucket orders by the merchant's local day, week or month.
+ALTER TABLE merchants
+    ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Daily rollups are refreshed by the analytics job; day is the merchant's local date.
+CREATE TABLE IF NOT EXISTS merchant_sales_daily (
+    merchant_id UUID NOT NULL REFERENCES merchants(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    order_count BIGINT NOT NULL DEFAULT 0,
+    revenue BIGINT NOT NULL DEFAULT 0,
+    refreshed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (merchant_id, day)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS merchant_item_sales_daily (
+    merchant_id UUID NOT NULL REFERENCES merchants(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    menu_item_id UUID NOT NULL,
+    quantity BIGINT NOT NULL DEFAULT 0,
+    revenue BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (merchant_id, day, menu_item_id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_orders_merchant_created_at ON orders(merchant_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_orders_merchant_created_at;
+DROP TABLE IF EXISTS merchant_item_sales_daily;
+DROP TABLE IF EXISTS merchant_sales_daily;
+ALTER TABLE merchants DROP COLUMN IF EXISTS timezone;
+-- +goose StatementEnd
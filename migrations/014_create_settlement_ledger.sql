@@ -0,0 +1,83 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The platform's cut of each completed order, in basis points.
+ALTER TABLE merchants
+    ADD COLUMN IF NOT EXISTS commission_bps INT NOT NULL DEFAULT 1500 CHECK (commission_bps BETWEEN 0 AND 10000);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Every transaction belongs to one merchant's settlement ledger and is in its currency.
+CREATE TABLE IF NOT EXISTS ledger_transactions (
+    id UUID PRIMARY KEY,
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    kind VARCHAR(32) NOT NULL,
+    reference VARCHAR(255) NOT NULL DEFAULT '',
+    memo TEXT NOT NULL DEFAULT '',
+    currency CHAR(3) NOT NULL,
+    created_by UUID,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Postings are debits (positive) and credits (negative) in minor units.
+CREATE TABLE IF NOT EXISTS ledger_postings (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id UUID NOT NULL REFERENCES ledger_transactions(id),
+    account VARCHAR(64) NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount <> 0)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_ledger_transactions_merchant_created_at ON ledger_transactions(merchant_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_ledger_postings_transaction_id ON ledger_postings(transaction_id);
+-- An order is only ever settled once.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_transactions_settled_orders ON ledger_transactions(kind, reference)
+    WHERE kind IN ('order_revenue', 'commission');
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Entries are never changed; mistakes are corrected with new entries.
+CREATE OR REPLACE FUNCTION ledger_reject_change() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'ledger entries are immutable';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER ledger_transactions_immutable BEFORE UPDATE OR DELETE ON ledger_transactions
+    FOR EACH ROW EXECUTE FUNCTION ledger_reject_change();
+CREATE TRIGGER ledger_transactions_no_truncate BEFORE TRUNCATE ON ledger_transactions
+    FOR EACH STATEMENT EXECUTE FUNCTION ledger_reject_change();
+CREATE TRIGGER ledger_postings_immutable BEFORE UPDATE OR DELETE ON ledger_postings
+    FOR EACH ROW EXECUTE FUNCTION ledger_reject_change();
+CREATE TRIGGER ledger_postings_no_truncate BEFORE TRUNCATE ON ledger_postings
+    FOR EACH STATEMENT EXECUTE FUNCTION ledger_reject_change();
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Checked at commit, once all of a transaction's postings are in.
+CREATE OR REPLACE FUNCTION ledger_check_balanced() RETURNS TRIGGER AS $$
+BEGIN
+    IF (SELECT SUM(amount) FROM ledger_postings WHERE transaction_id = NEW.transaction_id) <> 0 THEN
+        RAISE EXCEPTION 'ledger transaction % does not balance', NEW.transaction_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE CONSTRAINT TRIGGER ledger_postings_balanced AFTER INSERT ON ledger_postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION ledger_check_balanced();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS ledger_postings;
+DROP TABLE IF EXISTS ledger_transactions;
+DROP FUNCTION IF EXISTS ledger_check_balanced();
+DROP FUNCTION IF EXISTS ledger_reject_change();
+ALTER TABLE merchants DROP COLUMN IF EXISTS commission_bps;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Zero means the merchant takes any number of open orders. Pauses live in Redis.
+ALTER TABLE merchants
+    ADD COLUMN IF NOT EXISTS max_open_orders INTEGER NOT NULL DEFAULT 0 CHECK (max_open_orders >= 0);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- PlaceOrder counts a merchant's open (NEW and PENDING) orders on every order.
+CREATE INDEX IF NOT EXISTS idx_orders_merchant_open ON orders (merchant_id, created_at)
+    WHERE status IN (0, 1);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_orders_merchant_open;
+ALTER TABLE merchants DROP COLUMN IF EXISTS max_open_orders;
+-- +goose StatementEnd
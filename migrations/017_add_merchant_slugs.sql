@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS slug VARCHAR(60);
+
+-- Existing merchants get a slug from their name. Accents are not stripped
+-- here as they are for new merchants; owners can change the slug. Names that
+-- slugify alike are told apart by the start of their ID.
+UPDATE merchants SET slug = named.slug
+FROM (
+    SELECT id,
+        CASE WHEN ROW_NUMBER() OVER (PARTITION BY base ORDER BY created_at, id) = 1 THEN base
+            ELSE base || '-' || LEFT(id::TEXT, 8) END AS slug
+    FROM (
+        SELECT id, created_at,
+            CASE WHEN cleaned = '' THEN 'merchant'
+                WHEN LENGTH(cleaned) < 3 OR cleaned IN ('register', 'nearby') THEN cleaned || '-1'
+                ELSE cleaned END AS base
+        FROM (
+            SELECT id, created_at,
+                TRIM(BOTH '-' FROM LEFT(TRIM(BOTH '-' FROM REGEXP_REPLACE(LOWER(name), '[^a-z0-9]+', '-', 'g')), 50)) AS cleaned
+            FROM merchants
+        ) AS cleaned_names
+    ) AS bases
+) AS named
+WHERE merchants.id = named.id AND merchants.slug IS NULL;
+
+ALTER TABLE merchants ALTER COLUMN slug SET NOT NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS idx_merchants_slug ON merchants(slug);
+
+-- Slugs a merchant has moved away from keep redirecting to it, so no other
+-- merchant may take them.
+CREATE TABLE IF NOT EXISTS merchant_former_slugs (
+    slug VARCHAR(60) PRIMARY KEY,
+    merchant_id UUID NOT NULL REFERENCES merchants(id) ON DELETE CASCADE,
+    retired_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_merchant_former_slugs_merchant_id ON merchant_former_slugs(merchant_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS merchant_former_slugs;
+DROP INDEX IF EXISTS idx_merchants_slug;
+ALTER TABLE merchants DROP COLUMN IF EXISTS slug;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A user's role decides what they may do on the platform as a whole; what
+-- they may do at a merchant is still decided by merchant_memberships.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'customer'
+    CHECK (role IN ('customer', 'merchant', 'admin'));
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+UPDATE users SET role = 'merchant'
+WHERE role = 'customer'
+  AND (id IN (SELECT owner_id FROM merchants WHERE owner_id IS NOT NULL)
+       OR id IN (SELECT user_id FROM merchant_memberships));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Deleting an account anonymises the user instead of removing the row, so
+-- that the orders and merchants referring to it stay intact.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd